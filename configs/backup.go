@@ -0,0 +1,14 @@
+package configs
+
+import "os"
+
+// defaultBackupDir 默认备份目录
+const defaultBackupDir = "backups"
+
+// BackupDir 返回数据库备份目录，可通过环境变量 EXAM_BACKUP_DIR 配置
+func BackupDir() string {
+	if dir := os.Getenv("EXAM_BACKUP_DIR"); dir != "" {
+		return dir
+	}
+	return defaultBackupDir
+}
@@ -0,0 +1,170 @@
+package configs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 支持的数据库驱动
+const (
+	DriverSQLite   = "sqlite3"
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+)
+
+// defaultConfigFile 默认配置文件路径，文件不存在时使用默认配置
+const defaultConfigFile = "config.json"
+
+// Config 应用配置
+type Config struct {
+	Database DatabaseConfig `json:"database"`
+}
+
+// DatabaseConfig 数据库配置
+type DatabaseConfig struct {
+	Driver       string `json:"driver"` // sqlite3、mysql 或 postgres
+	DSN          string `json:"dsn"`
+	MaxIdleConns int    `json:"max_idle_conns"`
+	MaxOpenConns int    `json:"max_open_conns"`
+	LogMode      bool   `json:"log_mode"` // 是否输出SQL日志
+}
+
+// driverAliases 驱动名称的常见别名
+var driverAliases = map[string]string{
+	"sqlite":     DriverSQLite,
+	"sqlite3":    DriverSQLite,
+	"mysql":      DriverMySQL,
+	"postgres":   DriverPostgres,
+	"postgresql": DriverPostgres,
+	"pg":         DriverPostgres,
+}
+
+// DefaultConfig 返回默认配置，与原先硬编码的SQLite配置一致
+func DefaultConfig() Config {
+	return Config{
+		Database: DatabaseConfig{
+			Driver:       DriverSQLite,
+			DSN:          "exam.db",
+			MaxIdleConns: 10,
+			MaxOpenConns: 100,
+			LogMode:      true,
+		},
+	}
+}
+
+// LoadConfig 加载配置：先取默认值，再读取配置文件，最后用环境变量覆盖
+// 配置文件路径可通过环境变量 EXAM_CONFIG 指定，指定的文件不存在时返回错误
+func LoadConfig() (Config, error) {
+	cfg := DefaultConfig()
+
+	path, explicit := os.LookupEnv("EXAM_CONFIG")
+	if !explicit {
+		path = defaultConfigFile
+	}
+	// 未指定配置文件且默认文件不存在时使用默认配置
+	err := loadConfigFile(path, &cfg)
+	if err != nil && (explicit || !errors.Is(err, os.ErrNotExist)) {
+		return cfg, fmt.Errorf("读取配置文件 %s 失败: %v", path, err)
+	}
+
+	if err := applyDatabaseEnv(&cfg.Database); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Database.Validate(); err != nil {
+		return cfg, fmt.Errorf("数据库配置错误: %v", err)
+	}
+	return cfg, nil
+}
+
+// loadConfigFile 读取JSON配置文件，文件中未出现的字段保留原值
+func loadConfigFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(cfg)
+}
+
+// applyDatabaseEnv 使用 EXAM_DB_* 环境变量覆盖数据库配置
+func applyDatabaseEnv(db *DatabaseConfig) error {
+	if v := os.Getenv("EXAM_DB_DRIVER"); v != "" {
+		db.Driver = v
+	}
+	if v := os.Getenv("EXAM_DB_DSN"); v != "" {
+		db.DSN = v
+	}
+
+	ints := []struct {
+		name  string
+		value *int
+	}{
+		{"EXAM_DB_MAX_IDLE_CONNS", &db.MaxIdleConns},
+		{"EXAM_DB_MAX_OPEN_CONNS", &db.MaxOpenConns},
+	}
+	for _, item := range ints {
+		v := os.Getenv(item.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 %s 必须是整数: %q", item.name, v)
+		}
+		*item.value = n
+	}
+
+	if v := os.Getenv("EXAM_DB_LOG"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 EXAM_DB_LOG 必须是布尔值: %q", v)
+		}
+		db.LogMode = b
+	}
+	return nil
+}
+
+// Validate 校验数据库配置并规范化驱动名称
+func (c *DatabaseConfig) Validate() error {
+	driver, ok := driverAliases[strings.ToLower(strings.TrimSpace(c.Driver))]
+	if !ok {
+		return fmt.Errorf("不支持的数据库驱动 %q，可选值: sqlite3、mysql、postgres", c.Driver)
+	}
+	c.Driver = driver
+
+	if strings.TrimSpace(c.DSN) == "" {
+		return fmt.Errorf("%s 的DSN不能为空", c.Driver)
+	}
+	if c.Driver == DriverMySQL && !strings.Contains(strings.ToLower(c.DSN), "parsetime=true") {
+		return fmt.Errorf("MySQL的DSN必须包含 parseTime=True，否则无法读取时间字段")
+	}
+
+	if c.MaxIdleConns < 0 || c.MaxOpenConns < 0 {
+		return fmt.Errorf("连接池大小不能为负数")
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		return fmt.Errorf("最大空闲连接数(%d)不能大于最大连接数(%d)", c.MaxIdleConns, c.MaxOpenConns)
+	}
+	return nil
+}
+
+// IsSQLite 判断是否使用SQLite数据库
+func (c DatabaseConfig) IsSQLite() bool {
+	return c.Driver == DriverSQLite
+}
+
+// SQLitePath 返回SQLite数据库文件路径，去掉 file: 前缀和查询参数
+func (c DatabaseConfig) SQLitePath() string {
+	path := strings.TrimPrefix(c.DSN, "file:")
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
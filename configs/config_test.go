@@ -0,0 +1,198 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDatabaseConfigValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     DatabaseConfig
+		wantDriver string
+		wantErr    string
+	}{
+		{
+			name:       "默认配置",
+			config:     DefaultConfig().Database,
+			wantDriver: DriverSQLite,
+		},
+		{
+			name:       "驱动别名",
+			config:     DatabaseConfig{Driver: " PostgreSQL ", DSN: "host=db user=exam"},
+			wantDriver: DriverPostgres,
+		},
+		{
+			name:       "sqlite别名",
+			config:     DatabaseConfig{Driver: "sqlite", DSN: "file:exam.db?cache=shared"},
+			wantDriver: DriverSQLite,
+		},
+		{
+			name:       "MySQL包含parseTime",
+			config:     DatabaseConfig{Driver: "mysql", DSN: "exam:pw@tcp(db:3306)/exam?parseTime=True", MaxIdleConns: 5, MaxOpenConns: 5},
+			wantDriver: DriverMySQL,
+		},
+		{
+			name:    "不支持的驱动",
+			config:  DatabaseConfig{Driver: "oracle", DSN: "x"},
+			wantErr: "不支持的数据库驱动",
+		},
+		{
+			name:    "DSN为空",
+			config:  DatabaseConfig{Driver: "pg", DSN: "  "},
+			wantErr: "DSN不能为空",
+		},
+		{
+			name:    "MySQL缺少parseTime",
+			config:  DatabaseConfig{Driver: "mysql", DSN: "exam:pw@tcp(db:3306)/exam"},
+			wantErr: "parseTime=True",
+		},
+		{
+			name:    "连接池为负数",
+			config:  DatabaseConfig{Driver: "sqlite3", DSN: "exam.db", MaxOpenConns: -1},
+			wantErr: "不能为负数",
+		},
+		{
+			name:    "空闲连接多于最大连接",
+			config:  DatabaseConfig{Driver: "sqlite3", DSN: "exam.db", MaxIdleConns: 10, MaxOpenConns: 2},
+			wantErr: "不能大于最大连接数",
+		},
+		{
+			name:       "最大连接数为0时不限制空闲连接",
+			config:     DatabaseConfig{Driver: "sqlite3", DSN: "exam.db", MaxIdleConns: 10},
+			wantDriver: DriverSQLite,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.config
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() error = %v, 期望包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if cfg.Driver != tt.wantDriver {
+				t.Errorf("Driver = %q, 期望 %q", cfg.Driver, tt.wantDriver)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	valid := write("valid.json", `{"database": {"driver": "postgresql", "dsn": "host=db", "max_open_conns": 20}}`)
+	unknown := write("unknown.json", `{"database": {"driver": "sqlite3", "dns": "exam.db"}}`)
+	mysql := write("mysql.json", `{"database": {"driver": "mysql", "dsn": "exam@tcp(db)/exam"}}`)
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    DatabaseConfig
+		wantErr string
+	}{
+		{
+			name: "没有配置文件时使用默认配置",
+			env:  map[string]string{},
+			want: DefaultConfig().Database,
+		},
+		{
+			name: "配置文件覆盖默认值",
+			env:  map[string]string{"EXAM_CONFIG": valid},
+			want: DatabaseConfig{Driver: DriverPostgres, DSN: "host=db", MaxIdleConns: 10, MaxOpenConns: 20, LogMode: true},
+		},
+		{
+			name: "环境变量覆盖配置文件",
+			env: map[string]string{
+				"EXAM_CONFIG":            valid,
+				"EXAM_DB_DRIVER":         "sqlite",
+				"EXAM_DB_DSN":            "test.db",
+				"EXAM_DB_MAX_IDLE_CONNS": "1",
+				"EXAM_DB_MAX_OPEN_CONNS": "1",
+				"EXAM_DB_LOG":            "false",
+			},
+			want: DatabaseConfig{Driver: DriverSQLite, DSN: "test.db", MaxIdleConns: 1, MaxOpenConns: 1},
+		},
+		{
+			name:    "指定的配置文件不存在",
+			env:     map[string]string{"EXAM_CONFIG": filepath.Join(dir, "missing.json")},
+			wantErr: "读取配置文件",
+		},
+		{
+			name:    "配置文件包含未知字段",
+			env:     map[string]string{"EXAM_CONFIG": unknown},
+			wantErr: "unknown field",
+		},
+		{
+			name:    "连接数不是整数",
+			env:     map[string]string{"EXAM_DB_MAX_OPEN_CONNS": "many"},
+			wantErr: "EXAM_DB_MAX_OPEN_CONNS 必须是整数",
+		},
+		{
+			name:    "日志开关不是布尔值",
+			env:     map[string]string{"EXAM_DB_LOG": "verbose"},
+			wantErr: "EXAM_DB_LOG 必须是布尔值",
+		},
+		{
+			name:    "配置校验失败",
+			env:     map[string]string{"EXAM_CONFIG": mysql},
+			wantErr: "数据库配置错误",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"EXAM_CONFIG", "EXAM_DB_DRIVER", "EXAM_DB_DSN", "EXAM_DB_MAX_IDLE_CONNS", "EXAM_DB_MAX_OPEN_CONNS", "EXAM_DB_LOG"} {
+				t.Setenv(name, "")
+				os.Unsetenv(name)
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, err := LoadConfig()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadConfig() error = %v, 期望包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if cfg.Database != tt.want {
+				t.Errorf("Database = %+v, 期望 %+v", cfg.Database, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLitePath(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"exam.db", "exam.db"},
+		{"file:exam.db", "exam.db"},
+		{"file:/data/exam.db?cache=shared&_busy_timeout=5000", "/data/exam.db"},
+	}
+	for _, tt := range tests {
+		if got := (DatabaseConfig{Driver: DriverSQLite, DSN: tt.dsn}).SQLitePath(); got != tt.want {
+			t.Errorf("SQLitePath(%q) = %q, 期望 %q", tt.dsn, got, tt.want)
+		}
+	}
+}
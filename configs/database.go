@@ -1,27 +1,77 @@
 package configs
 
 import (
+	"fmt"
 	"log"
+	"sync"
 
 	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/mysql"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
 )
 
-var DB *gorm.DB
+// 当前的数据库连接，恢复备份时会被替换，通过 DB 和 SetDB 读写
+var (
+	dbMu      sync.RWMutex
+	currentDB *gorm.DB
+)
+
+// Database 当前使用的数据库配置，由 InitDB 加载
+var Database DatabaseConfig
+
+// sqlDrivers 各驱动在database/sql中注册的驱动名称，测试时替换为替身驱动
+var sqlDrivers = map[string]string{
+	DriverSQLite:   "sqlite3",
+	DriverMySQL:    "mysql",
+	DriverPostgres: "postgres",
+}
 
-// InitDB 初始化数据库连接
+// InitDB 加载配置并初始化数据库连接
 func InitDB() {
-	var err error
-	// 使用SQLite数据库
-	DB, err = gorm.Open("sqlite3", "exam.db")
+	cfg, err := LoadConfig()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	Database = cfg.Database
+
+	conn, err := OpenDB()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	SetDB(conn)
+	log.Printf("已连接数据库 (driver=%s)", Database.Driver)
+}
+
+// DB 返回当前的数据库连接。恢复备份会替换连接，因此每次使用时调用而不要保存返回值
+func DB() *gorm.DB {
+	dbMu.RLock()
+	defer dbMu.RUnlock()
+	return currentDB
+}
+
+// SetDB 替换当前的数据库连接并返回原来的连接，由调用方关闭原连接
+func SetDB(conn *gorm.DB) *gorm.DB {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+	old := currentDB
+	currentDB = conn
+	return old
+}
+
+// OpenDB 按当前配置打开数据库连接并设置连接池和日志
+func OpenDB() (*gorm.DB, error) {
+	db, err := gorm.Open(Database.Driver, sqlDrivers[Database.Driver], Database.DSN)
 	if err != nil {
-		log.Fatalf("数据库连接失败: %v", err)
+		// DSN中可能包含密码，错误信息中只输出驱动名称
+		return nil, fmt.Errorf("数据库连接失败 (driver=%s): %v", Database.Driver, err)
 	}
 
 	// 设置连接池
-	DB.DB().SetMaxIdleConns(10)
-	DB.DB().SetMaxOpenConns(100)
+	db.DB().SetMaxIdleConns(Database.MaxIdleConns)
+	db.DB().SetMaxOpenConns(Database.MaxOpenConns)
 
-	// 启用日志
-	DB.LogMode(true)
+	// 是否输出SQL日志
+	db.LogMode(Database.LogMode)
+	return db, nil
 }
@@ -0,0 +1,120 @@
+package configs
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubDriver 替身驱动，代替测试环境中没有的MySQL和PostgreSQL服务器，只用于检查OpenDB的驱动选择和错误处理，不执行SQL。
+// DSN包含 unreachable 时模拟连接失败
+type stubDriver struct{}
+
+func (stubDriver) Open(dsn string) (driver.Conn, error) {
+	if strings.Contains(dsn, "unreachable") {
+		return nil, errors.New("dial tcp: connection refused")
+	}
+	return stubConn{}, nil
+}
+
+type stubConn struct{}
+
+func (stubConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("stub: 不支持执行SQL")
+}
+func (stubConn) Close() error              { return nil }
+func (stubConn) Begin() (driver.Tx, error) { return nil, errors.New("stub: 不支持事务") }
+
+func init() {
+	sql.Register("stub", stubDriver{})
+}
+
+// useStubDrivers 在测试期间让MySQL和PostgreSQL使用替身驱动，并恢复全局配置
+func useStubDrivers(t *testing.T) {
+	t.Helper()
+	saved := Database
+	mysql, postgres := sqlDrivers[DriverMySQL], sqlDrivers[DriverPostgres]
+	sqlDrivers[DriverMySQL], sqlDrivers[DriverPostgres] = "stub", "stub"
+	t.Cleanup(func() {
+		Database = saved
+		sqlDrivers[DriverMySQL], sqlDrivers[DriverPostgres] = mysql, postgres
+	})
+}
+
+func TestOpenDB(t *testing.T) {
+	useStubDrivers(t)
+	dir := t.TempDir()
+
+	tests := []struct {
+		name        string
+		config      DatabaseConfig
+		wantDialect string
+		wantErr     string
+	}{
+		{
+			name:        "SQLite文件",
+			config:      DatabaseConfig{Driver: "sqlite", DSN: filepath.Join(dir, "exam.db"), MaxIdleConns: 2, MaxOpenConns: 4},
+			wantDialect: "sqlite3",
+		},
+		{
+			name:        "SQLite内存数据库",
+			config:      DatabaseConfig{Driver: "sqlite3", DSN: "file::memory:?cache=shared", MaxIdleConns: 1, MaxOpenConns: 1},
+			wantDialect: "sqlite3",
+		},
+		{
+			name:        "PostgreSQL",
+			config:      DatabaseConfig{Driver: "postgresql", DSN: "host=db user=exam password=secret", MaxIdleConns: 5, MaxOpenConns: 20},
+			wantDialect: "postgres",
+		},
+		{
+			name:        "MySQL",
+			config:      DatabaseConfig{Driver: "mysql", DSN: "exam:secret@tcp(db:3306)/exam?parseTime=True", MaxOpenConns: 10},
+			wantDialect: "mysql",
+		},
+		{
+			name:    "PostgreSQL连接失败",
+			config:  DatabaseConfig{Driver: "pg", DSN: "host=unreachable password=secret"},
+			wantErr: "数据库连接失败 (driver=postgres)",
+		},
+		{
+			name:    "SQLite目录不存在",
+			config:  DatabaseConfig{Driver: "sqlite3", DSN: filepath.Join(dir, "missing", "exam.db")},
+			wantErr: "数据库连接失败 (driver=sqlite3)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.config
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			Database = cfg
+
+			db, err := OpenDB()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("OpenDB() error = %v, 期望包含 %q", err, tt.wantErr)
+				}
+				if strings.Contains(err.Error(), "secret") {
+					t.Errorf("错误信息包含DSN中的密码: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OpenDB() error = %v", err)
+			}
+			defer db.Close()
+
+			if got := db.Dialect().GetName(); got != tt.wantDialect {
+				t.Errorf("Dialect = %q, 期望 %q", got, tt.wantDialect)
+			}
+			if got := db.DB().Stats().MaxOpenConnections; got != cfg.MaxOpenConns {
+				t.Errorf("MaxOpenConnections = %d, 期望 %d", got, cfg.MaxOpenConns)
+			}
+		})
+	}
+}
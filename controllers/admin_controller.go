@@ -1,10 +1,11 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
-	"time"
 
+	"github.com/exam-approval-system/middlewares"
 	"github.com/exam-approval-system/models"
 	"github.com/exam-approval-system/services"
 	"github.com/gin-gonic/gin"
@@ -12,21 +13,25 @@ import (
 
 // AdminController 管理员控制器
 type AdminController struct {
-	userService services.UserService
-	authService services.AuthService
+	userService     services.UserService
+	authService     services.AuthService
+	settingsService services.SettingsService
+	backupService   services.BackupService
 }
 
 // NewAdminController 创建管理员控制器
-func NewAdminController(userService services.UserService, authService services.AuthService) *AdminController {
+func NewAdminController(userService services.UserService, authService services.AuthService, settingsService services.SettingsService, backupService services.BackupService) *AdminController {
 	return &AdminController{
-		userService: userService,
-		authService: authService,
+		userService:     userService,
+		authService:     authService,
+		settingsService: settingsService,
+		backupService:   backupService,
 	}
 }
 
 // RegisterRoutes 注册管理员路由
 func (c *AdminController) RegisterRoutes(router *gin.Engine) {
-	admin := router.Group("/admin")
+	admin := router.Group("/admin", middlewares.AuthMiddleware(c.authService), middlewares.RoleMiddleware(models.RoleAdmin))
 	{
 		// 用户管理路由
 		admin.GET("/users", c.ListUsers)
@@ -35,6 +40,11 @@ func (c *AdminController) RegisterRoutes(router *gin.Engine) {
 		admin.PUT("/user/:id", c.UpdateUser)
 		admin.DELETE("/user/:id", c.DeleteUser)
 
+		// 用户会话管理路由
+		admin.GET("/user/:id/sessions", c.ListUserSessions)
+		admin.DELETE("/user/:id/sessions/:sid", c.RevokeUserSession)
+		admin.DELETE("/user/:id/sessions", c.RevokeAllUserSessions)
+
 		// 系统设置路由
 		admin.GET("/settings", c.GetSettings)
 		admin.POST("/settings", c.UpdateSettings)
@@ -43,29 +53,24 @@ func (c *AdminController) RegisterRoutes(router *gin.Engine) {
 		admin.POST("/backup", c.CreateBackup)
 		admin.GET("/backups", c.ListBackups)
 		admin.GET("/backup/:id", c.DownloadBackup)
+		admin.POST("/backup/:id/restore", c.RestoreBackup)
 	}
 }
 
 // ListUsers 获取用户列表
 func (c *AdminController) ListUsers(ctx *gin.Context) {
-	// 身份验证检查
-	username := ctx.GetHeader("X-Username")
-	if username == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
-		return
-	}
-
-	// 此处直接检查用户角色，实际中应通过中间件进行身份验证
-	user, err := c.userService.GetUserByUsername(username)
-	if err != nil || user.Role != "admin" {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
-		return
-	}
-
 	// 获取筛选参数
 	role := ctx.Query("role")
 	status := ctx.Query("status")
 
+	// 分页参数，每页条数取自系统设置
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的页码"})
+		return
+	}
+	pageSize := c.settingsService.Get().PageSize
+
 	// 获取用户列表
 	users, err := c.userService.ListUsersWithFilter(role, status)
 	if err != nil {
@@ -73,28 +78,27 @@ func (c *AdminController) ListUsers(ctx *gin.Context) {
 		return
 	}
 
+	total := len(users)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"users":   users,
+		"success":   true,
+		"users":     users[start:end],
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
 	})
 }
 
 // GetUser 获取用户详情
 func (c *AdminController) GetUser(ctx *gin.Context) {
-	// 身份验证检查
-	username := ctx.GetHeader("X-Username")
-	if username == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
-		return
-	}
-
-	// 检查用户权限
-	currentUser, err := c.userService.GetUserByUsername(username)
-	if err != nil || currentUser.Role != "admin" {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
-		return
-	}
-
 	// 获取用户ID
 	userID := ctx.Param("id")
 	if userID == "" {
@@ -124,20 +128,6 @@ func (c *AdminController) GetUser(ctx *gin.Context) {
 
 // CreateUser 创建用户
 func (c *AdminController) CreateUser(ctx *gin.Context) {
-	// 身份验证检查
-	username := ctx.GetHeader("X-Username")
-	if username == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
-		return
-	}
-
-	// 检查用户权限
-	currentUser, err := c.userService.GetUserByUsername(username)
-	if err != nil || currentUser.Role != "admin" {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
-		return
-	}
-
 	// 解析请求体
 	var newUser models.User
 	if err := ctx.ShouldBindJSON(&newUser); err != nil {
@@ -160,20 +150,6 @@ func (c *AdminController) CreateUser(ctx *gin.Context) {
 
 // UpdateUser 更新用户
 func (c *AdminController) UpdateUser(ctx *gin.Context) {
-	// 身份验证检查
-	username := ctx.GetHeader("X-Username")
-	if username == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
-		return
-	}
-
-	// 检查用户权限
-	currentUser, err := c.userService.GetUserByUsername(username)
-	if err != nil || currentUser.Role != "admin" {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
-		return
-	}
-
 	// 获取用户ID
 	userID := ctx.Param("id")
 	if userID == "" {
@@ -218,20 +194,6 @@ func (c *AdminController) UpdateUser(ctx *gin.Context) {
 
 // DeleteUser 删除用户
 func (c *AdminController) DeleteUser(ctx *gin.Context) {
-	// 身份验证检查
-	username := ctx.GetHeader("X-Username")
-	if username == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
-		return
-	}
-
-	// 检查用户权限
-	currentUser, err := c.userService.GetUserByUsername(username)
-	if err != nil || currentUser.Role != "admin" {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
-		return
-	}
-
 	// 获取用户ID
 	userID := ctx.Param("id")
 	if userID == "" {
@@ -241,15 +203,18 @@ func (c *AdminController) DeleteUser(ctx *gin.Context) {
 
 	// 防止删除自己
 	id, _ := strconv.ParseUint(userID, 10, 32)
-	if uint(id) == currentUser.ID {
+	if uint(id) == currentUser(ctx).ID {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "不能删除当前登录的管理员账户"})
 		return
 	}
 
 	// 删除用户
-	err = c.userService.DeleteUser(userID)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "删除用户失败: " + err.Error()})
+	if err := c.userService.DeleteUser(userID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, models.ErrPaperLocked) {
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, gin.H{"error": "删除用户失败: " + err.Error()})
 		return
 	}
 
@@ -259,168 +224,186 @@ func (c *AdminController) DeleteUser(ctx *gin.Context) {
 	})
 }
 
-// GetSettings 获取系统设置
-func (c *AdminController) GetSettings(ctx *gin.Context) {
-	// 身份验证检查
-	username := ctx.GetHeader("X-Username")
-	if username == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+// ListUserSessions 获取指定用户的登录会话
+func (c *AdminController) ListUserSessions(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
 		return
 	}
 
-	// 检查用户权限
-	currentUser, err := c.userService.GetUserByUsername(username)
-	if err != nil || currentUser.Role != "admin" {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
+	if _, err := c.userService.GetUserByID(uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
 		return
 	}
 
-	// 这里应该从配置服务或数据库获取系统设置
-	// 目前使用模拟数据
-	settings := gin.H{
-		"system_name":         "试卷审批管理系统",
-		"admin_email":         "admin@example.com",
-		"page_size":           10,
-		"min_password_length": 8,
-		"session_timeout":     30,
-		"max_login_attempts":  5,
-		"two_factor_auth":     false,
-		"auto_backup":         true,
-		"backup_frequency":    "weekly",
-		"backup_count":        10,
+	sessions, err := c.authService.ListSessions(uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取会话列表失败"})
+		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"success":  true,
-		"settings": settings,
+		"sessions": sessions,
 	})
 }
 
-// UpdateSettings 更新系统设置
-func (c *AdminController) UpdateSettings(ctx *gin.Context) {
-	// 身份验证检查
-	username := ctx.GetHeader("X-Username")
-	if username == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+// RevokeUserSession 注销指定用户的单个会话
+func (c *AdminController) RevokeUserSession(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+	sessionID, err := strconv.ParseUint(ctx.Param("sid"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的会话ID"})
+		return
+	}
+
+	if err := c.authService.RevokeSession(uint(id), uint(sessionID)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 检查用户权限
-	currentUser, err := c.userService.GetUserByUsername(username)
-	if err != nil || currentUser.Role != "admin" {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "会话已注销",
+	})
+}
+
+// RevokeAllUserSessions 注销指定用户的全部会话，强制其在所有设备上重新登录
+func (c *AdminController) RevokeAllUserSessions(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
 		return
 	}
 
+	count, err := c.authService.RevokeAllSessions(uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "注销会话失败: " + err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "已注销该用户的全部会话",
+		"revoked": count,
+	})
+}
+
+// GetSettings 获取系统设置
+func (c *AdminController) GetSettings(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"settings": c.settingsService.Get(),
+	})
+}
+
+// UpdateSettings 更新系统设置，只需提交要修改的设置项
+func (c *AdminController) UpdateSettings(ctx *gin.Context) {
 	// 解析请求体
-	var settings map[string]interface{}
-	if err := ctx.ShouldBindJSON(&settings); err != nil {
+	var changes map[string]interface{}
+	if err := ctx.ShouldBindJSON(&changes); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的设置数据"})
 		return
 	}
 
-	// 这里应该将设置保存到配置服务或数据库
-	// 目前只返回成功响应
+	settings, err := c.settingsService.Update(changes, currentUser(ctx).ID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "设置已成功更新",
+		"success":  true,
+		"message":  "设置已成功更新",
+		"settings": settings,
 	})
 }
 
 // CreateBackup 创建系统备份
 func (c *AdminController) CreateBackup(ctx *gin.Context) {
-	// 身份验证检查
-	username := ctx.GetHeader("X-Username")
-	if username == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
-		return
+	// 备注为可选项
+	var req struct {
+		Note string `json:"note"`
+	}
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+			return
+		}
 	}
 
-	// 检查用户权限
-	currentUser, err := c.userService.GetUserByUsername(username)
-	if err != nil || currentUser.Role != "admin" {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
+	backup, err := c.backupService.Create(currentUser(ctx).ID, req.Note)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建备份失败: " + err.Error()})
 		return
 	}
 
-	// 这里应该触发系统备份过程
-	// 目前只返回模拟的备份ID
-	backupID := "backup_" + time.Now().Format("20060102_150405")
-
 	ctx.JSON(http.StatusOK, gin.H{
 		"success":   true,
-		"backup_id": backupID,
+		"backup_id": backup.ID,
+		"backup":    backup,
 		"message":   "备份已成功创建",
 	})
 }
 
 // ListBackups 获取备份列表
 func (c *AdminController) ListBackups(ctx *gin.Context) {
-	// 身份验证检查
-	username := ctx.GetHeader("X-Username")
-	if username == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
-		return
-	}
-
-	// 检查用户权限
-	currentUser, err := c.userService.GetUserByUsername(username)
-	if err != nil || currentUser.Role != "admin" {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
+	backups, err := c.backupService.List()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取备份列表失败: " + err.Error()})
 		return
 	}
 
-	// 模拟备份列表
-	now := time.Now()
-	backups := []gin.H{
-		{
-			"id":         "backup_20230601_120000",
-			"created_at": now.AddDate(0, 0, -7).Format(time.RFC3339),
-			"size":       "2.5MB",
-			"status":     "completed",
-		},
-		{
-			"id":         "backup_20230608_120000",
-			"created_at": now.AddDate(0, 0, -1).Format(time.RFC3339),
-			"size":       "2.7MB",
-			"status":     "completed",
-		},
-	}
-
 	ctx.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"backups": backups,
 	})
 }
 
-// DownloadBackup 下载备份
+// DownloadBackup 下载备份，以附件形式直接输出快照文件
 func (c *AdminController) DownloadBackup(ctx *gin.Context) {
-	// 身份验证检查
-	username := ctx.GetHeader("X-Username")
-	if username == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+	backup, path, err := c.backupService.Get(ctx.Param("id"))
+	if err != nil {
+		backupError(ctx, err)
 		return
 	}
 
-	// 检查用户权限
-	currentUser, err := c.userService.GetUserByUsername(username)
-	if err != nil || currentUser.Role != "admin" {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限"})
-		return
-	}
+	ctx.Header("X-Backup-Checksum", "sha256="+backup.Checksum)
+	ctx.FileAttachment(path, backup.FileName)
+}
 
-	// 获取备份ID
-	backupID := ctx.Param("id")
-	if backupID == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "备份ID不能为空"})
+// RestoreBackup 从备份恢复数据库，恢复前会自动备份当前数据库
+func (c *AdminController) RestoreBackup(ctx *gin.Context) {
+	restorePoint, err := c.backupService.Restore(ctx.Param("id"), currentUser(ctx).ID)
+	if err != nil {
+		backupError(ctx, err)
 		return
 	}
 
-	// 这里应该返回备份文件
-	// 目前只返回下载链接
 	ctx.JSON(http.StatusOK, gin.H{
-		"success":      true,
-		"download_url": "/backups/" + backupID + ".zip",
-		"message":      "请点击链接下载备份文件",
+		"success":       true,
+		"message":       "数据库已从备份恢复，所有用户需要重新登录",
+		"restore_point": restorePoint,
 	})
 }
+
+// backupError 将备份服务的错误转换为对应的HTTP响应
+func backupError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrBackupNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrBackupUnsupported):
+		ctx.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrBackupInvalid):
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMaintenance), errors.Is(err, services.ErrMaintenanceBusy):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/exam-approval-system/middlewares"
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ApprovalController 多级审批控制器
+type ApprovalController struct {
+	approvalService services.ApprovalService
+	slaService      services.ApprovalSLAService
+	examService     services.ExamService
+	authService     services.AuthService
+}
+
+// NewApprovalController 创建多级审批控制器
+func NewApprovalController(approvalService services.ApprovalService, slaService services.ApprovalSLAService, examService services.ExamService, authService services.AuthService) *ApprovalController {
+	return &ApprovalController{
+		approvalService: approvalService,
+		slaService:      slaService,
+		examService:     examService,
+		authService:     authService,
+	}
+}
+
+// RegisterRoutes 注册路由，审批链、审批时限和审批报表只有管理员可以使用，委托由审批人自己管理
+func (c *ApprovalController) RegisterRoutes(router *gin.Engine) {
+	approvals := router.Group("/api/approvals", middlewares.AuthMiddleware(c.authService), middlewares.RoleMiddleware(models.RoleTeacher, models.RoleAdmin))
+	{
+		approvals.GET("/queue", c.GetQueue)
+		approvals.GET("/exams/:id", c.GetExamTasks)
+		approvals.GET("/delegations", c.ListDelegations)
+		approvals.POST("/delegations", c.CreateDelegation)
+		approvals.DELETE("/delegations/:id", c.DeleteDelegation)
+
+		chains := approvals.Group("/chains", middlewares.RoleMiddleware(models.RoleAdmin))
+		{
+			chains.GET("", c.ListChains)
+			chains.POST("", c.CreateChain)
+			chains.GET("/:id", c.GetChain)
+			chains.PUT("/:id", c.UpdateChain)
+			chains.DELETE("/:id", c.DeleteChain)
+		}
+
+		admin := approvals.Group("", middlewares.RoleMiddleware(models.RoleAdmin))
+		{
+			admin.GET("/slas", c.ListSLAs)
+			admin.PUT("/slas", c.SaveSLA)
+			admin.DELETE("/slas/:id", c.DeleteSLA)
+			admin.GET("/overdue", c.ListOverdue)
+			admin.GET("/report", c.GetLatencyReport)
+		}
+	}
+}
+
+// approvalChainRequest 创建或修改审批链的请求
+type approvalChainRequest struct {
+	Course string `json:"course" binding:"required"`
+	Name   string `json:"name"`
+	Mode   string `json:"mode"`
+	Stages []struct {
+		Name              string `json:"name"`
+		RequiredApprovals int    `json:"required_approvals"`
+		ApproverIDs       []uint `json:"approver_ids"`
+	} `json:"stages"`
+}
+
+// toModel 转换为审批链
+func (r *approvalChainRequest) toModel() *models.ApprovalChain {
+	chain := &models.ApprovalChain{
+		Course: r.Course,
+		Name:   r.Name,
+		Mode:   r.Mode,
+	}
+	for _, s := range r.Stages {
+		stage := models.ApprovalStage{
+			Name:              s.Name,
+			RequiredApprovals: s.RequiredApprovals,
+		}
+		for _, userID := range s.ApproverIDs {
+			stage.Approvers = append(stage.Approvers, models.ApprovalStageApprover{UserID: userID})
+		}
+		chain.Stages = append(chain.Stages, stage)
+	}
+	return chain
+}
+
+// GetQueue 获取等待当前用户处理的审批
+func (c *ApprovalController) GetQueue(ctx *gin.Context) {
+	user := currentUser(ctx)
+	items, err := c.approvalService.Queue(user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取待审批列表失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, items)
+}
+
+// GetExamTasks 获取考试每一轮审批的各级任务及审批意见
+func (c *ApprovalController) GetExamTasks(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的考试ID"})
+		return
+	}
+
+	if _, err := c.examService.GetExamByID(uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "考试不存在"})
+		return
+	}
+	tasks, err := c.approvalService.Tasks(uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取审批任务失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tasks)
+}
+
+// ListChains 获取全部审批链
+func (c *ApprovalController) ListChains(ctx *gin.Context) {
+	chains, err := c.approvalService.ListChains()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取审批链失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, chains)
+}
+
+// CreateChain 为科目创建审批链
+func (c *ApprovalController) CreateChain(ctx *gin.Context) {
+	var req approvalChainRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	chain := req.toModel()
+	if err := c.approvalService.SaveChain(chain); err != nil {
+		ctx.JSON(approvalStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, chain)
+}
+
+// GetChain 获取审批链详情
+func (c *ApprovalController) GetChain(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的审批链ID"})
+		return
+	}
+
+	chain, err := c.approvalService.GetChain(uint(id))
+	if err != nil {
+		ctx.JSON(approvalStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, chain)
+}
+
+// UpdateChain 修改审批链，只影响之后提交审批的考试
+func (c *ApprovalController) UpdateChain(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的审批链ID"})
+		return
+	}
+
+	var req approvalChainRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	chain := req.toModel()
+	chain.ID = uint(id)
+	if err := c.approvalService.SaveChain(chain); err != nil {
+		ctx.JSON(approvalStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, chain)
+}
+
+// DeleteChain 删除审批链
+func (c *ApprovalController) DeleteChain(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的审批链ID"})
+		return
+	}
+
+	if err := c.approvalService.DeleteChain(uint(id)); err != nil {
+		ctx.JSON(approvalStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// delegationRequest 创建委托的请求，时间格式为 2006-01-02 15:04:05
+type delegationRequest struct {
+	DelegateID uint   `json:"delegate_id" binding:"required"`
+	StartTime  string `json:"start_time" binding:"required"`
+	EndTime    string `json:"end_time" binding:"required"`
+	Reason     string `json:"reason"`
+}
+
+// ListDelegations 获取当前用户作为委托人或代理人的委托
+func (c *ApprovalController) ListDelegations(ctx *gin.Context) {
+	delegations, err := c.approvalService.ListDelegations(currentUser(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取委托失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, delegations)
+}
+
+// CreateDelegation 当前用户委托代理人在指定时间范围内代为审批
+func (c *ApprovalController) CreateDelegation(ctx *gin.Context) {
+	var req delegationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	startTime, err := parseTime(req.StartTime)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "开始时间格式错误"})
+		return
+	}
+	endTime, err := parseTime(req.EndTime)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "结束时间格式错误"})
+		return
+	}
+
+	delegation := &models.ApprovalDelegation{
+		UserID:     currentUser(ctx).ID,
+		DelegateID: req.DelegateID,
+		StartTime:  startTime,
+		EndTime:    endTime,
+		Reason:     req.Reason,
+	}
+	if err := c.approvalService.CreateDelegation(delegation); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, delegation)
+}
+
+// DeleteDelegation 删除委托，委托人和管理员可以删除
+func (c *ApprovalController) DeleteDelegation(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的委托ID"})
+		return
+	}
+
+	if err := c.approvalService.DeleteDelegation(uint(id), currentUser(ctx)); err != nil {
+		ctx.JSON(approvalStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// ListSLAs 获取全部科目审批时限
+func (c *ApprovalController) ListSLAs(ctx *gin.Context) {
+	slas, err := c.slaService.ListSLAs()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取审批时限失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, slas)
+}
+
+// SaveSLA 设置科目的审批时限
+func (c *ApprovalController) SaveSLA(ctx *gin.Context) {
+	var req struct {
+		Course string `json:"course" binding:"required"`
+		Hours  int    `json:"hours"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	sla := &models.ApprovalSLA{Course: req.Course, Hours: req.Hours, UpdatedBy: currentUser(ctx).ID}
+	if err := c.slaService.SaveSLA(sla); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sla)
+}
+
+// DeleteSLA 删除科目审批时限
+func (c *ApprovalController) DeleteSLA(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的审批时限ID"})
+		return
+	}
+
+	if err := c.slaService.DeleteSLA(uint(id)); err != nil {
+		ctx.JSON(approvalStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// ListOverdue 获取超时审批，默认只返回尚未审批的，status=all 时返回全部
+func (c *ApprovalController) ListOverdue(ctx *gin.Context) {
+	overdue, err := c.slaService.ListOverdue(ctx.Query("status") != "all")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取超时审批失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, overdue)
+}
+
+// GetLatencyReport 获取审批耗时报表，from、to 为 2006-01-02 格式的日期，包含 from 当天、不包含 to 当天，
+// 默认为最近30天
+func (c *ApprovalController) GetLatencyReport(ctx *gin.Context) {
+	to := time.Now().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -30)
+	if value := ctx.Query("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "开始日期格式错误"})
+			return
+		}
+		from = parsed
+	}
+	if value := ctx.Query("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "结束日期格式错误"})
+			return
+		}
+		to = parsed
+	}
+
+	report, err := c.slaService.LatencyReport(from, to)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// approvalStatus 审批链、委托和审批时限错误对应的HTTP状态码
+func approvalStatus(err error) int {
+	if err == services.ErrApprovalChainNotFound || err == services.ErrDelegationNotFound || err == services.ErrApprovalSLANotFound {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
@@ -2,10 +2,10 @@ package controllers
 
 import (
 	"net/http"
+	"time"
 
-	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/middlewares"
 	"github.com/exam-approval-system/models"
-	"github.com/exam-approval-system/repositories"
 	"github.com/exam-approval-system/services"
 	"github.com/gin-gonic/gin"
 )
@@ -13,14 +13,12 @@ import (
 // AuthController 认证控制器
 type AuthController struct {
 	authService services.AuthService
-	userRepo    repositories.UserRepository
 }
 
 // NewAuthController 创建认证控制器
 func NewAuthController(authService services.AuthService) *AuthController {
 	return &AuthController{
 		authService: authService,
-		userRepo:    repositories.NewUserRepository(),
 	}
 }
 
@@ -30,7 +28,7 @@ func (c *AuthController) RegisterRoutes(router *gin.Engine) {
 	{
 		auth.POST("/login", c.Login)
 		auth.POST("/register", c.Register)
-		auth.GET("/logout", c.Logout)
+		auth.POST("/logout", c.Logout)
 		auth.GET("/check", c.CheckAuth)
 	}
 }
@@ -48,15 +46,20 @@ func (c *AuthController) Login(ctx *gin.Context) {
 		return
 	}
 
-	user, err := c.authService.Login(loginReq.Username, loginReq.Password, loginReq.Role)
+	result, err := c.authService.Login(loginReq.Username, loginReq.Password, loginReq.Role, ctx.Request.UserAgent(), ctx.ClientIP())
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 返回用户信息，不设置cookie，由前端管理会话
+	// 会话令牌同时写入HttpOnly Cookie供页面请求使用
+	user := result.User
+	setSessionCookie(ctx, result.Token, int(time.Until(result.ExpiresAt).Seconds()))
+
 	ctx.JSON(http.StatusOK, gin.H{
-		"message": "登录成功",
+		"message":    "登录成功",
+		"token":      result.Token,
+		"expires_at": result.ExpiresAt,
 		"user": gin.H{
 			"id":       user.ID,
 			"username": user.Username,
@@ -72,7 +75,7 @@ func (c *AuthController) Register(ctx *gin.Context) {
 		Username string `json:"username" binding:"required"`
 		Password string `json:"password" binding:"required"`
 		Name     string `json:"name" binding:"required"`
-		Role     string `json:"role" binding:"required"`
+		Role     string `json:"role"`
 	}
 
 	if err := ctx.ShouldBindJSON(&registerReq); err != nil {
@@ -80,15 +83,12 @@ func (c *AuthController) Register(ctx *gin.Context) {
 		return
 	}
 
-	// 验证角色值是否有效
-	validRoles := map[string]bool{
-		models.RoleStudent: true,
-		models.RoleTeacher: true,
-		models.RoleAdmin:   true,
+	// 只能自行注册为学生，教师和管理员由管理员创建
+	if registerReq.Role == "" {
+		registerReq.Role = models.RoleStudent
 	}
-
-	if !validRoles[registerReq.Role] {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户角色，必须是student、teacher或admin"})
+	if registerReq.Role != models.RoleStudent {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只能注册学生账号，教师和管理员账号请联系管理员创建"})
 		return
 	}
 
@@ -104,20 +104,6 @@ func (c *AuthController) Register(ctx *gin.Context) {
 		return
 	}
 
-	// 如果注册的是教师，自动将所有学生与该教师关联
-	if user.Role == models.RoleTeacher {
-		var allStudents []models.User
-		configs.DB.Where("role = ?", models.RoleStudent).Find(&allStudents)
-
-		if len(allStudents) > 0 {
-			// 将所有学生关联到当前教师
-			for _, student := range allStudents {
-				student.TeacherID = user.ID
-				configs.DB.Save(&student)
-			}
-		}
-	}
-
 	ctx.JSON(http.StatusCreated, gin.H{
 		"message": "注册成功",
 		"user": gin.H{
@@ -129,9 +115,16 @@ func (c *AuthController) Register(ctx *gin.Context) {
 	})
 }
 
-// Logout 用户登出
+// Logout 用户登出，注销当前会话令牌并清除Cookie
 func (c *AuthController) Logout(ctx *gin.Context) {
-	// 客户端处理登出逻辑，这里只返回成功消息
+	if token := middlewares.TokenFromRequest(ctx); token != "" {
+		if err := c.authService.Logout(token); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "登出失败: " + err.Error()})
+			return
+		}
+	}
+	setSessionCookie(ctx, "", -1)
+
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "登出成功",
 	})
@@ -139,22 +132,11 @@ func (c *AuthController) Logout(ctx *gin.Context) {
 
 // CheckAuth 检查认证状态
 func (c *AuthController) CheckAuth(ctx *gin.Context) {
-	// 基于请求头的简单认证
-	username := ctx.GetHeader("X-Username")
-	if username == "" {
-		ctx.JSON(http.StatusUnauthorized, gin.H{
-			"authenticated": false,
-			"error":         "未提供用户名",
-		})
-		return
-	}
-
-	// 根据用户名获取用户信息
-	user, err := c.userRepo.GetByUsername(username)
+	user, _, err := c.authService.Authenticate(middlewares.TokenFromRequest(ctx))
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, gin.H{
 			"authenticated": false,
-			"error":         "用户不存在",
+			"error":         err.Error(),
 		})
 		return
 	}
@@ -169,3 +151,9 @@ func (c *AuthController) CheckAuth(ctx *gin.Context) {
 		},
 	})
 }
+
+// setSessionCookie 写入或清除会话Cookie，maxAge小于0表示删除
+func setSessionCookie(ctx *gin.Context, token string, maxAge int) {
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(middlewares.SessionCookieName, token, maxAge, "/", "", ctx.Request.TLS != nil, true)
+}
@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/exam-approval-system/middlewares"
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+	"github.com/exam-approval-system/services"
+	"github.com/gin-gonic/gin"
+)
+
+// QuestionBankController 题库控制器
+type QuestionBankController struct {
+	questionBankService services.QuestionBankService
+	authService         services.AuthService
+	settingsService     services.SettingsService
+}
+
+// NewQuestionBankController 创建题库控制器
+func NewQuestionBankController(questionBankService services.QuestionBankService, authService services.AuthService, settingsService services.SettingsService) *QuestionBankController {
+	return &QuestionBankController{
+		questionBankService: questionBankService,
+		authService:         authService,
+		settingsService:     settingsService,
+	}
+}
+
+// RegisterRoutes 注册题库路由，仅教师可用
+func (c *QuestionBankController) RegisterRoutes(router *gin.Engine) {
+	bank := router.Group("/api/bank", middlewares.AuthMiddleware(c.authService), middlewares.RoleMiddleware(models.RoleTeacher))
+	{
+		bank.GET("/questions", c.SearchQuestions)
+		bank.POST("/questions", c.CreateQuestion)
+		bank.GET("/questions/:id", c.GetQuestion)
+		bank.PUT("/questions/:id", c.UpdateQuestion)
+		bank.DELETE("/questions/:id", c.DeleteQuestion)
+		bank.GET("/questions/:id/versions", c.ListVersions)
+		bank.GET("/tags", c.ListTags)
+	}
+}
+
+// bankQuestionRequest 创建或修改题库题目的请求
+type bankQuestionRequest struct {
+	Course          string           `json:"course" binding:"required"`
+	Shared          bool             `json:"shared"`
+	Difficulty      int              `json:"difficulty"`
+	Topics          []string         `json:"topics"`
+	KnowledgePoints []string         `json:"knowledge_points"`
+	Question        *models.Question `json:"question" binding:"required"`
+}
+
+// toModel 转换为题库题目
+func (r *bankQuestionRequest) toModel() *models.BankQuestion {
+	question := &models.BankQuestion{
+		Course:     r.Course,
+		Shared:     r.Shared,
+		Difficulty: r.Difficulty,
+		Question:   r.Question,
+	}
+	for _, name := range r.Topics {
+		question.Tags = append(question.Tags, models.BankTag{Kind: models.BankTagTopic, Name: name})
+	}
+	for _, name := range r.KnowledgePoints {
+		question.Tags = append(question.Tags, models.BankTag{Kind: models.BankTagKnowledgePoint, Name: name})
+	}
+	return question
+}
+
+// SearchQuestions 搜索自己的题目和已共享的题目
+func (c *QuestionBankController) SearchQuestions(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+
+	// 分页参数，每页条数取自系统设置
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的页码"})
+		return
+	}
+	pageSize := c.settingsService.Get().PageSize
+
+	filter := repositories.BankQuestionFilter{
+		ViewerID:        userID.(uint),
+		OwnedOnly:       ctx.Query("mine") == "true",
+		Course:          ctx.Query("course"),
+		Type:            ctx.Query("type"),
+		Topics:          ctx.QueryArray("topic"),
+		KnowledgePoints: ctx.QueryArray("knowledge_point"),
+		Keyword:         ctx.Query("keyword"),
+	}
+	for param, target := range map[string]*int{"min_difficulty": &filter.MinDifficulty, "max_difficulty": &filter.MaxDifficulty} {
+		if value := ctx.Query(param); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil || *target < models.MinDifficulty || *target > models.MaxDifficulty {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的难度"})
+				return
+			}
+		}
+	}
+
+	questions, total, err := c.questionBankService.Search(filter, page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"questions": questions,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// CreateQuestion 创建题库题目
+func (c *QuestionBankController) CreateQuestion(ctx *gin.Context) {
+	var req bankQuestionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID, _ := ctx.Get("userID")
+	question := req.toModel()
+	question.OwnerID = userID.(uint)
+	if err := c.questionBankService.Create(question); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, question)
+}
+
+// GetQuestion 获取题库题目详情
+func (c *QuestionBankController) GetQuestion(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+		return
+	}
+
+	userID, _ := ctx.Get("userID")
+	question, err := c.questionBankService.Get(uint(id), userID.(uint))
+	if err != nil {
+		bankError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, question)
+}
+
+// UpdateQuestion 修改题库题目，题目内容变化时生成新版本
+func (c *QuestionBankController) UpdateQuestion(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+		return
+	}
+
+	var req bankQuestionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	userID, _ := ctx.Get("userID")
+	question, err := c.questionBankService.Update(uint(id), userID.(uint), req.toModel())
+	if err != nil {
+		bankError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, question)
+}
+
+// DeleteQuestion 删除题库题目
+func (c *QuestionBankController) DeleteQuestion(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+		return
+	}
+
+	userID, _ := ctx.Get("userID")
+	if err := c.questionBankService.Delete(uint(id), userID.(uint)); err != nil {
+		bankError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// ListVersions 获取题库题目的历史版本
+func (c *QuestionBankController) ListVersions(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的题目ID"})
+		return
+	}
+
+	userID, _ := ctx.Get("userID")
+	versions, err := c.questionBankService.Versions(uint(id), userID.(uint))
+	if err != nil {
+		bankError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// ListTags 获取可见题目使用的标签及题目数量
+func (c *QuestionBankController) ListTags(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+	tags, err := c.questionBankService.Tags(userID.(uint), ctx.Query("kind"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// bankError 按错误类型返回题库请求的错误响应
+func bankError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrBankQuestionNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrBankQuestionForbidden):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
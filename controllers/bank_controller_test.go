@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+	"github.com/exam-approval-system/services"
+	"github.com/gin-gonic/gin"
+)
+
+func TestQuestionBankController(t *testing.T) {
+	setupPageServices(t)
+	users := map[string]*models.User{
+		"owner":   {ID: 1, Role: models.RoleTeacher},
+		"other":   {ID: 2, Role: models.RoleTeacher},
+		"student": {ID: 3, Role: models.RoleStudent},
+	}
+	settings, err := services.NewSettingsService(repositories.NewSettingsRepository(), repositories.NewUserRepository())
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller := NewQuestionBankController(services.NewQuestionBankService(repositories.NewBankRepository()),
+		stubAuthService{users: users}, settings)
+	router := gin.New()
+	controller.RegisterRoutes(router)
+
+	request := func(user, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+user)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	create := func(shared bool) uint {
+		t.Helper()
+		body := fmt.Sprintf(`{"course":"数学","shared":%t,"topics":["数论"],"question":{"type":"true_false","content":"1是奇数","score":2,"answer":"true"}}`, shared)
+		w := request("owner", http.MethodPost, "/api/bank/questions", body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("创建题目状态码 = %d: %s", w.Code, w.Body.String())
+		}
+		var question models.BankQuestion
+		if err := json.Unmarshal(w.Body.Bytes(), &question); err != nil {
+			t.Fatal(err)
+		}
+		return question.ID
+	}
+	private, shared := create(false), create(true)
+	update := `{"course":"数学","shared":true,"topics":["数论"],"question":{"type":"true_false","content":"1不是偶数","score":2,"answer":"true"}}`
+
+	tests := []struct {
+		name       string
+		user       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"学生不能使用题库", "student", http.MethodGet, "/api/bank/questions", "", http.StatusForbidden},
+		{"难度超出范围", "owner", http.MethodPost, "/api/bank/questions", `{"course":"数学","difficulty":9,"question":{"type":"true_false","content":"x","score":1,"answer":"true"}}`, http.StatusBadRequest},
+		{"缺少题目", "owner", http.MethodPost, "/api/bank/questions", `{"course":"数学"}`, http.StatusBadRequest},
+		{"无效的页码", "owner", http.MethodGet, "/api/bank/questions?page=0", "", http.StatusBadRequest},
+		{"无效的难度筛选", "owner", http.MethodGet, "/api/bank/questions?min_difficulty=7", "", http.StatusBadRequest},
+		{"其他教师查看未共享的题目", "other", http.MethodGet, fmt.Sprintf("/api/bank/questions/%d", private), "", http.StatusNotFound},
+		{"其他教师查看共享的题目", "other", http.MethodGet, fmt.Sprintf("/api/bank/questions/%d", shared), "", http.StatusOK},
+		{"其他教师修改共享的题目", "other", http.MethodPut, fmt.Sprintf("/api/bank/questions/%d", shared), update, http.StatusForbidden},
+		{"其他教师删除共享的题目", "other", http.MethodDelete, fmt.Sprintf("/api/bank/questions/%d", shared), "", http.StatusForbidden},
+		{"创建者修改题目", "owner", http.MethodPut, fmt.Sprintf("/api/bank/questions/%d", shared), update, http.StatusOK},
+		{"查看历史版本", "other", http.MethodGet, fmt.Sprintf("/api/bank/questions/%d/versions", shared), "", http.StatusOK},
+		{"未知的标签类型", "owner", http.MethodGet, "/api/bank/tags?kind=chapter", "", http.StatusBadRequest},
+		{"创建者删除题目", "owner", http.MethodDelete, fmt.Sprintf("/api/bank/questions/%d", private), "", http.StatusOK},
+		{"删除后不存在", "owner", http.MethodGet, fmt.Sprintf("/api/bank/questions/%d", private), "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(tt.user, tt.method, tt.path, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Errorf("状态码 = %d, 期望 %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+
+	// 其他教师只能搜索到共享的题目
+	w := request("other", http.MethodGet, "/api/bank/questions?topic=数论", "")
+	var result struct {
+		Total     int                   `json:"total"`
+		Questions []models.BankQuestion `json:"questions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 1 || len(result.Questions) != 1 || result.Questions[0].ID != shared {
+		t.Errorf("搜索结果 = %+v, 期望只有共享的题目 %d", result, shared)
+	}
+}
@@ -6,31 +6,49 @@ import (
 
 	"github.com/exam-approval-system/middlewares"
 	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
 	"github.com/exam-approval-system/services"
 	"github.com/gin-gonic/gin"
 )
 
 // ExamController 考试控制器
 type ExamController struct {
-	examService services.ExamService
-	authService services.AuthService
+	examService    services.ExamService
+	commentService services.CommentService
+	authService    services.AuthService
 }
 
 // NewExamController 创建考试控制器
-func NewExamController(examService services.ExamService, authService services.AuthService) *ExamController {
+func NewExamController(examService services.ExamService, commentService services.CommentService, authService services.AuthService) *ExamController {
 	return &ExamController{
-		examService: examService,
-		authService: authService,
+		examService:    examService,
+		commentService: commentService,
+		authService:    authService,
 	}
 }
 
 // RegisterRoutes 注册路由
 func (c *ExamController) RegisterRoutes(router *gin.Engine) {
-	exam := router.Group("/api/exams", middlewares.AuthMiddleware())
+	exam := router.Group("/api/exams", middlewares.AuthMiddleware(c.authService))
 	{
 		// 公共路由
 		exam.GET("/:id", c.GetExam)
 		exam.GET("/:id/comments", c.GetExamComments)
+		exam.POST("/:id/comments", c.AddComment)
+		exam.PUT("/:id/comments/:commentId", c.EditComment)
+		exam.POST("/:id/comments/:commentId/resolve", c.ResolveComment(true))
+		exam.POST("/:id/comments/:commentId/unresolve", c.ResolveComment(false))
+		exam.GET("/:id/comments/:commentId/history", c.GetCommentHistory)
+		exam.GET("/:id/transitions", c.GetExamTransitions)
+		exam.GET("/:id/time-in-state", c.GetExamTimeInState)
+
+		// 状态变更，可执行的角色由考试状态机检查
+		exam.POST("/:id/submit", c.TransitionExam(models.ExamActionSubmit))
+		exam.POST("/:id/approve", c.TransitionExam(models.ExamActionApprove))
+		exam.POST("/:id/reject", c.TransitionExam(models.ExamActionReject))
+		exam.POST("/:id/publish", c.TransitionExam(models.ExamActionPublish))
+		exam.POST("/:id/close", c.TransitionExam(models.ExamActionClose))
+		exam.POST("/:id/archive", c.TransitionExam(models.ExamActionArchive))
 
 		// 学生路由
 		student := exam.Group("/", middlewares.RoleMiddleware(models.RoleStudent))
@@ -45,7 +63,6 @@ func (c *ExamController) RegisterRoutes(router *gin.Engine) {
 			teacher.POST("", c.CreateExam)
 			teacher.PUT("/:id", c.UpdateExam)
 			teacher.DELETE("/:id", c.DeleteExam)
-			teacher.POST("/:id/submit", c.SubmitExam)
 			teacher.POST("/:id/comment", c.AddComment)
 		}
 
@@ -54,9 +71,6 @@ func (c *ExamController) RegisterRoutes(router *gin.Engine) {
 		{
 			admin.GET("", c.ListAllExams)
 			admin.GET("/pending", c.ListPendingExams)
-			admin.POST("/:id/approve", c.ApproveExam)
-			admin.POST("/:id/reject", c.RejectExam)
-			admin.POST("/:id/publish", c.PublishExam)
 			admin.POST("/:id/admincomment", c.AddComment)
 		}
 	}
@@ -70,6 +84,9 @@ func (c *ExamController) CreateExam(ctx *gin.Context) {
 		Course      string `json:"course" binding:"required"`
 		StartTime   string `json:"start_time" binding:"required"`
 		EndTime     string `json:"end_time" binding:"required"`
+		MaxAttempts int    `json:"max_attempts"`
+		Cooldown    int    `json:"attempt_cooldown"`
+		ScorePolicy string `json:"score_policy"`
 	}
 
 	if err := ctx.ShouldBindJSON(&examReq); err != nil {
@@ -99,6 +116,10 @@ func (c *ExamController) CreateExam(ctx *gin.Context) {
 		EndTime:     endTime,
 		CreatorID:   userID.(uint),
 		Status:      models.StatusDraft,
+
+		MaxAttempts:     examReq.MaxAttempts,
+		AttemptCooldown: examReq.Cooldown,
+		ScorePolicy:     examReq.ScorePolicy,
 	}
 
 	if err := c.examService.CreateExam(exam); err != nil {
@@ -142,6 +163,9 @@ func (c *ExamController) UpdateExam(ctx *gin.Context) {
 		Course      string `json:"course"`
 		StartTime   string `json:"start_time"`
 		EndTime     string `json:"end_time"`
+		MaxAttempts *int   `json:"max_attempts"`
+		Cooldown    *int   `json:"attempt_cooldown"`
+		ScorePolicy string `json:"score_policy"`
 	}
 
 	if err := ctx.ShouldBindJSON(&examReq); err != nil {
@@ -188,6 +212,15 @@ func (c *ExamController) UpdateExam(ctx *gin.Context) {
 		}
 		exam.EndTime = endTime
 	}
+	if examReq.MaxAttempts != nil {
+		exam.MaxAttempts = *examReq.MaxAttempts
+	}
+	if examReq.Cooldown != nil {
+		exam.AttemptCooldown = *examReq.Cooldown
+	}
+	if examReq.ScorePolicy != "" {
+		exam.ScorePolicy = examReq.ScorePolicy
+	}
 
 	if err := c.examService.UpdateExam(exam); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -272,8 +305,43 @@ func (c *ExamController) ListPublishedExams(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, exams)
 }
 
-// SubmitExam 提交考试审批
-func (c *ExamController) SubmitExam(ctx *gin.Context) {
+// TransitionExam 返回执行指定状态变更的处理函数，角色和状态检查由考试状态机完成
+func (c *ExamController) TransitionExam(action string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		idStr := ctx.Param("id")
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的考试ID"})
+			return
+		}
+
+		// 审批意见可选，拒绝时由状态机要求填写理由
+		var transitionReq struct {
+			Comment string `json:"comment"`
+		}
+		if ctx.Request.ContentLength != 0 {
+			if err := ctx.ShouldBindJSON(&transitionReq); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+				return
+			}
+		}
+
+		userID, _ := ctx.Get("userID")
+		exam, err := c.examService.TransitionExam(uint(id), userID.(uint), action, transitionReq.Comment)
+		if err != nil {
+			ctx.JSON(transitionStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": transitionMessage(action, exam),
+			"status":  exam.Status,
+		})
+	}
+}
+
+// GetExamTransitions 获取考试的状态变更记录
+func (c *ExamController) GetExamTransitions(ctx *gin.Context) {
 	idStr := ctx.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
@@ -281,29 +349,67 @@ func (c *ExamController) SubmitExam(ctx *gin.Context) {
 		return
 	}
 
-	exam, err := c.examService.GetExamByID(uint(id))
+	transitions, err := c.examService.ListTransitions(uint(id))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "考试不存在"})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取状态变更记录失败"})
 		return
 	}
 
-	// 检查是否是考试的创建者
-	userID, _ := ctx.Get("userID")
-	if exam.CreatorID != userID.(uint) {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "只有创建者才能提交考试审批"})
-		return
+	ctx.JSON(http.StatusOK, transitions)
+}
+
+// transitionMessage 状态变更成功后的提示，按审批链审批且本轮尚无结果时提示等待其他审批人
+func transitionMessage(action string, exam *models.Exam) string {
+	if exam.Status == models.StatusPending && action != models.ExamActionSubmit {
+		return "已记录审批意见，等待其他审批人处理"
 	}
+	return services.ExamActionName(action) + "成功"
+}
 
-	if err := c.examService.SubmitForApproval(uint(id)); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// transitionStatus 状态变更错误对应的HTTP状态码
+func transitionStatus(err error) int {
+	switch err {
+	case services.ErrExamNotFound:
+		return http.StatusNotFound
+	case services.ErrTransitionForbidden, services.ErrNoApprovalTask:
+		return http.StatusForbidden
+	case services.ErrTransitionConflict:
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
 	}
+}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "提交成功"})
+// commentStatus 评论操作错误对应的HTTP状态码
+func commentStatus(err error) int {
+	switch err {
+	case services.ErrExamNotFound, services.ErrCommentNotFound:
+		return http.StatusNotFound
+	case services.ErrCommentForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
 }
 
-// ApproveExam 审批通过考试
-func (c *ExamController) ApproveExam(ctx *gin.Context) {
+// parseCommentID 解析路径中的考试ID和评论ID
+func parseCommentID(ctx *gin.Context) (uint, uint, bool) {
+	examID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的考试ID"})
+		return 0, 0, false
+	}
+	commentID, err := strconv.ParseUint(ctx.Param("commentId"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的评论ID"})
+		return 0, 0, false
+	}
+	return uint(examID), uint(commentID), true
+}
+
+// AddComment 添加评论，parent_id 不为0时回复所在的讨论串。
+// 未指定类型时教师和管理员发表审批意见，学生发表讨论
+func (c *ExamController) AddComment(ctx *gin.Context) {
 	idStr := ctx.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
@@ -311,26 +417,44 @@ func (c *ExamController) ApproveExam(ctx *gin.Context) {
 		return
 	}
 
-	var approveReq struct {
-		Comment string `json:"comment"`
+	var commentReq struct {
+		Content    string `json:"content" binding:"required"`
+		Kind       string `json:"kind"`
+		Visibility string `json:"visibility"`
+		ParentID   uint   `json:"parent_id"`
+		PaperID    uint   `json:"paper_id"`
+		QuestionID uint   `json:"question_id"`
+		ExamDataID uint   `json:"exam_data_id"`
 	}
 
-	if err := ctx.ShouldBindJSON(&approveReq); err != nil {
+	if err := ctx.ShouldBindJSON(&commentReq); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
 		return
 	}
 
 	userID, _ := ctx.Get("userID")
-	if err := c.examService.ApproveExam(uint(id), userID.(uint), approveReq.Comment); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	comment := &models.Comment{
+		ExamID:     uint(id),
+		ParentID:   commentReq.ParentID,
+		Kind:       commentReq.Kind,
+		Visibility: commentReq.Visibility,
+		PaperID:    commentReq.PaperID,
+		QuestionID: commentReq.QuestionID,
+		ExamDataID: commentReq.ExamDataID,
+		Content:    commentReq.Content,
+	}
+
+	if err := c.commentService.Create(userID.(uint), comment); err != nil {
+		ctx.JSON(commentStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "审批通过"})
+	ctx.JSON(http.StatusCreated, comment)
 }
 
-// RejectExam 拒绝考试
-func (c *ExamController) RejectExam(ctx *gin.Context) {
+// GetExamComments 获取考试评论，按讨论串返回当前用户可见的评论，
+// 可按类型、试卷、题目、答卷和解决状态过滤
+func (c *ExamController) GetExamComments(ctx *gin.Context) {
 	idStr := ctx.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
@@ -338,88 +462,126 @@ func (c *ExamController) RejectExam(ctx *gin.Context) {
 		return
 	}
 
-	var rejectReq struct {
-		Comment string `json:"comment" binding:"required"`
+	filter := repositories.CommentFilter{ExamID: uint(id), Kind: ctx.Query("kind")}
+	anchors := []struct {
+		name  string
+		value *uint
+	}{
+		{"paper_id", &filter.PaperID},
+		{"question_id", &filter.QuestionID},
+		{"exam_data_id", &filter.ExamDataID},
+	}
+	for _, anchor := range anchors {
+		if value := ctx.Query(anchor.name); value != "" {
+			parsed, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的" + anchor.name})
+				return
+			}
+			*anchor.value = uint(parsed)
+		}
 	}
-
-	if err := ctx.ShouldBindJSON(&rejectReq); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
-		return
+	if value := ctx.Query("resolved"); value != "" {
+		resolved, err := strconv.ParseBool(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "resolved必须是true或false"})
+			return
+		}
+		filter.Resolved = &resolved
 	}
 
 	userID, _ := ctx.Get("userID")
-	if err := c.examService.RejectExam(uint(id), userID.(uint), rejectReq.Comment); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	comments, err := c.commentService.List(userID.(uint), filter)
+	if err == services.ErrExamNotFound {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
-
-	ctx.JSON(http.StatusOK, gin.H{"message": "已拒绝"})
-}
-
-// PublishExam 发布考试
-func (c *ExamController) PublishExam(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的考试ID"})
-		return
-	}
-
-	if err := c.examService.PublishExam(uint(id)); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取评论失败"})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "发布成功"})
+	ctx.JSON(http.StatusOK, comments)
 }
 
-// AddComment 添加评论
-func (c *ExamController) AddComment(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的考试ID"})
+// EditComment 修改自己发表的评论，原内容记入编辑历史
+func (c *ExamController) EditComment(ctx *gin.Context) {
+	examID, commentID, ok := parseCommentID(ctx)
+	if !ok {
 		return
 	}
 
 	var commentReq struct {
 		Content string `json:"content" binding:"required"`
 	}
-
 	if err := ctx.ShouldBindJSON(&commentReq); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
 		return
 	}
 
 	userID, _ := ctx.Get("userID")
-	comment := &models.Comment{
-		ExamID:  uint(id),
-		UserID:  userID.(uint),
-		Content: commentReq.Content,
+	comment, err := c.commentService.Edit(examID, commentID, userID.(uint), commentReq.Content)
+	if err != nil {
+		ctx.JSON(commentStatus(err), gin.H{"error": err.Error()})
+		return
 	}
 
-	if err := c.examService.AddComment(comment); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	ctx.JSON(http.StatusOK, comment)
+}
+
+// ResolveComment 返回将讨论串标记为已解决或重新打开的处理函数
+func (c *ExamController) ResolveComment(resolved bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		examID, commentID, ok := parseCommentID(ctx)
+		if !ok {
+			return
+		}
+
+		userID, _ := ctx.Get("userID")
+		comment, err := c.commentService.SetResolved(examID, commentID, userID.(uint), resolved)
+		if err != nil {
+			ctx.JSON(commentStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, comment)
+	}
+}
+
+// GetCommentHistory 获取评论的编辑历史
+func (c *ExamController) GetCommentHistory(ctx *gin.Context) {
+	examID, commentID, ok := parseCommentID(ctx)
+	if !ok {
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, comment)
+	userID, _ := ctx.Get("userID")
+	revisions, err := c.commentService.History(examID, commentID, userID.(uint))
+	if err != nil {
+		ctx.JSON(commentStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, revisions)
 }
 
-// GetExamComments 获取考试评论
-func (c *ExamController) GetExamComments(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
+// GetExamTimeInState 获取考试在各状态累计停留的时间
+func (c *ExamController) GetExamTimeInState(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的考试ID"})
 		return
 	}
 
-	comments, err := c.examService.GetCommentsByExamID(uint(id))
+	result, err := c.examService.TimeInState(uint(id))
+	if err == services.ErrExamNotFound {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取评论失败"})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取状态停留时间失败"})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, comments)
+	ctx.JSON(http.StatusOK, result)
 }
@@ -2,9 +2,11 @@ package controllers
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"log"
@@ -18,9 +20,17 @@ import (
 
 // 服务依赖
 var (
-	AuthService      services.AuthService
-	DashboardService services.DashboardService
-	ExamService      services.ExamService
+	AuthService       services.AuthService
+	DashboardService  services.DashboardService
+	UserService       services.UserService
+	ExamService       services.ExamService
+	SettingsService   services.SettingsService
+	BackupScheduler   services.BackupScheduler
+	SubmissionService services.SubmissionService
+	GradingService    services.GradingService
+	AttemptService    services.AttemptService
+	ApprovalService   services.ApprovalService
+	PaperLockService  services.PaperLockService
 )
 
 // LoginPage 登录页面
@@ -68,27 +78,9 @@ func ForceRegisterPage(c *gin.Context) {
 	})
 }
 
-// Dashboard 控制面板页面 - 根据用户请求头和查询参数决定显示内容
+// Dashboard 控制面板页面 - 根据当前登录用户的角色决定显示内容
 func Dashboard(c *gin.Context) {
-	// 尝试从请求头获取用户名
-	username := c.GetHeader("X-Username")
-	if username == "" {
-		// 如果请求头没有，尝试从查询参数获取
-		username = c.Query("username")
-		if username == "" {
-			// 如果都没有提供，重定向到登录页面
-			c.Redirect(http.StatusFound, "/login")
-			return
-		}
-	}
-
-	// 使用仓库直接查询用户
-	userRepo := repositories.NewUserRepository()
-	user, err := userRepo.GetByUsername(username)
-	if err != nil {
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
+	user := currentUser(c)
 
 	// 根据用户角色选择合适的控制面板模板
 	var template string
@@ -118,6 +110,7 @@ func Dashboard(c *gin.Context) {
 				dashboardData["recentPapers"] = stats.RecentPapers
 				dashboardData["averageScore"] = stats.AverageScore
 				dashboardData["examDataList"] = stats.ExamDataList
+				dashboardData["grades"] = gradeReports(stats.ExamDataList)
 			}
 		}
 
@@ -135,11 +128,19 @@ func Dashboard(c *gin.Context) {
 				dashboardData["pendingPapers"] = stats.PendingPaperList
 				dashboardData["rejectedPapers"] = stats.RejectedPapers
 				dashboardData["recentPapers"] = stats.RecentPapers
+				dashboardData["examActions"] = examActionButtons(stats.RecentPapers, user)
 				dashboardData["totalStudents"] = stats.TotalStudents
 				dashboardData["examDataList"] = stats.ExamDataList
 			}
 		}
 
+		// 获取等待教师处理的审批
+		if ApprovalService != nil {
+			if queue, err := ApprovalService.Queue(user); err == nil {
+				dashboardData["approvalQueue"] = queue
+			}
+		}
+
 	case "admin":
 		template = "dashboard-admin.html" // 使用管理员面板
 		title = "管理员控制面板"
@@ -176,31 +177,7 @@ func Dashboard(c *gin.Context) {
 
 // DashboardStudent 学生控制面板页面
 func DashboardStudent(c *gin.Context) {
-	// 尝试从请求头获取用户名
-	username := c.GetHeader("X-Username")
-	if username == "" {
-		// 如果请求头没有，尝试从查询参数获取
-		username = c.Query("username")
-		if username == "" {
-			// 如果都没有提供，显示错误页面
-			c.HTML(http.StatusOK, "dashboard-student.html", gin.H{
-				"title": "学生控制面板",
-				"error": "未登录或会话已过期",
-			})
-			return
-		}
-	}
-
-	// 使用仓库直接查询用户
-	userRepo := repositories.NewUserRepository()
-	user, err := userRepo.GetByUsername(username)
-	if err != nil {
-		c.HTML(http.StatusOK, "dashboard-student.html", gin.H{
-			"title": "学生控制面板",
-			"error": "用户不存在",
-		})
-		return
-	}
+	user := currentUser(c)
 
 	// 准备仪表板数据
 	dashboardData := gin.H{
@@ -303,6 +280,7 @@ func DashboardStudent(c *gin.Context) {
 
 	if err == nil && len(examDataList) > 0 {
 		dashboardData["examDataList"] = examDataList
+		dashboardData["grades"] = gradeReports(examDataList)
 
 		// 计算统计数据
 		var approvedCount, pendingCount, rejectedCount int
@@ -361,37 +339,26 @@ func DashboardStudent(c *gin.Context) {
 	c.HTML(http.StatusOK, "dashboard-student.html", dashboardData)
 }
 
-// DashboardTeacher 教师控制面板页面
-func DashboardTeacher(c *gin.Context) {
-	// 尝试从请求头获取用户名
-	username := c.GetHeader("X-Username")
-	if username == "" {
-		// 如果请求头没有，尝试从查询参数获取
-		username = c.Query("username")
-		if username == "" {
-			// 如果都没有提供，显示错误页面
-			c.HTML(http.StatusOK, "dashboard-teacher.html", gin.H{
-				"title": "教师控制面板",
-				"error": "未登录或会话已过期",
-				"stats": &services.DashboardStats{}, // 添加默认值
-				"user":  &models.User{},             // 添加默认值
-			})
-			return
+// gradeReports 按等级制换算已批阅答题记录的成绩，以答题记录ID为键
+func gradeReports(examDataList []models.ExamData) map[uint]models.GradeResult {
+	grades := make(map[uint]models.GradeResult)
+	for i := range examDataList {
+		if examDataList[i].Status != models.StatusApproved {
+			continue
 		}
+		report, err := GradingService.Report(&examDataList[i])
+		if err != nil {
+			log.Printf("换算答题记录(%d)成绩失败: %v", examDataList[i].ID, err)
+			continue
+		}
+		grades[examDataList[i].ID] = report
 	}
+	return grades
+}
 
-	// 使用仓库直接查询用户
-	userRepo := repositories.NewUserRepository()
-	user, err := userRepo.GetByUsername(username)
-	if err != nil {
-		c.HTML(http.StatusOK, "dashboard-teacher.html", gin.H{
-			"title": "教师控制面板",
-			"error": "用户不存在",
-			"stats": &services.DashboardStats{}, // 添加默认值
-			"user":  &models.User{},             // 添加默认值
-		})
-		return
-	}
+// DashboardTeacher 教师控制面板页面
+func DashboardTeacher(c *gin.Context) {
+	user := currentUser(c)
 
 	// 准备仪表板数据
 	dashboardData := gin.H{
@@ -409,11 +376,11 @@ func DashboardTeacher(c *gin.Context) {
 
 	// 获取教师关联的学生列表
 	var students []models.User
-	configs.DB.Where("teacher_id = ?", user.ID).Find(&students)
+	configs.DB().Where("teacher_id = ?", user.ID).Find(&students)
 
 	// 查询所有学生并确保关联到当前教师（临时解决方案）
 	var allStudents []models.User
-	configs.DB.Where("role = ?", models.RoleStudent).Find(&allStudents)
+	configs.DB().Where("role = ?", models.RoleStudent).Find(&allStudents)
 
 	log.Printf("找到 %d 名学生待关联", len(allStudents))
 
@@ -421,13 +388,20 @@ func DashboardTeacher(c *gin.Context) {
 	for _, student := range allStudents {
 		if student.TeacherID != user.ID {
 			student.TeacherID = user.ID
-			configs.DB.Save(&student)
+			configs.DB().Save(&student)
 		}
 	}
 
 	// 更新仪表板数据中的学生列表
 	dashboardData["students"] = allStudents
 
+	// 获取等待教师处理的审批
+	if ApprovalService != nil {
+		if queue, err := ApprovalService.Queue(user); err == nil {
+			dashboardData["approvalQueue"] = queue
+		}
+	}
+
 	// 获取教师仪表板数据
 	if DashboardService != nil {
 		stats, err := DashboardService.GetTeacherDashboardStats(user.ID)
@@ -438,6 +412,7 @@ func DashboardTeacher(c *gin.Context) {
 			dashboardData["pendingPapers"] = stats.PendingPaperList
 			dashboardData["rejectedPapers"] = stats.RejectedPapers
 			dashboardData["recentPapers"] = stats.RecentPapers
+			dashboardData["examActions"] = examActionButtons(stats.RecentPapers, user)
 			dashboardData["totalStudents"] = len(students) // 使用关联学生数量
 			dashboardData["examDataList"] = stats.ExamDataList
 		} else {
@@ -480,33 +455,7 @@ func DashboardTeacher(c *gin.Context) {
 
 // DashboardAdmin 管理员控制面板页面
 func DashboardAdmin(c *gin.Context) {
-	// 尝试从请求头获取用户名
-	username := c.GetHeader("X-Username")
-	if username == "" {
-		// 如果请求头没有，尝试从查询参数获取
-		username = c.Query("username")
-		if username == "" {
-			// 如果都没有提供，显示错误页面
-			c.HTML(http.StatusOK, "dashboard-admin.html", gin.H{
-				"title": "管理员控制面板",
-				"error": "未登录或会话已过期",
-				"now":   time.Now(),
-			})
-			return
-		}
-	}
-
-	// 使用仓库直接查询用户
-	userRepo := repositories.NewUserRepository()
-	user, err := userRepo.GetByUsername(username)
-	if err != nil {
-		c.HTML(http.StatusOK, "dashboard-admin.html", gin.H{
-			"title": "管理员控制面板",
-			"error": "用户不存在",
-			"now":   time.Now(),
-		})
-		return
-	}
+	user := currentUser(c)
 
 	// 准备仪表板数据
 	dashboardData := gin.H{
@@ -534,6 +483,7 @@ func DashboardAdmin(c *gin.Context) {
 	}
 
 	// 获取所有用户列表，用于用户管理
+	userRepo := repositories.NewUserRepository()
 	allUsers, err := userRepo.List()
 	if err == nil {
 		dashboardData["allUsers"] = allUsers
@@ -558,6 +508,26 @@ func DashboardAdmin(c *gin.Context) {
 	allPapers, err := examRepo.List()
 	if err == nil {
 		dashboardData["allPapers"] = allPapers
+		dashboardData["examActions"] = examActionButtons(allPapers, user)
+	}
+
+	// 获取等待管理员处理的审批
+	if ApprovalService != nil {
+		if queue, err := ApprovalService.Queue(user); err == nil {
+			dashboardData["approvalQueue"] = queue
+		}
+	}
+
+	// 获取最近的定时备份记录及下一次备份时间
+	backupRunRepo := repositories.NewBackupRunRepository()
+	backupRuns, err := backupRunRepo.ListRecent(10)
+	if err == nil {
+		dashboardData["backupRuns"] = backupRuns
+	}
+	if BackupScheduler != nil {
+		if next, enabled := BackupScheduler.NextRun(); enabled {
+			dashboardData["nextBackupAt"] = next
+		}
 	}
 
 	c.HTML(http.StatusOK, "dashboard-admin.html", dashboardData)
@@ -570,25 +540,12 @@ func HandleCreatePaper(c *gin.Context) {
 	course := c.PostForm("course")
 	description := c.PostForm("description")
 
-	// 获取当前用户名 (用于创建者ID和重定向时的会话保持)
-	username := c.GetHeader("X-Username")
-	if username == "" {
-		username = c.Query("username") // 尝试从查询参数获取
-		if username == "" {
-			username = c.PostForm("username") // 尝试从表单数据获取
-		}
-	}
+	// 当前登录用户即试卷创建者
+	user := currentUser(c)
 
 	// 是否请求JSON响应
 	wantJSON := c.GetHeader("Accept") == "application/json" || c.GetHeader("X-Requested-With") == "XMLHttpRequest"
 
-	// 尝试获取用户信息，无论后续操作是否成功，都可能需要在渲染错误页面时使用
-	var currentUser *models.User
-	if username != "" {
-		userRepo := repositories.NewUserRepository() // 临时的repo实例
-		currentUser, _ = userRepo.GetByUsername(username)
-	}
-
 	// 验证必填字段
 	if title == "" || course == "" {
 		if wantJSON {
@@ -599,56 +556,30 @@ func HandleCreatePaper(c *gin.Context) {
 		} else {
 			c.HTML(http.StatusBadRequest, "dashboard-admin.html", gin.H{
 				"title": "管理员控制面板",
-				"user":  currentUser, // 传递用户信息
+				"user":  user, // 传递用户信息
 				"error": "试卷标题和科目不能为空",
 			})
 		}
 		return
 	}
 
-	// 如果此时仍没有获取到用户名，说明用户未认证或会话丢失
-	if username == "" {
-		if wantJSON {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"success": false,
-				"message": "用户会话已过期或未登录",
-			})
-		} else {
-			c.Redirect(http.StatusFound, "/login?error=session_expired_or_missing_username")
-		}
-		return
-	}
-
-	// 再次确认用户信息，并获取用户ID
-	if currentUser == nil || currentUser.Username != username { // currentUser可能是之前获取失败的
-		userRepo := repositories.NewUserRepository()
-		var err error
-		currentUser, err = userRepo.GetByUsername(username)
-		if err != nil {
-			if wantJSON {
-				c.JSON(http.StatusUnauthorized, gin.H{
-					"success": false,
-					"message": "用户不存在",
-				})
-			} else {
-				c.Redirect(http.StatusFound, "/login?error=user_not_found")
-			}
-			return
-		}
-	}
-
 	// 创建试卷对象 (Exam)
 	exam := &models.Exam{
 		Title:       title,
 		Description: description,
 		Course:      course,
-		CreatorID:   currentUser.ID,                     // 使用认证用户的ID
-		Status:      models.StatusPublished,             // 直接设置为已发布状态
+		CreatorID:   user.ID,                            // 使用认证用户的ID
+		Status:      models.StatusDraft,                 // 新建试卷为草稿，审批通过并发布后学生才能作答
 		StartTime:   time.Now(),                         // 可根据需求调整
 		EndTime:     time.Now().Add(time.Hour * 24 * 7), // 默认有效期一周，可调整
+		ScorePolicy: c.PostForm("score_policy"),
 		// CreatedAt 和 UpdatedAt 会由GORM的钩子自动处理
 	}
 
+	// 作答次数和间隔为可选项，未填写时不限次数、不限间隔
+	exam.MaxAttempts, _ = strconv.Atoi(c.DefaultPostForm("max_attempts", "0"))
+	exam.AttemptCooldown, _ = strconv.Atoi(c.DefaultPostForm("attempt_cooldown", "0"))
+
 	// 检查 ExamService 是否已初始化
 	if ExamService == nil {
 		if wantJSON {
@@ -659,7 +590,7 @@ func HandleCreatePaper(c *gin.Context) {
 		} else {
 			c.HTML(http.StatusInternalServerError, "dashboard-admin.html", gin.H{
 				"title": "管理员控制面板",
-				"user":  currentUser,
+				"user":  user,
 				"error": "内部服务器错误: ExamService 未初始化",
 			})
 		}
@@ -677,7 +608,7 @@ func HandleCreatePaper(c *gin.Context) {
 		} else {
 			c.HTML(http.StatusInternalServerError, "dashboard-admin.html", gin.H{
 				"title": "管理员控制面板",
-				"user":  currentUser,
+				"user":  user,
 				"error": "创建试卷失败: " + err.Error(),
 			})
 		}
@@ -695,8 +626,8 @@ func HandleCreatePaper(c *gin.Context) {
 			},
 		})
 	} else {
-		// 重定向回管理员仪表板的试卷管理模块，并带上用户名和成功提示
-		redirectURL := "/admin/dashboard?username=" + currentUser.Username + "&success=paper_created#papers"
+		// 重定向回管理员仪表板的试卷管理模块，并带上成功提示
+		redirectURL := "/admin/dashboard?success=paper_created#papers"
 		c.Redirect(http.StatusFound, redirectURL)
 	}
 }
@@ -721,48 +652,9 @@ func HandleDeletePaper(c *gin.Context) {
 		return
 	}
 
-	// 获取发起操作的用户名，用于重定向
-	username := c.GetHeader("X-Username")
-	if username == "" {
-		username = c.Query("username") // 尝试从查询参数获取
-		if username == "" {
-			// 尝试从cookie中获取
-			usernameCookie, err := c.Cookie("username")
-			if err == nil && usernameCookie != "" {
-				username = usernameCookie
-			}
-		}
-		if username == "" {
-			// 仍然没有找到用户名
-			log.Printf("试卷删除操作未提供用户名，重定向到登录页面")
-			// 判断请求类型
-			if c.GetHeader("X-Requested-With") == "XMLHttpRequest" {
-				c.JSON(http.StatusUnauthorized, gin.H{
-					"success": false,
-					"message": "用户未登录或会话已过期",
-				})
-			} else {
-				c.Redirect(http.StatusFound, "/login")
-			}
-			return
-		}
-	}
-
-	// 查找用户信息验证身份
-	userRepo := repositories.NewUserRepository()
-	user, err := userRepo.GetByUsername(username)
-	if err != nil {
-		log.Printf("获取用户信息失败: %v", err)
-		if c.GetHeader("X-Requested-With") == "XMLHttpRequest" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"success": false,
-				"message": "用户未登录或会话已过期",
-			})
-		} else {
-			c.Redirect(http.StatusFound, "/login")
-		}
-		return
-	}
+	// 获取发起操作的用户，用于权限校验和重定向
+	user := currentUser(c)
+	username := user.Username
 
 	// 检查用户权限，只有管理员和教师可以删除试卷
 	if user.Role != "admin" && user.Role != "teacher" {
@@ -777,77 +669,38 @@ func HandleDeletePaper(c *gin.Context) {
 			if user.Role == "student" {
 				dashboardPath = "/dashboard-student"
 			}
-			c.Redirect(http.StatusFound, dashboardPath+"?username="+username+"&error=您没有删除试卷的权限")
-		}
-		return
-	}
-
-	// 获取试卷信息
-	examRepo := repositories.NewExamRepository()
-	exam, err := examRepo.GetByID(uint(id))
-	if err != nil {
-		log.Printf("查找试卷失败: %v", err)
-		if c.GetHeader("X-Requested-With") == "XMLHttpRequest" {
-			c.JSON(http.StatusNotFound, gin.H{
-				"success": false,
-				"message": "试卷不存在",
-			})
-		} else {
-			c.HTML(http.StatusNotFound, "dashboard-admin.html", gin.H{
-				"error": "试卷不存在",
-			})
+			c.Redirect(http.StatusFound, dashboardPath+"?error=您没有删除试卷的权限")
 		}
 		return
 	}
 
-	// 删除与试卷相关的所有数据
-	log.Printf("准备删除试卷ID: %d, 标题: %s", id, exam.Title)
-
-	// 1. 查找和删除相关的ExamData记录
-	var examDataList []models.ExamData
-	result := configs.DB.Where("exam_id = ?", id).Find(&examDataList)
-	if result.Error != nil {
-		log.Printf("查询试卷相关ExamData记录失败: %v", result.Error)
-	} else {
-		log.Printf("找到%d条相关ExamData记录需要删除", len(examDataList))
-		// 记录每一条要删除的ExamData信息用于调试
-		for _, data := range examDataList {
-			log.Printf("删除ExamData记录: ID=%d, 学生ID=%d, 试卷ID=%d, 标题=%s",
-				data.ID, data.StudentID, data.ExamID, data.Title)
+	// 删除考试及其试卷、学生答卷、提交、作答和评论，由服务检查创建者和试卷锁定
+	if err := ExamService.RemoveExam(uint(id), user); err != nil {
+		log.Printf("删除试卷 %d 失败: %v", id, err)
+		status := http.StatusInternalServerError
+		switch err {
+		case services.ErrExamNotFound:
+			status = http.StatusNotFound
+		case services.ErrExamDeleteForbidden:
+			status = http.StatusForbidden
+		case models.ErrPaperLocked:
+			status = http.StatusConflict
 		}
-	}
-
-	// 执行删除
-	deleteResult := configs.DB.Where("exam_id = ?", id).Delete(&models.ExamData{})
-	log.Printf("删除ExamData结果: 影响行数=%d", deleteResult.RowsAffected)
-
-	// 2. 删除相关的Comment记录
-	result = configs.DB.Where("exam_id = ?", id).Delete(&models.Comment{})
-	log.Printf("删除Comment结果: 影响行数=%d", result.RowsAffected)
-
-	// 3. 删除相关的Paper记录
-	result = configs.DB.Where("exam_id = ?", id).Delete(&models.Paper{})
-	log.Printf("删除Paper结果: 影响行数=%d", result.RowsAffected)
-
-	// 4. 最后删除试卷本身
-	err = examRepo.Delete(uint(id))
-	if err != nil {
-		log.Printf("删除试卷失败: %v", err)
 		// 判断请求类型
 		if c.GetHeader("X-Requested-With") == "XMLHttpRequest" {
-			c.JSON(http.StatusInternalServerError, gin.H{
+			c.JSON(status, gin.H{
 				"success": false,
 				"message": "删除试卷失败: " + err.Error(),
 			})
 		} else {
-			c.HTML(http.StatusInternalServerError, "dashboard-admin.html", gin.H{
+			c.HTML(status, "dashboard-admin.html", gin.H{
 				"error": "删除试卷失败: " + err.Error(),
 			})
 		}
 		return
 	}
 
-	log.Printf("成功删除试卷ID: %d, 标题: %s", id, exam.Title)
+	log.Printf("成功删除试卷ID: %d", id)
 
 	// 根据请求类型返回响应
 	if c.GetHeader("X-Requested-With") == "XMLHttpRequest" {
@@ -857,12 +710,12 @@ func HandleDeletePaper(c *gin.Context) {
 		})
 	} else {
 		// 根据用户角色选择合适的重定向URL
-		redirectURL := "/dashboard-admin?username=" + username + "#papers"
+		redirectURL := "/dashboard-admin"
 		if user.Role == "teacher" {
-			redirectURL = "/dashboard-teacher?username=" + username + "#papers"
+			redirectURL = "/dashboard-teacher"
 		}
 		// 添加成功消息
-		redirectURL += "&success=1"
+		redirectURL += "?success=1#papers"
 		c.Redirect(http.StatusFound, redirectURL)
 	}
 }
@@ -897,13 +750,26 @@ func HandleCreateUser(c *gin.Context) {
 		return
 	}
 
+	// 按系统设置校验密码强度
+	if err := services.ValidatePassword(SettingsService, password); err != nil {
+		c.HTML(http.StatusBadRequest, "dashboard-admin.html", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	// 创建用户对象
 	user := &models.User{
 		Username: username,
-		Password: password,
 		Name:     name,
 		Role:     role,
 	}
+	if err := user.SetPassword(password); err != nil {
+		c.HTML(http.StatusInternalServerError, "dashboard-admin.html", gin.H{
+			"error": "创建用户失败: " + err.Error(),
+		})
+		return
+	}
 
 	// 保存到数据库
 	userRepo := repositories.NewUserRepository()
@@ -915,17 +781,36 @@ func HandleCreateUser(c *gin.Context) {
 		return
 	}
 
-	// 获取发起操作的管理员用户名，用于重定向
-	actingAdminUsername := c.GetHeader("X-Username")
-	if actingAdminUsername == "" {
-		c.Redirect(http.StatusFound, "/login")
-		return
+	// 创建教师时将所有学生与该教师关联
+	if user.Role == models.RoleTeacher {
+		students, err := userRepo.ListByRole(models.RoleStudent)
+		if err != nil {
+			log.Printf("获取学生列表失败: %v", err)
+		}
+		for i := range students {
+			students[i].TeacherID = user.ID
+			if err := userRepo.Update(&students[i]); err != nil {
+				log.Printf("关联学生 %d 与教师 %d 失败: %v", students[i].ID, user.ID, err)
+			}
+		}
 	}
-	c.Redirect(http.StatusFound, "/admin/dashboard?username="+actingAdminUsername+"#users")
+
+	// 重定向回管理员仪表板的用户管理模块
+	c.Redirect(http.StatusFound, "/admin/dashboard#users")
 }
 
 // HandleApprovePaper 处理批准试卷的请求
 func HandleApprovePaper(c *gin.Context) {
+	handleReviewPaper(c, models.ExamActionApprove)
+}
+
+// HandleRejectPaper 处理拒绝试卷的请求
+func HandleRejectPaper(c *gin.Context) {
+	handleReviewPaper(c, models.ExamActionReject)
+}
+
+// handleReviewPaper 通过考试状态机审批或拒绝试卷，完成后返回管理员仪表板的审批管理模块
+func handleReviewPaper(c *gin.Context, action string) {
 	// 获取试卷ID
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
@@ -936,110 +821,49 @@ func HandleApprovePaper(c *gin.Context) {
 		return
 	}
 
-	// 获取当前用户ID
-	username := c.GetHeader("X-Username")
-	userRepo := repositories.NewUserRepository()
-	user, err := userRepo.GetByUsername(username)
-	if err != nil {
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
-
-	// 获取试卷
-	examRepo := repositories.NewExamRepository()
-	exam, err := examRepo.GetByID(uint(id))
-	if err != nil {
-		c.HTML(http.StatusNotFound, "dashboard-admin.html", gin.H{
-			"error": "试卷不存在",
-		})
-		return
-	}
-
-	// 更新试卷状态为已审批
-	exam.Status = models.StatusApproved
-	exam.ApproverID = user.ID
+	// 获取当前用户
+	user := currentUser(c)
 
-	// 保存到数据库
-	err = examRepo.Update(exam)
-	if err != nil {
-		c.HTML(http.StatusInternalServerError, "dashboard-admin.html", gin.H{
-			"error": "审批试卷失败: " + err.Error(),
+	// 审批意见可选，拒绝时必须填写理由
+	if _, err := ExamService.TransitionExam(uint(id), user.ID, action, c.PostForm("comment")); err != nil {
+		c.HTML(transitionStatus(err), "dashboard-admin.html", gin.H{
+			"error": services.ExamActionName(action) + "失败: " + err.Error(),
 		})
 		return
 	}
 
-	// 添加审批评论（可选）
-	comment := c.PostForm("comment")
-	if comment != "" {
-		commentObj := &models.Comment{
-			ExamID:  exam.ID,
-			UserID:  user.ID,
-			Content: comment,
-		}
-		examRepo.AddComment(commentObj)
-	}
-
 	// 重定向回管理员仪表板，并显示审批管理模块
-	c.Redirect(http.StatusFound, "/admin/dashboard?username="+username+"#approval")
+	c.Redirect(http.StatusFound, "/admin/dashboard#approval")
 }
 
-// HandleRejectPaper 处理拒绝试卷的请求
-func HandleRejectPaper(c *gin.Context) {
-	// 获取试卷ID
+// HandlePaperTransition 处理试卷状态变更的请求，表单字段action为操作，comment为审批意见，
+// 可执行的角色和前置条件由考试状态机检查
+func HandlePaperTransition(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		c.HTML(http.StatusBadRequest, "dashboard-admin.html", gin.H{
-			"error": "无效的试卷ID",
-		})
-		return
-	}
-
-	// 获取当前用户ID
-	username := c.GetHeader("X-Username")
-	userRepo := repositories.NewUserRepository()
-	user, err := userRepo.GetByUsername(username)
-	if err != nil {
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
-
-	// 获取试卷
-	examRepo := repositories.NewExamRepository()
-	exam, err := examRepo.GetByID(uint(id))
-	if err != nil {
-		c.HTML(http.StatusNotFound, "dashboard-admin.html", gin.H{
-			"error": "试卷不存在",
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的试卷ID",
 		})
 		return
 	}
 
-	// 更新试卷状态为已拒绝
-	exam.Status = models.StatusRejected
-	exam.ApproverID = user.ID
-
-	// 保存到数据库
-	err = examRepo.Update(exam)
+	action := c.PostForm("action")
+	exam, err := ExamService.TransitionExam(uint(id), currentUser(c).ID, action, c.PostForm("comment"))
 	if err != nil {
-		c.HTML(http.StatusInternalServerError, "dashboard-admin.html", gin.H{
-			"error": "拒绝试卷失败: " + err.Error(),
+		c.JSON(transitionStatus(err), gin.H{
+			"success": false,
+			"message": err.Error(),
 		})
 		return
 	}
 
-	// 添加拒绝理由（可选）
-	comment := c.PostForm("comment")
-	if comment != "" {
-		commentObj := &models.Comment{
-			ExamID:  exam.ID,
-			UserID:  user.ID,
-			Content: comment,
-		}
-		examRepo.AddComment(commentObj)
-	}
-
-	// 重定向回管理员仪表板，并显示审批管理模块
-	c.Redirect(http.StatusFound, "/admin/dashboard?username="+username+"#approval")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": transitionMessage(action, exam),
+		"status":  exam.Status,
+	})
 }
 
 // HandleChangePassword 处理修改密码的请求
@@ -1066,26 +890,33 @@ func HandleChangePassword(c *gin.Context) {
 	}
 
 	// 获取当前用户
-	username := c.GetHeader("X-Username")
+	user := currentUser(c)
 	userRepo := repositories.NewUserRepository()
-	user, err := userRepo.GetByUsername(username)
-	if err != nil {
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
 
 	// 验证旧密码
-	if user.Password != oldPassword {
+	if err := user.CheckPassword(oldPassword); err != nil {
 		c.HTML(http.StatusBadRequest, "dashboard-admin.html", gin.H{
 			"error": "旧密码不正确",
 		})
 		return
 	}
 
+	// 按系统设置校验新密码强度
+	if err := services.ValidatePassword(SettingsService, newPassword); err != nil {
+		c.HTML(http.StatusBadRequest, "dashboard-admin.html", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	// 更新密码
-	user.Password = newPassword
-	err = userRepo.Update(user)
-	if err != nil {
+	if err := user.SetPassword(newPassword); err != nil {
+		c.HTML(http.StatusInternalServerError, "dashboard-admin.html", gin.H{
+			"error": "修改密码失败: " + err.Error(),
+		})
+		return
+	}
+	if err := userRepo.Update(user); err != nil {
 		c.HTML(http.StatusInternalServerError, "dashboard-admin.html", gin.H{
 			"error": "修改密码失败: " + err.Error(),
 		})
@@ -1093,7 +924,7 @@ func HandleChangePassword(c *gin.Context) {
 	}
 
 	// 重定向回管理员仪表板，并显示个人中心模块
-	c.Redirect(http.StatusFound, "/admin/dashboard?username="+username+"#profile&success=true")
+	c.Redirect(http.StatusFound, "/admin/dashboard?success=true#profile")
 }
 
 // HandleDeleteUser 处理删除用户的请求
@@ -1108,35 +939,13 @@ func HandleDeleteUser(c *gin.Context) {
 		return
 	}
 
-	// 获取当前管理员用户名（从URL查询参数获取，这是重点修复）
-	username := c.Query("username")
-	if username == "" {
-		// 尝试从请求头获取
-		username = c.GetHeader("X-Username")
-		if username == "" {
-			// 尝试从cookie中获取
-			usernameCookie, err := c.Cookie("username")
-			if err == nil && usernameCookie != "" {
-				username = usernameCookie
-			}
-		}
-		if username == "" {
-			// 仍然没有找到用户名
-			log.Printf("用户删除操作未提供用户名，重定向到登录页面")
-			c.Redirect(http.StatusFound, "/login")
-			return
-		}
-	}
+	// 获取当前登录的管理员
+	adminUser := currentUser(c)
+	username := adminUser.Username
 
 	log.Printf("尝试删除用户ID: %d，操作人: %s", id, username)
 
 	userRepo := repositories.NewUserRepository()
-	adminUser, err := userRepo.GetByUsername(username)
-	if err != nil {
-		log.Printf("获取管理员用户信息失败: %v", err)
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
 
 	// 验证当前用户是否为管理员
 	if adminUser.Role != models.RoleAdmin {
@@ -1173,71 +982,59 @@ func HandleDeleteUser(c *gin.Context) {
 
 	// 如果用户是学生，需要清除与该学生相关的考试数据
 	if targetUser.Role == models.RoleStudent {
-		result := configs.DB.Where("student_id = ?", id).Delete(&models.ExamData{})
+		result := configs.DB().Where("student_id = ?", id).Delete(&models.ExamData{})
 		log.Printf("删除学生相关考试数据，影响行数: %d", result.RowsAffected)
 
 		// 删除与该学生相关的评论
-		result = configs.DB.Where("user_id = ?", id).Delete(&models.Comment{})
-		log.Printf("删除学生评论数据，影响行数: %d", result.RowsAffected)
-	}
-
-	// 如果用户是教师，确保没有学生与该教师关联
-	if targetUser.Role == models.RoleTeacher {
-		// 查找关联到该教师的学生
-		var students []models.User
-		result := configs.DB.Where("teacher_id = ?", id).Find(&students)
-		if result.Error == nil && len(students) > 0 {
-			log.Printf("该教师有 %d 名关联学生，将学生的teacher_id设为0", len(students))
-			// 更新关联的学生，将其teacher_id设为0
-			result = configs.DB.Model(&models.User{}).Where("teacher_id = ?", id).Update("teacher_id", 0)
-			log.Printf("更新学生的teacher_id，影响行数: %d", result.RowsAffected)
-		}
-
-		// 删除该教师创建的试卷以及相关数据
-		var exams []models.Exam
-		result = configs.DB.Where("creator_id = ?", id).Find(&exams)
-		if result.Error == nil && len(exams) > 0 {
-			log.Printf("该教师创建了 %d 份试卷，将删除相关数据", len(exams))
-
-			for _, exam := range exams {
-				// 删除试卷相关的ExamData
-				result = configs.DB.Where("exam_id = ?", exam.ID).Delete(&models.ExamData{})
-				log.Printf("删除试卷(%d)的ExamData，影响行数: %d", exam.ID, result.RowsAffected)
-
-				// 删除试卷相关的Comment
-				result = configs.DB.Where("exam_id = ?", exam.ID).Delete(&models.Comment{})
-				log.Printf("删除试卷(%d)的Comment，影响行数: %d", exam.ID, result.RowsAffected)
-
-				// 删除试卷相关的Paper
-				result = configs.DB.Where("exam_id = ?", exam.ID).Delete(&models.Paper{})
-				log.Printf("删除试卷(%d)的Paper，影响行数: %d", exam.ID, result.RowsAffected)
-			}
-
-			// 删除试卷
-			result = configs.DB.Delete(&models.Exam{}, "creator_id = ?", id)
-			log.Printf("删除教师创建的试卷，影响行数: %d", result.RowsAffected)
+		if deleted, err := repositories.NewCommentRepository().DeleteByUser(uint(id)); err != nil {
+			log.Printf("删除学生评论数据失败: %v", err)
+		} else {
+			log.Printf("删除学生评论数据，影响行数: %d", deleted)
 		}
 
-		// 删除该教师的评论
-		result = configs.DB.Where("user_id = ?", id).Delete(&models.Comment{})
-		log.Printf("删除教师评论，影响行数: %d", result.RowsAffected)
+		// 删除该学生的答卷提交
+		if deleted, err := repositories.NewSubmissionRepository().DeleteByStudent(uint(id)); err != nil {
+			log.Printf("删除学生答卷提交失败: %v", err)
+		} else {
+			log.Printf("删除学生答卷提交，影响行数: %d", deleted)
+		}
+		if deleted, err := repositories.NewAttemptRepository().DeleteByStudent(uint(id)); err != nil {
+			log.Printf("删除学生作答会话失败: %v", err)
+		} else {
+			log.Printf("删除学生作答会话，影响行数: %d", deleted)
+		}
 	}
 
-	// 删除用户
-	err = userRepo.Delete(uint(id))
-	if err != nil {
+	// 删除用户及其登录会话和审批委托，教师创建的考试由考试服务在同一事务中删除
+	if err := UserService.DeleteUser(idStr); err != nil {
 		log.Printf("删除用户失败: %v", err)
-		c.HTML(http.StatusInternalServerError, "dashboard-admin.html", gin.H{
+		status := http.StatusInternalServerError
+		if errors.Is(err, models.ErrPaperLocked) {
+			status = http.StatusConflict
+		}
+		c.HTML(status, "dashboard-admin.html", gin.H{
 			"error": "删除用户失败: " + err.Error(),
 			"user":  adminUser,
 		})
 		return
 	}
 
+	// 如果用户是教师，解除学生与该教师的关联并删除其评论
+	if targetUser.Role == models.RoleTeacher {
+		result := configs.DB().Model(&models.User{}).Where("teacher_id = ?", id).Update("teacher_id", 0)
+		log.Printf("更新学生的teacher_id，影响行数: %d", result.RowsAffected)
+
+		if deleted, err := repositories.NewCommentRepository().DeleteByUser(uint(id)); err != nil {
+			log.Printf("删除教师评论失败: %v", err)
+		} else {
+			log.Printf("删除教师评论，影响行数: %d", deleted)
+		}
+	}
+
 	log.Printf("成功删除用户ID: %d, 用户名: %s, 角色: %s", id, targetUser.Username, targetUser.Role)
 
 	// 重定向回管理员仪表板，并显示用户管理模块
-	c.Redirect(http.StatusFound, "/dashboard-admin?username="+adminUser.Username+"&success=1#users")
+	c.Redirect(http.StatusFound, "/dashboard-admin?success=1#users")
 }
 
 // HandleViewPaper 处理查看试卷的请求
@@ -1260,34 +1057,15 @@ func HandleViewPaper(c *gin.Context) {
 		return
 	}
 
-	// 获取当前用户名
-	username := c.GetHeader("X-Username")
-	if username == "" {
-		username = c.Query("username") // 尝试从查询参数获取
-	}
-
-	if username == "" {
-		// 如果都没有提供用户名，返回错误
+	// 获取试卷信息
+	examRepo := repositories.NewExamRepository()
+	exam, err := examRepo.GetByID(uint(id))
+	if err != nil {
+		// 判断请求类型
 		if c.GetHeader("X-Requested-With") == "XMLHttpRequest" {
-			c.JSON(http.StatusUnauthorized, gin.H{
+			c.JSON(http.StatusNotFound, gin.H{
 				"success": false,
-				"message": "用户未登录或会话已过期",
-			})
-		} else {
-			c.Redirect(http.StatusFound, "/login")
-		}
-		return
-	}
-
-	// 获取试卷信息
-	examRepo := repositories.NewExamRepository()
-	exam, err := examRepo.GetByID(uint(id))
-	if err != nil {
-		// 判断请求类型
-		if c.GetHeader("X-Requested-With") == "XMLHttpRequest" {
-			c.JSON(http.StatusNotFound, gin.H{
-				"success": false,
-				"message": "试卷不存在",
+				"message": "试卷不存在",
 			})
 		} else {
 			c.HTML(http.StatusNotFound, "dashboard-teacher.html", gin.H{
@@ -1328,25 +1106,6 @@ func HandleUpdatePaper(c *gin.Context) {
 		return
 	}
 
-	// 获取当前用户名
-	username := c.GetHeader("X-Username")
-	if username == "" {
-		username = c.Query("username") // 尝试从查询参数获取
-	}
-
-	if username == "" {
-		// 如果都没有提供用户名，返回错误
-		if c.GetHeader("X-Requested-With") == "XMLHttpRequest" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"success": false,
-				"message": "用户未登录或会话已过期",
-			})
-		} else {
-			c.Redirect(http.StatusFound, "/login")
-		}
-		return
-	}
-
 	// 获取试卷信息
 	examRepo := repositories.NewExamRepository()
 	exam, err := examRepo.GetByID(uint(id))
@@ -1365,11 +1124,29 @@ func HandleUpdatePaper(c *gin.Context) {
 		return
 	}
 
-	// 从请求体获取更新数据
+	// 只有考试创建者和管理员可以修改考试
+	if user := currentUser(c); user.Role != models.RoleAdmin && exam.CreatorID != user.ID {
+		if c.GetHeader("X-Requested-With") == "XMLHttpRequest" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "您没有修改该试卷的权限",
+			})
+		} else {
+			c.HTML(http.StatusForbidden, "dashboard-teacher.html", gin.H{
+				"error": "您没有修改该试卷的权限",
+			})
+		}
+		return
+	}
+
+	// 从请求体获取更新数据，未提供的字段保持不变
 	var updateData struct {
-		Title       string `json:"title"`
-		Course      string `json:"course"`
-		Description string `json:"description"`
+		Title       string  `json:"title"`
+		Course      string  `json:"course"`
+		Description *string `json:"description"`
+		MaxAttempts *int    `json:"max_attempts"`
+		Cooldown    *int    `json:"attempt_cooldown"`
+		ScorePolicy string  `json:"score_policy"`
 	}
 
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -1387,6 +1164,17 @@ func HandleUpdatePaper(c *gin.Context) {
 		return
 	}
 
+	// 审批通过后考试内容已锁定，标题、科目和说明不能再修改，作答次数和计分方式仍可调整
+	if exam.Locked() && ((updateData.Title != "" && updateData.Title != exam.Title) ||
+		(updateData.Course != "" && updateData.Course != exam.Course) ||
+		(updateData.Description != nil && *updateData.Description != exam.Description)) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "考试已审批通过，不能修改标题、科目和说明",
+		})
+		return
+	}
+
 	// 更新试卷信息
 	if updateData.Title != "" {
 		exam.Title = updateData.Title
@@ -1394,7 +1182,25 @@ func HandleUpdatePaper(c *gin.Context) {
 	if updateData.Course != "" {
 		exam.Course = updateData.Course
 	}
-	exam.Description = updateData.Description // 可以为空
+	if updateData.Description != nil {
+		exam.Description = *updateData.Description // 可以为空
+	}
+	if updateData.MaxAttempts != nil {
+		exam.MaxAttempts = *updateData.MaxAttempts
+	}
+	if updateData.Cooldown != nil {
+		exam.AttemptCooldown = *updateData.Cooldown
+	}
+	if updateData.ScorePolicy != "" {
+		exam.ScorePolicy = updateData.ScorePolicy
+	}
+	if err := exam.ValidateAttemptPolicy(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 
 	// 保存更新
 	err = examRepo.Update(exam)
@@ -1421,23 +1227,13 @@ func HandleUpdatePaper(c *gin.Context) {
 			"data":    exam,
 		})
 	} else {
-		redirectURL := "/dashboard-teacher?username=" + username + "#papers"
+		redirectURL := "/dashboard-teacher#papers"
 		c.Redirect(http.StatusFound, redirectURL)
 	}
 }
 
 // HandleDistributePaper 处理教师分发试卷给学生的请求
 func HandleDistributePaper(c *gin.Context) {
-	// 从请求头获取教师用户名
-	teacherUsername := c.GetHeader("X-Username")
-	if teacherUsername == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"message": "用户未登录或会话已过期",
-		})
-		return
-	}
-
 	// 获取请求体中的数据
 	var req struct {
 		ExamID     uint   `json:"examId"`
@@ -1465,8 +1261,8 @@ func HandleDistributePaper(c *gin.Context) {
 
 	// 验证请求的用户是否是试卷的创建者或管理员
 	userRepo := repositories.NewUserRepository()
-	teacher, err := userRepo.GetByUsername(teacherUsername)
-	if err != nil || (teacher.ID != exam.CreatorID && teacher.Role != "admin") {
+	teacher := currentUser(c)
+	if teacher.ID != exam.CreatorID && teacher.Role != "admin" {
 		c.JSON(http.StatusForbidden, gin.H{
 			"success": false,
 			"message": "无权分发此试卷",
@@ -1474,15 +1270,15 @@ func HandleDistributePaper(c *gin.Context) {
 		return
 	}
 
-	// 将试卷状态更新为已发布
-	exam.Status = models.StatusPublished
-	err = examRepo.Update(exam)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "更新试卷状态失败: " + err.Error(),
-		})
-		return
+	// 已审批的试卷先通过考试状态机发布，其他状态由状态机拒绝
+	if exam.Status != models.StatusPublished {
+		if _, err := ExamService.TransitionExam(exam.ID, teacher.ID, models.ExamActionPublish, ""); err != nil {
+			c.JSON(transitionStatus(err), gin.H{
+				"success": false,
+				"message": "发布试卷失败: " + err.Error(),
+			})
+			return
+		}
 	}
 
 	// 如果没有选择特定学生，则分发给所有学生
@@ -1527,20 +1323,10 @@ func HandleDistributePaper(c *gin.Context) {
 
 // HandleListStudents 获取所有学生列表
 func HandleListStudents(c *gin.Context) {
-	// 从请求头获取教师用户名
-	teacherUsername := c.GetHeader("X-Username")
-	if teacherUsername == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"message": "用户未登录或会话已过期",
-		})
-		return
-	}
-
 	// 验证请求者是否是教师或管理员
 	userRepo := repositories.NewUserRepository()
-	teacher, err := userRepo.GetByUsername(teacherUsername)
-	if err != nil || (teacher.Role != "teacher" && teacher.Role != "admin") {
+	teacher := currentUser(c)
+	if teacher.Role != "teacher" && teacher.Role != "admin" {
 		c.JSON(http.StatusForbidden, gin.H{
 			"success": false,
 			"message": "无权访问学生列表",
@@ -1575,23 +1361,9 @@ func HandleListStudents(c *gin.Context) {
 
 // HandleGetAssignedPapers 获取分配给学生的试卷列表
 func HandleGetAssignedPapers(c *gin.Context) {
-	// 从请求头获取学生用户名
-	studentUsername := c.GetHeader("X-Username")
-	if studentUsername == "" {
-		studentUsername = c.Query("username")
-		if studentUsername == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"success": false,
-				"message": "用户未登录或会话已过期",
-			})
-			return
-		}
-	}
-
 	// 验证请求者是否是学生
-	userRepo := repositories.NewUserRepository()
-	student, err := userRepo.GetByUsername(studentUsername)
-	if err != nil || student.Role != "student" {
+	student := currentUser(c)
+	if student.Role != "student" {
 		c.JSON(http.StatusForbidden, gin.H{
 			"success": false,
 			"message": "只有学生才能查看分配的试卷",
@@ -1642,24 +1414,8 @@ func HandleExamView(c *gin.Context) {
 		return
 	}
 
-	// 获取当前学生用户名
-	username := c.GetHeader("X-Username")
-	if username == "" {
-		username = c.Query("username") // 尝试从查询参数获取
-	}
-
-	if username == "" {
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
-
-	// 获取学生信息
-	userRepo := repositories.NewUserRepository()
-	student, err := userRepo.GetByUsername(username)
-	if err != nil {
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
+	// 获取当前学生信息
+	student := currentUser(c)
 
 	// 验证用户是否是学生角色
 	if student.Role != "student" {
@@ -1681,11 +1437,24 @@ func HandleExamView(c *gin.Context) {
 		return
 	}
 
-	// 验证考试是否处于已发布状态
-	if exam.Status != "published" {
+	// 验证考试是否处于已发布状态，关闭后不再接受新的作答
+	if exam.Status != models.StatusPublished {
+		message := "该考试尚未发布，无法参加"
+		if exam.Released() {
+			message = "该考试已关闭，无法参加"
+		}
 		c.HTML(http.StatusForbidden, "dashboard-student.html", gin.H{
 			"title": "学生控制面板",
-			"error": "该考试尚未发布，无法参加",
+			"error": message,
+		})
+		return
+	}
+
+	// 审批通过时锁定的试卷内容被修改过时不提供试卷
+	if err := PaperLockService.VerifyExam(exam, student.ID, models.PaperSourceExamView); err != nil {
+		c.HTML(lockStatus(err), "dashboard-student.html", gin.H{
+			"title": "学生控制面板",
+			"error": err.Error(),
 		})
 		return
 	}
@@ -1705,7 +1474,7 @@ func HandleExamView(c *gin.Context) {
 	// 如果没有提供有效的examDataId，尝试查找或创建一个
 	if examDataId == 0 {
 		// 使用事务来确保原子性
-		tx := configs.DB.Begin()
+		tx := configs.DB().Begin()
 		if tx.Error != nil {
 			log.Printf("开始事务失败: %v", tx.Error)
 			c.HTML(http.StatusInternalServerError, "dashboard-student.html", gin.H{
@@ -1748,15 +1517,70 @@ func HandleExamView(c *gin.Context) {
 		}
 	}
 
+	examData, err := repositories.NewExamDataRepository().GetByID(examDataId)
+	if err != nil || examData.StudentID != student.ID || examData.ExamID != exam.ID {
+		c.HTML(http.StatusNotFound, "dashboard-student.html", gin.H{
+			"title": "学生控制面板",
+			"error": "考试记录不存在",
+		})
+		return
+	}
+
+	// 开始或继续限时作答，记录开始时间并计算个人截止时间
+	attempt, err := AttemptService.Start(exam, examData)
+	if err != nil {
+		c.HTML(http.StatusForbidden, "dashboard-student.html", gin.H{
+			"title": "学生控制面板",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 获取考试题目，不向学生展示答案
+	questions, err := SubmissionService.ExamQuestions(exam.ID)
+	if err != nil {
+		log.Printf("获取考试题目失败: %v", err)
+	}
+	for i := range questions {
+		questions[i].HideAnswerKey()
+	}
+
+	// 按答题记录打乱题目和选项顺序，暂存的作答换算为展示的选项标签
+	shuffle := models.NewShuffle(examDataId, questions)
+
 	// 渲染考试页面，传递examDataId
 	c.HTML(http.StatusOK, "exam.html", gin.H{
 		"title":      exam.Title + " - 在线考试",
 		"exam":       exam,
 		"user":       student,
 		"examDataId": examDataId,
+		"questions":  shuffle.Apply(questions),
+		"remaining":  remainingSeconds(attempt),
+		"draft":      shuffle.ToDisplay(attempt.Responses()),
+		"version":    attempt.DraftVersion,
 	})
 }
 
+// draftVersion 返回作答会话的暂存版本，没有会话时返回0
+func draftVersion(attempt *models.ExamAttempt) int {
+	if attempt == nil {
+		return 0
+	}
+	return attempt.DraftVersion
+}
+
+// remainingSeconds 返回作答会话的剩余秒数，没有会话或不限时返回-1
+func remainingSeconds(attempt *models.ExamAttempt) int {
+	if attempt == nil {
+		return -1
+	}
+	remaining, limited := attempt.Remaining(time.Now())
+	if !limited {
+		return -1
+	}
+	return int(remaining.Seconds())
+}
+
 // HandleExamSubmit 处理学生提交考试答案的请求
 func HandleExamSubmit(c *gin.Context) {
 	// 获取考试ID
@@ -1770,27 +1594,8 @@ func HandleExamSubmit(c *gin.Context) {
 		return
 	}
 
-	// 获取当前学生用户名
-	username := c.GetHeader("X-Username")
-	if username == "" {
-		username = c.PostForm("username") // 从表单获取
-		if username == "" {
-			username = c.Query("username") // 尝试从查询参数获取
-		}
-	}
-
-	if username == "" {
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
-
-	// 获取学生信息
-	userRepo := repositories.NewUserRepository()
-	student, err := userRepo.GetByUsername(username)
-	if err != nil {
-		c.Redirect(http.StatusFound, "/login")
-		return
-	}
+	// 获取当前学生信息
+	student := currentUser(c)
 
 	// 验证用户是否是学生角色
 	if student.Role != "student" {
@@ -1812,88 +1617,162 @@ func HandleExamSubmit(c *gin.Context) {
 		return
 	}
 
-	// 获取学生提交的答案
-	answer := c.PostForm("answer")
-	if answer == "" {
-		c.HTML(http.StatusBadRequest, "exam.html", gin.H{
-			"title": exam.Title + " - 在线考试",
-			"exam":  exam,
-			"user":  student,
-			"error": "答案不能为空",
+	// 试卷内容被修改过时不按其评分
+	if err := PaperLockService.VerifyExam(exam, student.ID, models.PaperSourceExamSubmit); err != nil {
+		c.HTML(lockStatus(err), "dashboard-student.html", gin.H{
+			"title": "学生控制面板",
+			"error": err.Error(),
 		})
 		return
 	}
 
-	// 检查是否有已存在的ExamData记录
-	examDataRepo := repositories.NewExamDataRepository()
-	var examData *models.ExamData
+	// 获取考试题目，按题目收集学生的作答
+	questions, err := SubmissionService.ExamQuestions(exam.ID)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "dashboard-student.html", gin.H{
+			"title": "学生控制面板",
+			"error": "获取考试题目失败: " + err.Error(),
+		})
+		return
+	}
 
-	// 尝试从表单获取examDataId
-	examDataIdStr := c.PostForm("examDataId")
-	if examDataIdStr != "" {
-		examDataIdUint, parseErr := strconv.ParseUint(examDataIdStr, 10, 32)
-		if parseErr == nil {
-			// 如果提供了有效的examDataId，尝试获取该记录
-			existingExamData, getErr := examDataRepo.GetByID(uint(examDataIdUint))
-			if getErr == nil && existingExamData.StudentID == student.ID && existingExamData.ExamID == exam.ID {
-				examData = existingExamData
-				log.Printf("使用已存在的ExamData记录(ID: %d)提交答案", examData.ID)
-			}
+	responses := make(map[uint]string)
+	if len(questions) == 0 {
+		responses[0] = c.PostForm("answer")
+	}
+	for _, question := range questions {
+		// 多选题会提交多个同名字段
+		values := c.PostFormArray("answer_" + strconv.FormatUint(uint64(question.ID), 10))
+		if len(values) > 0 {
+			responses[question.ID] = strings.Join(values, ",")
 		}
 	}
 
-	// 如果没有找到有效的ExamData记录，创建一个新的
-	if examData == nil {
-		examData = &models.ExamData{
-			ExamID:     exam.ID,
-			StudentID:  student.ID,
-			Title:      exam.Title,
-			Course:     exam.Course,
-			TotalScore: 0.0,                  // 初始分数为0，等待教师批阅
-			Status:     models.StatusPending, // 设置为待批阅状态
+	// 查找作答会话所属的答题记录。答题记录在进入考试开始作答时创建，
+	// 找不到时说明没有进行中的作答，不能在交卷时创建，否则只会留下没有提交的记录
+	examDataRepo := repositories.NewExamDataRepository()
+	var examData *models.ExamData
+	if examDataID, parseErr := strconv.ParseUint(c.PostForm("examDataId"), 10, 32); parseErr == nil {
+		existingExamData, getErr := examDataRepo.GetByID(uint(examDataID))
+		if getErr == nil && existingExamData.StudentID == student.ID && existingExamData.ExamID == exam.ID {
+			examData = existingExamData
 		}
-
-		// 保存到数据库
-		if err := configs.DB.Create(examData).Error; err != nil {
-			c.HTML(http.StatusInternalServerError, "dashboard-student.html", gin.H{
+	}
+	if examData == nil {
+		existingExamData, getErr := examRepo.GetExamDataByExamAndStudent(exam.ID, student.ID)
+		if getErr != nil {
+			c.HTML(http.StatusForbidden, "dashboard-student.html", gin.H{
 				"title": "学生控制面板",
-				"error": "创建答题记录失败: " + err.Error(),
+				"error": "提交答案失败: " + services.ErrNoActiveAttempt.Error(),
 			})
 			return
 		}
-
-		log.Printf("创建新的ExamData记录(ID: %d)提交答案", examData.ID)
-	} else {
-		// 如果是使用已有记录，更新状态为pending
-		examData.Status = models.StatusPending
-		if err := configs.DB.Save(examData).Error; err != nil {
-			log.Printf("更新ExamData状态失败: %v", err)
-		}
-	}
-
-	// 添加答案到Comment表中，关联到ExamData
-	comment := &models.Comment{
-		ExamID:    exam.ID,
-		UserID:    student.ID,
-		Content:   answer,
-		CreatedAt: time.Now(),
+		examData = existingExamData
 	}
 
-	// 保存答案内容
-	if err := configs.DB.Create(comment).Error; err != nil {
-		c.HTML(http.StatusInternalServerError, "dashboard-student.html", gin.H{
+	// 结束作答会话并保存本次提交的各题作答，自动评分客观题
+	submission, err := AttemptService.Submit(exam, examData, responses)
+	if errors.Is(err, services.ErrNoActiveAttempt) || errors.Is(err, services.ErrAttemptExpired) {
+		c.HTML(http.StatusForbidden, "dashboard-student.html", gin.H{
 			"title": "学生控制面板",
 			"error": "提交答案失败: " + err.Error(),
 		})
 		return
 	}
+	// 校验失败时会话保持作答中，带着题目重新渲染考试页面
+	if err != nil {
+		attempt, _ := AttemptService.Start(exam, examData)
+		for i := range questions {
+			questions[i].HideAnswerKey()
+		}
+		c.HTML(http.StatusBadRequest, "exam.html", gin.H{
+			"title":      exam.Title + " - 在线考试",
+			"exam":       exam,
+			"user":       student,
+			"examDataId": examData.ID,
+			"questions":  models.NewShuffle(examData.ID, questions).Apply(questions),
+			"remaining":  remainingSeconds(attempt),
+			"draft":      responses,
+			"version":    draftVersion(attempt),
+			"error":      "提交答案失败: " + err.Error(),
+		})
+		return
+	}
 
 	// 添加日志记录
-	log.Printf("学生 %s (ID: %d) 提交了考试 %s (ID: %d) 的答案，ExamData ID: %d",
-		student.Username, student.ID, exam.Title, exam.ID, examData.ID)
+	log.Printf("学生 %s (ID: %d) 提交了考试 %s (ID: %d) 的答案，ExamData ID: %d，第%d次提交，自动评分 %.1f 分，状态: %s，逾期: %t",
+		student.Username, student.ID, exam.Title, exam.ID, examData.ID, submission.Attempt, submission.Score, submission.Status, submission.Late)
 
 	// 重定向回学生控制面板
-	c.Redirect(http.StatusFound, "/dashboard-student?username="+username)
+	c.Redirect(http.StatusFound, "/dashboard-student")
+}
+
+// HandleExamAutosave 自动保存学生作答中的答案，请求中的 version 与服务器上的暂存版本不一致时返回409
+func HandleExamAutosave(c *gin.Context) {
+	var req struct {
+		ExamDataID uint              `json:"examDataId"`
+		Version    int               `json:"version"`
+		Answers    map[string]string `json:"answers"` // 以题目ID为键，多选题为以逗号分隔的选项标签
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	student := currentUser(c)
+	examData, err := repositories.NewExamDataRepository().GetByID(req.ExamDataID)
+	if err != nil || examData.StudentID != student.ID || strconv.FormatUint(uint64(examData.ExamID), 10) != c.Param("id") {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "考试记录不存在",
+		})
+		return
+	}
+
+	responses := make(map[uint]string, len(req.Answers))
+	for key, value := range req.Answers {
+		questionID, err := strconv.ParseUint(key, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "无效的题目ID: " + key,
+			})
+			return
+		}
+		responses[uint(questionID)] = value
+	}
+
+	attempt, err := AttemptService.SaveDraft(examData, req.Version, responses)
+	switch {
+	case errors.Is(err, services.ErrDraftConflict):
+		// 其他页面暂存的作答换算为本页展示的选项标签
+		questions, _ := SubmissionService.ExamQuestions(examData.ExamID)
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"version": attempt.DraftVersion,
+			"answers": models.NewShuffle(examData.ID, questions).ToDisplay(attempt.Responses()),
+		})
+	case errors.Is(err, services.ErrNoActiveAttempt) || errors.Is(err, services.ErrAttemptExpired):
+		c.JSON(http.StatusGone, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "保存失败: " + err.Error(),
+		})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"version": attempt.DraftVersion,
+			"savedAt": attempt.DraftSavedAt.Format("15:04:05"),
+		})
+	}
 }
 
 // HandleGetExamData 获取试卷数据和学生答案
@@ -1910,21 +1789,8 @@ func HandleGetExamData(c *gin.Context) {
 	}
 
 	// 验证教师身份
-	username := c.GetHeader("X-Username")
-	if username == "" {
-		username = c.Query("username")
-		if username == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"success": false,
-				"message": "未登录或会话已过期",
-			})
-			return
-		}
-	}
-
-	userRepo := repositories.NewUserRepository()
-	teacher, err := userRepo.GetByUsername(username)
-	if err != nil || teacher.Role != "teacher" {
+	teacher := currentUser(c)
+	if teacher.Role != "teacher" {
 		c.JSON(http.StatusForbidden, gin.H{
 			"success": false,
 			"message": "无权访问该资源",
@@ -1952,32 +1818,48 @@ func HandleGetExamData(c *gin.Context) {
 		return
 	}
 
-	// 查询学生答案
-	commentRepo := repositories.NewCommentRepository()
-	comments, err := commentRepo.GetCommentsByExamID(examData.ExamID)
+	// 试卷内容被修改过时不提供答卷
+	if err := PaperLockService.VerifyExam(&examData.Exam, teacher.ID, models.PaperSourceExamData); err != nil {
+		c.JSON(lockStatus(err), gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 
+	// 查询学生最近一次提交的答案
 	var answer string
-	if err == nil && len(comments) > 0 {
-		// 找到该学生提交的最新答案
-		for i := len(comments) - 1; i >= 0; i-- {
-			if comments[i].UserID == examData.StudentID {
-				answer = comments[i].Content
-				break
-			}
-		}
+	var submission *models.Submission
+	if latest, err := SubmissionService.GetLatest(examData.ID); err == nil {
+		submission = latest
+		answer = latest.Text()
+	}
+
+	// 考试总分和及格分用于评分校验
+	total, passing, err := GradingService.ScoreRange(examData.ExamID)
+	if err != nil {
+		log.Printf("获取考试总分失败: %v", err)
+	}
+
+	response := gin.H{
+		"id":            examData.ID,
+		"title":         examData.Title,
+		"course":        examData.Course,
+		"student":       examData.Student,
+		"exam":          examData.Exam,
+		"status":        examData.Status,
+		"answer":        answer,
+		"submission":    submission,
+		"score":         examData.TotalScore,
+		"total_score":   total,
+		"passing_score": passing,
+	}
+	if examData.Status == models.StatusApproved {
+		response["grade"] = models.MapGrade(SettingsService.Get().GradeScale, examData.TotalScore, total, passing)
 	}
 
 	// 返回试卷数据和学生答案
-	c.JSON(http.StatusOK, gin.H{
-		"id":      examData.ID,
-		"title":   examData.Title,
-		"course":  examData.Course,
-		"student": examData.Student,
-		"exam":    examData.Exam,
-		"status":  examData.Status,
-		"answer":  answer,
-		"score":   examData.TotalScore,
-	})
+	c.JSON(http.StatusOK, response)
 }
 
 // HandleGradeExam 处理教师评分
@@ -1989,9 +1871,10 @@ func HandleGradeExam(c *gin.Context) {
 
 	// 获取请求数据
 	var req struct {
-		ExamDataID uint    `json:"examDataId"` // 保持与前端字段名一致：examDataId
-		Score      float64 `json:"score"`
-		Comment    string  `json:"comment"`
+		ExamDataID uint                   `json:"examDataId"` // 保持与前端字段名一致：examDataId
+		Answers    []services.AnswerGrade `json:"answers"`    // 各题评分
+		Score      *float64               `json:"score"`      // 仅用于没有结构化题目的整卷作答
+		Comment    string                 `json:"comment"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -2003,30 +1886,16 @@ func HandleGradeExam(c *gin.Context) {
 		return
 	}
 
-	log.Printf("成功解析评分请求: examDataId=%d, score=%.1f", req.ExamDataID, req.Score)
+	log.Printf("成功解析评分请求: examDataId=%d, 评分题目数=%d", req.ExamDataID, len(req.Answers))
 
-	// 验证评分是否在有效范围
-	if req.Score < 0 || req.Score > 100 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "评分必须在0-100之间",
-		})
-		return
+	// 兼容只提交总分的整卷作答评分
+	if len(req.Answers) == 0 && req.Score != nil {
+		req.Answers = []services.AnswerGrade{{Score: req.Score}}
 	}
 
 	// 验证教师身份
-	username := c.GetHeader("X-Username")
-	if username == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"message": "未登录或会话已过期",
-		})
-		return
-	}
-
-	userRepo := repositories.NewUserRepository()
-	teacher, err := userRepo.GetByUsername(username)
-	if err != nil || teacher.Role != "teacher" {
+	teacher := currentUser(c)
+	if teacher.Role != "teacher" {
 		c.JSON(http.StatusForbidden, gin.H{
 			"success": false,
 			"message": "无权进行评分操作",
@@ -2054,14 +1923,11 @@ func HandleGradeExam(c *gin.Context) {
 		return
 	}
 
-	// 更新试卷数据的分数和状态
-	examData.TotalScore = req.Score
-	examData.Status = models.StatusApproved // 设置为已批阅状态
-	examData.ApproverID = teacher.ID
-
-	if err := examDataRepo.Update(examData); err != nil {
+	// 保存各题评分，总分由各题得分汇总，全部评分后设置为已批阅状态
+	submission, err := GradingService.Grade(examData, teacher.ID, req.Answers)
+	if err != nil {
 		log.Printf("更新试卷评分失败: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": "更新试卷评分失败: " + err.Error(),
 		})
@@ -2072,10 +1938,13 @@ func HandleGradeExam(c *gin.Context) {
 	if req.Comment != "" {
 		commentRepo := repositories.NewCommentRepository()
 		comment := &models.Comment{
-			ExamID:    examData.ExamID,
-			UserID:    teacher.ID,
-			Content:   req.Comment,
-			CreatedAt: time.Now(),
+			ExamID:     examData.ExamID,
+			ExamDataID: examData.ID,
+			UserID:     teacher.ID,
+			Kind:       models.CommentKindGrading,
+			Visibility: models.CommentVisibilityPublic,
+			Content:    req.Comment,
+			CreatedAt:  time.Now(),
 		}
 
 		if err := commentRepo.Create(comment); err != nil {
@@ -2088,11 +1957,12 @@ func HandleGradeExam(c *gin.Context) {
 	log.Printf("教师 %s(ID:%d) 对学生 %s(ID:%d) 的试卷 %s(ID:%d) 评分为 %.1f 分",
 		teacher.Username, teacher.ID,
 		examData.Student.Username, examData.Student.ID,
-		examData.Title, examData.ExamID, req.Score)
+		examData.Title, examData.ExamID, submission.Score)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "试卷评分成功",
+		"score":   submission.Score,
 	})
 }
 
@@ -2108,31 +1978,8 @@ func HandleExamResult(c *gin.Context) {
 		return
 	}
 
-	// 尝试从请求头获取用户名
-	username := c.GetHeader("X-Username")
-	if username == "" {
-		// 如果请求头没有，尝试从查询参数获取
-		username = c.Query("username")
-		if username == "" {
-			// 如果都没有提供，返回错误
-			c.JSON(http.StatusBadRequest, gin.H{
-				"success": false,
-				"message": "用户名不能为空",
-			})
-			return
-		}
-	}
-
-	// 验证用户身份
-	userRepo := repositories.NewUserRepository()
-	user, err := userRepo.GetByUsername(username)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"message": "用户验证失败",
-		})
-		return
-	}
+	// 获取当前用户
+	user := currentUser(c)
 
 	// 检查用户是否是学生
 	if user.Role != "student" {
@@ -2184,7 +2031,7 @@ func HandleExamResult(c *gin.Context) {
 	}
 
 	// u68c0u67e5u8bd5u5377u662fu5426u5c5eu4e8eu5f53u524du5b66u751f
-	if exam.Status != "published" {
+	if !exam.Released() {
 		c.JSON(http.StatusForbidden, gin.H{
 			"success": false,
 			"message": "该试卷尚未发布，无法查看评分详情",
@@ -2192,6 +2039,15 @@ func HandleExamResult(c *gin.Context) {
 		return
 	}
 
+	// 试卷内容被修改过时不提供评分详情
+	if err := PaperLockService.VerifyExam(exam, user.ID, models.PaperSourceExamResult); err != nil {
+		c.JSON(lockStatus(err), gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
 	// 准备基本的返回数据
 	responseData := gin.H{
 		"success":    true,
@@ -2204,36 +2060,56 @@ func HandleExamResult(c *gin.Context) {
 		"updated_at": examData.UpdatedAt,
 	}
 
-	// 仅当试卷已批阅时才返回评分和评语
-	if examData.Status == models.StatusApproved {
-		// 获取教师评语
-		commentRepo := repositories.NewCommentRepository()
-		comments, _ := commentRepo.ListByExamID(examData.ExamID)
-
-		// 查找教师评语（教师的评论，非学生自己的评论）
-		var comment string
-		var teacherID uint = examData.ApproverID // 批阅教师ID
-
-		if len(comments) > 0 {
-			// 查找教师的最新评语
-			var newestTeacherComment *models.Comment
-			for i := range comments {
-				// 只查找教师评论（非学生自己提交的答案）
-				if comments[i].UserID == teacherID {
-					if newestTeacherComment == nil || comments[i].CreatedAt.After(newestTeacherComment.CreatedAt) {
-						newestTeacherComment = &comments[i]
-					}
-				}
+	// 返回学生最近一次提交的答案，批阅完成后才包含各题得分、评分标准得分和反馈
+	if submission, err := SubmissionService.GetLatest(examData.ID); err == nil {
+		for i := range submission.Answers {
+			if submission.Answers[i].Question != nil {
+				submission.Answers[i].Question.HideAnswerKey()
 			}
+		}
+		if examData.Status != models.StatusApproved {
+			submission.HideGrades()
+		}
+		responseData["submission"] = submission
+	}
 
-			if newestTeacherComment != nil {
-				comment = newestTeacherComment.Content
+	// 返回各次提交的作答记录，批阅完成前不返回得分
+	if submissions, err := SubmissionService.List(examData.ID); err == nil {
+		attempts := make([]gin.H, 0, len(submissions))
+		for _, submission := range submissions {
+			attempt := gin.H{
+				"attempt":        submission.Attempt,
+				"submitted_at":   submission.SubmittedAt,
+				"status":         submission.Status,
+				"late":           submission.Late,
+				"auto_submitted": submission.AutoSubmitted,
+				"score":          nil,
 			}
+			if examData.Status == models.StatusApproved && submission.Status == models.SubmissionGraded {
+				attempt["score"] = submission.Score
+			}
+			attempts = append(attempts, attempt)
 		}
+		responseData["attempts"] = attempts
+		responseData["score_policy"] = exam.ScorePolicy
+		responseData["max_attempts"] = exam.MaxAttempts
+	}
 
-		// 添加评分和评语信息
+	// 仅当试卷已批阅时才返回评分和评语
+	if examData.Status == models.StatusApproved {
+		// 获取批阅评语
+		var comment string
+		if feedback, err := repositories.NewCommentRepository().LatestFeedback(examData); err == nil {
+			comment = feedback.Content
+		}
+
+		// 添加评分、等级和评语信息
 		responseData["score"] = examData.TotalScore
 		responseData["comment"] = comment
+		if report, err := GradingService.Report(examData); err == nil {
+			responseData["total_score"] = report.TotalScore
+			responseData["grade"] = report
+		}
 	} else {
 		// 未批阅或待批阅试卷不返回分数和评语
 		responseData["score"] = 0
@@ -2257,23 +2133,10 @@ func HandleGetStudentExams(c *gin.Context) {
 		return
 	}
 
-	// 获取教师用户名，验证权限
-	teacherUsername := c.GetHeader("X-Username")
-	if teacherUsername == "" {
-		teacherUsername = c.Query("username")
-		if teacherUsername == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"success": false,
-				"message": "用户未登录或会话已过期",
-			})
-			return
-		}
-	}
-
 	// 验证请求者是否是教师或管理员
 	userRepo := repositories.NewUserRepository()
-	teacher, err := userRepo.GetByUsername(teacherUsername)
-	if err != nil || (teacher.Role != "teacher" && teacher.Role != "admin") {
+	teacher := currentUser(c)
+	if teacher.Role != "teacher" && teacher.Role != "admin" {
 		c.JSON(http.StatusForbidden, gin.H{
 			"success": false,
 			"message": "无权访问学生试卷",
@@ -2302,27 +2165,27 @@ func HandleGetStudentExams(c *gin.Context) {
 		return
 	}
 
-	// 获取评论数据
+	// 获取批阅评语
 	commentRepo := repositories.NewCommentRepository()
+	grades := gradeReports(examDataList)
 
 	// 格式化试卷数据
 	var formattedExams []gin.H
 	for _, examData := range examDataList {
-		// 获取试卷的评论
-		comments, _ := commentRepo.GetCommentsByExamID(examData.ExamID)
+		// 查找学生最近一次提交的答案
 		var answerText, commentText string
+		if submission, err := SubmissionService.GetLatest(examData.ID); err == nil {
+			answerText = submission.Text()
+		}
 
-		// 查找学生的答案和教师评语
-		for _, comment := range comments {
-			// 通过用户ID查询用户角色
-			user, _ := userRepo.GetByID(comment.UserID)
-			if user != nil {
-				if user.Role == "student" {
-					answerText = comment.Content
-				} else if user.Role == "teacher" {
-					commentText = comment.Content
-				}
-			}
+		// 查找批阅评语
+		if feedback, err := commentRepo.LatestFeedback(&examData); err == nil {
+			commentText = feedback.Content
+		}
+
+		var grade interface{}
+		if report, ok := grades[examData.ID]; ok {
+			grade = report
 		}
 
 		formattedExams = append(formattedExams, gin.H{
@@ -2334,6 +2197,7 @@ func HandleGetStudentExams(c *gin.Context) {
 			"studentName": student.Name,
 			"status":      examData.Status,
 			"score":       examData.TotalScore,
+			"grade":       grade,
 			"answer":      answerText,
 			"comment":     commentText,
 			"createdAt":   examData.CreatedAt.Format("2006-01-02 15:04:05"),
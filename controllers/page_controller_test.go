@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/migrations"
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+	"github.com/exam-approval-system/services"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	// 迁移和服务的日志与测试结果无关
+	log.SetOutput(io.Discard)
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// setupPageServices 为测试创建执行过全部迁移的SQLite数据库，并设置交卷用到的页面控制器依赖
+func setupPageServices(t *testing.T) {
+	t.Helper()
+	savedConfig := configs.Database
+	configs.Database = configs.DatabaseConfig{Driver: configs.DriverSQLite, DSN: filepath.Join(t.TempDir(), "exam.db")}
+	db, err := configs.OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.NewMigrator(db).Up(); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+	saved := configs.SetDB(db)
+	savedSubmission, savedAttempt, savedLock := SubmissionService, AttemptService, PaperLockService
+	t.Cleanup(func() {
+		configs.SetDB(saved).Close()
+		configs.Database = savedConfig
+		SubmissionService, AttemptService, PaperLockService = savedSubmission, savedAttempt, savedLock
+	})
+
+	paperRepo := repositories.NewPaperRepository()
+	examDataRepo := repositories.NewExamDataRepository()
+	settings, err := services.NewSettingsService(repositories.NewSettingsRepository(), repositories.NewUserRepository())
+	if err != nil {
+		t.Fatal(err)
+	}
+	SubmissionService = services.NewSubmissionService(repositories.NewSubmissionRepository(), paperRepo, examDataRepo)
+	AttemptService = services.NewAttemptService(repositories.NewAttemptRepository(), paperRepo, examDataRepo, SubmissionService, settings)
+	PaperLockService = services.NewPaperLockService(paperRepo)
+}
+
+func TestHandleExamSubmit(t *testing.T) {
+	tests := []struct {
+		name       string
+		assigned   bool // 学生是否已有答题记录
+		started    bool // 是否已开始作答
+		wantStatus int
+		wantError  string
+	}{
+		{name: "开始作答后交卷", assigned: true, started: true, wantStatus: http.StatusFound},
+		{name: "已分配但未开始作答", assigned: true, wantStatus: http.StatusForbidden, wantError: services.ErrNoActiveAttempt.Error()},
+		{name: "没有答题记录", wantStatus: http.StatusForbidden, wantError: services.ErrNoActiveAttempt.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupPageServices(t)
+			student := &models.User{Username: "student", Password: "x", Name: "学生", Role: models.RoleStudent}
+			if err := repositories.NewUserRepository().Create(student); err != nil {
+				t.Fatal(err)
+			}
+			exam := &models.Exam{
+				Title: "随堂测验", Course: "数学", Status: models.StatusPublished,
+				StartTime: time.Now().Add(-time.Hour), EndTime: time.Now().Add(time.Hour),
+			}
+			if err := configs.DB().Create(exam).Error; err != nil {
+				t.Fatal(err)
+			}
+			examData := &models.ExamData{ExamID: exam.ID, StudentID: student.ID, Title: exam.Title, Status: "assigned"}
+			if tt.assigned {
+				if err := configs.DB().Create(examData).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.started {
+				if _, err := AttemptService.Start(exam, examData); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			router := gin.New()
+			router.LoadHTMLGlob("../templates/*")
+			router.POST("/student/submit-exam/:id", func(c *gin.Context) { c.Set("user", student) }, HandleExamSubmit)
+			form := url.Values{"answer": {"答案"}}
+			req := httptest.NewRequest(http.MethodPost, "/student/submit-exam/"+strconv.FormatUint(uint64(exam.ID), 10), strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("状态码 = %d, 期望 %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantError != "" && !strings.Contains(w.Body.String(), tt.wantError) {
+				t.Errorf("页面未显示错误 %q", tt.wantError)
+			}
+			// 交卷失败时不创建答题记录
+			var count int
+			configs.DB().Model(&models.ExamData{}).Where("exam_id = ?", exam.ID).Count(&count)
+			if want := map[bool]int{true: 1, false: 0}[tt.assigned]; count != want {
+				t.Errorf("答题记录有 %d 条, 期望 %d 条", count, want)
+			}
+		})
+	}
+}
+
+func TestHandleUpdatePaper(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          string
+		actor           string
+		body            string
+		wantStatus      int
+		wantTitle       string
+		wantDescription string
+	}{
+		{name: "已审批的考试只修改作答次数", status: models.StatusApproved, actor: "owner", body: `{"title":"期中考试","max_attempts":3}`,
+			wantStatus: http.StatusOK, wantTitle: "期中考试", wantDescription: "闭卷"},
+		{name: "已审批的考试不能修改说明", status: models.StatusApproved, actor: "owner", body: `{"description":"开卷"}`,
+			wantStatus: http.StatusConflict, wantTitle: "期中考试", wantDescription: "闭卷"},
+		{name: "未提供说明时保持不变", status: models.StatusDraft, actor: "owner", body: `{"title":"期末考试"}`,
+			wantStatus: http.StatusOK, wantTitle: "期末考试", wantDescription: "闭卷"},
+		{name: "清空说明", status: models.StatusDraft, actor: "owner", body: `{"description":""}`,
+			wantStatus: http.StatusOK, wantTitle: "期中考试", wantDescription: ""},
+		{name: "管理员修改", status: models.StatusDraft, actor: "admin", body: `{"description":"开卷"}`,
+			wantStatus: http.StatusOK, wantTitle: "期中考试", wantDescription: "开卷"},
+		{name: "其他教师不能修改", status: models.StatusDraft, actor: "other", body: `{"title":"期末考试"}`,
+			wantStatus: http.StatusForbidden, wantTitle: "期中考试", wantDescription: "闭卷"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupPageServices(t)
+			userRepo := repositories.NewUserRepository()
+			users := map[string]*models.User{
+				"owner": {Username: "owner", Password: "x", Role: models.RoleTeacher},
+				"other": {Username: "other", Password: "x", Role: models.RoleTeacher},
+				"admin": {Username: "admin", Password: "x", Role: models.RoleAdmin},
+			}
+			for _, user := range users {
+				if err := userRepo.Create(user); err != nil {
+					t.Fatal(err)
+				}
+			}
+			exam := &models.Exam{Title: "期中考试", Course: "数学", Description: "闭卷", Status: tt.status, CreatorID: users["owner"].ID,
+				StartTime: time.Now().Add(time.Hour), EndTime: time.Now().Add(2 * time.Hour)}
+			if err := configs.DB().Create(exam).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			router := gin.New()
+			router.LoadHTMLGlob("../templates/*")
+			router.POST("/teacher/papers/update/:id", func(c *gin.Context) { c.Set("user", users[tt.actor]) }, HandleUpdatePaper)
+			req := httptest.NewRequest(http.MethodPost, "/teacher/papers/update/"+strconv.FormatUint(uint64(exam.ID), 10), strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Requested-With", "XMLHttpRequest")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("状态码 = %d, 期望 %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			saved, err := repositories.NewExamRepository().GetByID(exam.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.Title != tt.wantTitle || saved.Description != tt.wantDescription {
+				t.Errorf("标题和说明 = %q、%q, 期望 %q、%q", saved.Title, saved.Description, tt.wantTitle, tt.wantDescription)
+			}
+		})
+	}
+}
+
+func TestHandleDeleteUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string // 教师创建的考试的状态
+		wantStatus int
+		wantKept   bool
+	}{
+		{name: "删除教师及其草稿考试", status: models.StatusDraft, wantStatus: http.StatusFound},
+		{name: "教师有已审批的考试", status: models.StatusApproved, wantStatus: http.StatusConflict, wantKept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupPageServices(t)
+			savedUser := UserService
+			t.Cleanup(func() { UserService = savedUser })
+			userRepo := repositories.NewUserRepository()
+			examRepo := repositories.NewExamRepository()
+			submissionRepo := repositories.NewSubmissionRepository()
+			attemptRepo := repositories.NewAttemptRepository()
+			approvalRepo := repositories.NewApprovalRepository()
+			settings, err := services.NewSettingsService(repositories.NewSettingsRepository(), userRepo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stateMachine := services.NewExamStateMachine(examRepo, userRepo, submissionRepo, attemptRepo,
+				services.NewApprovalService(approvalRepo, examRepo, userRepo, settings), PaperLockService)
+			examService := services.NewExamService(examRepo, userRepo, repositories.NewPaperRepository(), submissionRepo, attemptRepo, stateMachine)
+			UserService = services.NewUserService(userRepo, repositories.NewSessionRepository(), approvalRepo, examService, settings)
+
+			admin := &models.User{Username: "admin", Password: "x", Role: models.RoleAdmin}
+			teacher := &models.User{Username: "teacher", Password: "x", Role: models.RoleTeacher}
+			for _, user := range []*models.User{admin, teacher} {
+				if err := userRepo.Create(user); err != nil {
+					t.Fatal(err)
+				}
+			}
+			student := &models.User{Username: "student", Password: "x", Role: models.RoleStudent, TeacherID: teacher.ID}
+			if err := userRepo.Create(student); err != nil {
+				t.Fatal(err)
+			}
+			exam := &models.Exam{Title: "期中考试", Course: "数学", Status: tt.status, CreatorID: teacher.ID,
+				StartTime: time.Now().Add(time.Hour), EndTime: time.Now().Add(2 * time.Hour)}
+			if err := configs.DB().Create(exam).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			router := gin.New()
+			router.LoadHTMLGlob("../templates/*")
+			router.POST("/admin/users/delete/:id", func(c *gin.Context) { c.Set("user", admin) }, HandleDeleteUser)
+			req := httptest.NewRequest(http.MethodPost, "/admin/users/delete/"+strconv.FormatUint(uint64(teacher.ID), 10), nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("状态码 = %d, 期望 %d", w.Code, tt.wantStatus)
+			}
+			_, teacherErr := userRepo.GetByID(teacher.ID)
+			_, examErr := examRepo.GetByID(exam.ID)
+			if (teacherErr == nil) != tt.wantKept || (examErr == nil) != tt.wantKept {
+				t.Errorf("教师和考试保留 = %v、%v, 期望 %v", teacherErr == nil, examErr == nil, tt.wantKept)
+			}
+			// 删除失败时学生仍关联到该教师
+			saved, err := userRepo.GetByID(student.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[bool]uint{true: teacher.ID, false: 0}[tt.wantKept]; saved.TeacherID != want {
+				t.Errorf("学生的教师 = %d, 期望 %d", saved.TeacherID, want)
+			}
+		})
+	}
+}
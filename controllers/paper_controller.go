@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -12,36 +13,56 @@ import (
 
 // PaperController 试卷控制器
 type PaperController struct {
-	paperService services.PaperService
-	examService  services.ExamService
-	authService  services.AuthService
+	paperService     services.PaperService
+	examService      services.ExamService
+	paperLockService services.PaperLockService
+	authService      services.AuthService
 }
 
 // NewPaperController 创建试卷控制器
-func NewPaperController(paperService services.PaperService, examService services.ExamService, authService services.AuthService) *PaperController {
+func NewPaperController(paperService services.PaperService, examService services.ExamService,
+	paperLockService services.PaperLockService, authService services.AuthService) *PaperController {
 	return &PaperController{
-		paperService: paperService,
-		examService:  examService,
-		authService:  authService,
+		paperService:     paperService,
+		examService:      examService,
+		paperLockService: paperLockService,
+		authService:      authService,
 	}
 }
 
 // RegisterRoutes 注册路由
 func (c *PaperController) RegisterRoutes(router *gin.Engine) {
-	paper := router.Group("/api/papers", middlewares.AuthMiddleware())
+	paper := router.Group("/api/papers", middlewares.AuthMiddleware(c.authService))
 	{
 		// 公共路由
-		paper.GET("/:id", c.GetPaper)
-		paper.GET("/exam/:exam_id", c.GetPapersByExam)
 		paper.GET("/:id/verify", c.VerifyPaperSignature)
 
 		// 教师路由
 		teacher := paper.Group("/", middlewares.RoleMiddleware(models.RoleTeacher))
 		{
 			teacher.POST("", c.CreatePaper)
+			teacher.POST("/generate", c.GeneratePaper)
 			teacher.PUT("/:id", c.UpdatePaper)
 			teacher.DELETE("/:id", c.DeletePaper)
 			teacher.POST("/:id/sign", c.SignPaper)
+			teacher.POST("/:id/revisions/:revision/restore", c.RestoreRevision)
+		}
+
+		// 教师和管理员查看试卷及其历史版本，学生只能在考试开放期间通过考试页面作答
+		staff := paper.Group("/", middlewares.RoleMiddleware(models.RoleTeacher, models.RoleAdmin))
+		{
+			staff.GET("/:id", c.GetPaper)
+			staff.GET("/exam/:exam_id", c.GetPapersByExam)
+			staff.GET("/:id/revisions", c.ListRevisions)
+			staff.GET("/:id/revisions/:revision", c.GetRevision)
+			staff.GET("/:id/diff", c.DiffRevisions)
+			staff.GET("/:id/integrity", c.CheckIntegrity)
+		}
+
+		// 管理员查看试卷审计事件
+		admin := paper.Group("/", middlewares.RoleMiddleware(models.RoleAdmin))
+		{
+			admin.GET("/audit-events", c.ListAuditEvents)
 		}
 	}
 }
@@ -49,13 +70,13 @@ func (c *PaperController) RegisterRoutes(router *gin.Engine) {
 // CreatePaper 创建试卷
 func (c *PaperController) CreatePaper(ctx *gin.Context) {
 	var paperReq struct {
-		ExamID       uint    `json:"exam_id" binding:"required"`
-		Title        string  `json:"title" binding:"required"`
-		Content      string  `json:"content"`
-		Questions    string  `json:"questions"`
-		Duration     int     `json:"duration" binding:"required"`
-		TotalScore   float64 `json:"total_score" binding:"required"`
-		PassingScore float64 `json:"passing_score" binding:"required"`
+		ExamID       uint              `json:"exam_id" binding:"required"`
+		Title        string            `json:"title" binding:"required"`
+		Content      string            `json:"content"`
+		Questions    []models.Question `json:"questions" binding:"required"`
+		Duration     int               `json:"duration" binding:"required"`
+		TotalScore   float64           `json:"total_score" binding:"required"`
+		PassingScore float64           `json:"passing_score" binding:"required"`
 	}
 
 	if err := ctx.ShouldBindJSON(&paperReq); err != nil {
@@ -96,6 +117,64 @@ func (c *PaperController) CreatePaper(ctx *gin.Context) {
 	ctx.JSON(http.StatusCreated, paper)
 }
 
+// GeneratePaper 按组卷方案从题库抽题生成试卷，dry_run为true时只返回生成结果不保存
+func (c *PaperController) GeneratePaper(ctx *gin.Context) {
+	var paperReq struct {
+		ExamID       uint              `json:"exam_id" binding:"required"`
+		Title        string            `json:"title" binding:"required"`
+		Content      string            `json:"content"`
+		Duration     int               `json:"duration" binding:"required"`
+		PassingScore float64           `json:"passing_score" binding:"required"`
+		Blueprint    *models.Blueprint `json:"blueprint" binding:"required"`
+		DryRun       bool              `json:"dry_run"`
+	}
+
+	if err := ctx.ShouldBindJSON(&paperReq); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	// 检查关联的考试是否存在
+	exam, err := c.examService.GetExamByID(paperReq.ExamID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "考试不存在"})
+		return
+	}
+
+	// 检查当前用户是否是考试的创建者
+	userID, _ := ctx.Get("userID")
+	if exam.CreatorID != userID.(uint) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只有考试创建者才能添加试卷"})
+		return
+	}
+
+	paper := &models.Paper{
+		ExamID:       paperReq.ExamID,
+		Title:        paperReq.Title,
+		Content:      paperReq.Content,
+		Duration:     paperReq.Duration,
+		PassingScore: paperReq.PassingScore,
+		Status:       models.StatusDraft,
+	}
+
+	if err := c.paperService.GeneratePaper(paper, paperReq.Blueprint, paperReq.DryRun); err != nil {
+		// 题库无法满足组卷方案时列出每条不足的规则
+		var blueprintErr *services.BlueprintError
+		if errors.As(err, &blueprintErr) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "shortfalls": blueprintErr.Shortfalls})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if paperReq.DryRun {
+		ctx.JSON(http.StatusOK, paper)
+		return
+	}
+	ctx.JSON(http.StatusCreated, paper)
+}
+
 // GetPaper 获取试卷详情
 func (c *PaperController) GetPaper(ctx *gin.Context) {
 	idStr := ctx.Param("id")
@@ -111,6 +190,18 @@ func (c *PaperController) GetPaper(ctx *gin.Context) {
 		return
 	}
 
+	// 已审批考试的试卷内容被修改过时不提供
+	exam, err := c.examService.GetExamByID(paper.ExamID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "考试不存在"})
+		return
+	}
+	userID, _ := ctx.Get("userID")
+	if err := c.paperLockService.Verify(exam, []models.Paper{*paper}, userID.(uint), models.PaperSourceAPI); err != nil {
+		ctx.JSON(lockStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx.JSON(http.StatusOK, paper)
 }
 
@@ -134,10 +225,8 @@ func (c *PaperController) GetPapersByExam(ctx *gin.Context) {
 	userID, _ := ctx.Get("userID")
 	role, _ := ctx.Get("role")
 
-	// 只有考试创建者、管理员或者已发布考试的学生才能查看试卷
-	if exam.CreatorID != userID.(uint) &&
-		role.(string) != models.RoleAdmin &&
-		!(role.(string) == models.RoleStudent && exam.Status == models.StatusPublished) {
+	// 只有考试创建者和管理员才能查看试卷
+	if exam.CreatorID != userID.(uint) && role.(string) != models.RoleAdmin {
 		ctx.JSON(http.StatusForbidden, gin.H{"error": "没有权限查看试卷"})
 		return
 	}
@@ -147,6 +236,10 @@ func (c *PaperController) GetPapersByExam(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取试卷失败"})
 		return
 	}
+	if err := c.paperLockService.Verify(exam, papers, userID.(uint), models.PaperSourceAPI); err != nil {
+		ctx.JSON(lockStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
 	ctx.JSON(http.StatusOK, papers)
 }
@@ -161,12 +254,12 @@ func (c *PaperController) UpdatePaper(ctx *gin.Context) {
 	}
 
 	var paperReq struct {
-		Title        string  `json:"title"`
-		Content      string  `json:"content"`
-		Questions    string  `json:"questions"`
-		Duration     int     `json:"duration"`
-		TotalScore   float64 `json:"total_score"`
-		PassingScore float64 `json:"passing_score"`
+		Title        string             `json:"title"`
+		Content      string             `json:"content"`
+		Questions    *[]models.Question `json:"questions"` // 为空时保留原有题目
+		Duration     int                `json:"duration"`
+		TotalScore   float64            `json:"total_score"`
+		PassingScore float64            `json:"passing_score"`
 	}
 
 	if err := ctx.ShouldBindJSON(&paperReq); err != nil {
@@ -201,8 +294,8 @@ func (c *PaperController) UpdatePaper(ctx *gin.Context) {
 	if paperReq.Content != "" {
 		paper.Content = paperReq.Content
 	}
-	if paperReq.Questions != "" {
-		paper.Questions = paperReq.Questions
+	if paperReq.Questions != nil {
+		paper.Questions = *paperReq.Questions
 	}
 	if paperReq.Duration > 0 {
 		paper.Duration = paperReq.Duration
@@ -214,7 +307,7 @@ func (c *PaperController) UpdatePaper(ctx *gin.Context) {
 		paper.PassingScore = paperReq.PassingScore
 	}
 
-	if err := c.paperService.UpdatePaper(paper); err != nil {
+	if err := c.paperService.UpdatePaper(paper, userID.(uint)); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -259,6 +352,132 @@ func (c *PaperController) DeletePaper(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
 
+// revisionStatus 试卷版本操作错误对应的HTTP状态码
+func revisionStatus(err error) int {
+	if err == services.ErrPaperRevisionNotFound {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// lockStatus 试卷完整性校验错误对应的HTTP状态码
+func lockStatus(err error) int {
+	if err == services.ErrPaperTampered || err == services.ErrPaperNotLocked {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// ListRevisions 获取试卷的全部版本
+func (c *PaperController) ListRevisions(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的试卷ID"})
+		return
+	}
+	if _, err := c.paperService.GetPaperByID(uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "试卷不存在"})
+		return
+	}
+
+	revisions, err := c.paperService.ListRevisions(uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取试卷版本失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, revisions)
+}
+
+// GetRevision 获取试卷的指定版本及其题目
+func (c *PaperController) GetRevision(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的试卷ID"})
+		return
+	}
+	revision, err := strconv.Atoi(ctx.Param("revision"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的版本号"})
+		return
+	}
+
+	v, err := c.paperService.GetRevision(uint(id), revision)
+	if err != nil {
+		ctx.JSON(revisionStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, v)
+}
+
+// DiffRevisions 比较试卷的两个版本，from和to省略时比较最新版本和上一个版本
+func (c *PaperController) DiffRevisions(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的试卷ID"})
+		return
+	}
+	var from, to int
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"from", &from}, {"to", &to}} {
+		if value := ctx.Query(param.name); value != "" {
+			if *param.value, err = strconv.Atoi(value); err != nil || *param.value < 1 {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的版本号"})
+				return
+			}
+		}
+	}
+
+	diff, err := c.paperService.DiffRevisions(uint(id), from, to)
+	if err != nil {
+		ctx.JSON(revisionStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, diff)
+}
+
+// RestoreRevision 将试卷恢复为指定版本，只有考试创建者可以操作
+func (c *PaperController) RestoreRevision(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的试卷ID"})
+		return
+	}
+	revision, err := strconv.Atoi(ctx.Param("revision"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的版本号"})
+		return
+	}
+
+	paper, err := c.paperService.GetPaperByID(uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "试卷不存在"})
+		return
+	}
+	exam, err := c.examService.GetExamByID(paper.ExamID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "考试不存在"})
+		return
+	}
+	userID, _ := ctx.Get("userID")
+	if exam.CreatorID != userID.(uint) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只有考试创建者才能恢复试卷版本"})
+		return
+	}
+
+	paper, err = c.paperService.RestoreRevision(uint(id), revision, userID.(uint))
+	if err != nil {
+		ctx.JSON(revisionStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, paper)
+}
+
 // SignPaper 为试卷签名
 func (c *PaperController) SignPaper(ctx *gin.Context) {
 	idStr := ctx.Param("id")
@@ -303,3 +522,54 @@ func (c *PaperController) VerifyPaperSignature(ctx *gin.Context) {
 		"is_valid": isValid,
 	})
 }
+
+// CheckIntegrity 检查试卷的锁定状态和内容是否与审批通过时一致
+func (c *PaperController) CheckIntegrity(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的试卷ID"})
+		return
+	}
+
+	integrity, err := c.paperLockService.Check(uint(id))
+	if err == services.ErrPaperNotFound {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, integrity)
+}
+
+// ListAuditEvents 获取试卷审计事件，可按exam_id、paper_id和event过滤
+func (c *PaperController) ListAuditEvents(ctx *gin.Context) {
+	var examID, paperID uint
+	filters := []struct {
+		name  string
+		value *uint
+	}{
+		{"exam_id", &examID},
+		{"paper_id", &paperID},
+	}
+	for _, filter := range filters {
+		if value := ctx.Query(filter.name); value != "" {
+			parsed, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的" + filter.name})
+				return
+			}
+			*filter.value = uint(parsed)
+		}
+	}
+
+	events, err := c.paperLockService.ListEvents(examID, paperID, ctx.Query("event"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, events)
+}
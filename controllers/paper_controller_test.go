@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/services"
+	"github.com/gin-gonic/gin"
+)
+
+// stubAuthService 以令牌作为用户名认证的服务替身
+type stubAuthService struct {
+	services.AuthService
+	users map[string]*models.User
+}
+
+func (s stubAuthService) Authenticate(token string) (*models.User, *models.Session, error) {
+	user, ok := s.users[token]
+	if !ok {
+		return nil, nil, errors.New("会话无效")
+	}
+	return user, &models.Session{ID: 1}, nil
+}
+
+// stubPaperService 只有试卷1的试卷服务替身
+type stubPaperService struct {
+	services.PaperService
+	paper models.Paper
+}
+
+func (s stubPaperService) GetPaperByID(id uint) (*models.Paper, error) {
+	paper := s.paper
+	return &paper, nil
+}
+
+func (s stubPaperService) GetPapersByExamID(examID uint) ([]models.Paper, error) {
+	return []models.Paper{s.paper}, nil
+}
+
+// stubExamService 只有一场考试的考试服务替身
+type stubExamService struct {
+	services.ExamService
+	exam models.Exam
+}
+
+func (s stubExamService) GetExamByID(id uint) (*models.Exam, error) {
+	exam := s.exam
+	return &exam, nil
+}
+
+// stubPaperLockService 不检查锁定的试卷锁定服务替身
+type stubPaperLockService struct {
+	services.PaperLockService
+}
+
+func (stubPaperLockService) Verify(exam *models.Exam, papers []models.Paper, userID uint, source string) error {
+	return nil
+}
+
+func TestPaperControllerAccess(t *testing.T) {
+	users := map[string]*models.User{
+		"creator": {ID: 1, Role: models.RoleTeacher},
+		"teacher": {ID: 2, Role: models.RoleTeacher},
+		"admin":   {ID: 3, Role: models.RoleAdmin},
+		"student": {ID: 4, Role: models.RoleStudent},
+	}
+	// 已发布且正在开放的考试，学生也只能通过考试页面查看试卷
+	exam := models.Exam{
+		ID: 1, CreatorID: 1, Status: models.StatusPublished,
+		StartTime: time.Now().Add(-time.Hour), EndTime: time.Now().Add(time.Hour),
+	}
+	controller := NewPaperController(stubPaperService{paper: models.Paper{ID: 1, ExamID: 1, Title: "试卷A"}},
+		stubExamService{exam: exam}, stubPaperLockService{}, stubAuthService{users: users})
+	router := gin.New()
+	controller.RegisterRoutes(router)
+
+	tests := []struct {
+		user       string
+		path       string
+		wantStatus int
+	}{
+		{"student", "/api/papers/1", http.StatusForbidden},
+		{"student", "/api/papers/exam/1", http.StatusForbidden},
+		{"creator", "/api/papers/1", http.StatusOK},
+		{"creator", "/api/papers/exam/1", http.StatusOK},
+		{"teacher", "/api/papers/exam/1", http.StatusForbidden},
+		{"admin", "/api/papers/exam/1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.user)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("状态码 = %d, 期望 %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -28,12 +28,17 @@ func NewUserController(userService services.UserService, authService services.Au
 func (c *UserController) RegisterRoutes(router *gin.Engine) {
 	api := router.Group("/api")
 	{
-		authenticated := api.Group("", middlewares.AuthMiddleware())
+		authenticated := api.Group("", middlewares.AuthMiddleware(c.authService))
 		{
 			// 用户个人资料相关API
 			authenticated.GET("/user/profile", c.GetProfile)
 			authenticated.PUT("/user/profile", c.UpdateProfile)
 
+			// 当前用户的登录会话管理
+			authenticated.GET("/user/sessions", c.ListMySessions)
+			authenticated.DELETE("/user/sessions/:id", c.RevokeMySession)
+			authenticated.DELETE("/user/sessions", c.RevokeAllMySessions)
+
 			// 管理员专用API
 			admin := authenticated.Group("/admin", middlewares.RoleMiddleware(models.RoleAdmin))
 			{
@@ -176,3 +181,54 @@ func (c *UserController) UpdateUser(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, user)
 }
+
+// ListMySessions 获取当前用户的登录会话
+func (c *UserController) ListMySessions(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+	sessions, err := c.authService.ListSessions(userID.(uint))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "获取会话列表失败"})
+		return
+	}
+
+	currentSessionID, _ := ctx.Get("sessionID")
+	ctx.JSON(http.StatusOK, gin.H{
+		"sessions":        sessions,
+		"current_session": currentSessionID,
+	})
+}
+
+// RevokeMySession 注销当前用户的指定会话
+func (c *UserController) RevokeMySession(ctx *gin.Context) {
+	idStr := ctx.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的会话ID"})
+		return
+	}
+
+	userID, _ := ctx.Get("userID")
+	if err := c.authService.RevokeSession(userID.(uint), uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "会话已注销"})
+}
+
+// RevokeAllMySessions 注销当前用户的全部会话（退出所有设备）
+func (c *UserController) RevokeAllMySessions(ctx *gin.Context) {
+	userID, _ := ctx.Get("userID")
+	count, err := c.authService.RevokeAllSessions(userID.(uint))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "注销会话失败"})
+		return
+	}
+
+	// 当前会话也已注销，同时清除Cookie
+	setSessionCookie(ctx, "", -1)
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "已退出所有设备",
+		"revoked": count,
+	})
+}
@@ -2,9 +2,37 @@ package controllers
 
 import (
 	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/services"
+	"github.com/gin-gonic/gin"
 )
 
 // parseTime 解析时间字符串
 func parseTime(timeStr string) (time.Time, error) {
 	return time.Parse("2006-01-02 15:04:05", timeStr)
 }
+
+// currentUser 获取认证中间件写入上下文的当前用户
+func currentUser(c *gin.Context) *models.User {
+	value, exists := c.Get("user")
+	if !exists {
+		return nil
+	}
+	user, _ := value.(*models.User)
+	return user
+}
+
+// examActionButtons 列表中每份试卷当前用户可以执行的状态变更操作，用于显示操作按钮
+func examActionButtons(exams []models.Exam, user *models.User) map[uint][]gin.H {
+	buttons := make(map[uint][]gin.H, len(exams))
+	for i := range exams {
+		for _, action := range ExamService.ExamActions(&exams[i], user) {
+			buttons[exams[i].ID] = append(buttons[exams[i].ID], gin.H{
+				"action": action,
+				"name":   services.ExamActionName(action),
+			})
+		}
+	}
+	return buttons
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+	"github.com/exam-approval-system/services"
+)
+
+// runCreateAdmin 执行 create-admin 子命令，创建管理员账号。
+// 页面只能注册学生，第一个管理员通过该命令创建，之后由管理员在控制面板创建教师和管理员
+func runCreateAdmin(args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		return fmt.Errorf("用法: create-admin <用户名> <密码> [姓名]")
+	}
+	name := args[0]
+	if len(args) == 3 {
+		name = args[2]
+	}
+
+	settingsService, err := services.NewSettingsService(repositories.NewSettingsRepository(), repositories.NewUserRepository())
+	if err != nil {
+		return err
+	}
+	authService := services.NewAuthService(repositories.NewUserRepository(), repositories.NewSessionRepository(), settingsService)
+	user := &models.User{
+		Username: args[0],
+		Password: args[1],
+		Name:     name,
+		Role:     models.RoleAdmin,
+	}
+	if err := authService.Register(user); err != nil {
+		return err
+	}
+	fmt.Printf("已创建管理员 %s\n", user.Username)
+	return nil
+}
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/exam-approval-system/configs"
 	"github.com/exam-approval-system/controllers"
+	"github.com/exam-approval-system/middlewares"
 	"github.com/exam-approval-system/models"
 	"github.com/exam-approval-system/repositories"
 	"github.com/exam-approval-system/services"
@@ -13,28 +21,38 @@ import (
 )
 
 func main() {
+	autoMigrate := flag.Bool("auto-migrate", false, "启动时自动执行未完成的数据库迁移")
+	flag.Usage = usage
+	flag.Parse()
+
 	// 初始化数据库连接
 	configs.InitDB()
-	defer configs.DB.Close()
-
-	// 自动迁移数据库表结构
-	configs.DB.AutoMigrate(&models.User{}, &models.Exam{}, &models.Paper{}, &models.Comment{}, &models.ExamData{})
-
-	// 手动添加外键约束
-	configs.DB.Model(&models.User{}).AddForeignKey("teacher_id", "users(id)", "SET NULL", "CASCADE")
-
-	// 更新所有draft状态的试卷为published状态
-	var draftExams []models.Exam
-	result := configs.DB.Where("status = ?", "draft").Find(&draftExams)
-	if result.Error == nil {
-		log.Printf("找到 %d 份草稿状态的试卷，正在更新为已发布状态", len(draftExams))
-		for _, exam := range draftExams {
-			exam.Status = "published"
-			configs.DB.Save(&exam)
+	// 恢复备份时会替换数据库连接，退出时关闭当前连接
+	defer func() { configs.DB().Close() }()
+
+	// migrate 子命令只执行数据库迁移，不启动服务器
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(flag.Args()[1:]); err != nil {
+			log.Printf("%v", err)
+			configs.DB().Close()
+			os.Exit(1)
 		}
-		log.Printf("已将所有草稿试卷更新为已发布状态")
-	} else {
-		log.Printf("查询草稿试卷时出错: %v", result.Error)
+		return
+	}
+
+	// 存在未执行的迁移时拒绝启动，除非指定了 -auto-migrate
+	if err := checkMigrations(*autoMigrate); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// create-admin 子命令创建管理员账号，不启动服务器
+	if flag.Arg(0) == "create-admin" {
+		if err := runCreateAdmin(flag.Args()[1:]); err != nil {
+			log.Printf("%v", err)
+			configs.DB().Close()
+			os.Exit(1)
+		}
+		return
 	}
 
 	// 创建Gin路由引擎
@@ -44,30 +62,73 @@ func main() {
 	router.Static("/static", "./static")
 	router.LoadHTMLGlob("templates/*")
 
+	// 恢复备份时进入维护模式，拒绝新的请求并等待进行中的请求结束
+	maintenanceService := services.NewMaintenanceService()
+	router.Use(middlewares.MaintenanceMiddleware(maintenanceService, "/admin/backup/:id/restore"))
+
 	// 初始化存储库
 	userRepo := repositories.NewUserRepository()
 	examRepo := repositories.NewExamRepository()
 	paperRepo := repositories.NewPaperRepository()
 	examDataRepo := repositories.NewExamDataRepository()
+	sessionRepo := repositories.NewSessionRepository()
+	settingsRepo := repositories.NewSettingsRepository()
+	backupRunRepo := repositories.NewBackupRunRepository()
+	submissionRepo := repositories.NewSubmissionRepository()
+	attemptRepo := repositories.NewAttemptRepository()
+	bankRepo := repositories.NewBankRepository()
+	approvalRepo := repositories.NewApprovalRepository()
+	approvalSLARepo := repositories.NewApprovalSLARepository()
+	commentRepo := repositories.NewCommentRepository()
 
 	// 初始化服务
-	authService := services.NewAuthService(userRepo)
-	userService := services.NewUserService(userRepo)
-	examService := services.NewExamService(examRepo, userRepo)
-	paperService := services.NewPaperService(paperRepo, examRepo)
+	settingsService, err := services.NewSettingsService(settingsRepo, userRepo)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	backupService, err := services.NewBackupService(configs.BackupDir(), sessionRepo, settingsService, maintenanceService)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	backupScheduler := services.NewBackupScheduler(backupService, settingsService, backupRunRepo, maintenanceService)
+	authService := services.NewAuthService(userRepo, sessionRepo, settingsService)
+	approvalService := services.NewApprovalService(approvalRepo, examRepo, userRepo, settingsService)
+	approvalSLAService := services.NewApprovalSLAService(approvalSLARepo, examRepo, userRepo)
+	paperLockService := services.NewPaperLockService(paperRepo)
+	examStateMachine := services.NewExamStateMachine(examRepo, userRepo, submissionRepo, attemptRepo, approvalService, paperLockService)
+	examService := services.NewExamService(examRepo, userRepo, paperRepo, submissionRepo, attemptRepo, examStateMachine)
+	userService := services.NewUserService(userRepo, sessionRepo, approvalRepo, examService, settingsService)
+	commentService := services.NewCommentService(commentRepo, examRepo, paperRepo, examDataRepo, userRepo)
+	questionBankService := services.NewQuestionBankService(bankRepo)
+	paperService := services.NewPaperService(paperRepo, examRepo, questionBankService)
 	dashboardService := services.NewDashboardService(examRepo, userRepo, paperRepo, examDataRepo)
+	submissionService := services.NewSubmissionService(submissionRepo, paperRepo, examDataRepo)
+	gradingService := services.NewGradingService(submissionRepo, examDataRepo, paperRepo, settingsService)
+	attemptService := services.NewAttemptService(attemptRepo, paperRepo, examDataRepo, submissionService, settingsService)
+	attemptSweeper := services.NewAttemptSweeper(attemptService, maintenanceService)
+	approvalMonitor := services.NewApprovalMonitor(approvalService, approvalSLAService, maintenanceService)
 
 	// 设置页面控制器的依赖项
 	controllers.AuthService = authService
 	controllers.DashboardService = dashboardService
+	controllers.UserService = userService
 	controllers.ExamService = examService
+	controllers.SettingsService = settingsService
+	controllers.BackupScheduler = backupScheduler
+	controllers.SubmissionService = submissionService
+	controllers.GradingService = gradingService
+	controllers.AttemptService = attemptService
+	controllers.ApprovalService = approvalService
+	controllers.PaperLockService = paperLockService
 
 	// 初始化控制器
 	authController := controllers.NewAuthController(authService)
 	userController := controllers.NewUserController(userService, authService)
-	examController := controllers.NewExamController(examService, authService)
-	paperController := controllers.NewPaperController(paperService, examService, authService)
-	adminController := controllers.NewAdminController(userService, authService)
+	examController := controllers.NewExamController(examService, commentService, authService)
+	paperController := controllers.NewPaperController(paperService, examService, paperLockService, authService)
+	adminController := controllers.NewAdminController(userService, authService, settingsService, backupService)
+	questionBankController := controllers.NewQuestionBankController(questionBankService, authService, settingsService)
+	approvalController := controllers.NewApprovalController(approvalService, approvalSLAService, examService, authService)
 
 	// 注册API路由
 	authController.RegisterRoutes(router)
@@ -75,6 +136,8 @@ func main() {
 	examController.RegisterRoutes(router)
 	paperController.RegisterRoutes(router)
 	adminController.RegisterRoutes(router)
+	questionBankController.RegisterRoutes(router)
+	approvalController.RegisterRoutes(router)
 
 	// 注册前端路由
 	router.GET("/", func(c *gin.Context) {
@@ -82,12 +145,15 @@ func main() {
 	})
 	router.GET("/login", controllers.LoginPage)
 	router.GET("/register", controllers.RegisterPage)
-	router.GET("/dashboard", controllers.Dashboard)
+
+	// 页面路由统一通过会话令牌认证
+	authMiddleware := middlewares.AuthMiddleware(authService)
+	router.GET("/dashboard", authMiddleware, controllers.Dashboard)
 
 	// 添加基于角色的控制面板路由
-	router.GET("/dashboard-student", controllers.DashboardStudent)
-	router.GET("/dashboard-teacher", controllers.DashboardTeacher)
-	router.GET("/dashboard-admin", controllers.DashboardAdmin)
+	router.GET("/dashboard-student", authMiddleware, controllers.DashboardStudent)
+	router.GET("/dashboard-teacher", authMiddleware, controllers.DashboardTeacher)
+	router.GET("/dashboard-admin", authMiddleware, controllers.DashboardAdmin)
 
 	// 添加重定向路由
 	router.GET("/redirect-to-register", func(c *gin.Context) {
@@ -105,29 +171,35 @@ func main() {
 	})
 
 	// 注册管理员相关路由
-	adminRouterGroup := router.Group("/admin")
+	adminRouterGroup := router.Group("/admin", authMiddleware, middlewares.RoleMiddleware(models.RoleAdmin))
 	adminRouterGroup.GET("/dashboard", controllers.DashboardAdmin)
 
 	// 试卷管理路由
 	adminRouterGroup.POST("/papers/create", controllers.HandleCreatePaper)
-	adminRouterGroup.GET("/papers/delete/:id", controllers.HandleDeletePaper)
+	adminRouterGroup.POST("/papers/delete/:id", controllers.HandleDeletePaper)
+	adminRouterGroup.POST("/papers/transition/:id", controllers.HandlePaperTransition)
+
+	// 审批管理路由
+	adminRouterGroup.POST("/approve/:id", controllers.HandleApprovePaper)
+	adminRouterGroup.POST("/reject/:id", controllers.HandleRejectPaper)
 
 	// 用户管理路由
 	adminRouterGroup.POST("/users/create", controllers.HandleCreateUser)
-	adminRouterGroup.GET("/users/delete/:id", controllers.HandleDeleteUser)
+	adminRouterGroup.POST("/users/delete/:id", controllers.HandleDeleteUser)
 
 	// 个人中心路由
 	adminRouterGroup.POST("/profile/change-password", controllers.HandleChangePassword)
 
 	// 添加教师专用路由组
-	teacherRouterGroup := router.Group("/teacher")
+	teacherRouterGroup := router.Group("/teacher", authMiddleware, middlewares.RoleMiddleware(models.RoleTeacher, models.RoleAdmin))
 	teacherRouterGroup.GET("/dashboard", controllers.DashboardTeacher)
 
 	// 教师试卷管理路由
 	teacherRouterGroup.POST("/papers/create", controllers.HandleCreatePaper)
-	teacherRouterGroup.GET("/papers/delete/:id", controllers.HandleDeletePaper)
+	teacherRouterGroup.POST("/papers/delete/:id", controllers.HandleDeletePaper)
 	teacherRouterGroup.GET("/papers/view/:id", controllers.HandleViewPaper)
 	teacherRouterGroup.POST("/papers/update/:id", controllers.HandleUpdatePaper)
+	teacherRouterGroup.POST("/papers/transition/:id", controllers.HandlePaperTransition)
 
 	// 教师批阅管理路由
 	teacherRouterGroup.GET("/examdata/:id", controllers.HandleGetExamData)
@@ -136,20 +208,43 @@ func main() {
 	// 教师学生管理路由
 	teacherRouterGroup.GET("/student-exams/:id", controllers.HandleGetStudentExams)
 
-	// 教师审批管理路由
-	teacherRouterGroup.POST("/approve/:id", controllers.HandleApprovePaper)
-	teacherRouterGroup.POST("/reject/:id", controllers.HandleRejectPaper)
-
 	// 教师个人中心路由
 	teacherRouterGroup.POST("/profile/change-password", controllers.HandleChangePassword)
 
 	// 添加学生专用路由组
-	studentRouterGroup := router.Group("/student")
+	studentRouterGroup := router.Group("/student", authMiddleware, middlewares.RoleMiddleware(models.RoleStudent))
 	studentRouterGroup.GET("/dashboard", controllers.DashboardStudent)
 	studentRouterGroup.GET("/exam/:id", controllers.HandleExamView)
+	studentRouterGroup.POST("/exam/:id/autosave", controllers.HandleExamAutosave)
 	studentRouterGroup.POST("/submit-exam/:id", controllers.HandleExamSubmit)
 	studentRouterGroup.GET("/exam-result/:id", controllers.HandleExamResult)
 
-	// 启动服务器
-	router.Run(":8080")
+	// 启动定时备份、超时作答自动交卷和超时审批检查
+	backupScheduler.Start()
+	attemptSweeper.Start()
+	approvalMonitor.Start()
+
+	// 启动服务器，收到退出信号后优雅关闭
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: ":8080", Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("服务器启动失败: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("正在关闭服务器...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("关闭服务器失败: %v", err)
+	}
+	backupScheduler.Stop()
+	attemptSweeper.Stop()
+	approvalMonitor.Stop()
+	log.Printf("服务器已关闭")
 }
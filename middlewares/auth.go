@@ -2,35 +2,48 @@ package middlewares
 
 import (
 	"net/http"
+	"strings"
 
-	"github.com/exam-approval-system/repositories"
+	"github.com/exam-approval-system/services"
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware 简单认证中间件，基于请求头检查用户名
-func AuthMiddleware() gin.HandlerFunc {
-	userRepo := repositories.NewUserRepository()
+// SessionCookieName 会话令牌Cookie名称
+const SessionCookieName = "session_token"
 
-	return func(c *gin.Context) {
-		// 从请求头获取用户名
-		username := c.GetHeader("X-Username")
-		if username == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "未提供用户名"})
-			c.Abort()
-			return
-		}
+// TokenFromRequest 从Cookie或Authorization请求头中读取会话令牌
+func TokenFromRequest(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+
+	token, err := c.Cookie(SessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return token
+}
 
-		// 获取用户信息
-		user, err := userRepo.GetByUsername(username)
+// AuthMiddleware 认证中间件，根据签名会话令牌解析当前用户
+// API请求返回401，页面请求重定向到登录页
+func AuthMiddleware(authService services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, session, err := authService.Authenticate(TokenFromRequest(c))
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+			if wantsHTML(c) {
+				c.Redirect(http.StatusFound, "/login")
+			} else {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录或会话已过期"})
+			}
 			c.Abort()
 			return
 		}
 
 		// 将用户信息存储到上下文中
+		c.Set("user", user)
 		c.Set("userID", user.ID)
 		c.Set("role", user.Role)
+		c.Set("sessionID", session.ID)
 		c.Next()
 	}
 }
@@ -60,3 +73,14 @@ func RoleMiddleware(roles ...string) gin.HandlerFunc {
 		c.Abort()
 	}
 }
+
+// wantsHTML 判断请求是否来自浏览器页面跳转而非接口调用
+func wantsHTML(c *gin.Context) bool {
+	if c.GetHeader("X-Requested-With") == "XMLHttpRequest" {
+		return false
+	}
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		return false
+	}
+	return strings.Contains(c.GetHeader("Accept"), "text/html")
+}
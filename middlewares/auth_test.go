@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/services"
+	"github.com/gin-gonic/gin"
+)
+
+// stubAuthService 只接受令牌 good 的认证服务替身
+type stubAuthService struct {
+	services.AuthService
+}
+
+func (stubAuthService) Authenticate(token string) (*models.User, *models.Session, error) {
+	if token != "good" {
+		return nil, nil, errors.New("会话无效")
+	}
+	return &models.User{ID: 3, Role: models.RoleTeacher}, &models.Session{ID: 9}, nil
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		path         string
+		header       map[string]string
+		cookie       string
+		roles        []string
+		wantStatus   int
+		wantLocation string
+	}{
+		{name: "Bearer令牌", path: "/api/exams", header: map[string]string{"Authorization": "Bearer good"}, wantStatus: http.StatusOK},
+		{name: "Cookie令牌", path: "/exams", cookie: "good", wantStatus: http.StatusOK},
+		{name: "接口未登录", path: "/api/exams", header: map[string]string{"Accept": "text/html"}, wantStatus: http.StatusUnauthorized},
+		{name: "不信任用户名请求头", path: "/api/exams", header: map[string]string{"X-Username": "admin"}, wantStatus: http.StatusUnauthorized},
+		{name: "页面未登录跳转", path: "/exams", header: map[string]string{"Accept": "text/html"}, wantStatus: http.StatusFound, wantLocation: "/login"},
+		{name: "页面中的异步请求", path: "/exams", header: map[string]string{"Accept": "text/html", "X-Requested-With": "XMLHttpRequest"}, cookie: "bad", wantStatus: http.StatusUnauthorized},
+		{name: "角色允许", path: "/api/exams", cookie: "good", roles: []string{models.RoleAdmin, models.RoleTeacher}, wantStatus: http.StatusOK},
+		{name: "角色不允许", path: "/api/exams", cookie: "good", roles: []string{models.RoleAdmin}, wantStatus: http.StatusForbidden},
+		{name: "教师访问学生路由", path: "/student/dashboard", cookie: "good", roles: []string{models.RoleStudent}, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			handlers := []gin.HandlerFunc{AuthMiddleware(stubAuthService{})}
+			if tt.roles != nil {
+				handlers = append(handlers, RoleMiddleware(tt.roles...))
+			}
+			handlers = append(handlers, func(c *gin.Context) {
+				if c.GetUint("userID") != 3 || c.GetUint("sessionID") != 9 {
+					t.Errorf("上下文中的用户 = %v, 会话 = %v", c.GetUint("userID"), c.GetUint("sessionID"))
+				}
+				c.Status(http.StatusOK)
+			})
+			router.GET(tt.path, handlers...)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("状态码 = %d, 期望 %d", w.Code, tt.wantStatus)
+			}
+			if location := w.Header().Get("Location"); location != tt.wantLocation {
+				t.Errorf("Location = %q, 期望 %q", location, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestRoleMiddlewareWithoutAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/admin", RoleMiddleware(models.RoleAdmin), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("未经过认证中间件时状态码 = %d, 期望 %d", w.Code, http.StatusUnauthorized)
+	}
+}
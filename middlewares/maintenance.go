@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/exam-approval-system/services"
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceMiddleware 维护模式中间件，记录进行中的请求，维护模式下返回503。
+// exclusive 中的路由自身会进入维护模式（如恢复备份），不计入进行中的请求，避免等待自己结束
+func MaintenanceMiddleware(maintenance services.MaintenanceService, exclusive ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(exclusive))
+	for _, path := range exclusive {
+		skip[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if skip[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		if err := maintenance.Enter(); err != nil {
+			c.Header("Retry-After", "10")
+			if wantsHTML(c) {
+				c.String(http.StatusServiceUnavailable, err.Error())
+			} else {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			}
+			c.Abort()
+			return
+		}
+		defer maintenance.Leave()
+		c.Next()
+	}
+}
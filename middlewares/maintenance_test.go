@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/exam-approval-system/services"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		path        string
+		maintenance bool
+		wantStatus  int
+	}{
+		{name: "正常请求", path: "/api/exams", wantStatus: http.StatusOK},
+		{name: "维护中", path: "/api/exams", maintenance: true, wantStatus: http.StatusServiceUnavailable},
+		{name: "维护中的页面", path: "/dashboard", maintenance: true, wantStatus: http.StatusServiceUnavailable},
+		{name: "进入维护模式的路由", path: "/admin/backup/b1/restore", maintenance: true, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maintenance := services.NewMaintenanceService()
+			router := gin.New()
+			router.Use(MaintenanceMiddleware(maintenance, "/admin/backup/:id/restore"))
+			handler := func(c *gin.Context) { c.Status(http.StatusOK) }
+			router.GET("/api/exams", handler)
+			router.GET("/dashboard", handler)
+			router.GET("/admin/backup/:id/restore", handler)
+
+			if tt.maintenance {
+				if err := maintenance.Begin(time.Second); err != nil {
+					t.Fatal(err)
+				}
+			}
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Accept", "text/html")
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("状态码 = %d, 期望 %d", w.Code, tt.wantStatus)
+			}
+
+			// 请求结束后不再计入进行中的请求
+			maintenance.End()
+			if err := maintenance.Begin(10 * time.Millisecond); err != nil {
+				t.Errorf("请求结束后进入维护模式 error = %v", err)
+			}
+		})
+	}
+}
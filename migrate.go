@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/migrations"
+)
+
+// usage 输出命令行用法
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "用法:\n")
+	fmt.Fprintf(out, "  %s [-auto-migrate]          启动服务器\n", os.Args[0])
+	fmt.Fprintf(out, "  %s migrate status           查看迁移状态\n", os.Args[0])
+	fmt.Fprintf(out, "  %s migrate up               执行全部未执行的迁移\n", os.Args[0])
+	fmt.Fprintf(out, "  %s migrate down [n]         回滚最近的n个迁移（默认1）\n", os.Args[0])
+	fmt.Fprintf(out, "  %s migrate to <version>     迁移到指定版本（0表示全部回滚）\n", os.Args[0])
+	fmt.Fprintf(out, "  %s create-admin <用户名> <密码> [姓名]  创建管理员账号\n", os.Args[0])
+	fmt.Fprintf(out, "\n参数:\n")
+	flag.PrintDefaults()
+}
+
+// runMigrate 执行 migrate 子命令
+func runMigrate(args []string) error {
+	migrator := migrations.NewMigrator(configs.DB())
+	if len(args) == 0 {
+		flag.Usage()
+		return fmt.Errorf("缺少 migrate 子命令")
+	}
+
+	switch args[0] {
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.Applied {
+				fmt.Printf("[已执行] %s  %s\n", status.Migration, status.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("[未执行] %s\n", status.Migration)
+			}
+		}
+		return nil
+
+	case "up":
+		count, err := migrator.Up()
+		fmt.Printf("已执行 %d 个迁移\n", count)
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("无效的回滚数量: %s", args[1])
+			}
+			steps = n
+		}
+		count, err := migrator.Down(steps)
+		fmt.Printf("已回滚 %d 个迁移\n", count)
+		return err
+
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("migrate to 需要指定版本号")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return fmt.Errorf("无效的版本号: %s", args[1])
+		}
+		count, err := migrator.To(version)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已迁移到版本 %d，共变更 %d 个迁移\n", version, count)
+		return nil
+	}
+
+	flag.Usage()
+	return fmt.Errorf("未知的 migrate 子命令: %s", args[0])
+}
+
+// checkMigrations 检查是否存在未执行的迁移，autoApply 为 true 时自动执行
+func checkMigrations(autoApply bool) error {
+	migrator := migrations.NewMigrator(configs.DB())
+	pending, err := migrator.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if !autoApply {
+		for _, migration := range pending {
+			log.Printf("未执行的迁移: %s", migration)
+		}
+		return fmt.Errorf("存在 %d 个未执行的数据库迁移，请先运行 `%s migrate up`，或使用 -auto-migrate 参数启动", len(pending), os.Args[0])
+	}
+
+	count, err := migrator.Up()
+	if err != nil {
+		return err
+	}
+	log.Printf("已自动执行 %d 个数据库迁移", count)
+	return nil
+}
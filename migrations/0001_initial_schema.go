@@ -0,0 +1,114 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 初始表结构：用户、考试、试卷、审批评论和试卷数据
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&user0001{}, &exam0001{}, &paper0001{}, &comment0001{}, &examData0001{}).Error; err != nil {
+				return err
+			}
+			// SQLite不支持通过ALTER TABLE添加外键约束
+			if tx.Dialect().GetName() == "sqlite3" {
+				return nil
+			}
+			return tx.Model(&user0001{}).AddForeignKey("teacher_id", "users(id)", "SET NULL", "CASCADE").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.DropTableIfExists("comments", "papers", "exam_data", "exams", "users").Error
+		},
+	})
+}
+
+// 以下为本版本时的表结构。迁移不使用models中的模型，模型之后的修改不会改变已发布迁移创建的表
+
+// user0001 用户表
+type user0001 struct {
+	ID        uint   `gorm:"primary_key"`
+	Username  string `gorm:"size:50;unique;not null"`
+	Password  string `gorm:"size:100;not null"`
+	Name      string `gorm:"size:50;not null"`
+	Role      string `gorm:"size:20;not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	TeacherID uint
+}
+
+// TableName 表名
+func (user0001) TableName() string { return "users" }
+
+// exam0001 考试表
+type exam0001 struct {
+	ID          uint   `gorm:"primary_key"`
+	Title       string `gorm:"size:100;not null"`
+	Description string `gorm:"size:1000"`
+	Course      string `gorm:"size:100;not null"`
+	StartTime   time.Time
+	EndTime     time.Time
+	CreatorID   uint
+	Status      string `gorm:"size:20;not null;default:'draft'"`
+	ApproverID  uint
+	TotalScore  float64
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// TableName 表名
+func (exam0001) TableName() string { return "exams" }
+
+// paper0001 试卷表，题目以JSON保存在questions列中，0006转换为题目表后该列保留不删除
+type paper0001 struct {
+	ID           uint `gorm:"primary_key"`
+	ExamID       uint
+	Title        string `gorm:"size:100;not null"`
+	Content      string `gorm:"type:text"`
+	Questions    string `gorm:"type:text"`
+	Duration     int
+	TotalScore   float64
+	PassingScore float64
+	Status       string `gorm:"size:20;not null;default:'draft'"`
+	Signature    string `gorm:"size:256"`
+	SignedAt     time.Time
+	SignedBy     uint
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// TableName 表名
+func (paper0001) TableName() string { return "papers" }
+
+// comment0001 审批评论表
+type comment0001 struct {
+	ID        uint `gorm:"primary_key"`
+	ExamID    uint
+	UserID    uint
+	Content   string `gorm:"size:1000;not null"`
+	CreatedAt time.Time
+}
+
+// TableName 表名
+func (comment0001) TableName() string { return "comments" }
+
+// examData0001 试卷数据表
+type examData0001 struct {
+	ID         uint `gorm:"primary_key"`
+	ExamID     uint
+	StudentID  uint
+	Title      string `gorm:"size:100;not null"`
+	Course     string `gorm:"size:100;not null"`
+	TotalScore float64
+	Status     string `gorm:"size:20;not null;default:'draft'"`
+	ApproverID uint
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// TableName 表名
+func (examData0001) TableName() string { return "exam_data" }
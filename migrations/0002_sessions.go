@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 登录会话表
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "sessions",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&session0002{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.DropTableIfExists("sessions").Error
+		},
+	})
+}
+
+// session0002 登录会话表
+type session0002 struct {
+	ID         uint   `gorm:"primary_key"`
+	SessionID  string `gorm:"size:64;unique_index;not null"`
+	UserID     uint   `gorm:"index;not null"`
+	Device     string `gorm:"size:255"`
+	IP         string `gorm:"size:64"`
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+// TableName 表名
+func (session0002) TableName() string { return "sessions" }
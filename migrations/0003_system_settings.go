@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 系统设置表，默认设置由设置服务在首次启动时写入
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "system_settings",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&systemSettings0003{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.DropTableIfExists("system_settings").Error
+		},
+	})
+}
+
+// systemSettings0003 系统设置表
+type systemSettings0003 struct {
+	ID                uint   `gorm:"primary_key"`
+	SystemName        string `gorm:"size:100;not null"`
+	AdminEmail        string `gorm:"size:100"`
+	PageSize          int    `gorm:"not null"`
+	MinPasswordLength int    `gorm:"not null"`
+	SessionTimeout    int    `gorm:"not null"`
+	MaxLoginAttempts  int    `gorm:"not null"`
+	TwoFactorAuth     bool
+	AutoBackup        bool
+	BackupFrequency   string `gorm:"size:20;not null"`
+	BackupCount       int    `gorm:"not null"`
+	UpdatedBy         uint
+	UpdatedAt         time.Time
+}
+
+// TableName 表名
+func (systemSettings0003) TableName() string { return "system_settings" }
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 定时备份执行记录表
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "backup_runs",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&backupRun0004{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.DropTableIfExists("backup_runs").Error
+		},
+	})
+}
+
+// backupRun0004 定时备份执行记录表
+type backupRun0004 struct {
+	ID         uint      `gorm:"primary_key"`
+	BackupID   string    `gorm:"size:64"`
+	Frequency  string    `gorm:"size:20"`
+	Status     string    `gorm:"size:20;not null;index"`
+	Error      string    `gorm:"type:text"`
+	StartedAt  time.Time `gorm:"index"`
+	FinishedAt time.Time
+}
+
+// TableName 表名
+func (backupRun0004) TableName() string { return "backup_runs" }
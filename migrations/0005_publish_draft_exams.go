@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// 将历史草稿试卷更新为已发布状态，替代原先每次启动时执行的修正逻辑。
+// 只修正启用迁移之前创建的考试：回滚后重新执行时，不会把之后新建的草稿也发布
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "publish_draft_exams",
+		Up: func(tx *gorm.DB) error {
+			var first SchemaMigration
+			err := tx.Order("version").First(&first).Error
+			if gorm.IsRecordNotFoundError(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			return tx.Table("exams").
+				Where("status = ? AND (created_at IS NULL OR created_at < ?)", "draft", first.AppliedAt).
+				UpdateColumn("status", "published").Error
+		},
+		// 无法区分哪些试卷原本是草稿，回滚时不修改数据
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
+}
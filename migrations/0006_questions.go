@@ -0,0 +1,151 @@
+package migrations
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 题目与选项表，并将 papers.questions 中的旧JSON题目转换为结构化数据
+// 旧列保留不删除，回滚时只需删除新表
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "questions",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&question0006{}, &questionOption0006{}).Error; err != nil {
+				return err
+			}
+			if !tx.Dialect().HasColumn("papers", "questions") {
+				return nil
+			}
+			return convertLegacyQuestions(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.DropTableIfExists("question_options", "questions").Error
+		},
+	})
+}
+
+// question0006 题目表
+type question0006 struct {
+	ID        uint                 `gorm:"primary_key"`
+	PaperID   uint                 `gorm:"index;not null"`
+	Position  int                  `gorm:"not null"`
+	Type      string               `gorm:"size:20;not null"`
+	Content   string               `gorm:"type:text;not null"`
+	Score     float64              `gorm:"not null"`
+	Answer    string               `gorm:"type:text"`
+	Options   []questionOption0006 `gorm:"foreignkey:QuestionID"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName 表名
+func (question0006) TableName() string { return "questions" }
+
+// questionOption0006 选择题选项表
+type questionOption0006 struct {
+	ID         uint   `gorm:"primary_key"`
+	QuestionID uint   `gorm:"index;not null"`
+	Position   int    `gorm:"not null"`
+	Label      string `gorm:"size:10;not null"`
+	Content    string `gorm:"type:text;not null"`
+	IsCorrect  bool
+}
+
+// TableName 表名
+func (questionOption0006) TableName() string { return "question_options" }
+
+// legacyQuestion 前端脚本生成的旧题目格式
+type legacyQuestion struct {
+	Content string   `json:"content"`
+	Type    string   `json:"type"` // single、multiple 或 text
+	Score   float64  `json:"score"`
+	Options []string `json:"options"`
+	Answer  string   `json:"answer"`
+}
+
+// convertLegacyQuestions 转换旧题目，无法解析的试卷只记录日志并跳过
+func convertLegacyQuestions(tx *gorm.DB) error {
+	rows, err := tx.Raw("SELECT id, questions FROM papers WHERE questions IS NOT NULL AND questions <> ''").Rows()
+	if err != nil {
+		return err
+	}
+
+	legacy := make(map[uint]string)
+	for rows.Next() {
+		var id uint
+		var questions string
+		if err := rows.Scan(&id, &questions); err != nil {
+			rows.Close()
+			return err
+		}
+		legacy[id] = questions
+	}
+	rows.Close()
+
+	for paperID, raw := range legacy {
+		var count int
+		if err := tx.Model(&question0006{}).Where("paper_id = ?", paperID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		var items []legacyQuestion
+		if err := json.Unmarshal([]byte(raw), &items); err != nil {
+			log.Printf("试卷 %d 的旧题目无法解析，已跳过: %v", paperID, err)
+			continue
+		}
+		for i, item := range items {
+			question := convertLegacyQuestion(item)
+			question.PaperID = paperID
+			question.Position = i + 1
+			if err := tx.Create(&question).Error; err != nil {
+				return err
+			}
+		}
+		log.Printf("已转换试卷 %d 的 %d 道旧题目", paperID, len(items))
+	}
+	return nil
+}
+
+// convertLegacyQuestion 转换单道旧题目，选择题答案可以是选项内容或选项字母（多选以逗号分隔）
+func convertLegacyQuestion(item legacyQuestion) question0006 {
+	question := question0006{
+		Content: item.Content,
+		Score:   item.Score,
+	}
+
+	switch item.Type {
+	case "single":
+		question.Type = "single_choice"
+	case "multiple":
+		question.Type = "multiple_choice"
+	default:
+		question.Type = "short_answer"
+		question.Answer = item.Answer
+		return question
+	}
+
+	answers := make(map[string]bool)
+	for _, answer := range strings.Split(item.Answer, ",") {
+		answers[strings.ToUpper(strings.TrimSpace(answer))] = true
+	}
+	for i, content := range item.Options {
+		label := string(rune('A' + i))
+		question.Options = append(question.Options, questionOption0006{
+			Position:  i + 1,
+			Label:     label,
+			Content:   content,
+			IsCorrect: answers[label] || answers[strings.ToUpper(strings.TrimSpace(content))],
+		})
+	}
+	return question
+}
@@ -0,0 +1,148 @@
+package migrations
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 答卷提交与作答表，并将旧版保存在 comments 中的学生答案转换为提交记录
+// 回滚时把提交内容写回 comments，按题作答会合并为一条文本
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "submissions",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&submission0007{}, &answer0007{}).Error; err != nil {
+				return err
+			}
+			return convertCommentAnswers(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := restoreCommentAnswers(tx); err != nil {
+				return err
+			}
+			return tx.DropTableIfExists("answers", "submissions").Error
+		},
+	})
+}
+
+// submission0007 答卷提交表
+type submission0007 struct {
+	ID          uint         `gorm:"primary_key"`
+	ExamDataID  uint         `gorm:"index;not null"`
+	ExamID      uint         `gorm:"index;not null"`
+	StudentID   uint         `gorm:"index;not null"`
+	Attempt     int          `gorm:"not null"`
+	Answers     []answer0007 `gorm:"foreignkey:SubmissionID"`
+	SubmittedAt time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// TableName 表名
+func (submission0007) TableName() string { return "submissions" }
+
+// answer0007 作答表，QuestionID为0表示不对应具体题目的整卷作答
+type answer0007 struct {
+	ID           uint          `gorm:"primary_key"`
+	SubmissionID uint          `gorm:"unique_index:idx_answer_submission_question;not null"`
+	QuestionID   uint          `gorm:"unique_index:idx_answer_submission_question"`
+	Question     *question0006 `gorm:"foreignkey:QuestionID"`
+	Response     string        `gorm:"type:text"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// TableName 表名
+func (answer0007) TableName() string { return "answers" }
+
+// text 将提交的作答合并为一条文本，按题作答带有题号
+func (s *submission0007) text() string {
+	lines := make([]string, 0, len(s.Answers))
+	for _, answer := range s.Answers {
+		if answer.Question == nil || answer.QuestionID == 0 {
+			lines = append(lines, answer.Response)
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("第%d题: %s", answer.Question.Position, answer.Response))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// convertCommentAnswers 旧版把学生答案作为该学生在考试下的评论保存，
+// 每条评论按时间顺序转换为一次提交，同一学生在同一考试有多条答题记录时归入最新的一条
+func convertCommentAnswers(tx *gorm.DB) error {
+	var examDataList []examData0001
+	if err := tx.Order("id desc").Find(&examDataList).Error; err != nil {
+		return err
+	}
+
+	type pair struct{ examID, studentID uint }
+	seen := make(map[pair]bool)
+	converted := 0
+	for _, examData := range examDataList {
+		key := pair{examData.ExamID, examData.StudentID}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		var comments []comment0001
+		err := tx.Where("exam_id = ? AND user_id = ?", examData.ExamID, examData.StudentID).
+			Order("created_at, id").Find(&comments).Error
+		if err != nil {
+			return err
+		}
+
+		for i, comment := range comments {
+			submission := submission0007{
+				ExamDataID:  examData.ID,
+				ExamID:      examData.ExamID,
+				StudentID:   examData.StudentID,
+				Attempt:     i + 1,
+				Answers:     []answer0007{{Response: comment.Content}},
+				SubmittedAt: comment.CreatedAt,
+			}
+			if err := tx.Create(&submission).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&comment).Error; err != nil {
+				return err
+			}
+			converted++
+		}
+	}
+
+	if converted > 0 {
+		log.Printf("已将 %d 条评论中的学生答案转换为提交记录", converted)
+	}
+	return nil
+}
+
+// restoreCommentAnswers 将提交记录写回为学生评论
+func restoreCommentAnswers(tx *gorm.DB) error {
+	var submissions []submission0007
+	err := tx.Preload("Answers", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Answers.Question").
+		Order("submitted_at, id").Find(&submissions).Error
+	if err != nil {
+		return err
+	}
+
+	for _, submission := range submissions {
+		comment := comment0001{
+			ExamID:    submission.ExamID,
+			UserID:    submission.StudentID,
+			Content:   submission.text(),
+			CreatedAt: submission.SubmittedAt,
+		}
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 自动评分：题目的评分规则、提交的评分状态和总分、每道题的得分
+// 已有提交默认为待批阅状态
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "auto_grading",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&question0008{}, &submission0008{}, &answer0008{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			columns := []struct {
+				table  string
+				column string
+			}{
+				{"questions", "partial_credit"},
+				{"questions", "match_mode"},
+				{"questions", "tolerance"},
+				{"submissions", "status"},
+				{"submissions", "score"},
+				{"answers", "score"},
+				{"answers", "auto_graded"},
+				{"answers", "graded_at"},
+			}
+			for _, c := range columns {
+				if err := tx.Table(c.table).DropColumn(c.column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// question0008 题目表新增的评分规则列
+type question0008 struct {
+	PartialCredit string `gorm:"size:20"`
+	MatchMode     string `gorm:"size:20"`
+	Tolerance     float64
+}
+
+// TableName 表名
+func (question0008) TableName() string { return "questions" }
+
+// submission0008 提交表新增的评分状态和总分列
+type submission0008 struct {
+	Status string `gorm:"size:20;not null;default:'pending_review'"`
+	Score  float64
+}
+
+// TableName 表名
+func (submission0008) TableName() string { return "submissions" }
+
+// answer0008 作答表新增的得分列，未评分时得分为空
+type answer0008 struct {
+	Score      *float64
+	AutoGraded bool
+	GradedAt   *time.Time
+}
+
+// TableName 表名
+func (answer0008) TableName() string { return "answers" }
@@ -0,0 +1,73 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// 主观题评分标准、按评分维度的得分，以及作答的评分教师和反馈
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "rubrics",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rubricCriterion0009{}, &rubricLevel0009{}, &criterionScore0009{}, &answer0009{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.DropTableIfExists("criterion_scores", "rubric_levels", "rubric_criterions").Error; err != nil {
+				return err
+			}
+			for _, column := range []string{"graded_by", "feedback"} {
+				if err := tx.Table("answers").DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// rubricCriterion0009 评分维度表
+type rubricCriterion0009 struct {
+	ID          uint   `gorm:"primary_key"`
+	QuestionID  uint   `gorm:"index;not null"`
+	Position    int    `gorm:"not null"`
+	Name        string `gorm:"size:100;not null"`
+	Description string `gorm:"type:text"`
+}
+
+// TableName 表名
+func (rubricCriterion0009) TableName() string { return "rubric_criterions" }
+
+// rubricLevel0009 评分维度等级表
+type rubricLevel0009 struct {
+	ID          uint    `gorm:"primary_key"`
+	CriterionID uint    `gorm:"index;not null"`
+	Position    int     `gorm:"not null"`
+	Label       string  `gorm:"size:50;not null"`
+	Description string  `gorm:"type:text"`
+	Points      float64 `gorm:"not null"`
+}
+
+// TableName 表名
+func (rubricLevel0009) TableName() string { return "rubric_levels" }
+
+// criterionScore0009 按评分维度的得分表
+type criterionScore0009 struct {
+	ID          uint    `gorm:"primary_key"`
+	AnswerID    uint    `gorm:"index;not null"`
+	CriterionID uint    `gorm:"not null"`
+	LevelID     uint    `gorm:"not null"`
+	Points      float64 `gorm:"not null"`
+}
+
+// TableName 表名
+func (criterionScore0009) TableName() string { return "criterion_scores" }
+
+// answer0009 作答表新增的评分教师和反馈列
+type answer0009 struct {
+	GradedBy uint
+	Feedback string `gorm:"type:text"`
+}
+
+// TableName 表名
+func (answer0009) TableName() string { return "answers" }
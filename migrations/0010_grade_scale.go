@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// 成绩等级制设置，已有设置默认使用百分制
+func init() {
+	register(Migration{
+		Version: 10,
+		Name:    "grade_scale",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&systemSettings0010{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Table("system_settings").DropColumn("grade_scale").Error
+		},
+	})
+}
+
+// systemSettings0010 系统设置表新增的成绩等级制列
+type systemSettings0010 struct {
+	GradeScale string `gorm:"size:20;not null;default:'percent'"`
+}
+
+// TableName 表名
+func (systemSettings0010) TableName() string { return "system_settings" }
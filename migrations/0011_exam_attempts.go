@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 限时作答：作答会话表、提交对应的会话及逾期标记、逾期交卷宽限期设置
+// 已有提交没有对应的会话
+func init() {
+	register(Migration{
+		Version: 11,
+		Name:    "exam_attempts",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&examAttempt0011{}, &submission0011{}, &systemSettings0011{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.DropTableIfExists("exam_attempts").Error; err != nil {
+				return err
+			}
+			if err := tx.Table("submissions").RemoveIndex("idx_submissions_attempt_id").Error; err != nil {
+				return err
+			}
+			columns := []struct {
+				table  string
+				column string
+			}{
+				{"submissions", "attempt_id"},
+				{"submissions", "late"},
+				{"submissions", "auto_submitted"},
+				{"system_settings", "late_submission_grace"},
+			}
+			for _, c := range columns {
+				if err := tx.Table(c.table).DropColumn(c.column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// examAttempt0011 作答会话表，截止时间为空表示不限时
+type examAttempt0011 struct {
+	ID            uint   `gorm:"primary_key"`
+	ExamDataID    uint   `gorm:"index;not null"`
+	ExamID        uint   `gorm:"index;not null"`
+	StudentID     uint   `gorm:"index;not null"`
+	Status        string `gorm:"size:20;not null;default:'in_progress'"`
+	StartedAt     time.Time
+	Deadline      *time.Time `gorm:"index"`
+	Draft         string     `gorm:"type:text"`
+	SubmittedAt   *time.Time
+	SubmissionID  uint
+	Late          bool
+	AutoSubmitted bool
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// TableName 表名
+func (examAttempt0011) TableName() string { return "exam_attempts" }
+
+// submission0011 提交表新增的作答会话和逾期标记列
+type submission0011 struct {
+	AttemptID     uint `gorm:"index"`
+	Late          bool
+	AutoSubmitted bool
+}
+
+// TableName 表名
+func (submission0011) TableName() string { return "submissions" }
+
+// systemSettings0011 系统设置表新增的逾期交卷宽限期列
+type systemSettings0011 struct {
+	LateSubmissionGrace int `gorm:"not null;default:0"`
+}
+
+// TableName 表名
+func (systemSettings0011) TableName() string { return "system_settings" }
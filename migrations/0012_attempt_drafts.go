@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 作答暂存版本，用于自动保存时检测多个页面同时修改
+func init() {
+	register(Migration{
+		Version: 12,
+		Name:    "attempt_drafts",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&examAttempt0012{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"draft_version", "draft_saved_at"} {
+				if err := tx.Table("exam_attempts").DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// examAttempt0012 作答会话表新增的暂存版本列
+type examAttempt0012 struct {
+	DraftVersion int `gorm:"not null;default:0"`
+	DraftSavedAt *time.Time
+}
+
+// TableName 表名
+func (examAttempt0012) TableName() string { return "exam_attempts" }
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// 考试的作答次数、作答间隔和多次作答计分方式，已有考试不限次数并按最近一次提交计分
+func init() {
+	register(Migration{
+		Version: 13,
+		Name:    "attempt_policy",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&exam0013{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"max_attempts", "attempt_cooldown", "score_policy"} {
+				if err := tx.Table("exams").DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// exam0013 考试表新增的作答次数、间隔和计分方式列
+type exam0013 struct {
+	MaxAttempts     int    `gorm:"not null;default:0"`
+	AttemptCooldown int    `gorm:"not null;default:0"`
+	ScorePolicy     string `gorm:"size:20;not null;default:'latest'"`
+}
+
+// TableName 表名
+func (exam0013) TableName() string { return "exams" }
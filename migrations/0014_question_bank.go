@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 题库：题目、标签和历史版本，试卷题目记录引用的题库题目及版本
+func init() {
+	register(Migration{
+		Version: 14,
+		Name:    "question_bank",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&bankQuestion0014{}, &bankTag0014{}, &bankQuestionVersion0014{}, &question0014{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.DropTableIfExists("bank_question_versions", "bank_tags", "bank_questions").Error; err != nil {
+				return err
+			}
+			if err := tx.Table("questions").RemoveIndex("idx_questions_bank_question_id").Error; err != nil {
+				return err
+			}
+			for _, column := range []string{"bank_question_id", "bank_version"} {
+				if err := tx.Table("questions").DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// bankQuestion0014 题库题目表，完整题目以JSON保存在Body中
+type bankQuestion0014 struct {
+	ID         uint   `gorm:"primary_key"`
+	OwnerID    uint   `gorm:"index;not null"`
+	Course     string `gorm:"size:100;index;not null"`
+	Shared     bool
+	Type       string  `gorm:"size:20;not null"`
+	Content    string  `gorm:"type:text;not null"`
+	Score      float64 `gorm:"not null"`
+	Difficulty int     `gorm:"not null;default:3"`
+	Version    int     `gorm:"not null;default:1"`
+	Body       string  `gorm:"type:text;not null"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// TableName 表名
+func (bankQuestion0014) TableName() string { return "bank_questions" }
+
+// bankTag0014 题库题目标签表
+type bankTag0014 struct {
+	ID             uint   `gorm:"primary_key"`
+	BankQuestionID uint   `gorm:"index;not null"`
+	Kind           string `gorm:"size:20;not null"`
+	Name           string `gorm:"size:50;not null;index"`
+}
+
+// TableName 表名
+func (bankTag0014) TableName() string { return "bank_tags" }
+
+// bankQuestionVersion0014 题库题目历史版本表
+type bankQuestionVersion0014 struct {
+	ID             uint   `gorm:"primary_key"`
+	BankQuestionID uint   `gorm:"index;not null"`
+	Version        int    `gorm:"not null"`
+	EditorID       uint   `gorm:"not null"`
+	Body           string `gorm:"type:text;not null"`
+	CreatedAt      time.Time
+}
+
+// TableName 表名
+func (bankQuestionVersion0014) TableName() string { return "bank_question_versions" }
+
+// question0014 题目表新增的题库引用列
+type question0014 struct {
+	BankQuestionID uint `gorm:"index"`
+	BankVersion    int
+}
+
+// TableName 表名
+func (question0014) TableName() string { return "questions" }
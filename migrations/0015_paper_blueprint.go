@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// 试卷记录生成时使用的组卷方案及随机种子，已有试卷为空
+func init() {
+	register(Migration{
+		Version: 15,
+		Name:    "paper_blueprint",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&paper0015{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Table("papers").DropColumn("blueprint").Error
+		},
+	})
+}
+
+// paper0015 试卷表新增的组卷方案列
+type paper0015 struct {
+	Blueprint string `gorm:"type:text"`
+}
+
+// TableName 表名
+func (paper0015) TableName() string { return "papers" }
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 考试状态变更记录表，已有考试没有变更记录
+func init() {
+	register(Migration{
+		Version: 16,
+		Name:    "exam_transitions",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&examTransition0016{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.DropTableIfExists("exam_transitions").Error
+		},
+	})
+}
+
+// examTransition0016 考试状态变更记录表
+type examTransition0016 struct {
+	ID         uint   `gorm:"primary_key"`
+	ExamID     uint   `gorm:"index;not null"`
+	Action     string `gorm:"size:20;not null"`
+	FromStatus string `gorm:"size:20"`
+	ToStatus   string `gorm:"size:20;not null"`
+	ActorID    uint
+	ActorRole  string `gorm:"size:20"`
+	Comment    string `gorm:"size:1000"`
+	CreatedAt  time.Time
+}
+
+// TableName 表名
+func (examTransition0016) TableName() string { return "exam_transitions" }
@@ -0,0 +1,86 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 各科目的多级审批链及考试的审批任务，已有考试没有审批任务，由管理员审批
+func init() {
+	register(Migration{
+		Version: 17,
+		Name:    "approval_chains",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(
+				&approvalChain0017{},
+				&approvalStage0017{},
+				&approvalStageApprover0017{},
+				&examApprovalTask0017{},
+			).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.DropTableIfExists(
+				"exam_approval_tasks",
+				"approval_stage_approvers",
+				"approval_stages",
+				"approval_chains",
+			).Error
+		},
+	})
+}
+
+// approvalChain0017 审批链表，每个科目一条
+type approvalChain0017 struct {
+	ID        uint   `gorm:"primary_key"`
+	Course    string `gorm:"size:100;not null;unique_index"`
+	Name      string `gorm:"size:100"`
+	Mode      string `gorm:"size:20;not null;default:'sequential'"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName 表名
+func (approvalChain0017) TableName() string { return "approval_chains" }
+
+// approvalStage0017 审批链的各级审批表
+type approvalStage0017 struct {
+	ID                uint   `gorm:"primary_key"`
+	ChainID           uint   `gorm:"index;not null"`
+	Position          int    `gorm:"not null"`
+	Name              string `gorm:"size:100;not null"`
+	RequiredApprovals int    `gorm:"not null;default:1"`
+}
+
+// TableName 表名
+func (approvalStage0017) TableName() string { return "approval_stages" }
+
+// approvalStageApprover0017 各级审批的审批人表
+type approvalStageApprover0017 struct {
+	ID      uint `gorm:"primary_key"`
+	StageID uint `gorm:"index;not null"`
+	UserID  uint `gorm:"not null"`
+}
+
+// TableName 表名
+func (approvalStageApprover0017) TableName() string { return "approval_stage_approvers" }
+
+// examApprovalTask0017 考试审批任务表
+type examApprovalTask0017 struct {
+	ID         uint `gorm:"primary_key"`
+	ExamID     uint `gorm:"index;not null"`
+	ChainID    uint
+	Round      int    `gorm:"not null"`
+	Stage      int    `gorm:"not null"`
+	StageName  string `gorm:"size:100"`
+	Required   int    `gorm:"not null"`
+	ApproverID uint   `gorm:"index;not null"`
+	Status     string `gorm:"size:20;not null"`
+	Comment    string `gorm:"size:1000"`
+	DecidedAt  *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// TableName 表名
+func (examApprovalTask0017) TableName() string { return "exam_approval_tasks" }
@@ -0,0 +1,86 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 审批委托表、审批任务的实际审批人和超时转交记录、状态变更记录的委托人，以及超时转交设置
+func init() {
+	register(Migration{
+		Version: 18,
+		Name:    "approval_delegation",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(
+				&approvalDelegation0018{},
+				&examApprovalTask0018{},
+				&examTransition0018{},
+				&systemSettings0018{},
+			).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.DropTableIfExists("approval_delegations").Error; err != nil {
+				return err
+			}
+			columns := []struct {
+				table  string
+				column string
+			}{
+				{"exam_approval_tasks", "decided_by_id"},
+				{"exam_approval_tasks", "escalated_from_id"},
+				{"exam_approval_tasks", "escalated_at"},
+				{"exam_transitions", "on_behalf_of_id"},
+				{"system_settings", "approval_escalation_hours"},
+				{"system_settings", "approval_fallback_approver"},
+			}
+			for _, c := range columns {
+				if err := tx.Table(c.table).DropColumn(c.column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// approvalDelegation0018 审批委托表
+type approvalDelegation0018 struct {
+	ID         uint      `gorm:"primary_key"`
+	UserID     uint      `gorm:"index;not null"`
+	DelegateID uint      `gorm:"index;not null"`
+	StartTime  time.Time `gorm:"not null"`
+	EndTime    time.Time `gorm:"not null"`
+	Reason     string    `gorm:"size:200"`
+	CreatedAt  time.Time
+}
+
+// TableName 表名
+func (approvalDelegation0018) TableName() string { return "approval_delegations" }
+
+// examApprovalTask0018 审批任务表新增的实际审批人和超时转交列
+type examApprovalTask0018 struct {
+	DecidedByID     uint
+	EscalatedFromID uint
+	EscalatedAt     *time.Time
+}
+
+// TableName 表名
+func (examApprovalTask0018) TableName() string { return "exam_approval_tasks" }
+
+// examTransition0018 状态变更记录表新增的委托人列
+type examTransition0018 struct {
+	OnBehalfOfID uint
+}
+
+// TableName 表名
+func (examTransition0018) TableName() string { return "exam_transitions" }
+
+// systemSettings0018 系统设置表新增的超时转交列
+type systemSettings0018 struct {
+	ApprovalEscalationHours  int  `gorm:"not null;default:0"`
+	ApprovalFallbackApprover uint `gorm:"not null;default:0"`
+}
+
+// TableName 表名
+func (systemSettings0018) TableName() string { return "system_settings" }
@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 科目审批时限表和超时审批记录表，已经超时的待审批考试在下一次检查时标记
+func init() {
+	register(Migration{
+		Version: 19,
+		Name:    "approval_sla",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&approvalSLA0019{}, &overdueApproval0019{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.DropTableIfExists("overdue_approvals", "approval_slas").Error
+		},
+	})
+}
+
+// approvalSLA0019 科目审批时限表
+type approvalSLA0019 struct {
+	ID        uint   `gorm:"primary_key"`
+	Course    string `gorm:"size:100;not null;unique_index"`
+	Hours     int    `gorm:"not null"`
+	UpdatedBy uint
+	UpdatedAt time.Time
+}
+
+// TableName 表名
+func (approvalSLA0019) TableName() string { return "approval_slas" }
+
+// overdueApproval0019 超时审批记录表，每次提交审批最多一条
+type overdueApproval0019 struct {
+	ID             uint   `gorm:"primary_key"`
+	ExamID         uint   `gorm:"index;not null"`
+	TransitionID   uint   `gorm:"not null;unique_index"`
+	Course         string `gorm:"size:100"`
+	SubmittedAt    time.Time
+	DueAt          time.Time
+	FlaggedAt      time.Time
+	ResolvedAt     *time.Time
+	ResolvedAction string `gorm:"size:20"`
+}
+
+// TableName 表名
+func (overdueApproval0019) TableName() string { return "overdue_approvals" }
@@ -0,0 +1,102 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 评论类型、可见范围、回复、定位、解决状态和编辑历史。
+// 已有评论默认是仅教师和管理员可见的审批意见；学生的评论改为讨论；
+// 批阅过该考试答卷的教师发表、且不是其审批时填写的意见的评论改为批阅评语
+func init() {
+	register(Migration{
+		Version: 20,
+		Name:    "comment_threads",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&comment0020{}, &commentRevision0020{}).Error; err != nil {
+				return err
+			}
+			// 新增的列在已有评论中为NULL，按顶层评论且没有定位处理
+			err := tx.Table("comments").Where("parent_id IS NULL").Updates(map[string]interface{}{
+				"parent_id":    0,
+				"paper_id":     0,
+				"question_id":  0,
+				"exam_data_id": 0,
+				"resolved_by":  0,
+			}).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Table("comments").
+				Where("user_id IN (SELECT id FROM users WHERE role = ?)", "student").
+				Updates(map[string]interface{}{
+					"kind":       "discussion",
+					"visibility": "public",
+				}).Error
+			if err != nil {
+				return err
+			}
+			return tx.Table("comments").
+				Where("kind = ?", "review").
+				Where("EXISTS (SELECT 1 FROM exam_data WHERE exam_data.exam_id = comments.exam_id AND exam_data.approver_id = comments.user_id)").
+				Where("NOT EXISTS (SELECT 1 FROM exam_transitions WHERE exam_transitions.exam_id = comments.exam_id AND exam_transitions.actor_id = comments.user_id AND exam_transitions.comment = comments.content)").
+				Updates(map[string]interface{}{
+					"kind":       "grading",
+					"visibility": "public",
+				}).Error
+		},
+		// 回滚时回复变为普通评论，编辑历史删除
+		Down: func(tx *gorm.DB) error {
+			if err := tx.DropTableIfExists("comment_revisions").Error; err != nil {
+				return err
+			}
+			for _, index := range []string{"idx_comments_exam_id", "idx_comments_parent_id"} {
+				if err := tx.Table("comments").RemoveIndex(index).Error; err != nil {
+					return err
+				}
+			}
+			columns := []string{
+				"parent_id", "kind", "visibility", "paper_id", "question_id", "exam_data_id",
+				"resolved", "resolved_by", "resolved_at", "edited_at", "updated_at",
+			}
+			for _, column := range columns {
+				if err := tx.Table("comments").DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// comment0020 评论表新增的列，exam_id补充索引
+type comment0020 struct {
+	ExamID     uint   `gorm:"index"`
+	ParentID   uint   `gorm:"index"`
+	Kind       string `gorm:"size:20;not null;default:'review'"`
+	Visibility string `gorm:"size:20;not null;default:'staff'"`
+	PaperID    uint
+	QuestionID uint
+	ExamDataID uint
+	Resolved   bool `gorm:"not null;default:false"`
+	ResolvedBy uint
+	ResolvedAt *time.Time
+	EditedAt   *time.Time
+	UpdatedAt  time.Time
+}
+
+// TableName 表名
+func (comment0020) TableName() string { return "comments" }
+
+// commentRevision0020 评论编辑历史表
+type commentRevision0020 struct {
+	ID        uint   `gorm:"primary_key"`
+	CommentID uint   `gorm:"index;not null"`
+	Content   string `gorm:"size:1000;not null"`
+	EditedBy  uint
+	CreatedAt time.Time
+}
+
+// TableName 表名
+func (commentRevision0020) TableName() string { return "comment_revisions" }
@@ -0,0 +1,207 @@
+package migrations
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 试卷历史版本表，已有试卷以当前内容记为第1版，编辑人为考试创建者
+func init() {
+	register(Migration{
+		Version: 21,
+		Name:    "paper_revisions",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&paperRevision0021{}).Error; err != nil {
+				return err
+			}
+			return recordPaperRevisions(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.DropTableIfExists("paper_revisions").Error
+		},
+	})
+}
+
+// paperRevision0021 试卷历史版本表
+type paperRevision0021 struct {
+	ID           uint   `gorm:"primary_key"`
+	PaperID      uint   `gorm:"not null;unique_index:idx_paper_revisions_paper_revision"`
+	Revision     int    `gorm:"not null;unique_index:idx_paper_revisions_paper_revision"`
+	Title        string `gorm:"size:100;not null"`
+	Content      string `gorm:"type:text"`
+	Duration     int
+	TotalScore   float64
+	PassingScore float64
+	Body         string `gorm:"type:text;not null"`
+	EditorID     uint
+	RestoredFrom int
+	CreatedAt    time.Time
+}
+
+// TableName 表名
+func (paperRevision0021) TableName() string { return "paper_revisions" }
+
+// 以下为本版本时试卷内容快照的格式，快照正文是按顺序排列的题目的JSON，
+// 0022按同一格式计算锁定哈希。之后models中快照格式的修改不影响这两个迁移写入的数据
+type revisionPaper0021 struct {
+	ID           uint
+	ExamID       uint
+	Title        string
+	Content      string
+	Questions    []revisionQuestion0021 `gorm:"foreignkey:PaperID"`
+	Duration     int
+	TotalScore   float64
+	PassingScore float64
+	UpdatedAt    time.Time
+}
+
+// TableName 表名
+func (revisionPaper0021) TableName() string { return "papers" }
+
+type revisionQuestion0021 struct {
+	ID             uint                    `json:"id"`
+	PaperID        uint                    `json:"paper_id"`
+	Position       int                     `json:"position"`
+	Type           string                  `json:"type"`
+	Content        string                  `json:"content"`
+	Score          float64                 `json:"score"`
+	Answer         string                  `json:"answer,omitempty"`
+	Options        []revisionOption0021    `gorm:"foreignkey:QuestionID" json:"options"`
+	Rubric         []revisionCriterion0021 `gorm:"foreignkey:QuestionID" json:"rubric,omitempty"`
+	PartialCredit  string                  `json:"partial_credit,omitempty"`
+	MatchMode      string                  `json:"match_mode,omitempty"`
+	Tolerance      float64                 `json:"tolerance,omitempty"`
+	BankQuestionID uint                    `json:"bank_question_id,omitempty"`
+	BankVersion    int                     `json:"bank_version,omitempty"`
+	CreatedAt      time.Time               `json:"created_at"`
+	UpdatedAt      time.Time               `json:"updated_at"`
+}
+
+// TableName 表名
+func (revisionQuestion0021) TableName() string { return "questions" }
+
+type revisionOption0021 struct {
+	ID         uint   `json:"id"`
+	QuestionID uint   `json:"question_id"`
+	Position   int    `json:"position"`
+	Label      string `json:"label"`
+	Content    string `json:"content"`
+	IsCorrect  bool   `json:"is_correct,omitempty"`
+}
+
+// TableName 表名
+func (revisionOption0021) TableName() string { return "question_options" }
+
+type revisionCriterion0021 struct {
+	ID          uint                `json:"id"`
+	QuestionID  uint                `json:"question_id"`
+	Position    int                 `json:"position"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Levels      []revisionLevel0021 `gorm:"foreignkey:CriterionID" json:"levels"`
+}
+
+// TableName 表名
+func (revisionCriterion0021) TableName() string { return "rubric_criterions" }
+
+type revisionLevel0021 struct {
+	ID          uint    `json:"id"`
+	CriterionID uint    `json:"criterion_id"`
+	Position    int     `json:"position"`
+	Label       string  `json:"label"`
+	Description string  `json:"description"`
+	Points      float64 `json:"points"`
+}
+
+// TableName 表名
+func (revisionLevel0021) TableName() string { return "rubric_levels" }
+
+// loadRevisionPapers 按顺序加载符合条件的试卷及其题目、选项和评分标准
+func loadRevisionPapers(db *gorm.DB) ([]revisionPaper0021, error) {
+	var papers []revisionPaper0021
+	err := db.
+		Preload("Questions", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Questions.Options", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Questions.Rubric", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Questions.Rubric.Levels", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Order("id").Find(&papers).Error
+	return papers, err
+}
+
+// revisionBody 生成快照正文，去除随保存变化的字段：题目所属试卷和时间，选项和评分标准的ID
+func (p *revisionPaper0021) revisionBody() (string, error) {
+	questions := make([]revisionQuestion0021, len(p.Questions))
+	for i, question := range p.Questions {
+		question.PaperID = 0
+		question.CreatedAt = time.Time{}
+		question.UpdatedAt = time.Time{}
+
+		options := make([]revisionOption0021, len(question.Options))
+		for j, option := range question.Options {
+			option.ID = 0
+			option.QuestionID = 0
+			options[j] = option
+		}
+		question.Options = options
+
+		rubric := make([]revisionCriterion0021, len(question.Rubric))
+		for j, criterion := range question.Rubric {
+			criterion.ID = 0
+			criterion.QuestionID = 0
+			levels := make([]revisionLevel0021, len(criterion.Levels))
+			for k, level := range criterion.Levels {
+				level.ID = 0
+				level.CriterionID = 0
+				levels[k] = level
+			}
+			criterion.Levels = levels
+			rubric[j] = criterion
+		}
+		question.Rubric = rubric
+		questions[i] = question
+	}
+	data, err := json.Marshal(questions)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// recordPaperRevisions 为每份已有试卷记录第一个版本
+func recordPaperRevisions(tx *gorm.DB) error {
+	papers, err := loadRevisionPapers(tx)
+	if err != nil {
+		return err
+	}
+
+	for i := range papers {
+		paper := &papers[i]
+		body, err := paper.revisionBody()
+		if err != nil {
+			return err
+		}
+		revision := paperRevision0021{
+			PaperID:      paper.ID,
+			Revision:     1,
+			Title:        paper.Title,
+			Content:      paper.Content,
+			Duration:     paper.Duration,
+			TotalScore:   paper.TotalScore,
+			PassingScore: paper.PassingScore,
+			Body:         body,
+			CreatedAt:    paper.UpdatedAt,
+		}
+		var exam exam0001
+		if err := tx.Select("creator_id").First(&exam, paper.ExamID).Error; err == nil {
+			revision.EditorID = exam.CreatorID
+		} else if !gorm.IsRecordNotFoundError(err) {
+			return err
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 试卷锁定和审计事件。已审批、已发布、已关闭和已归档考试的试卷以当前内容锁定，
+// 锁定的版本为其最新版本，审计事件记为迁移锁定
+func init() {
+	register(Migration{
+		Version: 22,
+		Name:    "paper_locks",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&paper0022{}, &paperAuditEvent0022{}).Error; err != nil {
+				return err
+			}
+			return lockApprovedPapers(tx)
+		},
+		// 回滚时锁定的试卷恢复为草稿状态，审计事件删除
+		Down: func(tx *gorm.DB) error {
+			if err := tx.DropTableIfExists("paper_audit_events").Error; err != nil {
+				return err
+			}
+			err := tx.Table("papers").Where("locked_at IS NOT NULL").
+				UpdateColumn("status", "draft").Error
+			if err != nil {
+				return err
+			}
+			for _, column := range []string{"locked_hash", "locked_at", "locked_revision"} {
+				if err := tx.Table("papers").DropColumn(column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// paper0022 试卷表新增的锁定列
+type paper0022 struct {
+	LockedHash     string `gorm:"size:64"`
+	LockedAt       *time.Time
+	LockedRevision int
+}
+
+// TableName 表名
+func (paper0022) TableName() string { return "papers" }
+
+// paperAuditEvent0022 试卷审计事件表
+type paperAuditEvent0022 struct {
+	ID           uint   `gorm:"primary_key"`
+	PaperID      uint   `gorm:"index;not null"`
+	ExamID       uint   `gorm:"index;not null"`
+	Event        string `gorm:"size:20;not null"`
+	ExpectedHash string `gorm:"size:64"`
+	ActualHash   string `gorm:"size:64"`
+	Source       string `gorm:"size:50"`
+	UserID       uint
+	CreatedAt    time.Time
+}
+
+// TableName 表名
+func (paperAuditEvent0022) TableName() string { return "paper_audit_events" }
+
+// contentHash 按本版本时的规则计算试卷内容哈希：标题、说明、时长、分值和0021格式的快照正文
+func (p *revisionPaper0021) contentHash() (string, error) {
+	body, err := p.revisionBody()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(struct {
+		Title        string          `json:"title"`
+		Content      string          `json:"content"`
+		Duration     int             `json:"duration"`
+		TotalScore   float64         `json:"total_score"`
+		PassingScore float64         `json:"passing_score"`
+		Questions    json.RawMessage `json:"questions"`
+	}{
+		Title:        p.Title,
+		Content:      p.Content,
+		Duration:     p.Duration,
+		TotalScore:   p.TotalScore,
+		PassingScore: p.PassingScore,
+		Questions:    json.RawMessage(body),
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// lockApprovedPapers 锁定已审批考试的全部试卷
+func lockApprovedPapers(tx *gorm.DB) error {
+	statuses := []string{"approved", "published", "closed", "archived"}
+	exams := tx.Table("exams").Select("id").Where("status IN (?)", statuses).SubQuery()
+
+	papers, err := loadRevisionPapers(tx.Where("exam_id IN ?", exams))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range papers {
+		paper := &papers[i]
+		hash, err := paper.contentHash()
+		if err != nil {
+			return err
+		}
+		var latest paperRevision0021
+		err = tx.Where("paper_id = ?", paper.ID).Order("revision desc").First(&latest).Error
+		if err != nil && !gorm.IsRecordNotFoundError(err) {
+			return err
+		}
+		err = tx.Table("papers").Where("id = ?", paper.ID).UpdateColumns(map[string]interface{}{
+			"status":          "approved",
+			"locked_hash":     hash,
+			"locked_at":       now,
+			"locked_revision": latest.Revision,
+		}).Error
+		if err != nil {
+			return err
+		}
+		event := paperAuditEvent0022{
+			PaperID:      paper.ID,
+			ExamID:       paper.ExamID,
+			Event:        "locked",
+			ExpectedHash: hash,
+			ActualHash:   hash,
+			Source:       "migration",
+		}
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
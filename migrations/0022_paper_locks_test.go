@@ -0,0 +1,126 @@
+package migrations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/exam-approval-system/models"
+)
+
+// 迁移中冻结的快照格式和哈希在发布时须与models一致，否则迁移锁定的试卷读取时会被判为篡改
+func TestFrozenContentHashMatchesModels(t *testing.T) {
+	now := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		paper models.Paper
+	}{
+		{
+			name:  "没有题目",
+			paper: models.Paper{ID: 1, ExamID: 1, Title: "空试卷", Duration: 60, TotalScore: 100, PassingScore: 60},
+		},
+		{
+			name: "选择题和判断题",
+			paper: models.Paper{
+				ID: 2, ExamID: 1, Title: "期中", Content: "闭卷", Duration: 90, TotalScore: 10, PassingScore: 6,
+				Questions: []models.Question{
+					{
+						ID: 5, PaperID: 2, Position: 1, Type: "multiple_choice", Content: "1+1=?", Score: 5,
+						PartialCredit: "proportional", CreatedAt: now, UpdatedAt: now,
+						Options: []models.QuestionOption{
+							{ID: 9, QuestionID: 5, Position: 1, Label: "A", Content: "2", IsCorrect: true},
+							{ID: 10, QuestionID: 5, Position: 2, Label: "B", Content: "3"},
+						},
+					},
+					{ID: 6, PaperID: 2, Position: 2, Type: "true_false", Content: "地球是圆的", Score: 5, Answer: "true"},
+				},
+			},
+		},
+		{
+			name: "评分标准、数值题和题库引用",
+			paper: models.Paper{
+				ID: 3, ExamID: 2, Title: "期末", Duration: 120, TotalScore: 30.5, PassingScore: 18,
+				Questions: []models.Question{
+					{
+						ID: 7, PaperID: 3, Position: 1, Type: "essay", Content: "论述", Score: 20, Answer: "要点",
+						BankQuestionID: 4, BankVersion: 2,
+						Rubric: []models.RubricCriterion{
+							{
+								ID: 1, QuestionID: 7, Position: 1, Name: "论点", Description: "是否明确",
+								Levels: []models.RubricLevel{
+									{ID: 1, CriterionID: 1, Position: 1, Label: "优秀", Points: 10},
+									{ID: 2, CriterionID: 1, Position: 2, Label: "及格", Description: "基本明确", Points: 6},
+								},
+							},
+						},
+					},
+					{ID: 8, PaperID: 3, Position: 2, Type: "numeric", Content: "π≈?", Score: 10.5, Answer: "3.14", Tolerance: 0.01},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := tt.paper.ContentHash()
+			if err != nil {
+				t.Fatal(err)
+			}
+			revision, err := models.NewPaperRevision(&tt.paper)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			frozen := frozenPaper(&tt.paper)
+			body, err := frozen.revisionBody()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body != revision.Body {
+				t.Errorf("快照正文不一致:\n got %s\nwant %s", body, revision.Body)
+			}
+			got, err := frozen.contentHash()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("哈希 = %s, 期望 %s", got, want)
+			}
+		})
+	}
+}
+
+// frozenPaper 把models中的试卷转换为迁移加载的结构
+func frozenPaper(p *models.Paper) *revisionPaper0021 {
+	paper := &revisionPaper0021{
+		ID: p.ID, ExamID: p.ExamID, Title: p.Title, Content: p.Content,
+		Duration: p.Duration, TotalScore: p.TotalScore, PassingScore: p.PassingScore, UpdatedAt: p.UpdatedAt,
+	}
+	for _, q := range p.Questions {
+		question := revisionQuestion0021{
+			ID: q.ID, PaperID: q.PaperID, Position: q.Position, Type: q.Type, Content: q.Content,
+			Score: q.Score, Answer: q.Answer, PartialCredit: q.PartialCredit, MatchMode: q.MatchMode,
+			Tolerance: q.Tolerance, BankQuestionID: q.BankQuestionID, BankVersion: q.BankVersion,
+			CreatedAt: q.CreatedAt, UpdatedAt: q.UpdatedAt,
+		}
+		for _, o := range q.Options {
+			question.Options = append(question.Options, revisionOption0021{
+				ID: o.ID, QuestionID: o.QuestionID, Position: o.Position, Label: o.Label,
+				Content: o.Content, IsCorrect: o.IsCorrect,
+			})
+		}
+		for _, c := range q.Rubric {
+			criterion := revisionCriterion0021{
+				ID: c.ID, QuestionID: c.QuestionID, Position: c.Position, Name: c.Name, Description: c.Description,
+			}
+			for _, l := range c.Levels {
+				criterion.Levels = append(criterion.Levels, revisionLevel0021{
+					ID: l.ID, CriterionID: l.CriterionID, Position: l.Position, Label: l.Label,
+					Description: l.Description, Points: l.Points,
+				})
+			}
+			question.Rubric = append(question.Rubric, criterion)
+		}
+		paper.Questions = append(paper.Questions, question)
+	}
+	return paper
+}
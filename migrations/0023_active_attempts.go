@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// 每条答题记录最多一个作答中的会话：作答中的会话记录答题记录ID并建立唯一索引，结束后清空。
+// 已有多个作答中的会话时只标记最新的一个，与读取作答中会话时的顺序一致，其余的由超时自动交卷结束
+func init() {
+	register(Migration{
+		Version: 23,
+		Name:    "active_attempts",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&examAttempt0023{}).Error; err != nil {
+				return err
+			}
+
+			var attempts []struct {
+				ID         uint
+				ExamDataID uint
+			}
+			err := tx.Table("exam_attempts").Select("id, exam_data_id").
+				Where("status = ?", "in_progress").Order("id desc").Scan(&attempts).Error
+			if err != nil {
+				return err
+			}
+			marked := make(map[uint]bool)
+			for _, attempt := range attempts {
+				if marked[attempt.ExamDataID] {
+					continue
+				}
+				marked[attempt.ExamDataID] = true
+				err := tx.Table("exam_attempts").Where("id = ?", attempt.ID).
+					Update("active_exam_data_id", attempt.ExamDataID).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Table("exam_attempts").RemoveIndex("uix_exam_attempts_active_exam_data_id").Error; err != nil {
+				return err
+			}
+			return tx.Table("exam_attempts").DropColumn("active_exam_data_id").Error
+		},
+	})
+}
+
+// examAttempt0023 作答会话表新增的作答中答题记录列，结束的会话为空，不受唯一索引限制
+type examAttempt0023 struct {
+	ActiveExamDataID *uint `gorm:"unique_index"`
+}
+
+// TableName 表名
+func (examAttempt0023) TableName() string { return "exam_attempts" }
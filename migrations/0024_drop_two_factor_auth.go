@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// 删除系统设置中从未生效的双因素认证开关
+func init() {
+	register(Migration{
+		Version: 24,
+		Name:    "drop_two_factor_auth",
+		Up: func(tx *gorm.DB) error {
+			return tx.Table("system_settings").DropColumn("two_factor_auth").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&systemSettings0024{}).Error
+		},
+	})
+}
+
+// systemSettings0024 系统设置表回滚时恢复的双因素认证开关
+type systemSettings0024 struct {
+	TwoFactorAuth bool
+}
+
+// TableName 表名
+func (systemSettings0024) TableName() string { return "system_settings" }
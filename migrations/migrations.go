@@ -0,0 +1,304 @@
+package migrations
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Migration 一次版本化的数据库变更，Down 为空表示不可回滚
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// String 返回迁移的显示名称，例如 0001_initial_schema
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// SchemaMigration 已执行的迁移记录
+type SchemaMigration struct {
+	Version   int       `gorm:"primary_key;auto_increment:false"`
+	Name      string    `gorm:"size:100;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName 迁移记录表名
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status 迁移的执行状态
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// registry 已注册的迁移，各迁移文件在 init 中注册
+var registry []Migration
+
+// register 注册迁移，版本号重复时直接panic
+func register(m Migration) {
+	for _, existing := range registry {
+		if existing.Version == m.Version {
+			panic(fmt.Sprintf("迁移版本号重复: %s 与 %s", existing, m))
+		}
+	}
+	registry = append(registry, m)
+}
+
+// All 返回按版本号排序的全部迁移
+func All() []Migration {
+	all := append([]Migration(nil), registry...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all
+}
+
+// Migrator 迁移执行器接口
+type Migrator interface {
+	Status() ([]Status, error)
+	Pending() ([]Migration, error)
+	Current() (int, error)
+	Up() (int, error)
+	Down(steps int) (int, error)
+	To(version int) (int, error)
+}
+
+// migrator 迁移执行器实现，每个迁移在独立事务中执行
+type migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator 创建迁移执行器
+func NewMigrator(db *gorm.DB) Migrator {
+	return &migrator{
+		db:         db,
+		migrations: All(),
+	}
+}
+
+// Status 获取全部迁移的执行状态
+func (m *migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending 获取尚未执行的迁移
+func (m *migrator) Pending() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Current 获取已执行的最高版本号，没有执行过任何迁移时返回0
+func (m *migrator) Current() (int, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	current := 0
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+	return current, nil
+}
+
+// Up 执行全部未执行的迁移，返回执行的数量
+func (m *migrator) Up() (int, error) {
+	return m.upTo(m.latest())
+}
+
+// Down 回滚最近执行的 steps 个迁移，返回回滚的数量
+func (m *migrator) Down(steps int) (int, error) {
+	if steps <= 0 {
+		return 0, fmt.Errorf("回滚数量必须大于0")
+	}
+
+	applied, err := m.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if steps > len(applied) {
+		steps = len(applied)
+	}
+	return m.rollback(applied[len(applied)-steps:])
+}
+
+// To 迁移到指定版本：高于当前版本时执行迁移，低于当前版本时回滚，0表示全部回滚
+func (m *migrator) To(version int) (int, error) {
+	if version != 0 && !m.known(version) {
+		return 0, fmt.Errorf("未知的迁移版本: %d", version)
+	}
+
+	applied, err := m.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	var rollback []Migration
+	for _, migration := range applied {
+		if migration.Version > version {
+			rollback = append(rollback, migration)
+		}
+	}
+	if len(rollback) > 0 {
+		return m.rollback(rollback)
+	}
+	return m.upTo(version)
+}
+
+// upTo 按版本顺序执行版本号不超过 version 的未执行迁移
+func (m *migrator) upTo(version int) (int, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range pending {
+		if migration.Version > version {
+			break
+		}
+		err := m.transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("执行迁移 %s 失败: %v", migration, err)
+		}
+		log.Printf("已执行迁移 %s", migration)
+		count++
+	}
+	return count, nil
+}
+
+// rollback 按版本倒序回滚给定的已执行迁移
+func (m *migrator) rollback(migrations []Migration) (int, error) {
+	// 先检查是否全部可回滚，避免回滚到一半才失败
+	for _, migration := range migrations {
+		if migration.Down == nil {
+			return 0, fmt.Errorf("迁移 %s 不可回滚", migration)
+		}
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		err := m.transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Where("version = ?", migration.Version).Delete(&SchemaMigration{}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("回滚迁移 %s 失败: %v", migration, err)
+		}
+		log.Printf("已回滚迁移 %s", migration)
+		count++
+	}
+	return count, nil
+}
+
+// transaction 在事务中执行迁移
+func (m *migrator) transaction(fn func(tx *gorm.DB) error) error {
+	tx := m.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// applied 读取已执行的迁移记录，迁移记录表不存在时自动创建
+func (m *migrator) applied() (map[int]SchemaMigration, error) {
+	if err := m.db.AutoMigrate(&SchemaMigration{}).Error; err != nil {
+		return nil, fmt.Errorf("创建迁移记录表失败: %v", err)
+	}
+
+	var records []SchemaMigration
+	if err := m.db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("读取迁移记录失败: %v", err)
+	}
+
+	applied := make(map[int]SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// appliedMigrations 返回已执行且在本程序中注册的迁移，按版本号升序
+func (m *migrator) appliedMigrations() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, status := range statuses {
+		if status.Applied {
+			applied = append(applied, status.Migration)
+		}
+	}
+	return applied, nil
+}
+
+// latest 返回最新的迁移版本号
+func (m *migrator) latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// known 判断版本号是否已注册
+func (m *migrator) known(version int) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
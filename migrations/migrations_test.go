@@ -0,0 +1,214 @@
+package migrations
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+func TestMain(m *testing.M) {
+	// 迁移日志与测试结果无关
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// openTestDB 打开测试专用的空SQLite数据库
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "exam.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// userTables 返回迁移记录表以外的全部表
+func userTables(t *testing.T, db *gorm.DB) []string {
+	t.Helper()
+	var tables []string
+	err := db.Raw("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence') ORDER BY name").
+		Pluck("name", &tables).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tables
+}
+
+// 全部已注册的迁移都能执行、逐个回滚并重新执行
+func TestMigratorRegisteredMigrations(t *testing.T) {
+	db := openTestDB(t)
+	migrator := NewMigrator(db)
+	all := All()
+	latest := all[len(all)-1].Version
+
+	if n, err := migrator.Up(); err != nil || n != len(all) {
+		t.Fatalf("Up() = %d, %v, 期望 %d", n, err, len(all))
+	}
+	if current, _ := migrator.Current(); current != latest {
+		t.Errorf("Current() = %d, 期望 %d", current, latest)
+	}
+	if n, err := migrator.Up(); err != nil || n != 0 {
+		t.Errorf("重复 Up() = %d, %v, 期望 0", n, err)
+	}
+
+	for i := len(all) - 1; i >= 0; i-- {
+		if n, err := migrator.Down(1); err != nil || n != 1 {
+			t.Fatalf("回滚 %s: Down(1) = %d, %v", all[i], n, err)
+		}
+		want := 0
+		if i > 0 {
+			want = all[i-1].Version
+		}
+		if current, _ := migrator.Current(); current != want {
+			t.Fatalf("回滚 %s 后 Current() = %d, 期望 %d", all[i], current, want)
+		}
+	}
+	if tables := userTables(t, db); len(tables) != 0 {
+		t.Errorf("全部回滚后仍有表 %v", tables)
+	}
+
+	if n, err := migrator.Up(); err != nil || n != len(all) {
+		t.Fatalf("回滚后重新 Up() = %d, %v, 期望 %d", n, err, len(all))
+	}
+}
+
+func TestMigratorTo(t *testing.T) {
+	var applied []int
+	fixture := func(version int, down bool) Migration {
+		m := Migration{Version: version, Name: "fixture", Up: func(tx *gorm.DB) error {
+			applied = append(applied, version)
+			return nil
+		}}
+		if down {
+			m.Down = func(tx *gorm.DB) error {
+				applied = append(applied, -version)
+				return nil
+			}
+		}
+		return m
+	}
+
+	tests := []struct {
+		name        string
+		migrations  []Migration
+		setup       func(Migrator) error // 执行测试操作前的准备
+		run         func(Migrator) (int, error)
+		wantCount   int
+		wantErr     string
+		wantApplied []int // 测试操作按顺序执行（正数）和回滚（负数）的迁移
+		wantCurrent int
+	}{
+		{
+			name:        "向上迁移到指定版本",
+			migrations:  []Migration{fixture(1, true), fixture(2, true), fixture(3, true)},
+			run:         func(m Migrator) (int, error) { return m.To(2) },
+			wantCount:   2,
+			wantApplied: []int{1, 2},
+			wantCurrent: 2,
+		},
+		{
+			name:        "向下回滚到指定版本",
+			migrations:  []Migration{fixture(1, true), fixture(2, true), fixture(3, true)},
+			setup:       func(m Migrator) error { _, err := m.Up(); return err },
+			run:         func(m Migrator) (int, error) { return m.To(1) },
+			wantCount:   2,
+			wantApplied: []int{-3, -2},
+			wantCurrent: 1,
+		},
+		{
+			name:        "回滚到0",
+			migrations:  []Migration{fixture(1, true), fixture(2, true)},
+			setup:       func(m Migrator) error { _, err := m.Up(); return err },
+			run:         func(m Migrator) (int, error) { return m.To(0) },
+			wantCount:   2,
+			wantApplied: []int{-2, -1},
+		},
+		{
+			name:       "未知版本",
+			migrations: []Migration{fixture(1, true)},
+			run:        func(m Migrator) (int, error) { return m.To(7) },
+			wantErr:    "未知的迁移版本",
+		},
+		{
+			name:        "回滚数量超过已执行数量",
+			migrations:  []Migration{fixture(1, true), fixture(2, true), fixture(3, true)},
+			setup:       func(m Migrator) error { _, err := m.To(2); return err },
+			run:         func(m Migrator) (int, error) { return m.Down(5) },
+			wantCount:   2,
+			wantApplied: []int{-2, -1},
+		},
+		{
+			name:       "回滚数量为0",
+			migrations: []Migration{fixture(1, true)},
+			run:        func(m Migrator) (int, error) { return m.Down(0) },
+			wantErr:    "回滚数量必须大于0",
+		},
+		{
+			name:        "包含不可回滚的迁移时不回滚任何迁移",
+			migrations:  []Migration{fixture(1, false), fixture(2, true)},
+			setup:       func(m Migrator) error { _, err := m.Up(); return err },
+			run:         func(m Migrator) (int, error) { return m.Down(2) },
+			wantErr:     "不可回滚",
+			wantCurrent: 2,
+		},
+		{
+			name: "失败的迁移不记录版本并回滚其变更",
+			migrations: []Migration{fixture(1, true), {
+				Version: 2, Name: "broken",
+				Up: func(tx *gorm.DB) error {
+					if err := tx.Exec("CREATE TABLE broken (id INTEGER)").Error; err != nil {
+						return err
+					}
+					return errors.New("failed")
+				},
+			}},
+			run:         func(m Migrator) (int, error) { return m.Up() },
+			wantCount:   1,
+			wantErr:     "执行迁移 0002_broken 失败",
+			wantApplied: []int{1},
+			wantCurrent: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			migrator := &migrator{db: db, migrations: tt.migrations}
+			if tt.setup != nil {
+				if err := tt.setup(migrator); err != nil {
+					t.Fatal(err)
+				}
+			}
+			applied = nil
+
+			n, err := tt.run(migrator)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, 期望包含 %q", err, tt.wantErr)
+			}
+			if n != tt.wantCount {
+				t.Errorf("数量 = %d, 期望 %d", n, tt.wantCount)
+			}
+			if len(applied) != len(tt.wantApplied) {
+				t.Fatalf("执行的迁移 = %v, 期望 %v", applied, tt.wantApplied)
+			}
+			for i := range applied {
+				if applied[i] != tt.wantApplied[i] {
+					t.Fatalf("执行的迁移 = %v, 期望 %v", applied, tt.wantApplied)
+				}
+			}
+			if current, _ := migrator.Current(); current != tt.wantCurrent {
+				t.Errorf("Current() = %d, 期望 %d", current, tt.wantCurrent)
+			}
+			if tables := userTables(t, db); len(tables) != 0 {
+				t.Errorf("数据库中有意外的表 %v", tables)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 审批链中各级审批的进行方式
+const (
+	ApprovalSequential = "sequential" // 逐级审批，上一级通过后下一级才开始
+	ApprovalParallel   = "parallel"   // 各级同时审批，全部通过后考试才通过
+)
+
+// 审批任务状态
+const (
+	ApprovalTaskWaiting  = "waiting"  // 前面的审批级别尚未通过
+	ApprovalTaskPending  = "pending"  // 等待审批人处理
+	ApprovalTaskApproved = "approved" // 审批人已通过
+	ApprovalTaskRejected = "rejected" // 审批人已拒绝
+	ApprovalTaskSkipped  = "skipped"  // 本级已有足够的人通过，或本轮审批已结束，无需再处理
+)
+
+// MaxApprovalStages 审批链最多的级数
+const MaxApprovalStages = 10
+
+// AdminApprovalStageName 没有审批链的考试由管理员审批，超时未审批时转交给超时转交审批人的任务也使用该名称
+const AdminApprovalStageName = "管理员审批"
+
+// MaxDelegationDays 一次委托的最长天数
+const MaxDelegationDays = 180
+
+// ApprovalChain 科目的审批链：该科目的考试提交审批后按各级审批人的意见决定是否通过，
+// 没有审批链的科目由任一管理员审批
+type ApprovalChain struct {
+	ID        uint            `gorm:"primary_key" json:"id"`
+	Course    string          `gorm:"size:100;not null;unique_index" json:"course"`
+	Name      string          `gorm:"size:100" json:"name"`
+	Mode      string          `gorm:"size:20;not null;default:'sequential'" json:"mode"`
+	Stages    []ApprovalStage `gorm:"foreignkey:ChainID" json:"stages"` // 按级别排列
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// ApprovalStage 审批链中的一级审批，需要RequiredApprovals名审批人通过
+type ApprovalStage struct {
+	ID                uint                    `gorm:"primary_key" json:"id"`
+	ChainID           uint                    `gorm:"index;not null" json:"chain_id"`
+	Position          int                     `gorm:"not null" json:"position"` // 级别，从1开始
+	Name              string                  `gorm:"size:100;not null" json:"name"`
+	RequiredApprovals int                     `gorm:"not null;default:1" json:"required_approvals"`
+	Approvers         []ApprovalStageApprover `gorm:"foreignkey:StageID" json:"approvers"`
+}
+
+// ApprovalStageApprover 一级审批中的审批人
+type ApprovalStageApprover struct {
+	ID      uint `gorm:"primary_key" json:"-"`
+	StageID uint `gorm:"index;not null" json:"-"`
+	UserID  uint `gorm:"not null" json:"user_id"`
+}
+
+// ExamApprovalTask 考试提交审批后分配给审批人的审批任务，每次提交审批为一轮，
+// 每轮为审批链中每一级的每名审批人各生成一条任务。
+// 没有审批链的考试超时未审批时，会生成一条ChainID为0的任务转交给超时转交审批人
+type ExamApprovalTask struct {
+	ID              uint       `gorm:"primary_key" json:"id"`
+	ExamID          uint       `gorm:"index;not null" json:"exam_id"`
+	Exam            *Exam      `gorm:"foreignkey:ExamID" json:"exam,omitempty"`
+	ChainID         uint       `json:"chain_id"`
+	Round           int        `gorm:"not null" json:"round"` // 第几次提交审批，从1开始
+	Stage           int        `gorm:"not null" json:"stage"` // 审批级别，从1开始
+	StageName       string     `gorm:"size:100" json:"stage_name"`
+	Required        int        `gorm:"not null" json:"required"` // 本级需要通过的人数
+	ApproverID      uint       `gorm:"index;not null" json:"approver_id"`
+	Status          string     `gorm:"size:20;not null" json:"status"`
+	Comment         string     `gorm:"size:1000" json:"comment"`
+	DecidedByID     uint       `json:"decided_by_id"` // 作出决定的用户，代理审批时为代理人
+	DecidedAt       *time.Time `json:"decided_at"`
+	EscalatedFromID uint       `json:"escalated_from_id"` // 超时转交前的审批人
+	EscalatedAt     *time.Time `json:"escalated_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"` // 任务变为待处理、被转交或处理完成的时间
+}
+
+// ApprovalDelegation 审批委托：审批人在StartTime到EndTime之间不在时，由代理人处理分配给审批人的审批
+type ApprovalDelegation struct {
+	ID         uint      `gorm:"primary_key" json:"id"`
+	UserID     uint      `gorm:"index;not null" json:"user_id"`     // 委托人
+	DelegateID uint      `gorm:"index;not null" json:"delegate_id"` // 代理人
+	StartTime  time.Time `gorm:"not null" json:"start_time"`
+	EndTime    time.Time `gorm:"not null" json:"end_time"`
+	Reason     string    `gorm:"size:200" json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Validate 校验审批链并按顺序设置各级的级别
+func (c *ApprovalChain) Validate() error {
+	c.Course = strings.TrimSpace(c.Course)
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Course == "" {
+		return errors.New("科目不能为空")
+	}
+	switch c.Mode {
+	case "":
+		c.Mode = ApprovalSequential
+	case ApprovalSequential, ApprovalParallel:
+	default:
+		return errors.New("审批方式必须是sequential或parallel")
+	}
+	if len(c.Stages) == 0 || len(c.Stages) > MaxApprovalStages {
+		return fmt.Errorf("审批链需要1-%d级审批", MaxApprovalStages)
+	}
+
+	for i := range c.Stages {
+		stage := &c.Stages[i]
+		stage.Position = i + 1
+		stage.Name = strings.TrimSpace(stage.Name)
+		if stage.Name == "" {
+			return fmt.Errorf("第%d级审批的名称不能为空", stage.Position)
+		}
+		seen := make(map[uint]bool)
+		for _, approver := range stage.Approvers {
+			if approver.UserID == 0 || seen[approver.UserID] {
+				return fmt.Errorf("第%d级审批的审批人无效或重复", stage.Position)
+			}
+			seen[approver.UserID] = true
+		}
+		if stage.RequiredApprovals < 1 || stage.RequiredApprovals > len(stage.Approvers) {
+			return fmt.Errorf("第%d级审批需要通过的人数必须在1到审批人数之间", stage.Position)
+		}
+	}
+	return nil
+}
+
+// Validate 校验委托的代理人和时间范围
+func (d *ApprovalDelegation) Validate() error {
+	d.Reason = strings.TrimSpace(d.Reason)
+	if d.DelegateID == 0 || d.DelegateID == d.UserID {
+		return errors.New("代理人无效")
+	}
+	if !d.EndTime.After(d.StartTime) {
+		return errors.New("委托的结束时间必须晚于开始时间")
+	}
+	if d.EndTime.Sub(d.StartTime) > MaxDelegationDays*24*time.Hour {
+		return fmt.Errorf("一次委托不能超过%d天", MaxDelegationDays)
+	}
+	if len([]rune(d.Reason)) > 200 {
+		return errors.New("委托说明不能超过200个字符")
+	}
+	return nil
+}
+
+// Active 委托在at时是否生效
+func (d *ApprovalDelegation) Active(at time.Time) bool {
+	return !at.Before(d.StartTime) && at.Before(d.EndTime)
+}
+
+// Open 任务是否仍未处理
+func (t *ExamApprovalTask) Open() bool {
+	return t.Status == ApprovalTaskWaiting || t.Status == ApprovalTaskPending
+}
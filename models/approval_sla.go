@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxApprovalSLAHours 科目审批时限的最大值（小时）
+const MaxApprovalSLAHours = 720
+
+// ApprovalSLA 科目的审批时限：考试提交审批后超过Hours小时仍未审批通过或拒绝即为超时
+type ApprovalSLA struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	Course    string    `gorm:"size:100;not null;unique_index" json:"course"`
+	Hours     int       `gorm:"not null" json:"hours"`
+	UpdatedBy uint      `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OverdueApproval 超过科目审批时限的一次审批，每次提交审批最多标记一次，审批通过或拒绝后记录结束时间
+type OverdueApproval struct {
+	ID             uint       `gorm:"primary_key" json:"id"`
+	ExamID         uint       `gorm:"index;not null" json:"exam_id"`
+	Exam           *Exam      `gorm:"foreignkey:ExamID" json:"exam,omitempty"`
+	TransitionID   uint       `gorm:"not null;unique_index" json:"transition_id"` // 开始本次审批的提交审批记录
+	Course         string     `gorm:"size:100" json:"course"`
+	SubmittedAt    time.Time  `json:"submitted_at"`
+	DueAt          time.Time  `json:"due_at"`
+	FlaggedAt      time.Time  `json:"flagged_at"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+	ResolvedAction string     `gorm:"size:20" json:"resolved_action"` // 结束审批的操作：approve或reject
+}
+
+// Validate 校验科目审批时限
+func (s *ApprovalSLA) Validate() error {
+	s.Course = strings.TrimSpace(s.Course)
+	if s.Course == "" {
+		return errors.New("科目不能为空")
+	}
+	if s.Hours < 1 || s.Hours > MaxApprovalSLAHours {
+		return fmt.Errorf("审批时限必须在1-%d小时之间", MaxApprovalSLAHours)
+	}
+	return nil
+}
+
+// Duration 返回审批时限
+func (s *ApprovalSLA) Duration() time.Duration {
+	return time.Duration(s.Hours) * time.Hour
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 作答会话状态
+const (
+	AttemptInProgress = "in_progress" // 作答中
+	AttemptSubmitted  = "submitted"   // 学生已交卷
+	AttemptExpired    = "expired"     // 超时后由系统自动交卷
+)
+
+// ExamAttempt 学生的一次作答会话，记录开始时间和个人截止时间
+type ExamAttempt struct {
+	ID         uint `gorm:"primary_key" json:"id"`
+	ExamDataID uint `gorm:"index;not null" json:"exam_data_id"`
+	// ActiveExamDataID 作答中时为答题记录ID，结束后为空，唯一索引保证每条答题记录最多一个作答中的会话
+	ActiveExamDataID *uint      `gorm:"unique_index" json:"-"`
+	ExamID           uint       `gorm:"index;not null" json:"exam_id"`
+	StudentID        uint       `gorm:"index;not null" json:"student_id"`
+	Status           string     `gorm:"size:20;not null;default:'in_progress'" json:"status"`
+	StartedAt        time.Time  `json:"started_at"`
+	Deadline         *time.Time `gorm:"index" json:"deadline"`                   // 为空表示不限时
+	Draft            string     `gorm:"type:text" json:"-"`                      // 暂存的作答，以题目ID为键的JSON
+	DraftVersion     int        `gorm:"not null;default:0" json:"draft_version"` // 每次暂存加1，用于检测多个页面同时修改
+	DraftSavedAt     *time.Time `json:"draft_saved_at"`
+	SubmittedAt      *time.Time `json:"submitted_at"`
+	SubmissionID     uint       `json:"submission_id"`
+	Late             bool       `json:"late"`           // 超过截止时间后在宽限期内交卷
+	AutoSubmitted    bool       `json:"auto_submitted"` // 超时后由系统提交暂存的作答
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// AttemptDeadline 计算个人截止时间：开始时间加考试时长与考试结束时间中较早的一个
+// duration 为0表示不限时长，考试结束时间为零值表示不限结束时间，两者都不限制时返回nil
+func AttemptDeadline(exam *Exam, duration time.Duration, startedAt time.Time) *time.Time {
+	var deadline *time.Time
+	if duration > 0 {
+		t := startedAt.Add(duration)
+		deadline = &t
+	}
+	if !exam.EndTime.IsZero() && (deadline == nil || exam.EndTime.Before(*deadline)) {
+		t := exam.EndTime
+		deadline = &t
+	}
+	return deadline
+}
+
+// IsOpen 判断会话是否仍在作答中
+func (a *ExamAttempt) IsOpen() bool {
+	return a.Status == AttemptInProgress
+}
+
+// Remaining 返回距离截止时间的剩余时长，不限时时第二个返回值为false
+func (a *ExamAttempt) Remaining(now time.Time) (time.Duration, bool) {
+	if a.Deadline == nil {
+		return 0, false
+	}
+	remaining := a.Deadline.Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
+
+// Responses 解析暂存的作答，没有暂存时返回空map
+func (a *ExamAttempt) Responses() map[uint]string {
+	responses := make(map[uint]string)
+	if a.Draft != "" {
+		json.Unmarshal([]byte(a.Draft), &responses)
+	}
+	return responses
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttemptDeadline(t *testing.T) {
+	start := time.Date(2024, 6, 1, 9, 0, 0, 0, time.Local)
+	at := func(hour, minute int) *time.Time {
+		t := time.Date(2024, 6, 1, hour, minute, 0, 0, time.Local)
+		return &t
+	}
+	tests := []struct {
+		name     string
+		end      time.Time
+		duration time.Duration
+		want     *time.Time
+	}{
+		{"按时长", *at(12, 0), 90 * time.Minute, at(10, 30)},
+		{"考试先结束", *at(10, 0), 90 * time.Minute, at(10, 0)},
+		{"不限时长", *at(10, 0), 0, at(10, 0)},
+		{"不限结束时间", time.Time{}, 30 * time.Minute, at(9, 30)},
+		{"都不限制", time.Time{}, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AttemptDeadline(&Exam{EndTime: tt.end}, tt.duration, start)
+			if (got == nil) != (tt.want == nil) || (got != nil && !got.Equal(*tt.want)) {
+				t.Errorf("AttemptDeadline() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// 备份执行结果常量
+const (
+	BackupRunSuccess = "success" // 备份成功
+	BackupRunFailed  = "failed"  // 备份失败
+)
+
+// BackupRun 定时备份的执行记录
+type BackupRun struct {
+	ID         uint      `gorm:"primary_key" json:"id"`
+	BackupID   string    `gorm:"size:64" json:"backup_id"` // 成功时生成的备份ID
+	Frequency  string    `gorm:"size:20" json:"frequency"` // 执行时的备份频率设置
+	Status     string    `gorm:"size:20;not null;index" json:"status"`
+	Error      string    `gorm:"type:text" json:"error"`
+	StartedAt  time.Time `gorm:"index" json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Duration 返回本次备份耗时
+func (r BackupRun) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt)
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 题库标签类型
+const (
+	BankTagTopic          = "topic"           // 知识主题
+	BankTagKnowledgePoint = "knowledge_point" // 知识点
+)
+
+// 题库题目难度范围
+const (
+	MinDifficulty = 1
+	MaxDifficulty = 5
+)
+
+// BankQuestion 题库中的题目，归属于创建的教师，共享后同一科目的教师都可以在试卷中引用
+// 题目内容、答案和评分规则以JSON保存在Body中，每次修改生成新版本，试卷引用时记录所用的版本
+type BankQuestion struct {
+	ID         uint      `gorm:"primary_key" json:"id"`
+	OwnerID    uint      `gorm:"index;not null" json:"owner_id"`
+	Course     string    `gorm:"size:100;index;not null" json:"course"`
+	Shared     bool      `json:"shared"` // 是否共享给同一科目的其他教师
+	Type       string    `gorm:"size:20;not null" json:"type"`
+	Content    string    `gorm:"type:text;not null" json:"content"` // 题干，用于搜索和列表展示
+	Score      float64   `gorm:"not null" json:"score"`             // 默认分值
+	Difficulty int       `gorm:"not null;default:3" json:"difficulty"`
+	Version    int       `gorm:"not null;default:1" json:"version"` // 当前版本号，从1开始
+	Body       string    `gorm:"type:text;not null" json:"-"`
+	Tags       []BankTag `gorm:"foreignkey:BankQuestionID" json:"tags"`
+	Question   *Question `gorm:"-" json:"question,omitempty"` // 当前版本的完整题目，仅在详情中返回
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// BankTag 题库题目的主题或知识点标签
+type BankTag struct {
+	ID             uint   `gorm:"primary_key" json:"id"`
+	BankQuestionID uint   `gorm:"index;not null" json:"bank_question_id"`
+	Kind           string `gorm:"size:20;not null" json:"kind"`
+	Name           string `gorm:"size:50;not null;index" json:"name"`
+}
+
+// BankTagCount 标签及使用该标签的题目数量
+type BankTagCount struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// BankQuestionVersion 题库题目的历史版本
+type BankQuestionVersion struct {
+	ID             uint      `gorm:"primary_key" json:"id"`
+	BankQuestionID uint      `gorm:"index;not null" json:"bank_question_id"`
+	Version        int       `gorm:"not null" json:"version"`
+	EditorID       uint      `gorm:"not null" json:"editor_id"`
+	Body           string    `gorm:"type:text;not null" json:"-"`
+	Question       *Question `gorm:"-" json:"question,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// BankBody 生成题目在题库中保存的JSON，去除题目、选项和评分标准在试卷中的ID及顺序
+func BankBody(question Question) (string, error) {
+	question.ID = 0
+	question.PaperID = 0
+	question.Position = 0
+	question.BankQuestionID = 0
+	question.BankVersion = 0
+	question.CreatedAt = time.Time{}
+	question.UpdatedAt = time.Time{}
+
+	options := make([]QuestionOption, len(question.Options))
+	for i, option := range question.Options {
+		option.ID = 0
+		option.QuestionID = 0
+		options[i] = option
+	}
+	question.Options = options
+
+	rubric := make([]RubricCriterion, len(question.Rubric))
+	for i, criterion := range question.Rubric {
+		criterion.ID = 0
+		criterion.QuestionID = 0
+		levels := make([]RubricLevel, len(criterion.Levels))
+		for j, level := range criterion.Levels {
+			level.ID = 0
+			level.CriterionID = 0
+			levels[j] = level
+		}
+		criterion.Levels = levels
+		rubric[i] = criterion
+	}
+	question.Rubric = rubric
+
+	data, err := json.Marshal(question)
+	return string(data), err
+}
+
+// ParseBankBody 解析题库中保存的题目JSON
+func ParseBankBody(body string) (*Question, error) {
+	var question Question
+	if err := json.Unmarshal([]byte(body), &question); err != nil {
+		return nil, err
+	}
+	return &question, nil
+}
+
+// VisibleTo 判断题目能否被教师查看：自己的题目或已共享的题目
+func (q *BankQuestion) VisibleTo(userID uint) bool {
+	return q.OwnerID == userID || q.Shared
+}
+
+// UsableIn 判断题目能否被教师在指定科目的试卷中引用：自己的题目或同一科目下已共享的题目
+func (q *BankQuestion) UsableIn(userID uint, course string) bool {
+	return q.OwnerID == userID || (q.Shared && q.Course == course)
+}
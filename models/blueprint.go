@@ -0,0 +1,101 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 组卷规则的数量限制
+const (
+	MaxBlueprintRules     = 50
+	MaxBlueprintRuleCount = 100
+)
+
+// Blueprint 组卷方案：按规则从题库中随机抽题生成试卷，相同的方案和随机种子在题库不变时生成相同的试卷
+type Blueprint struct {
+	Seed       int64           `json:"seed"`        // 随机种子，为0时自动生成
+	TotalScore float64         `json:"total_score"` // 试卷总分，为0时取抽到题目的分值之和
+	Rules      []BlueprintRule `json:"rules"`
+}
+
+// BlueprintRule 一条抽题规则：从符合条件的题库题目中抽取Count道，题目之间不重复
+type BlueprintRule struct {
+	Count           int      `json:"count"`
+	Type            string   `json:"type,omitempty"`
+	Topics          []string `json:"topics,omitempty"`           // 需同时具有所有主题标签
+	KnowledgePoints []string `json:"knowledge_points,omitempty"` // 需同时具有所有知识点标签
+	MinDifficulty   int      `json:"min_difficulty,omitempty"`
+	MaxDifficulty   int      `json:"max_difficulty,omitempty"`
+	Score           float64  `json:"score,omitempty"` // 每道题的分值，为0时使用题库中的默认分值
+}
+
+// Validate 校验组卷规则
+func (b *Blueprint) Validate() error {
+	if len(b.Rules) == 0 {
+		return errors.New("组卷方案至少需要一条规则")
+	}
+	if len(b.Rules) > MaxBlueprintRules {
+		return fmt.Errorf("组卷规则不能超过%d条", MaxBlueprintRules)
+	}
+	if b.TotalScore < 0 {
+		return errors.New("试卷总分不能为负数")
+	}
+	for i, rule := range b.Rules {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("规则%d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// validate 校验单条规则
+func (r *BlueprintRule) validate() error {
+	if r.Count < 1 || r.Count > MaxBlueprintRuleCount {
+		return fmt.Errorf("抽题数量必须在1-%d之间", MaxBlueprintRuleCount)
+	}
+	switch r.Type {
+	case "", QuestionSingleChoice, QuestionMultipleChoice, QuestionTrueFalse, QuestionFillBlank,
+		QuestionNumeric, QuestionShortAnswer, QuestionEssay:
+	default:
+		return fmt.Errorf("未知的题目类型: %s", r.Type)
+	}
+	for _, difficulty := range []int{r.MinDifficulty, r.MaxDifficulty} {
+		if difficulty != 0 && (difficulty < MinDifficulty || difficulty > MaxDifficulty) {
+			return fmt.Errorf("难度必须在%d-%d之间", MinDifficulty, MaxDifficulty)
+		}
+	}
+	if r.MinDifficulty > 0 && r.MaxDifficulty > 0 && r.MinDifficulty > r.MaxDifficulty {
+		return errors.New("最低难度不能高于最高难度")
+	}
+	if r.Score < 0 {
+		return errors.New("分值不能为负数")
+	}
+	return nil
+}
+
+// String 返回规则的筛选条件描述，用于错误提示
+func (r *BlueprintRule) String() string {
+	var parts []string
+	if r.Type != "" {
+		parts = append(parts, "题型"+r.Type)
+	}
+	if len(r.Topics) > 0 {
+		parts = append(parts, "主题"+strings.Join(r.Topics, "、"))
+	}
+	if len(r.KnowledgePoints) > 0 {
+		parts = append(parts, "知识点"+strings.Join(r.KnowledgePoints, "、"))
+	}
+	switch {
+	case r.MinDifficulty > 0 && r.MaxDifficulty > 0:
+		parts = append(parts, fmt.Sprintf("难度%d-%d", r.MinDifficulty, r.MaxDifficulty))
+	case r.MinDifficulty > 0:
+		parts = append(parts, fmt.Sprintf("难度不低于%d", r.MinDifficulty))
+	case r.MaxDifficulty > 0:
+		parts = append(parts, fmt.Sprintf("难度不高于%d", r.MaxDifficulty))
+	}
+	if len(parts) == 0 {
+		return "不限条件"
+	}
+	return strings.Join(parts, "，")
+}
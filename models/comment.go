@@ -0,0 +1,131 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 评论类型
+const (
+	CommentKindReview     = "review"     // 审批意见，仅教师和管理员可见
+	CommentKindGrading    = "grading"    // 批阅评语
+	CommentKindDiscussion = "discussion" // 讨论
+)
+
+// 评论可见范围
+const (
+	CommentVisibilityStaff  = "staff"  // 仅教师和管理员可见
+	CommentVisibilityPublic = "public" // 学生也可见，批阅评语只对被批阅的学生可见
+)
+
+// MaxCommentLength 评论内容的最大字数
+const MaxCommentLength = 1000
+
+// Comment 考试评论。ParentID不为0时是对顶层评论的回复，回复沿用所在讨论串的类型、可见范围和定位；
+// 评论可以定位到考试的某份试卷或某道题，批阅评语可以定位到某个学生的答卷
+type Comment struct {
+	ID         uint       `gorm:"primary_key" json:"id"`
+	ExamID     uint       `gorm:"index" json:"exam_id"`
+	ParentID   uint       `gorm:"index" json:"parent_id"`
+	Kind       string     `gorm:"size:20;not null;default:'review'" json:"kind"`
+	Visibility string     `gorm:"size:20;not null;default:'staff'" json:"visibility"`
+	PaperID    uint       `json:"paper_id"`
+	QuestionID uint       `json:"question_id"`
+	ExamDataID uint       `json:"exam_data_id"`
+	UserID     uint       `json:"user_id"`
+	User       User       `gorm:"foreignkey:UserID" json:"user"`
+	Content    string     `gorm:"size:1000;not null" json:"content"`
+	Resolved   bool       `gorm:"not null;default:false" json:"resolved"`
+	ResolvedBy uint       `json:"resolved_by"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	EditedAt   *time.Time `json:"edited_at"` // 最近一次编辑时间，未编辑过为空
+	Replies    []Comment  `gorm:"-" json:"replies,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// CommentRevision 评论的编辑历史，每次编辑前保存一条原内容
+type CommentRevision struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CommentID uint      `gorm:"index;not null" json:"comment_id"`
+	Content   string    `gorm:"size:1000;not null" json:"content"`
+	EditedBy  uint      `json:"edited_by"`
+	Editor    User      `gorm:"foreignkey:EditedBy" json:"editor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidateCommentContent 校验评论内容并去掉首尾空白
+func ValidateCommentContent(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", errors.New("评论内容不能为空")
+	}
+	if utf8.RuneCountInString(content) > MaxCommentLength {
+		return "", fmt.Errorf("评论内容不能超过%d字", MaxCommentLength)
+	}
+	return content, nil
+}
+
+// Normalize 按作者角色补全并校验顶层评论的类型和可见范围：
+// 学生只能发表对所有人可见的讨论，审批意见始终仅教师和管理员可见，其余类型默认学生可见
+func (c *Comment) Normalize(author *User) error {
+	content, err := ValidateCommentContent(c.Content)
+	if err != nil {
+		return err
+	}
+	c.Content = content
+
+	if c.Kind == "" {
+		c.Kind = CommentKindReview
+		if author.Role == RoleStudent {
+			c.Kind = CommentKindDiscussion
+		}
+	}
+	switch c.Kind {
+	case CommentKindReview, CommentKindGrading, CommentKindDiscussion:
+	default:
+		return errors.New("评论类型必须是review、grading或discussion")
+	}
+	if author.Role == RoleStudent && c.Kind != CommentKindDiscussion {
+		return errors.New("学生只能发表讨论")
+	}
+
+	switch {
+	case c.Kind == CommentKindReview:
+		c.Visibility = CommentVisibilityStaff
+	case author.Role == RoleStudent || c.Visibility == "":
+		c.Visibility = CommentVisibilityPublic
+	case c.Visibility != CommentVisibilityStaff && c.Visibility != CommentVisibilityPublic:
+		return errors.New("可见范围必须是staff或public")
+	}
+	if c.ExamDataID != 0 && c.Kind != CommentKindGrading {
+		return errors.New("只有批阅评语可以定位到学生答卷")
+	}
+	return nil
+}
+
+// InheritThread 回复沿用讨论串的类型、可见范围和定位
+func (c *Comment) InheritThread(root *Comment) {
+	c.ParentID = root.ID
+	c.ExamID = root.ExamID
+	c.Kind = root.Kind
+	c.Visibility = root.Visibility
+	c.PaperID = root.PaperID
+	c.QuestionID = root.QuestionID
+	c.ExamDataID = root.ExamDataID
+}
+
+// VisibleTo 判断用户能否看到该评论，sheetOwner 为评论定位的答卷所属学生，未定位到答卷时为0。
+// 教师和管理员可以看到所有评论，学生看不到仅教师和管理员可见的评论及其他学生的批阅评语
+func (c *Comment) VisibleTo(user *User, sheetOwner uint) bool {
+	if user.Role != RoleStudent {
+		return true
+	}
+	if c.Kind == CommentKindReview || c.Visibility != CommentVisibilityPublic {
+		return false
+	}
+	return c.ExamDataID == 0 || sheetOwner == user.ID
+}
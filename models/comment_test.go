@@ -0,0 +1,35 @@
+package models
+
+import "testing"
+
+func TestCommentVisibleTo(t *testing.T) {
+	student := &User{ID: 1, Role: RoleStudent}
+	teacher := &User{ID: 2, Role: RoleTeacher}
+	tests := []struct {
+		name       string
+		comment    Comment
+		user       *User
+		sheetOwner uint
+		want       bool
+	}{
+		{"教师看到审批意见", Comment{Kind: CommentKindReview, Visibility: CommentVisibilityStaff}, teacher, 0, true},
+		{"教师看到其他学生的批阅评语", Comment{Kind: CommentKindGrading, Visibility: CommentVisibilityPublic, ExamDataID: 9}, teacher, 3, true},
+		{"学生看不到审批意见", Comment{Kind: CommentKindReview, Visibility: CommentVisibilityStaff}, student, 0, false},
+		{"学生看不到误标为公开的审批意见", Comment{Kind: CommentKindReview, Visibility: CommentVisibilityPublic}, student, 0, false},
+		{"学生看不到仅教师可见的讨论", Comment{Kind: CommentKindDiscussion, Visibility: CommentVisibilityStaff}, student, 0, false},
+		{"学生看不到仅教师可见的批阅评语", Comment{Kind: CommentKindGrading, Visibility: CommentVisibilityStaff, ExamDataID: 9}, student, 1, false},
+		{"学生看不到未知可见范围的评论", Comment{Kind: CommentKindDiscussion}, student, 0, false},
+		{"学生看到公开的讨论", Comment{Kind: CommentKindDiscussion, Visibility: CommentVisibilityPublic}, student, 0, true},
+		{"学生看到自己答卷的批阅评语", Comment{Kind: CommentKindGrading, Visibility: CommentVisibilityPublic, ExamDataID: 9}, student, 1, true},
+		{"学生看不到其他学生答卷的批阅评语", Comment{Kind: CommentKindGrading, Visibility: CommentVisibilityPublic, ExamDataID: 9}, student, 3, false},
+		{"学生看不到答卷已删除的批阅评语", Comment{Kind: CommentKindGrading, Visibility: CommentVisibilityPublic, ExamDataID: 9}, student, 0, false},
+		{"学生看到未定位答卷的批阅评语", Comment{Kind: CommentKindGrading, Visibility: CommentVisibilityPublic}, student, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.comment.VisibleTo(tt.user, tt.sheetOwner); got != tt.want {
+				t.Errorf("VisibleTo() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
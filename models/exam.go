@@ -1,6 +1,8 @@
 package models
 
 import (
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/jinzhu/gorm"
@@ -13,25 +15,43 @@ const (
 	StatusApproved  = "approved"  // 已审批
 	StatusRejected  = "rejected"  // 已拒绝
 	StatusPublished = "published" // 已发布
+	StatusClosed    = "closed"    // 已关闭，不再接受新的作答
+	StatusArchived  = "archived"  // 已归档
+)
+
+// 多次作答时计入成绩的方式
+const (
+	ScorePolicyLatest  = "latest"  // 最近一次提交
+	ScorePolicyHighest = "highest" // 最高分
+	ScorePolicyAverage = "average" // 平均分
+)
+
+// 作答次数限制
+const (
+	MaxAttemptsLimit   = 100         // 允许设置的最大作答次数
+	MaxAttemptCooldown = 7 * 24 * 60 // 两次作答最长间隔（分钟）
 )
 
 // Exam 考试模型 - 试卷数据表
 type Exam struct {
-	ID          uint      `gorm:"primary_key" json:"id"`
-	Title       string    `gorm:"size:100;not null" json:"title"`
-	Description string    `gorm:"size:1000" json:"description"`
-	Course      string    `gorm:"size:100;not null" json:"course"`
-	StartTime   time.Time `json:"start_time"`
-	EndTime     time.Time `json:"end_time"`
-	CreatorID   uint      `json:"creator_id"`
-	Creator     User      `gorm:"foreignkey:CreatorID" json:"creator"`
-	Status      string    `gorm:"size:20;not null;default:'draft'" json:"status"`
-	ApproverID  uint      `json:"approver_id"`
-	Approver    User      `gorm:"foreignkey:ApproverID" json:"approver"`
-	Papers      []Paper   `gorm:"foreignkey:ExamID" json:"papers"`
-	TotalScore  float64   `json:"total_score"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID              uint      `gorm:"primary_key" json:"id"`
+	Title           string    `gorm:"size:100;not null" json:"title"`
+	Description     string    `gorm:"size:1000" json:"description"`
+	Course          string    `gorm:"size:100;not null" json:"course"`
+	StartTime       time.Time `json:"start_time"`
+	EndTime         time.Time `json:"end_time"`
+	CreatorID       uint      `json:"creator_id"`
+	Creator         User      `gorm:"foreignkey:CreatorID" json:"creator"`
+	Status          string    `gorm:"size:20;not null;default:'draft'" json:"status"`
+	ApproverID      uint      `json:"approver_id"`
+	Approver        User      `gorm:"foreignkey:ApproverID" json:"approver"`
+	Papers          []Paper   `gorm:"foreignkey:ExamID" json:"papers"`
+	TotalScore      float64   `json:"total_score"`
+	MaxAttempts     int       `gorm:"not null;default:0" json:"max_attempts"`                // 最多作答次数，0表示不限
+	AttemptCooldown int       `gorm:"not null;default:0" json:"attempt_cooldown"`            // 两次作答之间的间隔（分钟）
+	ScorePolicy     string    `gorm:"size:20;not null;default:'latest'" json:"score_policy"` // 多次作答时计入成绩的方式
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
 
 // ExamData 试卷数据表 - 用于专门存储试卷数据
@@ -53,31 +73,76 @@ type ExamData struct {
 
 // Paper 试卷模型
 type Paper struct {
-	ID           uint      `gorm:"primary_key" json:"id"`
-	ExamID       uint      `json:"exam_id"`
-	Title        string    `gorm:"size:100;not null" json:"title"`
-	Content      string    `gorm:"type:text" json:"content"`
-	Questions    string    `gorm:"type:text" json:"questions"` // JSON格式存储题目
-	Duration     int       `json:"duration"`                   // 考试时长（分钟）
-	TotalScore   float64   `json:"total_score"`
-	PassingScore float64   `json:"passing_score"`
-	Status       string    `gorm:"size:20;not null;default:'draft'" json:"status"`
-	Signature    string    `gorm:"size:256" json:"signature"` // 试卷签名
-	SignedAt     time.Time `json:"signed_at"`                 // 签名时间
-	SignedBy     uint      `json:"signed_by"`                 // 签名人ID
-	Signer       User      `gorm:"foreignkey:SignedBy" json:"signer"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           uint       `gorm:"primary_key" json:"id"`
+	ExamID       uint       `json:"exam_id"`
+	Title        string     `gorm:"size:100;not null" json:"title"`
+	Content      string     `gorm:"type:text" json:"content"`
+	Questions    []Question `gorm:"foreignkey:PaperID" json:"questions"` // 按顺序排列的题目
+	Duration     int        `json:"duration"`                            // 考试时长（分钟）
+	TotalScore   float64    `json:"total_score"`
+	PassingScore float64    `json:"passing_score"`
+	Status       string     `gorm:"size:20;not null;default:'draft'" json:"status"`
+	Signature    string     `gorm:"size:256" json:"signature"` // 试卷签名
+	SignedAt     time.Time  `json:"signed_at"`                 // 签名时间
+	SignedBy     uint       `json:"signed_by"`                 // 签名人ID
+	Signer       User       `gorm:"foreignkey:SignedBy" json:"signer"`
+	Blueprint    string     `gorm:"type:text" json:"blueprint,omitempty"` // 按组卷方案生成时记录方案及随机种子的JSON，用于重新生成
+	// 审批通过时锁定的内容哈希、时间和对应的版本号，读取试卷时据此校验内容未被修改
+	LockedHash     string     `gorm:"size:64" json:"locked_hash,omitempty"`
+	LockedAt       *time.Time `json:"locked_at,omitempty"`
+	LockedRevision int        `json:"locked_revision,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// ValidateAttemptPolicy 校验作答次数、间隔和计分方式，计分方式为空时使用最近一次提交
+func (e *Exam) ValidateAttemptPolicy() error {
+	if e.MaxAttempts < 0 || e.MaxAttempts > MaxAttemptsLimit {
+		return fmt.Errorf("最多作答次数必须在0-%d之间", MaxAttemptsLimit)
+	}
+	if e.AttemptCooldown < 0 || e.AttemptCooldown > MaxAttemptCooldown {
+		return fmt.Errorf("作答间隔必须在0-%d分钟之间", MaxAttemptCooldown)
+	}
+	switch e.ScorePolicy {
+	case "":
+		e.ScorePolicy = ScorePolicyLatest
+	case ScorePolicyLatest, ScorePolicyHighest, ScorePolicyAverage:
+	default:
+		return fmt.Errorf("计分方式必须是latest、highest或average")
+	}
+	return nil
+}
+
+// FinalScore 按计分方式汇总各次提交的得分，submissions 按提交次数排序。
+// 计入成绩的提交都已评分时第二个返回值为true：最近一次计分只看最后一次提交，其余方式需要全部提交已评分
+func (e *Exam) FinalScore(submissions []Submission) (float64, bool) {
+	if len(submissions) == 0 {
+		return 0, false
+	}
+	latest := submissions[len(submissions)-1]
+	if e.ScorePolicy != ScorePolicyHighest && e.ScorePolicy != ScorePolicyAverage {
+		return latest.Score, latest.Status == SubmissionGraded
+	}
+
+	var best, sum float64
+	for i, submission := range submissions {
+		if submission.Status != SubmissionGraded {
+			return 0, false
+		}
+		if i == 0 || submission.Score > best {
+			best = submission.Score
+		}
+		sum += submission.Score
+	}
+	if e.ScorePolicy == ScorePolicyHighest {
+		return best, true
+	}
+	return math.Round(sum/float64(len(submissions))*100) / 100, true
 }
 
-// Comment 审批评论
-type Comment struct {
-	ID        uint      `gorm:"primary_key" json:"id"`
-	ExamID    uint      `json:"exam_id"`
-	UserID    uint      `json:"user_id"`
-	User      User      `gorm:"foreignkey:UserID" json:"user"`
-	Content   string    `gorm:"size:1000;not null" json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+// Released 考试是否已发布过（已发布、已关闭或已归档），发布后学生可以查看成绩
+func (e *Exam) Released() bool {
+	return e.Status == StatusPublished || e.Status == StatusClosed || e.Status == StatusArchived
 }
 
 // BeforeCreate 创建记录前的钩子函数
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// 考试状态变更操作
+const (
+	ExamActionCreate  = "create"  // 创建草稿
+	ExamActionSubmit  = "submit"  // 提交审批
+	ExamActionApprove = "approve" // 审批通过
+	ExamActionReject  = "reject"  // 审批拒绝
+	ExamActionPublish = "publish" // 发布给学生
+	ExamActionClose   = "close"   // 关闭，不再接受新的作答
+	ExamActionArchive = "archive" // 归档
+)
+
+// ExamTransition 考试状态变更记录，每次状态变更写入一条，按ID顺序即为考试的状态历史
+type ExamTransition struct {
+	ID         uint   `gorm:"primary_key" json:"id"`
+	ExamID     uint   `gorm:"index;not null" json:"exam_id"`
+	Action     string `gorm:"size:20;not null" json:"action"`
+	FromStatus string `gorm:"size:20" json:"from_status"` // 创建考试时为空
+	ToStatus   string `gorm:"size:20;not null" json:"to_status"`
+	ActorID    uint   `json:"actor_id"`
+	ActorRole  string `gorm:"size:20" json:"actor_role"` // 操作时的角色
+	// OnBehalfOfID 代理审批时的委托人，操作人为其代理人
+	OnBehalfOfID uint      `json:"on_behalf_of_id"`
+	Comment      string    `gorm:"size:1000" json:"comment"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// StateDuration 考试在某个状态累计停留的时间
+type StateDuration struct {
+	Status  string `json:"status"`
+	Entries int    `json:"entries"` // 进入该状态的次数
+	Seconds int64  `json:"seconds"`
+}
+
+// TimeInState 按状态变更记录计算考试在各状态累计停留的时间，当前状态计算到now，按首次进入的顺序排列
+func TimeInState(transitions []ExamTransition, now time.Time) []StateDuration {
+	var durations []StateDuration
+	index := make(map[string]int)
+	for i, transition := range transitions {
+		end := now
+		if i+1 < len(transitions) {
+			end = transitions[i+1].CreatedAt
+		}
+		j, ok := index[transition.ToStatus]
+		if !ok {
+			j = len(durations)
+			index[transition.ToStatus] = j
+			durations = append(durations, StateDuration{Status: transition.ToStatus})
+		}
+		durations[j].Entries++
+		if end.After(transition.CreatedAt) {
+			durations[j].Seconds += int64(end.Sub(transition.CreatedAt) / time.Second)
+		}
+	}
+	return durations
+}
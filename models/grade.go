@@ -0,0 +1,100 @@
+package models
+
+import (
+	"fmt"
+	"math"
+)
+
+// 成绩等级制常量
+const (
+	GradeScalePercent   = "percent"    // 百分制
+	GradeScaleFivePoint = "five_point" // 五级制：优秀、良好、中等、及格、不及格
+	GradeScaleLetter    = "letter"     // 字母等级：A、B、C、D、F
+	GradeScalePassFail  = "pass_fail"  // 通过制：通过、不通过
+)
+
+// 没有试卷或试卷未设置分数时使用的默认总分和及格线比例
+const (
+	DefaultTotalScore   = 100
+	DefaultPassingRatio = 0.6
+)
+
+// GradeResult 按等级制换算后的成绩
+type GradeResult struct {
+	Score        float64 `json:"score"`
+	TotalScore   float64 `json:"total_score"`
+	PassingScore float64 `json:"passing_score"`
+	Percent      float64 `json:"percent"` // 得分占总分的百分比
+	Passed       bool    `json:"passed"`
+	Scale        string  `json:"scale"`
+	Grade        string  `json:"grade"` // 按等级制显示的成绩
+}
+
+// IsGradeScale 判断是否为支持的等级制
+func IsGradeScale(scale string) bool {
+	switch scale {
+	case GradeScalePercent, GradeScaleFivePoint, GradeScaleLetter, GradeScalePassFail:
+		return true
+	}
+	return false
+}
+
+// ScoreRange 汇总考试下各试卷的总分和及格分，未设置时使用默认值
+func ScoreRange(papers []Paper) (total, passing float64) {
+	for _, paper := range papers {
+		total += paper.TotalScore
+		passing += paper.PassingScore
+	}
+	if total <= 0 {
+		total = DefaultTotalScore
+	}
+	if passing <= 0 || passing > total {
+		passing = total * DefaultPassingRatio
+	}
+	return total, passing
+}
+
+// MapGrade 根据总分和及格分判断是否通过，并按等级制换算成绩
+// 未及格时为最低等级，及格后按得分比例分为90%、80%、70%以上和及格四档
+func MapGrade(scale string, score, total, passing float64) GradeResult {
+	result := GradeResult{
+		Score:        score,
+		TotalScore:   total,
+		PassingScore: passing,
+		Scale:        scale,
+		Passed:       score >= passing-1e-6,
+	}
+	if total > 0 {
+		result.Percent = math.Round(score/total*1000) / 10
+	}
+
+	level := 0 // 0不及格 1及格 2中等 3良好 4优秀
+	if result.Passed {
+		switch {
+		case result.Percent >= 90:
+			level = 4
+		case result.Percent >= 80:
+			level = 3
+		case result.Percent >= 70:
+			level = 2
+		default:
+			level = 1
+		}
+	}
+
+	switch scale {
+	case GradeScaleFivePoint:
+		result.Grade = []string{"不及格", "及格", "中等", "良好", "优秀"}[level]
+	case GradeScaleLetter:
+		result.Grade = []string{"F", "D", "C", "B", "A"}[level]
+	case GradeScalePassFail:
+		result.Grade = "不通过"
+		if result.Passed {
+			result.Grade = "通过"
+		}
+	default:
+		result.Scale = GradeScalePercent
+		result.Grade = fmt.Sprintf("%g分", result.Percent)
+	}
+	return result
+}
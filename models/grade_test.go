@@ -0,0 +1,63 @@
+package models
+
+import "testing"
+
+func TestMapGrade(t *testing.T) {
+	tests := []struct {
+		name        string
+		scale       string
+		score       float64
+		total       float64
+		passing     float64
+		wantGrade   string
+		wantPassed  bool
+		wantPercent float64
+	}{
+		{"百分制", GradeScalePercent, 85, 100, 60, "85分", true, 85},
+		{"未知等级制按百分制", "gpa", 42, 50, 30, "84分", true, 84},
+		{"五级制优秀", GradeScaleFivePoint, 90, 100, 60, "优秀", true, 90},
+		{"五级制良好", GradeScaleFivePoint, 89.9, 100, 60, "良好", true, 89.9},
+		{"五级制及格", GradeScaleFivePoint, 60, 100, 60, "及格", true, 60},
+		{"五级制不及格", GradeScaleFivePoint, 59.5, 100, 60, "不及格", false, 59.5},
+		{"字母等级", GradeScaleLetter, 15, 20, 12, "C", true, 75},
+		{"字母等级及格", GradeScaleLetter, 65, 100, 60, "D", true, 65},
+		{"字母等级不及格", GradeScaleLetter, 70, 100, 75, "F", false, 70},
+		{"通过制", GradeScalePassFail, 6, 10, 6, "通过", true, 60},
+		{"通过制不通过", GradeScalePassFail, 5, 10, 6, "不通过", false, 50},
+		{"总分为0", GradeScalePercent, 0, 0, 0, "0分", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MapGrade(tt.scale, tt.score, tt.total, tt.passing)
+			if got.Grade != tt.wantGrade || got.Passed != tt.wantPassed || got.Percent != tt.wantPercent {
+				t.Errorf("MapGrade() = %s passed=%v percent=%g, 期望 %s passed=%v percent=%g",
+					got.Grade, got.Passed, got.Percent, tt.wantGrade, tt.wantPassed, tt.wantPercent)
+			}
+			if !IsGradeScale(got.Scale) {
+				t.Errorf("换算后的等级制 %q 不受支持", got.Scale)
+			}
+		})
+	}
+}
+
+func TestScoreRange(t *testing.T) {
+	tests := []struct {
+		name        string
+		papers      []Paper
+		wantTotal   float64
+		wantPassing float64
+	}{
+		{"没有试卷", nil, 100, 60},
+		{"多份试卷合计", []Paper{{TotalScore: 60, PassingScore: 36}, {TotalScore: 40, PassingScore: 30}}, 100, 66},
+		{"未设置及格分", []Paper{{TotalScore: 50}}, 50, 30},
+		{"及格分超过总分", []Paper{{TotalScore: 50, PassingScore: 80}}, 50, 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, passing := ScoreRange(tt.papers)
+			if total != tt.wantTotal || passing != tt.wantPassing {
+				t.Errorf("ScoreRange() = %g, %g, 期望 %g, %g", total, passing, tt.wantTotal, tt.wantPassing)
+			}
+		})
+	}
+}
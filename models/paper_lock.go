@@ -0,0 +1,95 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrPaperLocked 试卷已在审批通过时锁定
+var ErrPaperLocked = errors.New("试卷已在审批通过时锁定，不能修改")
+
+// 试卷审计事件类型
+const (
+	PaperAuditLocked   = "locked"   // 审批通过时锁定试卷内容
+	PaperAuditTampered = "tampered" // 读取时发现试卷内容与锁定时不一致
+)
+
+// 锁定或读取试卷的入口，记录在审计事件中
+const (
+	PaperSourceApprove    = "approve"     // 考试审批通过
+	PaperSourceMigration  = "migration"   // 升级时锁定已审批的考试
+	PaperSourceExamView   = "exam_view"   // 学生参加考试
+	PaperSourceExamSubmit = "exam_submit" // 学生提交答案
+	PaperSourceExamResult = "exam_result" // 学生查看评分详情
+	PaperSourceExamData   = "exam_data"   // 教师查看学生答卷
+	PaperSourceAPI        = "api"         // 试卷查询接口
+)
+
+// PaperAuditEvent 试卷完整性审计事件，锁定试卷和发现篡改时各写入一条，试卷删除后仍保留
+type PaperAuditEvent struct {
+	ID           uint      `gorm:"primary_key" json:"id"`
+	PaperID      uint      `gorm:"index;not null" json:"paper_id"`
+	ExamID       uint      `gorm:"index;not null" json:"exam_id"`
+	Event        string    `gorm:"size:20;not null" json:"event"`
+	ExpectedHash string    `gorm:"size:64" json:"expected_hash"` // 锁定时的哈希，试卷未锁定时为空
+	ActualHash   string    `gorm:"size:64" json:"actual_hash"`   // 事件发生时试卷内容的哈希
+	Source       string    `gorm:"size:50" json:"source"`        // 触发事件的入口，如 approve、exam_view
+	UserID       uint      `json:"user_id"`                      // 审批人或读取试卷的用户，迁移时锁定为0
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PaperIntegrity 试卷完整性检查结果，内容不一致时附带锁定版本与当前内容的差异
+type PaperIntegrity struct {
+	PaperID        uint              `json:"paper_id"`
+	ExamID         uint              `json:"exam_id"`
+	Locked         bool              `json:"locked"`
+	LockedAt       *time.Time        `json:"locked_at"`
+	LockedRevision int               `json:"locked_revision"`
+	LockedHash     string            `json:"locked_hash"`
+	CurrentHash    string            `json:"current_hash"`
+	Intact         bool              `json:"intact"`
+	Diff           *PaperDiff        `json:"diff,omitempty"`
+	Events         []PaperAuditEvent `json:"events"`
+}
+
+// ContentHash 计算试卷内容的规范化哈希。内容与版本快照一致：标题、说明、时长、分值，
+// 以及按顺序排列、包含答案和评分标准的题目，题目需已按顺序加载
+func (p *Paper) ContentHash() (string, error) {
+	revision, err := NewPaperRevision(p)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(struct {
+		Title        string          `json:"title"`
+		Content      string          `json:"content"`
+		Duration     int             `json:"duration"`
+		TotalScore   float64         `json:"total_score"`
+		PassingScore float64         `json:"passing_score"`
+		Questions    json.RawMessage `json:"questions"`
+	}{
+		Title:        revision.Title,
+		Content:      revision.Content,
+		Duration:     revision.Duration,
+		TotalScore:   revision.TotalScore,
+		PassingScore: revision.PassingScore,
+		Questions:    json.RawMessage(revision.Body),
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Locked 试卷是否已锁定
+func (p *Paper) Locked() bool {
+	return p.LockedAt != nil
+}
+
+// Locked 考试是否已审批通过，审批通过后试卷内容以及考试的标题、科目和说明不能再修改
+func (e *Exam) Locked() bool {
+	return e.Status == StatusApproved || e.Released()
+}
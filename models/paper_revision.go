@@ -0,0 +1,205 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PaperRevision 试卷的历史版本，创建试卷和每次修改后保存一份完整快照，保存后不再修改。
+// 题目以JSON保存在Body中，保留题目ID用于比较版本间的变化
+type PaperRevision struct {
+	ID           uint       `gorm:"primary_key" json:"id"`
+	PaperID      uint       `gorm:"not null;unique_index:idx_paper_revisions_paper_revision" json:"paper_id"`
+	Revision     int        `gorm:"not null;unique_index:idx_paper_revisions_paper_revision" json:"revision"` // 版本号，从1开始
+	Title        string     `gorm:"size:100;not null" json:"title"`
+	Content      string     `gorm:"type:text" json:"content"`
+	Duration     int        `json:"duration"`
+	TotalScore   float64    `json:"total_score"`
+	PassingScore float64    `json:"passing_score"`
+	Body         string     `gorm:"type:text;not null" json:"-"`
+	Questions    []Question `gorm:"-" json:"questions,omitempty"` // 仅在查看单个版本时返回
+	EditorID     uint       `json:"editor_id"`
+	RestoredFrom int        `json:"restored_from"` // 恢复历史版本生成的版本记录恢复到的版本号
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// FieldChange 字段修改前后的值
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// QuestionChange 两个版本中都存在的题目的修改
+type QuestionChange struct {
+	QuestionID uint          `json:"question_id"`
+	Position   int           `json:"position"` // 在新版本中的位置
+	Changes    []FieldChange `json:"changes"`
+}
+
+// PaperDiff 试卷两个版本之间的差异，题目按ID对应
+type PaperDiff struct {
+	PaperID uint             `json:"paper_id"`
+	From    int              `json:"from"`
+	To      int              `json:"to"`
+	Fields  []FieldChange    `json:"fields"`  // 标题、说明、时长、总分和及格分的修改
+	Added   []Question       `json:"added"`   // 新版本中新增的题目
+	Removed []Question       `json:"removed"` // 新版本中删除的题目
+	Changed []QuestionChange `json:"changed"` // 修改过的题目
+}
+
+// NewPaperRevision 生成试卷当前内容的快照，题目需已保存并带有ID
+func NewPaperRevision(paper *Paper) (*PaperRevision, error) {
+	questions := make([]Question, len(paper.Questions))
+	for i, question := range paper.Questions {
+		questions[i] = revisionQuestion(question)
+	}
+	data, err := json.Marshal(questions)
+	if err != nil {
+		return nil, err
+	}
+	return &PaperRevision{
+		PaperID:      paper.ID,
+		Title:        paper.Title,
+		Content:      paper.Content,
+		Duration:     paper.Duration,
+		TotalScore:   paper.TotalScore,
+		PassingScore: paper.PassingScore,
+		Body:         string(data),
+	}, nil
+}
+
+// revisionQuestion 去除题目中随保存变化的字段：选项和评分标准每次修改试卷时重新创建，不保留其ID
+func revisionQuestion(question Question) Question {
+	question.PaperID = 0
+	question.CreatedAt = time.Time{}
+	question.UpdatedAt = time.Time{}
+
+	options := make([]QuestionOption, len(question.Options))
+	for i, option := range question.Options {
+		option.ID = 0
+		option.QuestionID = 0
+		options[i] = option
+	}
+	question.Options = options
+
+	rubric := make([]RubricCriterion, len(question.Rubric))
+	for i, criterion := range question.Rubric {
+		criterion.ID = 0
+		criterion.QuestionID = 0
+		levels := make([]RubricLevel, len(criterion.Levels))
+		for j, level := range criterion.Levels {
+			level.ID = 0
+			level.CriterionID = 0
+			levels[j] = level
+		}
+		criterion.Levels = levels
+		rubric[i] = criterion
+	}
+	question.Rubric = rubric
+	return question
+}
+
+// ParseQuestions 解析快照中的题目
+func (r *PaperRevision) ParseQuestions() error {
+	var questions []Question
+	if err := json.Unmarshal([]byte(r.Body), &questions); err != nil {
+		return err
+	}
+	r.Questions = questions
+	return nil
+}
+
+// SameContent 判断两个版本的试卷内容是否相同
+func (r *PaperRevision) SameContent(other *PaperRevision) bool {
+	return r.Title == other.Title && r.Content == other.Content && r.Duration == other.Duration &&
+		r.TotalScore == other.TotalScore && r.PassingScore == other.PassingScore && r.Body == other.Body
+}
+
+// DiffPaperRevisions 比较试卷的两个版本，两个版本都需已解析题目
+func DiffPaperRevisions(from, to *PaperRevision) *PaperDiff {
+	diff := &PaperDiff{
+		PaperID: to.PaperID,
+		From:    from.Revision,
+		To:      to.Revision,
+		Fields:  []FieldChange{},
+		Added:   []Question{},
+		Removed: []Question{},
+		Changed: []QuestionChange{},
+	}
+	diff.Fields = appendChange(diff.Fields, "title", from.Title, to.Title)
+	diff.Fields = appendChange(diff.Fields, "content", from.Content, to.Content)
+	diff.Fields = appendChange(diff.Fields, "duration", from.Duration, to.Duration)
+	diff.Fields = appendChange(diff.Fields, "total_score", from.TotalScore, to.TotalScore)
+	diff.Fields = appendChange(diff.Fields, "passing_score", from.PassingScore, to.PassingScore)
+
+	old := make(map[uint]Question, len(from.Questions))
+	for _, question := range from.Questions {
+		old[question.ID] = question
+	}
+	kept := make(map[uint]bool, len(to.Questions))
+	for _, question := range to.Questions {
+		previous, ok := old[question.ID]
+		if !ok {
+			diff.Added = append(diff.Added, question)
+			continue
+		}
+		kept[question.ID] = true
+		if changes := diffQuestion(previous, question); len(changes) > 0 {
+			diff.Changed = append(diff.Changed, QuestionChange{
+				QuestionID: question.ID,
+				Position:   question.Position,
+				Changes:    changes,
+			})
+		}
+	}
+	for _, question := range from.Questions {
+		if !kept[question.ID] {
+			diff.Removed = append(diff.Removed, question)
+		}
+	}
+	return diff
+}
+
+// diffQuestion 比较同一道题在两个版本中的各字段
+func diffQuestion(from, to Question) []FieldChange {
+	var changes []FieldChange
+	changes = appendChange(changes, "position", from.Position, to.Position)
+	changes = appendChange(changes, "type", from.Type, to.Type)
+	changes = appendChange(changes, "content", from.Content, to.Content)
+	changes = appendChange(changes, "score", from.Score, to.Score)
+	changes = appendChange(changes, "answer", from.Answer, to.Answer)
+	changes = appendChange(changes, "partial_credit", from.PartialCredit, to.PartialCredit)
+	changes = appendChange(changes, "match_mode", from.MatchMode, to.MatchMode)
+	changes = appendChange(changes, "tolerance", from.Tolerance, to.Tolerance)
+	changes = appendChange(changes, "bank_question_id", from.BankQuestionID, to.BankQuestionID)
+	changes = appendChange(changes, "bank_version", from.BankVersion, to.BankVersion)
+	if !sameJSON(from.Options, to.Options) {
+		changes = append(changes, FieldChange{Field: "options", From: from.Options, To: to.Options})
+	}
+	if !sameJSON(from.Rubric, to.Rubric) {
+		changes = append(changes, FieldChange{Field: "rubric", From: from.Rubric, To: to.Rubric})
+	}
+	return changes
+}
+
+// appendChange 值不同时记录字段的修改
+func appendChange(changes []FieldChange, field string, from, to interface{}) []FieldChange {
+	if from == to {
+		return changes
+	}
+	return append(changes, FieldChange{Field: field, From: from, To: to})
+}
+
+// sameJSON 按JSON比较选项或评分标准是否相同
+func sameJSON(a, b interface{}) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(x) == string(y)
+}
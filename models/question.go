@@ -0,0 +1,171 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 题目类型常量
+const (
+	QuestionSingleChoice   = "single_choice"   // 单选题
+	QuestionMultipleChoice = "multiple_choice" // 多选题
+	QuestionTrueFalse      = "true_false"      // 判断题
+	QuestionFillBlank      = "fill_blank"      // 填空题
+	QuestionNumeric        = "numeric"         // 数值题
+	QuestionShortAnswer    = "short_answer"    // 简答题
+	QuestionEssay          = "essay"           // 论述题
+)
+
+// 多选题部分得分规则
+const (
+	PartialCreditNone         = ""             // 全对得分，否则不得分
+	PartialCreditProportional = "proportional" // 未选错时按选对比例得分
+	PartialCreditPenalty      = "penalty"      // 每选对一项得分，每选错一项扣分，最低为0
+)
+
+// 填空题答案匹配方式
+const (
+	MatchIgnoreCase  = ""             // 忽略大小写，连续空白视为一个空格
+	MatchExact       = "exact"        // 仅去除首尾空白后完全一致
+	MatchIgnoreSpace = "ignore_space" // 忽略大小写和所有空白
+	MatchRegex       = "regex"        // 标准答案为正则表达式，需完整匹配
+)
+
+// Question 试卷题目
+type Question struct {
+	ID       uint              `gorm:"primary_key" json:"id"`
+	PaperID  uint              `gorm:"index;not null" json:"paper_id"`
+	Position int               `gorm:"not null" json:"position"` // 题目顺序，从1开始
+	Type     string            `gorm:"size:20;not null" json:"type"`
+	Content  string            `gorm:"type:text;not null" json:"content"`
+	Score    float64           `gorm:"not null" json:"score"`
+	Answer   string            `gorm:"type:text" json:"answer,omitempty"` // 判断题为 true/false，填空题为标准答案，数值题为数值，主观题为参考答案
+	Options  []QuestionOption  `gorm:"foreignkey:QuestionID" json:"options"`
+	Rubric   []RubricCriterion `gorm:"foreignkey:QuestionID" json:"rubric,omitempty"` // 主观题的评分标准
+
+	PartialCredit string  `gorm:"size:20" json:"partial_credit,omitempty"` // 多选题部分得分规则
+	MatchMode     string  `gorm:"size:20" json:"match_mode,omitempty"`     // 填空题匹配方式
+	Tolerance     float64 `json:"tolerance,omitempty"`                     // 数值题允许的绝对误差
+
+	// 引用的题库题目及版本，只提供题库题目ID时创建或更新试卷会用题库中的内容填充题目
+	BankQuestionID uint `gorm:"index" json:"bank_question_id,omitempty"`
+	BankVersion    int  `json:"bank_version,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// QuestionOption 选择题选项，正确选项即答案
+type QuestionOption struct {
+	ID         uint   `gorm:"primary_key" json:"id"`
+	QuestionID uint   `gorm:"index;not null" json:"question_id"`
+	Position   int    `gorm:"not null" json:"position"`
+	Label      string `gorm:"size:10;not null" json:"label"` // 选项标签，如 A、B、C
+	Content    string `gorm:"type:text;not null" json:"content"`
+	IsCorrect  bool   `json:"is_correct,omitempty"`
+}
+
+// HasRubric 判断题目是否设置了评分标准
+func (q *Question) HasRubric() bool {
+	return len(q.Rubric) > 0
+}
+
+// FindCriterion 根据ID查找评分维度，不存在时返回nil
+func (q *Question) FindCriterion(id uint) *RubricCriterion {
+	for i := range q.Rubric {
+		if q.Rubric[i].ID == id {
+			return &q.Rubric[i]
+		}
+	}
+	return nil
+}
+
+// IsObjective 判断题目是否为客观题
+func (q *Question) IsObjective() bool {
+	switch q.Type {
+	case QuestionSingleChoice, QuestionMultipleChoice, QuestionTrueFalse, QuestionFillBlank, QuestionNumeric:
+		return true
+	}
+	return false
+}
+
+// HasOptions 判断题目是否需要选项
+func (q *Question) HasOptions() bool {
+	return q.Type == QuestionSingleChoice || q.Type == QuestionMultipleChoice
+}
+
+// HideAnswerKey 清除答案信息，用于向学生展示题目
+func (q *Question) HideAnswerKey() {
+	q.Answer = ""
+	for i := range q.Options {
+		q.Options[i].IsCorrect = false
+	}
+}
+
+// HideAnswerKey 清除试卷中所有题目的答案信息
+func (p *Paper) HideAnswerKey() {
+	for i := range p.Questions {
+		p.Questions[i].HideAnswerKey()
+	}
+}
+
+// QuestionsText 返回题目内容的规范化JSON，用于试卷签名
+func (p *Paper) QuestionsText() string {
+	type option struct {
+		Label     string `json:"label"`
+		Content   string `json:"content"`
+		IsCorrect bool   `json:"is_correct"`
+	}
+	type level struct {
+		Label  string  `json:"label"`
+		Points float64 `json:"points"`
+	}
+	type criterion struct {
+		Name   string  `json:"name"`
+		Levels []level `json:"levels"`
+	}
+	type question struct {
+		Position int      `json:"position"`
+		Type     string   `json:"type"`
+		Content  string   `json:"content"`
+		Score    float64  `json:"score"`
+		Answer   string   `json:"answer"`
+		Options  []option `json:"options"`
+
+		// 评分规则，为默认值时省略，保证旧签名仍然有效
+		PartialCredit string      `json:"partial_credit,omitempty"`
+		MatchMode     string      `json:"match_mode,omitempty"`
+		Tolerance     float64     `json:"tolerance,omitempty"`
+		Rubric        []criterion `json:"rubric,omitempty"`
+	}
+
+	questions := make([]question, 0, len(p.Questions))
+	for _, q := range p.Questions {
+		item := question{
+			Position: q.Position,
+			Type:     q.Type,
+			Content:  q.Content,
+			Score:    q.Score,
+			Answer:   q.Answer,
+			Options:  make([]option, 0, len(q.Options)),
+
+			PartialCredit: q.PartialCredit,
+			MatchMode:     q.MatchMode,
+			Tolerance:     q.Tolerance,
+		}
+		for _, o := range q.Options {
+			item.Options = append(item.Options, option{Label: o.Label, Content: o.Content, IsCorrect: o.IsCorrect})
+		}
+		for _, c := range q.Rubric {
+			rubric := criterion{Name: c.Name, Levels: make([]level, 0, len(c.Levels))}
+			for _, l := range c.Levels {
+				rubric.Levels = append(rubric.Levels, level{Label: l.Label, Points: l.Points})
+			}
+			item.Rubric = append(item.Rubric, rubric)
+		}
+		questions = append(questions, item)
+	}
+
+	data, _ := json.Marshal(questions)
+	return string(data)
+}
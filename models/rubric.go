@@ -0,0 +1,51 @@
+package models
+
+// RubricCriterion 主观题评分标准中的一项评分维度
+type RubricCriterion struct {
+	ID          uint          `gorm:"primary_key" json:"id"`
+	QuestionID  uint          `gorm:"index;not null" json:"question_id"`
+	Position    int           `gorm:"not null" json:"position"`
+	Name        string        `gorm:"size:100;not null" json:"name"`
+	Description string        `gorm:"type:text" json:"description"`
+	Levels      []RubricLevel `gorm:"foreignkey:CriterionID" json:"levels"`
+}
+
+// RubricLevel 评分维度的一个等级及对应分数
+type RubricLevel struct {
+	ID          uint    `gorm:"primary_key" json:"id"`
+	CriterionID uint    `gorm:"index;not null" json:"criterion_id"`
+	Position    int     `gorm:"not null" json:"position"`
+	Label       string  `gorm:"size:50;not null" json:"label"` // 如 优秀、良好、及格
+	Description string  `gorm:"type:text" json:"description"`
+	Points      float64 `gorm:"not null" json:"points"`
+}
+
+// CriterionScore 教师按评分维度给出的得分
+type CriterionScore struct {
+	ID          uint    `gorm:"primary_key" json:"id"`
+	AnswerID    uint    `gorm:"index;not null" json:"answer_id"`
+	CriterionID uint    `gorm:"not null" json:"criterion_id"`
+	LevelID     uint    `gorm:"not null" json:"level_id"`
+	Points      float64 `gorm:"not null" json:"points"`
+}
+
+// MaxPoints 返回评分维度的最高分
+func (c *RubricCriterion) MaxPoints() float64 {
+	var max float64
+	for _, level := range c.Levels {
+		if level.Points > max {
+			max = level.Points
+		}
+	}
+	return max
+}
+
+// FindLevel 根据ID查找等级，不存在时返回nil
+func (c *RubricCriterion) FindLevel(id uint) *RubricLevel {
+	for i := range c.Levels {
+		if c.Levels[i].ID == id {
+			return &c.Levels[i]
+		}
+	}
+	return nil
+}
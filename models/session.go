@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Session 登录会话，每次登录签发的令牌对应一条记录
+type Session struct {
+	ID         uint       `gorm:"primary_key" json:"id"`
+	SessionID  string     `gorm:"size:64;unique_index;not null" json:"-"` // 令牌中的会话ID，不对外暴露
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	User       User       `gorm:"foreignkey:UserID" json:"-"`
+	Device     string     `gorm:"size:255" json:"device"` // 登录设备（User-Agent）
+	IP         string     `gorm:"size:64" json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// Active 判断会话在指定时间点是否仍然有效（未注销、未过期、未超过空闲时长）
+func (s *Session) Active(now time.Time, idleTimeout time.Duration) bool {
+	if s.RevokedAt != nil || !now.Before(s.ExpiresAt) {
+		return false
+	}
+	return idleTimeout <= 0 || now.Sub(s.LastSeenAt) < idleTimeout
+}
+
+// BeforeCreate 创建记录前的钩子函数
+func (s *Session) BeforeCreate(scope *gorm.Scope) error {
+	scope.SetColumn("CreatedAt", time.Now())
+	scope.SetColumn("LastSeenAt", time.Now())
+	return nil
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 备份频率常量
+const (
+	BackupDaily   = "daily"   // 每天
+	BackupWeekly  = "weekly"  // 每周
+	BackupMonthly = "monthly" // 每月
+
+	// BackupEveryPrefix 自定义备份间隔前缀，例如 "@every 6h"
+	BackupEveryPrefix = "@every "
+	// MinBackupInterval 自定义备份间隔的最小值
+	MinBackupInterval = 10 * time.Minute
+)
+
+// SystemSettings 系统设置，数据库中只保存一行
+type SystemSettings struct {
+	ID                  uint   `gorm:"primary_key" json:"-"`
+	SystemName          string `gorm:"size:100;not null" json:"system_name"`
+	AdminEmail          string `gorm:"size:100" json:"admin_email"`
+	PageSize            int    `gorm:"not null" json:"page_size"`
+	MinPasswordLength   int    `gorm:"not null" json:"min_password_length"`
+	SessionTimeout      int    `gorm:"not null" json:"session_timeout"` // 会话空闲超时（分钟）
+	MaxLoginAttempts    int    `gorm:"not null" json:"max_login_attempts"`
+	AutoBackup          bool   `json:"auto_backup"`
+	BackupFrequency     string `gorm:"size:20;not null" json:"backup_frequency"`              // daily/weekly/monthly 或 "@every <间隔>"
+	BackupCount         int    `gorm:"not null" json:"backup_count"`                          // 保留的备份数量
+	GradeScale          string `gorm:"size:20;not null;default:'percent'" json:"grade_scale"` // 成绩等级制
+	LateSubmissionGrace int    `gorm:"not null;default:0" json:"late_submission_grace"`       // 超过截止时间后仍接受交卷的宽限期（分钟），0表示拒绝逾期交卷
+	// ApprovalEscalationHours 审批任务待处理超过该时长（小时）后转交给超时转交审批人，0表示不转交
+	ApprovalEscalationHours  int       `gorm:"not null;default:0" json:"approval_escalation_hours"`
+	ApprovalFallbackApprover uint      `gorm:"not null;default:0" json:"approval_fallback_approver"` // 超时转交审批人的用户ID
+	UpdatedBy                uint      `json:"updated_by"`
+	UpdatedAt                time.Time `json:"updated_at"`
+}
+
+// DefaultSystemSettings 返回系统默认设置
+func DefaultSystemSettings() SystemSettings {
+	return SystemSettings{
+		SystemName:          "试卷审批管理系统",
+		AdminEmail:          "admin@example.com",
+		PageSize:            10,
+		MinPasswordLength:   8,
+		SessionTimeout:      30,
+		MaxLoginAttempts:    5,
+		AutoBackup:          true,
+		BackupFrequency:     BackupWeekly,
+		BackupCount:         10,
+		GradeScale:          GradeScalePercent,
+		LateSubmissionGrace: 0,
+	}
+}
+
+// SessionIdleTimeout 返回会话空闲超时时长
+func (s SystemSettings) SessionIdleTimeout() time.Duration {
+	return time.Duration(s.SessionTimeout) * time.Minute
+}
+
+// BeforeSave 保存记录前的钩子函数
+func (s *SystemSettings) BeforeSave(scope *gorm.Scope) error {
+	scope.SetColumn("UpdatedAt", time.Now())
+	return nil
+}
+
+// LateGrace 返回逾期交卷的宽限期
+func (s SystemSettings) LateGrace() time.Duration {
+	return time.Duration(s.LateSubmissionGrace) * time.Minute
+}
+
+// ApprovalSLA 返回审批任务超时转交的时限，0表示不转交
+func (s SystemSettings) ApprovalSLA() time.Duration {
+	return time.Duration(s.ApprovalEscalationHours) * time.Hour
+}
+
+// NextBackupTime 根据备份频率计算上次备份之后的下一次备份时间
+func NextBackupTime(frequency string, last time.Time) (time.Time, error) {
+	switch frequency {
+	case BackupDaily:
+		return last.AddDate(0, 0, 1), nil
+	case BackupWeekly:
+		return last.AddDate(0, 0, 7), nil
+	case BackupMonthly:
+		return last.AddDate(0, 1, 0), nil
+	}
+
+	if !strings.HasPrefix(frequency, BackupEveryPrefix) {
+		return time.Time{}, fmt.Errorf("必须是daily、weekly、monthly或\"@every <间隔>\"")
+	}
+	interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(frequency, BackupEveryPrefix)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无效的备份间隔: %v", err)
+	}
+	if interval < MinBackupInterval {
+		return time.Time{}, fmt.Errorf("备份间隔不能小于%v", MinBackupInterval)
+	}
+	return last.Add(interval), nil
+}
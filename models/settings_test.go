@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextBackupTime(t *testing.T) {
+	last := time.Date(2026, 1, 31, 2, 0, 0, 0, time.UTC)
+	tests := []struct {
+		frequency string
+		want      time.Time
+		wantErr   bool
+	}{
+		{BackupDaily, last.AddDate(0, 0, 1), false},
+		{BackupWeekly, last.AddDate(0, 0, 7), false},
+		{BackupMonthly, last.AddDate(0, 1, 0), false},
+		{"@every 6h", last.Add(6 * time.Hour), false},
+		{"@every 5m", time.Time{}, true},
+		{"@every soon", time.Time{}, true},
+		{"hourly", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.frequency, func(t *testing.T) {
+			got, err := NextBackupTime(tt.frequency, last)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NextBackupTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("NextBackupTime() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
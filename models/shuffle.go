@@ -0,0 +1,96 @@
+package models
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// Shuffle 学生看到的题目顺序和选择题选项顺序。顺序由答题记录ID确定，同一答题记录每次打开考试看到的顺序相同；
+// 选项按展示顺序重新标为A、B、C…，提交和暂存的作答使用展示标签，保存和评分前需换算为题目原有的标签
+type Shuffle struct {
+	order     []int                      // 按展示顺序排列的题目下标
+	canonical map[uint]map[string]string // 题目ID -> 展示标签 -> 原有标签
+	display   map[uint]map[string]string // 题目ID -> 原有标签 -> 展示标签
+	perms     map[uint][]int             // 题目ID -> 按展示顺序排列的选项下标
+}
+
+// NewShuffle 按答题记录ID为考试题目生成打乱后的顺序，questions为考试题目的原有顺序
+func NewShuffle(examDataID uint, questions []Question) *Shuffle {
+	s := &Shuffle{
+		order:     rand.New(rand.NewSource(int64(examDataID))).Perm(len(questions)),
+		canonical: make(map[uint]map[string]string),
+		display:   make(map[uint]map[string]string),
+		perms:     make(map[uint][]int),
+	}
+
+	for _, question := range questions {
+		if !question.HasOptions() {
+			continue
+		}
+		// 每道题的选项顺序只由答题记录和题目决定，不受其他题目的影响
+		seed := int64(examDataID)<<32 | int64(question.ID)
+		perm := rand.New(rand.NewSource(seed)).Perm(len(question.Options))
+		toCanonical := make(map[string]string, len(perm))
+		toDisplay := make(map[string]string, len(perm))
+		for i, index := range perm {
+			label := string(rune('A' + i))
+			toCanonical[label] = question.Options[index].Label
+			toDisplay[question.Options[index].Label] = label
+		}
+		s.perms[question.ID] = perm
+		s.canonical[question.ID] = toCanonical
+		s.display[question.ID] = toDisplay
+	}
+	return s
+}
+
+// Apply 返回按展示顺序排列的题目副本，题号从1开始重新编号，选项按展示顺序重新标注
+func (s *Shuffle) Apply(questions []Question) []Question {
+	shuffled := make([]Question, len(questions))
+	for i, index := range s.order {
+		question := questions[index]
+		question.Position = i + 1
+		if perm, ok := s.perms[question.ID]; ok {
+			options := make([]QuestionOption, len(perm))
+			for j, k := range perm {
+				options[j] = question.Options[k]
+				options[j].Position = j + 1
+				options[j].Label = string(rune('A' + j))
+			}
+			question.Options = options
+		}
+		shuffled[i] = question
+	}
+	return shuffled
+}
+
+// ToCanonical 将使用展示标签的作答换算为题目原有的选项标签，无法识别的标签原样保留由校验报错
+func (s *Shuffle) ToCanonical(responses map[uint]string) map[uint]string {
+	return s.convert(responses, s.canonical)
+}
+
+// ToDisplay 将使用原有选项标签的作答换算为展示标签，用于恢复暂存的作答
+func (s *Shuffle) ToDisplay(responses map[uint]string) map[uint]string {
+	return s.convert(responses, s.display)
+}
+
+// convert 按题目的标签对照表换算作答中的选项标签
+func (s *Shuffle) convert(responses map[uint]string, tables map[uint]map[string]string) map[uint]string {
+	converted := make(map[uint]string, len(responses))
+	for questionID, response := range responses {
+		table, ok := tables[questionID]
+		if !ok {
+			converted[questionID] = response
+			continue
+		}
+		labels := strings.Split(response, ",")
+		for i, label := range labels {
+			label = strings.ToUpper(strings.TrimSpace(label))
+			if mapped, ok := table[label]; ok {
+				labels[i] = mapped
+			}
+		}
+		converted[questionID] = strings.Join(labels, ",")
+	}
+	return converted
+}
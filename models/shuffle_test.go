@@ -0,0 +1,126 @@
+package models
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func shuffleQuestions() []Question {
+	options := func(labels ...string) []QuestionOption {
+		var result []QuestionOption
+		for i, label := range labels {
+			result = append(result, QuestionOption{Label: label, Content: "选项" + label, Position: i + 1})
+		}
+		return result
+	}
+	return []Question{
+		{ID: 11, Type: QuestionSingleChoice, Position: 1, Options: options("A", "B", "C", "D")},
+		{ID: 12, Type: QuestionMultipleChoice, Position: 2, Options: options("A", "B", "C", "D", "E")},
+		{ID: 13, Type: QuestionTrueFalse, Position: 3},
+		{ID: 14, Type: QuestionFillBlank, Position: 4},
+		{ID: 15, Type: QuestionSingleChoice, Position: 5, Options: options("A", "B", "C")},
+	}
+}
+
+func TestShuffleApply(t *testing.T) {
+	questions := shuffleQuestions()
+
+	tests := []struct {
+		name       string
+		examDataID uint
+	}{
+		{"答题记录1", 1},
+		{"答题记录2", 2},
+		{"答题记录较大", 1 << 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shuffled := NewShuffle(tt.examDataID, questions).Apply(questions)
+			again := NewShuffle(tt.examDataID, questions).Apply(questions)
+			if !reflect.DeepEqual(shuffled, again) {
+				t.Fatal("同一答题记录两次生成的顺序不同")
+			}
+
+			var ids []int
+			for i, question := range shuffled {
+				ids = append(ids, int(question.ID))
+				if question.Position != i+1 {
+					t.Errorf("第%d题的题号为 %d", i+1, question.Position)
+				}
+				for j, option := range question.Options {
+					if want := string(rune('A' + j)); option.Label != want || option.Position != j+1 {
+						t.Errorf("题目%d的第%d个选项标为 %s/%d, 期望 %s/%d", question.ID, j+1, option.Label, option.Position, want, j+1)
+					}
+				}
+			}
+			sort.Ints(ids)
+			if !reflect.DeepEqual(ids, []int{11, 12, 13, 14, 15}) {
+				t.Errorf("打乱后的题目 = %v, 不是原题目的排列", ids)
+			}
+			if questions[0].Options[0].Label != "A" || questions[0].Position != 1 {
+				t.Error("Apply修改了传入的题目")
+			}
+		})
+	}
+}
+
+func TestShuffleConvert(t *testing.T) {
+	questions := shuffleQuestions()
+	shuffle := NewShuffle(7, questions)
+	shuffled := shuffle.Apply(questions)
+
+	// 学生按展示的选项作答，换算后应为选项内容对应的原有标签
+	displayed := make(map[uint]map[string]string)
+	for _, question := range shuffled {
+		displayed[question.ID] = make(map[string]string)
+		for _, option := range question.Options {
+			displayed[question.ID][option.Label] = option.Content[len("选项"):]
+		}
+	}
+
+	tests := []struct {
+		name     string
+		display  map[uint]string
+		expected map[uint]string
+		// 换算回展示标签后的作答，为空时应与display相同
+		roundTrip map[uint]string
+	}{
+		{
+			name:     "单选",
+			display:  map[uint]string{11: "A"},
+			expected: map[uint]string{11: displayed[11]["A"]},
+		},
+		{
+			name:      "多选小写并带空格",
+			display:   map[uint]string{12: "a, c"},
+			expected:  map[uint]string{12: displayed[12]["A"] + "," + displayed[12]["C"]},
+			roundTrip: map[uint]string{12: "A,C"},
+		},
+		{
+			name:     "非选择题原样保留",
+			display:  map[uint]string{13: "true", 14: "答案"},
+			expected: map[uint]string{13: "true", 14: "答案"},
+		},
+		{
+			name:     "无法识别的标签原样保留",
+			display:  map[uint]string{15: "Z"},
+			expected: map[uint]string{15: "Z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canonical := shuffle.ToCanonical(tt.display)
+			if !reflect.DeepEqual(canonical, tt.expected) {
+				t.Fatalf("ToCanonical(%v) = %v, 期望 %v", tt.display, canonical, tt.expected)
+			}
+			want := tt.roundTrip
+			if want == nil {
+				want = tt.display
+			}
+			if back := shuffle.ToDisplay(canonical); !reflect.DeepEqual(back, want) {
+				t.Errorf("ToDisplay(%v) = %v, 期望 %v", canonical, back, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 提交的评分状态
+const (
+	SubmissionPendingReview = "pending_review" // 仍有题目等待教师评分
+	SubmissionGraded        = "graded"         // 全部题目已评分
+)
+
+// Submission 学生的一次答卷提交
+type Submission struct {
+	ID            uint      `gorm:"primary_key" json:"id"`
+	ExamDataID    uint      `gorm:"index;not null" json:"exam_data_id"`
+	ExamID        uint      `gorm:"index;not null" json:"exam_id"`
+	StudentID     uint      `gorm:"index;not null" json:"student_id"`
+	Attempt       int       `gorm:"not null" json:"attempt"` // 第几次提交，从1开始
+	AttemptID     uint      `gorm:"index" json:"attempt_id"` // 对应的作答会话
+	Late          bool      `json:"late"`                    // 超过截止时间后在宽限期内提交
+	AutoSubmitted bool      `json:"auto_submitted"`          // 超时后由系统自动提交
+	Answers       []Answer  `gorm:"foreignkey:SubmissionID" json:"answers"`
+	Status        string    `gorm:"size:20;not null;default:'pending_review'" json:"status"`
+	Score         float64   `json:"score"` // 已评分题目的得分之和
+	SubmittedAt   time.Time `json:"submitted_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Answer 单道题目的作答
+type Answer struct {
+	ID           uint       `gorm:"primary_key" json:"id"`
+	SubmissionID uint       `gorm:"unique_index:idx_answer_submission_question;not null" json:"submission_id"`
+	QuestionID   uint       `gorm:"unique_index:idx_answer_submission_question" json:"question_id"` // 0表示不对应具体题目的整卷作答
+	Question     *Question  `gorm:"foreignkey:QuestionID" json:"question,omitempty"`
+	Response     string     `gorm:"type:text" json:"response"` // 多选题为以逗号分隔的选项标签
+	Score        *float64   `json:"score"`                     // 未评分时为空
+	AutoGraded   bool       `json:"auto_graded"`
+	GradedBy     uint       `json:"graded_by"` // 人工评分的教师ID
+	GradedAt     *time.Time `json:"graded_at"`
+	Feedback     string     `gorm:"type:text" json:"feedback"` // 教师对该题的反馈
+
+	CriterionScores []CriterionScore `gorm:"foreignkey:AnswerID" json:"criterion_scores,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Text 将提交内容拼接为便于阅读的文本，按题目顺序逐行列出
+func (s *Submission) Text() string {
+	lines := make([]string, 0, len(s.Answers))
+	for _, answer := range s.Answers {
+		if answer.Question == nil || answer.QuestionID == 0 {
+			lines = append(lines, answer.Response)
+			continue
+		}
+		line := fmt.Sprintf("第%d题: %s", answer.Question.Position, answer.Response)
+		if answer.Score != nil {
+			line += fmt.Sprintf(" [得分 %g/%g]", *answer.Score, answer.Question.Score)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// IsGraded 判断作答是否已评分
+func (a *Answer) IsGraded() bool {
+	return a.Score != nil
+}
+
+// UpdateScore 根据各题得分更新提交的总分和评分状态
+func (s *Submission) UpdateScore() {
+	s.Score = 0
+	s.Status = SubmissionGraded
+	for _, answer := range s.Answers {
+		if !answer.IsGraded() {
+			s.Status = SubmissionPendingReview
+			continue
+		}
+		s.Score += *answer.Score
+	}
+}
+
+// HideGrades 清除各题得分和反馈，用于批阅完成前向学生展示
+func (s *Submission) HideGrades() {
+	s.Score = 0
+	for i := range s.Answers {
+		s.Answers[i].Score = nil
+		s.Answers[i].Feedback = ""
+		s.Answers[i].CriterionScores = nil
+	}
+}
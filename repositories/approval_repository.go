@@ -0,0 +1,268 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/jinzhu/gorm"
+)
+
+// ApprovalRepository 审批链及审批任务仓库接口
+type ApprovalRepository interface {
+	WithTx(tx *gorm.DB) ApprovalRepository
+	ListChains() ([]models.ApprovalChain, error)
+	GetChain(id uint) (*models.ApprovalChain, error)
+	GetChainByCourse(course string) (*models.ApprovalChain, error)
+	SaveChain(chain *models.ApprovalChain) error
+	DeleteChain(id uint) error
+	CreateTasks(tasks []models.ExamApprovalTask) error
+	LatestRound(examID uint) (int, error)
+	ListTasks(examID uint, round int) ([]models.ExamApprovalTask, error)
+	ListPendingByApprovers(approverIDs []uint) ([]models.ExamApprovalTask, error)
+	ListStalePending(before time.Time) ([]models.ExamApprovalTask, error)
+	Decide(task *models.ExamApprovalTask) (bool, error)
+	Escalate(task *models.ExamApprovalTask, to uint) (bool, error)
+	UpdateStatus(examID uint, round, stage int, from, to string) error
+	ListDelegations(userID uint) ([]models.ApprovalDelegation, error)
+	ListActiveDelegations(delegateID uint, at time.Time) ([]models.ApprovalDelegation, error)
+	GetDelegation(id uint) (*models.ApprovalDelegation, error)
+	CountOverlappingDelegations(delegation *models.ApprovalDelegation) (int, error)
+	CreateDelegation(delegation *models.ApprovalDelegation) error
+	DeleteDelegation(id uint) error
+	DeleteDelegationsByUser(userID uint) (int64, error)
+}
+
+// approvalRepository 审批链及审批任务仓库实现，tx不为空时所有操作在该事务中执行
+type approvalRepository struct {
+	tx *gorm.DB
+}
+
+// NewApprovalRepository 创建审批仓库
+func NewApprovalRepository() ApprovalRepository {
+	return &approvalRepository{}
+}
+
+// WithTx 返回在事务tx中执行操作的审批仓库
+func (r *approvalRepository) WithTx(tx *gorm.DB) ApprovalRepository {
+	return &approvalRepository{tx: tx}
+}
+
+// preloadStages 按级别预加载审批链的各级审批及审批人
+func preloadStages(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Stages", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Stages.Approvers", func(db *gorm.DB) *gorm.DB { return db.Order("id") })
+}
+
+// ListChains 获取全部审批链，按科目排列
+func (r *approvalRepository) ListChains() ([]models.ApprovalChain, error) {
+	var chains []models.ApprovalChain
+	err := preloadStages(conn(r.tx)).Order("course").Find(&chains).Error
+	return chains, err
+}
+
+// GetChain 根据ID获取审批链
+func (r *approvalRepository) GetChain(id uint) (*models.ApprovalChain, error) {
+	var chain models.ApprovalChain
+	err := preloadStages(conn(r.tx)).First(&chain, id).Error
+	return &chain, err
+}
+
+// GetChainByCourse 获取科目的审批链，没有时返回gorm.ErrRecordNotFound
+func (r *approvalRepository) GetChainByCourse(course string) (*models.ApprovalChain, error) {
+	var chain models.ApprovalChain
+	err := preloadStages(conn(r.tx)).Where("course = ?", course).First(&chain).Error
+	return &chain, err
+}
+
+// SaveChain 创建或更新审批链，更新时整体替换各级审批及审批人
+func (r *approvalRepository) SaveChain(chain *models.ApprovalChain) error {
+	return conn(r.tx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Set("gorm:save_associations", false).Save(chain).Error; err != nil {
+			return err
+		}
+		if err := deleteStages(tx, chain.ID); err != nil {
+			return err
+		}
+		for i := range chain.Stages {
+			stage := &chain.Stages[i]
+			stage.ID = 0
+			stage.ChainID = chain.ID
+			if err := tx.Set("gorm:save_associations", false).Create(stage).Error; err != nil {
+				return err
+			}
+			for j := range stage.Approvers {
+				approver := &stage.Approvers[j]
+				approver.ID = 0
+				approver.StageID = stage.ID
+				if err := tx.Create(approver).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteChain 删除审批链，进行中的审批按已生成的任务继续
+func (r *approvalRepository) DeleteChain(id uint) error {
+	return conn(r.tx).Transaction(func(tx *gorm.DB) error {
+		if err := deleteStages(tx, id); err != nil {
+			return err
+		}
+		return tx.Delete(&models.ApprovalChain{}, id).Error
+	})
+}
+
+// deleteStages 删除审批链的各级审批及审批人
+func deleteStages(tx *gorm.DB, chainID uint) error {
+	stages := tx.Model(&models.ApprovalStage{}).Select("id").Where("chain_id = ?", chainID).SubQuery()
+	if err := tx.Where("stage_id IN ?", stages).Delete(&models.ApprovalStageApprover{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("chain_id = ?", chainID).Delete(&models.ApprovalStage{}).Error
+}
+
+// CreateTasks 在事务中创建一轮审批任务
+func (r *approvalRepository) CreateTasks(tasks []models.ExamApprovalTask) error {
+	return conn(r.tx).Transaction(func(tx *gorm.DB) error {
+		for i := range tasks {
+			if err := tx.Create(&tasks[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LatestRound 获取考试最近一轮审批的轮次，没有审批任务时返回0
+func (r *approvalRepository) LatestRound(examID uint) (int, error) {
+	var result struct{ Round int }
+	err := conn(r.tx).Model(&models.ExamApprovalTask{}).Select("COALESCE(MAX(round), 0) AS round").
+		Where("exam_id = ?", examID).Scan(&result).Error
+	return result.Round, err
+}
+
+// ListTasks 获取考试指定轮次的审批任务，round为0时返回所有轮次，按轮次、级别排列
+func (r *approvalRepository) ListTasks(examID uint, round int) ([]models.ExamApprovalTask, error) {
+	db := conn(r.tx).Where("exam_id = ?", examID)
+	if round > 0 {
+		db = db.Where("round = ?", round)
+	}
+	var tasks []models.ExamApprovalTask
+	err := db.Order("round").Order("stage").Order("id").Find(&tasks).Error
+	return tasks, err
+}
+
+// ListPendingByApprovers 获取分配给这些审批人且待处理的审批任务及对应考试，按变为待处理的时间排列
+func (r *approvalRepository) ListPendingByApprovers(approverIDs []uint) ([]models.ExamApprovalTask, error) {
+	var tasks []models.ExamApprovalTask
+	err := conn(r.tx).Preload("Exam").Where("approver_id IN (?) AND status = ?", approverIDs, models.ApprovalTaskPending).
+		Order("updated_at").Order("id").Find(&tasks).Error
+	return tasks, err
+}
+
+// ListStalePending 获取在before之前变为待处理且仍未处理的审批任务及对应考试
+func (r *approvalRepository) ListStalePending(before time.Time) ([]models.ExamApprovalTask, error) {
+	var tasks []models.ExamApprovalTask
+	err := conn(r.tx).Preload("Exam").Where("status = ? AND updated_at < ?", models.ApprovalTaskPending, before).
+		Order("updated_at").Order("id").Find(&tasks).Error
+	return tasks, err
+}
+
+// Decide 在任务仍待处理时保存审批人的决定，任务已被处理或跳过时返回false
+func (r *approvalRepository) Decide(task *models.ExamApprovalTask) (bool, error) {
+	now := time.Now()
+	result := conn(r.tx).Model(&models.ExamApprovalTask{}).
+		Where("id = ? AND status = ?", task.ID, models.ApprovalTaskPending).
+		Updates(map[string]interface{}{
+			"status":        task.Status,
+			"comment":       task.Comment,
+			"decided_by_id": task.DecidedByID,
+			"decided_at":    now,
+			"updated_at":    now,
+		})
+	if result.Error != nil || result.RowsAffected != 1 {
+		return false, result.Error
+	}
+	task.DecidedAt = &now
+	return true, nil
+}
+
+// Escalate 在任务仍由原审批人待处理时转交给to，并记录原审批人，任务已被处理或转交时返回false
+func (r *approvalRepository) Escalate(task *models.ExamApprovalTask, to uint) (bool, error) {
+	now := time.Now()
+	result := conn(r.tx).Model(&models.ExamApprovalTask{}).
+		Where("id = ? AND status = ? AND approver_id = ?", task.ID, models.ApprovalTaskPending, task.ApproverID).
+		Updates(map[string]interface{}{
+			"approver_id":       to,
+			"escalated_from_id": task.ApproverID,
+			"escalated_at":      now,
+			"updated_at":        now,
+		})
+	if result.Error != nil || result.RowsAffected != 1 {
+		return false, result.Error
+	}
+	task.EscalatedFromID = task.ApproverID
+	task.ApproverID = to
+	task.EscalatedAt = &now
+	return true, nil
+}
+
+// UpdateStatus 将考试某轮审批中状态为from的任务更新为to，stage为0时更新该轮所有级别
+func (r *approvalRepository) UpdateStatus(examID uint, round, stage int, from, to string) error {
+	db := conn(r.tx).Model(&models.ExamApprovalTask{}).Where("exam_id = ? AND round = ? AND status = ?", examID, round, from)
+	if stage > 0 {
+		db = db.Where("stage = ?", stage)
+	}
+	return db.Updates(map[string]interface{}{"status": to, "updated_at": time.Now()}).Error
+}
+
+// ListDelegations 获取用户作为委托人或代理人的全部委托，按开始时间排列
+func (r *approvalRepository) ListDelegations(userID uint) ([]models.ApprovalDelegation, error) {
+	var delegations []models.ApprovalDelegation
+	err := conn(r.tx).Where("user_id = ? OR delegate_id = ?", userID, userID).
+		Order("start_time").Order("id").Find(&delegations).Error
+	return delegations, err
+}
+
+// ListActiveDelegations 获取at时生效、由delegateID代理的委托
+func (r *approvalRepository) ListActiveDelegations(delegateID uint, at time.Time) ([]models.ApprovalDelegation, error) {
+	var delegations []models.ApprovalDelegation
+	err := conn(r.tx).Where("delegate_id = ? AND start_time <= ? AND end_time > ?", delegateID, at, at).
+		Order("id").Find(&delegations).Error
+	return delegations, err
+}
+
+// GetDelegation 根据ID获取委托
+func (r *approvalRepository) GetDelegation(id uint) (*models.ApprovalDelegation, error) {
+	var delegation models.ApprovalDelegation
+	err := conn(r.tx).First(&delegation, id).Error
+	return &delegation, err
+}
+
+// CountOverlappingDelegations 统计委托人时间范围与之重叠的其他委托
+func (r *approvalRepository) CountOverlappingDelegations(delegation *models.ApprovalDelegation) (int, error) {
+	var count int
+	err := conn(r.tx).Model(&models.ApprovalDelegation{}).
+		Where("user_id = ? AND id <> ? AND start_time < ? AND end_time > ?",
+			delegation.UserID, delegation.ID, delegation.EndTime, delegation.StartTime).
+		Count(&count).Error
+	return count, err
+}
+
+// CreateDelegation 创建委托
+func (r *approvalRepository) CreateDelegation(delegation *models.ApprovalDelegation) error {
+	return conn(r.tx).Create(delegation).Error
+}
+
+// DeleteDelegation 删除委托
+func (r *approvalRepository) DeleteDelegation(id uint) error {
+	return conn(r.tx).Delete(&models.ApprovalDelegation{}, id).Error
+}
+
+// DeleteDelegationsByUser 删除用户作为委托人或代理人的全部委托，返回删除的委托数
+func (r *approvalRepository) DeleteDelegationsByUser(userID uint) (int64, error) {
+	result := conn(r.tx).Where("user_id = ? OR delegate_id = ?", userID, userID).Delete(&models.ApprovalDelegation{})
+	return result.RowsAffected, result.Error
+}
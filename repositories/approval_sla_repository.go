@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/models"
+)
+
+// ApprovalSLARepository 科目审批时限及超时审批仓库接口
+type ApprovalSLARepository interface {
+	ListSLAs() ([]models.ApprovalSLA, error)
+	GetSLA(id uint) (*models.ApprovalSLA, error)
+	GetSLAByCourse(course string) (*models.ApprovalSLA, error)
+	SaveSLA(sla *models.ApprovalSLA) error
+	DeleteSLA(id uint) error
+	FlagOverdue(overdue *models.OverdueApproval) (bool, error)
+	ListOverdue(openOnly bool) ([]models.OverdueApproval, error)
+	ResolveOverdue(id uint, at time.Time, action string) error
+	CountOpenOverdue() (int, error)
+}
+
+// approvalSLARepository 科目审批时限及超时审批仓库实现
+type approvalSLARepository struct{}
+
+// NewApprovalSLARepository 创建科目审批时限仓库
+func NewApprovalSLARepository() ApprovalSLARepository {
+	return &approvalSLARepository{}
+}
+
+// ListSLAs 获取全部科目审批时限，按科目排列
+func (r *approvalSLARepository) ListSLAs() ([]models.ApprovalSLA, error) {
+	var slas []models.ApprovalSLA
+	err := configs.DB().Order("course").Find(&slas).Error
+	return slas, err
+}
+
+// GetSLA 根据ID获取科目审批时限
+func (r *approvalSLARepository) GetSLA(id uint) (*models.ApprovalSLA, error) {
+	var sla models.ApprovalSLA
+	err := configs.DB().First(&sla, id).Error
+	return &sla, err
+}
+
+// GetSLAByCourse 获取科目的审批时限，没有时返回gorm.ErrRecordNotFound
+func (r *approvalSLARepository) GetSLAByCourse(course string) (*models.ApprovalSLA, error) {
+	var sla models.ApprovalSLA
+	err := configs.DB().Where("course = ?", course).First(&sla).Error
+	return &sla, err
+}
+
+// SaveSLA 创建或更新科目审批时限
+func (r *approvalSLARepository) SaveSLA(sla *models.ApprovalSLA) error {
+	return configs.DB().Save(sla).Error
+}
+
+// DeleteSLA 删除科目审批时限
+func (r *approvalSLARepository) DeleteSLA(id uint) error {
+	return configs.DB().Delete(&models.ApprovalSLA{}, id).Error
+}
+
+// FlagOverdue 标记超时审批，同一次提交审批已标记过时返回false
+func (r *approvalSLARepository) FlagOverdue(overdue *models.OverdueApproval) (bool, error) {
+	var count int
+	if err := configs.DB().Model(&models.OverdueApproval{}).Where("transition_id = ?", overdue.TransitionID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	if err := configs.DB().Create(overdue).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListOverdue 获取超时审批及对应考试，openOnly为true时只返回尚未结束的，按截止时间排列
+func (r *approvalSLARepository) ListOverdue(openOnly bool) ([]models.OverdueApproval, error) {
+	db := configs.DB().Preload("Exam")
+	if openOnly {
+		db = db.Where("resolved_at IS NULL")
+	}
+	var overdue []models.OverdueApproval
+	err := db.Order("due_at").Order("id").Find(&overdue).Error
+	return overdue, err
+}
+
+// ResolveOverdue 记录超时审批的结束时间和结束操作
+func (r *approvalSLARepository) ResolveOverdue(id uint, at time.Time, action string) error {
+	return configs.DB().Model(&models.OverdueApproval{}).Where("id = ? AND resolved_at IS NULL", id).
+		Updates(map[string]interface{}{"resolved_at": at, "resolved_action": action}).Error
+}
+
+// CountOpenOverdue 统计尚未结束的超时审批
+func (r *approvalSLARepository) CountOpenOverdue() (int, error) {
+	var count int
+	err := configs.DB().Model(&models.OverdueApproval{}).Where("resolved_at IS NULL").Count(&count).Error
+	return count, err
+}
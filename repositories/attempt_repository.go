@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/jinzhu/gorm"
+)
+
+// AttemptRepository 作答会话仓库接口
+type AttemptRepository interface {
+	WithTx(tx *gorm.DB) AttemptRepository
+	Create(attempt *models.ExamAttempt) error
+	GetActive(examDataID uint) (*models.ExamAttempt, error)
+	ListOverdue(before time.Time) ([]models.ExamAttempt, error)
+	CountActiveByExam(examID uint) (int, error)
+	SaveDraft(attempt *models.ExamAttempt, version int) (bool, error)
+	Close(attempt *models.ExamAttempt) (bool, error)
+	SetSubmission(attemptID, submissionID uint) error
+	DeleteByExam(examID uint) (int64, error)
+	DeleteByStudent(studentID uint) (int64, error)
+}
+
+// attemptRepository 作答会话仓库实现，tx不为空时所有操作在该事务中执行
+type attemptRepository struct {
+	tx *gorm.DB
+}
+
+// NewAttemptRepository 创建作答会话仓库
+func NewAttemptRepository() AttemptRepository {
+	return &attemptRepository{}
+}
+
+// WithTx 返回在事务tx中执行操作的作答仓库
+func (r *attemptRepository) WithTx(tx *gorm.DB) AttemptRepository {
+	return &attemptRepository{tx: tx}
+}
+
+// Create 创建作答会话，答题记录已有作答中的会话时违反唯一索引返回错误
+func (r *attemptRepository) Create(attempt *models.ExamAttempt) error {
+	if attempt.IsOpen() {
+		examDataID := attempt.ExamDataID
+		attempt.ActiveExamDataID = &examDataID
+	}
+	return conn(r.tx).Create(attempt).Error
+}
+
+// GetActive 获取答题记录中作答中的会话，没有时返回gorm.ErrRecordNotFound
+func (r *attemptRepository) GetActive(examDataID uint) (*models.ExamAttempt, error) {
+	var attempt models.ExamAttempt
+	err := conn(r.tx).Where("exam_data_id = ? AND status = ?", examDataID, models.AttemptInProgress).
+		Order("id desc").First(&attempt).Error
+	return &attempt, err
+}
+
+// ListOverdue 获取截止时间早于before且仍在作答中的会话
+func (r *attemptRepository) ListOverdue(before time.Time) ([]models.ExamAttempt, error) {
+	var attempts []models.ExamAttempt
+	err := conn(r.tx).Where("status = ? AND deadline IS NOT NULL AND deadline < ?", models.AttemptInProgress, before).
+		Order("deadline").Find(&attempts).Error
+	return attempts, err
+}
+
+// CountActiveByExam 统计考试中仍在作答中的会话数量
+func (r *attemptRepository) CountActiveByExam(examID uint) (int, error) {
+	var count int
+	err := conn(r.tx).Model(&models.ExamAttempt{}).Where("exam_id = ? AND status = ?", examID, models.AttemptInProgress).Count(&count).Error
+	return count, err
+}
+
+// SaveDraft 在暂存版本仍为version时保存attempt中的暂存作答并将版本加1，
+// 版本已被其他页面更新或会话已结束时返回false
+func (r *attemptRepository) SaveDraft(attempt *models.ExamAttempt, version int) (bool, error) {
+	now := time.Now()
+	result := conn(r.tx).Model(&models.ExamAttempt{}).
+		Where("id = ? AND status = ? AND draft_version = ?", attempt.ID, models.AttemptInProgress, version).
+		Updates(map[string]interface{}{
+			"draft":          attempt.Draft,
+			"draft_version":  version + 1,
+			"draft_saved_at": now,
+			"updated_at":     now,
+		})
+	if result.Error != nil || result.RowsAffected != 1 {
+		return false, result.Error
+	}
+	attempt.DraftVersion = version + 1
+	attempt.DraftSavedAt = &now
+	return true, nil
+}
+
+// Close 将作答中的会话更新为attempt中的结束状态，会话已被其他请求结束时返回false
+func (r *attemptRepository) Close(attempt *models.ExamAttempt) (bool, error) {
+	result := conn(r.tx).Model(&models.ExamAttempt{}).
+		Where("id = ? AND status = ?", attempt.ID, models.AttemptInProgress).
+		Updates(map[string]interface{}{
+			"status":              attempt.Status,
+			"active_exam_data_id": nil,
+			"submitted_at":        attempt.SubmittedAt,
+			"late":                attempt.Late,
+			"auto_submitted":      attempt.AutoSubmitted,
+			"updated_at":          time.Now(),
+		})
+	if result.Error != nil || result.RowsAffected != 1 {
+		return false, result.Error
+	}
+	attempt.ActiveExamDataID = nil
+	return true, nil
+}
+
+// SetSubmission 记录会话对应的提交
+func (r *attemptRepository) SetSubmission(attemptID, submissionID uint) error {
+	return conn(r.tx).Model(&models.ExamAttempt{}).Where("id = ?", attemptID).
+		Update("submission_id", submissionID).Error
+}
+
+// DeleteByExam 删除考试的全部作答会话
+func (r *attemptRepository) DeleteByExam(examID uint) (int64, error) {
+	result := conn(r.tx).Where("exam_id = ?", examID).Delete(&models.ExamAttempt{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteByStudent 删除学生的全部作答会话
+func (r *attemptRepository) DeleteByStudent(studentID uint) (int64, error) {
+	result := conn(r.tx).Where("student_id = ?", studentID).Delete(&models.ExamAttempt{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/migrations"
+	"github.com/exam-approval-system/models"
+	"github.com/jinzhu/gorm"
+)
+
+func TestMain(m *testing.M) {
+	// 迁移日志与测试结果无关
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// openTestDB 为测试创建执行过全部迁移的SQLite数据库并替换configs.DB()
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "exam.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.LogMode(false)
+	if _, err := migrations.NewMigrator(db).Up(); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+	saved := configs.SetDB(db)
+	t.Cleanup(func() {
+		configs.SetDB(saved)
+		db.Close()
+	})
+	return db
+}
+
+func TestAttemptRepositorySaveDraft(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      string
+		version     int
+		wantSaved   bool
+		wantVersion int
+	}{
+		{"版本一致", models.AttemptInProgress, 2, true, 3},
+		{"版本已被其他页面更新", models.AttemptInProgress, 1, false, 2},
+		{"会话已结束", models.AttemptSubmitted, 2, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openTestDB(t)
+			repo := NewAttemptRepository()
+			attempt := &models.ExamAttempt{ExamDataID: 1, ExamID: 1, StudentID: 1, Status: tt.status, StartedAt: time.Now(), DraftVersion: 2}
+			if err := repo.Create(attempt); err != nil {
+				t.Fatal(err)
+			}
+
+			attempt.Draft = `{"1":"A"}`
+			saved, err := repo.SaveDraft(attempt, tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved != tt.wantSaved {
+				t.Errorf("SaveDraft() = %v, 期望 %v", saved, tt.wantSaved)
+			}
+			var stored models.ExamAttempt
+			configs.DB().First(&stored, attempt.ID)
+			if stored.DraftVersion != tt.wantVersion || (stored.Draft != "") != tt.wantSaved {
+				t.Errorf("保存后版本 %d 暂存 %q", stored.DraftVersion, stored.Draft)
+			}
+		})
+	}
+}
+
+func TestAttemptRepositoryClose(t *testing.T) {
+	openTestDB(t)
+	repo := NewAttemptRepository()
+	attempt := &models.ExamAttempt{ExamDataID: 1, ExamID: 1, StudentID: 1, Status: models.AttemptInProgress, StartedAt: time.Now()}
+	if err := repo.Create(attempt); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一答题记录只能有一个作答中的会话
+	if err := repo.Create(&models.ExamAttempt{ExamDataID: 1, ExamID: 1, StudentID: 1, Status: models.AttemptInProgress, StartedAt: time.Now()}); err == nil {
+		t.Fatal("创建第二个作答中的会话应返回错误")
+	}
+
+	// 事务回滚时关闭不生效
+	tx := configs.DB().Begin()
+	closing := *attempt
+	closing.Status = models.AttemptExpired
+	if closed, err := repo.WithTx(tx).Close(&closing); !closed || err != nil {
+		t.Fatalf("事务中关闭 = %v, %v", closed, err)
+	}
+	tx.Rollback()
+	if _, err := repo.GetActive(attempt.ExamDataID); err != nil {
+		t.Fatalf("回滚后会话应仍在作答中: %v", err)
+	}
+
+	// 只有第一次关闭成功
+	for i, want := range []bool{true, false} {
+		submitted := *attempt
+		submitted.Status = models.AttemptSubmitted
+		if closed, err := repo.Close(&submitted); closed != want || err != nil {
+			t.Errorf("第%d次关闭 = %v, %v, 期望 %v", i+1, closed, err, want)
+		}
+	}
+	if count, _ := repo.CountActiveByExam(attempt.ExamID); count != 0 {
+		t.Errorf("关闭后仍有 %d 个作答中的会话", count)
+	}
+	if err := repo.Create(&models.ExamAttempt{ExamDataID: 1, ExamID: 1, StudentID: 1, Status: models.AttemptInProgress, StartedAt: time.Now()}); err != nil {
+		t.Errorf("会话结束后再次作答 error = %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/models"
+)
+
+// BackupRunRepository 备份执行记录仓库接口
+type BackupRunRepository interface {
+	Create(run *models.BackupRun) error
+	GetLatest() (*models.BackupRun, error)
+	GetLatestSuccess() (*models.BackupRun, error)
+	ListRecent(limit int) ([]models.BackupRun, error)
+}
+
+// backupRunRepository 备份执行记录仓库实现
+type backupRunRepository struct{}
+
+// NewBackupRunRepository 创建备份执行记录仓库
+func NewBackupRunRepository() BackupRunRepository {
+	return &backupRunRepository{}
+}
+
+// Create 创建备份执行记录
+func (r *backupRunRepository) Create(run *models.BackupRun) error {
+	return configs.DB().Create(run).Error
+}
+
+// GetLatest 获取最近一次备份执行记录，没有记录时返回gorm.ErrRecordNotFound
+func (r *backupRunRepository) GetLatest() (*models.BackupRun, error) {
+	var run models.BackupRun
+	err := configs.DB().Order("started_at desc").First(&run).Error
+	return &run, err
+}
+
+// GetLatestSuccess 获取最近一次成功的备份执行记录
+func (r *backupRunRepository) GetLatestSuccess() (*models.BackupRun, error) {
+	var run models.BackupRun
+	err := configs.DB().Where("status = ?", models.BackupRunSuccess).Order("started_at desc").First(&run).Error
+	return &run, err
+}
+
+// ListRecent 获取最近的备份执行记录
+func (r *backupRunRepository) ListRecent(limit int) ([]models.BackupRun, error) {
+	var runs []models.BackupRun
+	err := configs.DB().Order("started_at desc").Limit(limit).Find(&runs).Error
+	return runs, err
+}
@@ -0,0 +1,201 @@
+package repositories
+
+import (
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/models"
+	"github.com/jinzhu/gorm"
+)
+
+// BankQuestionFilter 题库搜索条件，零值表示不限
+type BankQuestionFilter struct {
+	ViewerID        uint // 只返回该教师自己的题目和已共享的题目
+	OwnedOnly       bool // 只返回该教师自己的题目
+	Course          string
+	Type            string
+	MinDifficulty   int
+	MaxDifficulty   int
+	Topics          []string // 需同时具有所有主题标签
+	KnowledgePoints []string // 需同时具有所有知识点标签
+	Keyword         string   // 题干包含的关键字
+}
+
+// BankRepository 题库仓库接口
+type BankRepository interface {
+	Create(question *models.BankQuestion, editorID uint) error
+	GetByID(id uint) (*models.BankQuestion, error)
+	Update(question *models.BankQuestion, editorID uint, newVersion bool) error
+	Delete(id uint) error
+	Search(filter BankQuestionFilter, offset, limit int) ([]models.BankQuestion, int, error)
+	Find(filter BankQuestionFilter) ([]models.BankQuestion, error)
+	ListVersions(bankQuestionID uint) ([]models.BankQuestionVersion, error)
+	GetVersion(bankQuestionID uint, version int) (*models.BankQuestionVersion, error)
+	ListTags(viewerID uint, kind string) ([]models.BankTagCount, error)
+}
+
+// bankRepository 题库仓库实现
+type bankRepository struct{}
+
+// NewBankRepository 创建题库仓库
+func NewBankRepository() BankRepository {
+	return &bankRepository{}
+}
+
+// Create 创建题库题目及标签，并记录第一个版本
+func (r *bankRepository) Create(question *models.BankQuestion, editorID uint) error {
+	return configs.DB().Transaction(func(tx *gorm.DB) error {
+		question.Version = 1
+		if err := tx.Create(question).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.BankQuestionVersion{
+			BankQuestionID: question.ID,
+			Version:        question.Version,
+			EditorID:       editorID,
+			Body:           question.Body,
+		}).Error
+	})
+}
+
+// GetByID 根据ID获取题库题目及标签
+func (r *bankRepository) GetByID(id uint) (*models.BankQuestion, error) {
+	var question models.BankQuestion
+	err := configs.DB().Preload("Tags", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).First(&question, id).Error
+	return &question, err
+}
+
+// Update 更新题库题目并整体替换标签，newVersion为true时版本号加1并记录新版本
+func (r *bankRepository) Update(question *models.BankQuestion, editorID uint, newVersion bool) error {
+	return configs.DB().Transaction(func(tx *gorm.DB) error {
+		if newVersion {
+			question.Version++
+		}
+		if err := tx.Set("gorm:save_associations", false).Save(question).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("bank_question_id = ?", question.ID).Delete(&models.BankTag{}).Error; err != nil {
+			return err
+		}
+		for i := range question.Tags {
+			tag := &question.Tags[i]
+			tag.ID = 0
+			tag.BankQuestionID = question.ID
+			if err := tx.Create(tag).Error; err != nil {
+				return err
+			}
+		}
+
+		if !newVersion {
+			return nil
+		}
+		return tx.Create(&models.BankQuestionVersion{
+			BankQuestionID: question.ID,
+			Version:        question.Version,
+			EditorID:       editorID,
+			Body:           question.Body,
+		}).Error
+	})
+}
+
+// Delete 删除题库题目及其标签和历史版本，已引用该题目的试卷保留各自的题目内容
+func (r *bankRepository) Delete(id uint) error {
+	return configs.DB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bank_question_id = ?", id).Delete(&models.BankTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("bank_question_id = ?", id).Delete(&models.BankQuestionVersion{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.BankQuestion{}, id).Error
+	})
+}
+
+// filterQuery 按搜索条件构造题库题目查询
+func filterQuery(filter BankQuestionFilter) *gorm.DB {
+	db := configs.DB().Model(&models.BankQuestion{})
+	if filter.OwnedOnly {
+		db = db.Where("owner_id = ?", filter.ViewerID)
+	} else {
+		db = db.Where("owner_id = ? OR shared = ?", filter.ViewerID, true)
+	}
+	if filter.Course != "" {
+		db = db.Where("course = ?", filter.Course)
+	}
+	if filter.Type != "" {
+		db = db.Where("type = ?", filter.Type)
+	}
+	if filter.MinDifficulty > 0 {
+		db = db.Where("difficulty >= ?", filter.MinDifficulty)
+	}
+	if filter.MaxDifficulty > 0 {
+		db = db.Where("difficulty <= ?", filter.MaxDifficulty)
+	}
+	if filter.Keyword != "" {
+		db = db.Where("content LIKE ?", "%"+filter.Keyword+"%")
+	}
+	for _, tags := range []struct {
+		kind  string
+		names []string
+	}{
+		{models.BankTagTopic, filter.Topics},
+		{models.BankTagKnowledgePoint, filter.KnowledgePoints},
+	} {
+		for _, name := range tags.names {
+			tagged := configs.DB().Model(&models.BankTag{}).Select("bank_question_id").
+				Where("kind = ? AND name = ?", tags.kind, name).SubQuery()
+			db = db.Where("id IN ?", tagged)
+		}
+	}
+	return db
+}
+
+// Search 按条件搜索题库题目，返回当前页的题目和符合条件的总数，按更新时间倒序排列
+func (r *bankRepository) Search(filter BankQuestionFilter, offset, limit int) ([]models.BankQuestion, int, error) {
+	db := filterQuery(filter)
+
+	var total int
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var questions []models.BankQuestion
+	err := db.Preload("Tags", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Order("updated_at desc").Order("id desc").Offset(offset).Limit(limit).Find(&questions).Error
+	return questions, total, err
+}
+
+// Find 获取符合条件的全部题库题目，按ID排列
+func (r *bankRepository) Find(filter BankQuestionFilter) ([]models.BankQuestion, error) {
+	var questions []models.BankQuestion
+	err := filterQuery(filter).Order("id").Find(&questions).Error
+	return questions, err
+}
+
+// ListVersions 获取题库题目的全部版本，按版本号倒序排列
+func (r *bankRepository) ListVersions(bankQuestionID uint) ([]models.BankQuestionVersion, error) {
+	var versions []models.BankQuestionVersion
+	err := configs.DB().Where("bank_question_id = ?", bankQuestionID).Order("version desc").Find(&versions).Error
+	return versions, err
+}
+
+// GetVersion 获取题库题目的指定版本
+func (r *bankRepository) GetVersion(bankQuestionID uint, version int) (*models.BankQuestionVersion, error) {
+	var v models.BankQuestionVersion
+	err := configs.DB().Where("bank_question_id = ? AND version = ?", bankQuestionID, version).First(&v).Error
+	return &v, err
+}
+
+// ListTags 统计教师可见题目的标签，kind为空时返回所有类型
+func (r *bankRepository) ListTags(viewerID uint, kind string) ([]models.BankTagCount, error) {
+	visible := configs.DB().Model(&models.BankQuestion{}).Select("id").
+		Where("owner_id = ? OR shared = ?", viewerID, true).SubQuery()
+	db := configs.DB().Model(&models.BankTag{}).Select("kind, name, COUNT(*) AS count").
+		Where("bank_question_id IN ?", visible)
+	if kind != "" {
+		db = db.Where("kind = ?", kind)
+	}
+
+	var tags []models.BankTagCount
+	err := db.Group("kind, name").Order("kind").Order("count desc").Order("name").Scan(&tags).Error
+	return tags, err
+}
@@ -1,16 +1,36 @@
 package repositories
 
 import (
+	"time"
+
 	"github.com/exam-approval-system/configs"
 	"github.com/exam-approval-system/models"
+	"github.com/jinzhu/gorm"
 )
 
+// CommentFilter 评论查询条件，为零值的条件不参与过滤
+type CommentFilter struct {
+	ExamID     uint
+	Kind       string
+	PaperID    uint
+	QuestionID uint
+	ExamDataID uint
+	Resolved   *bool
+}
+
 // CommentRepository 定义评论仓库接口
 type CommentRepository interface {
 	Create(comment *models.Comment) error
+	GetByID(id uint) (*models.Comment, error)
 	GetCommentsByUserID(userID uint) ([]models.Comment, error)
-	GetCommentsByExamID(examID uint) ([]models.Comment, error)
-	ListByExamID(examID uint) ([]models.Comment, error)
+	ListThreads(filter CommentFilter) ([]models.Comment, error)
+	ListReplies(parentIDs []uint) ([]models.Comment, error)
+	LatestFeedback(examData *models.ExamData) (*models.Comment, error)
+	Edit(comment *models.Comment, content string, editorID uint, at time.Time) error
+	SetResolved(comment *models.Comment) error
+	ListRevisions(commentID uint) ([]models.CommentRevision, error)
+	DeleteByExam(examID uint) (int64, error)
+	DeleteByUser(userID uint) (int64, error)
 }
 
 // commentRepository 实现评论仓库接口
@@ -23,26 +43,134 @@ func NewCommentRepository() CommentRepository {
 
 // Create 创建新的评论
 func (r *commentRepository) Create(comment *models.Comment) error {
-	return configs.DB.Create(comment).Error
+	return configs.DB().Create(comment).Error
+}
+
+// GetByID 根据ID获取评论
+func (r *commentRepository) GetByID(id uint) (*models.Comment, error) {
+	var comment models.Comment
+	err := configs.DB().Preload("User").First(&comment, id).Error
+	return &comment, err
 }
 
 // GetCommentsByUserID 获取指定用户的所有评论
 func (r *commentRepository) GetCommentsByUserID(userID uint) ([]models.Comment, error) {
 	var comments []models.Comment
-	err := configs.DB.Where("user_id = ?", userID).Find(&comments).Error
+	err := configs.DB().Where("user_id = ?", userID).Find(&comments).Error
 	return comments, err
 }
 
-// GetCommentsByExamID 获取指定考试的所有评论
-func (r *commentRepository) GetCommentsByExamID(examID uint) ([]models.Comment, error) {
+// ListThreads 按条件获取顶层评论，最新的在前
+func (r *commentRepository) ListThreads(filter CommentFilter) ([]models.Comment, error) {
+	db := configs.DB().Where("exam_id = ? AND parent_id = 0", filter.ExamID)
+	if filter.Kind != "" {
+		db = db.Where("kind = ?", filter.Kind)
+	}
+	if filter.PaperID != 0 {
+		db = db.Where("paper_id = ?", filter.PaperID)
+	}
+	if filter.QuestionID != 0 {
+		db = db.Where("question_id = ?", filter.QuestionID)
+	}
+	if filter.ExamDataID != 0 {
+		db = db.Where("exam_data_id = ?", filter.ExamDataID)
+	}
+	if filter.Resolved != nil {
+		db = db.Where("resolved = ?", *filter.Resolved)
+	}
+
 	var comments []models.Comment
-	err := configs.DB.Where("exam_id = ?", examID).Find(&comments).Error
+	err := db.Preload("User").Order("created_at desc, id desc").Find(&comments).Error
 	return comments, err
 }
 
-// ListByExamID 获取指定试卷的所有评论
-func (r *commentRepository) ListByExamID(examID uint) ([]models.Comment, error) {
-	var comments []models.Comment
-	result := configs.DB.Where("exam_id = ?", examID).Find(&comments)
-	return comments, result.Error
+// ListReplies 获取多个讨论串的回复，按时间顺序排列
+func (r *commentRepository) ListReplies(parentIDs []uint) ([]models.Comment, error) {
+	var replies []models.Comment
+	if len(parentIDs) == 0 {
+		return replies, nil
+	}
+	err := configs.DB().Where("parent_id IN (?)", parentIDs).Preload("User").Order("created_at, id").Find(&replies).Error
+	return replies, err
+}
+
+// LatestFeedback 获取答卷最新的批阅评语。早期的批阅评语没有定位到答卷，
+// 这类评语按批阅教师在该考试下发表的计算，没有时返回gorm.ErrRecordNotFound
+func (r *commentRepository) LatestFeedback(examData *models.ExamData) (*models.Comment, error) {
+	var comment models.Comment
+	err := configs.DB().
+		Where("kind = ? AND parent_id = 0", models.CommentKindGrading).
+		Where("exam_data_id = ? OR (exam_data_id = 0 AND exam_id = ? AND user_id = ?)",
+			examData.ID, examData.ExamID, examData.ApproverID).
+		Order("created_at desc, id desc").First(&comment).Error
+	return &comment, err
+}
+
+// Edit 修改评论内容，修改前的内容保存为一条编辑历史
+func (r *commentRepository) Edit(comment *models.Comment, content string, editorID uint, at time.Time) error {
+	return configs.DB().Transaction(func(tx *gorm.DB) error {
+		revision := models.CommentRevision{
+			CommentID: comment.ID,
+			Content:   comment.Content,
+			EditedBy:  editorID,
+			CreatedAt: at,
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		comment.Content = content
+		comment.EditedAt = &at
+		return tx.Model(comment).Updates(map[string]interface{}{
+			"content":   content,
+			"edited_at": at,
+		}).Error
+	})
+}
+
+// SetResolved 保存讨论串的解决状态
+func (r *commentRepository) SetResolved(comment *models.Comment) error {
+	return configs.DB().Model(comment).Updates(map[string]interface{}{
+		"resolved":    comment.Resolved,
+		"resolved_by": comment.ResolvedBy,
+		"resolved_at": comment.ResolvedAt,
+	}).Error
+}
+
+// ListRevisions 获取评论的编辑历史，按编辑顺序排列
+func (r *commentRepository) ListRevisions(commentID uint) ([]models.CommentRevision, error) {
+	var revisions []models.CommentRevision
+	err := configs.DB().Where("comment_id = ?", commentID).Preload("Editor").Order("id").Find(&revisions).Error
+	return revisions, err
+}
+
+// DeleteByExam 删除考试的全部评论及编辑历史，返回删除的评论数
+func (r *commentRepository) DeleteByExam(examID uint) (int64, error) {
+	return deleteComments(configs.DB(), "exam_id = ?", examID)
+}
+
+// DeleteByUser 删除用户的评论、这些评论下的回复及编辑历史，返回删除的评论数
+func (r *commentRepository) DeleteByUser(userID uint) (int64, error) {
+	return deleteComments(configs.DB(), "user_id = ?", userID)
+}
+
+// deleteComments 删除符合条件的评论，连同其回复和编辑历史
+func deleteComments(tx *gorm.DB, query string, args ...interface{}) (int64, error) {
+	var ids []uint
+	if err := tx.Model(&models.Comment{}).Where(query, args...).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	var replyIDs []uint
+	if err := tx.Model(&models.Comment{}).Where("parent_id IN (?)", ids).Pluck("id", &replyIDs).Error; err != nil {
+		return 0, err
+	}
+	ids = append(ids, replyIDs...)
+
+	if err := tx.Where("comment_id IN (?)", ids).Delete(&models.CommentRevision{}).Error; err != nil {
+		return 0, err
+	}
+	result := tx.Where("id IN (?)", ids).Delete(&models.Comment{})
+	return result.RowsAffected, result.Error
 }
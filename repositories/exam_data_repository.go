@@ -1,15 +1,16 @@
 package repositories
 
 import (
-	"github.com/exam-approval-system/configs"
 	"github.com/exam-approval-system/models"
+	"github.com/jinzhu/gorm"
 )
 
 // ExamDataRepository 试卷数据仓库接口
 type ExamDataRepository interface {
+	WithTx(tx *gorm.DB) ExamDataRepository
 	Create(examData *models.ExamData) error
 	GetByID(id uint) (*models.ExamData, error)
-	Update(examData *models.ExamData) error
+	UpdateResult(examData *models.ExamData) error
 	Delete(id uint) error
 	List() ([]models.ExamData, error)
 	ListByStudent(studentID uint) ([]models.ExamData, error)
@@ -18,61 +19,73 @@ type ExamDataRepository interface {
 	GetExamsByStudentID(studentID uint) ([]models.ExamData, error)
 }
 
-// examDataRepository 试卷数据仓库实现
-type examDataRepository struct{}
+// examDataRepository 试卷数据仓库实现，tx不为空时所有操作在该事务中执行
+type examDataRepository struct {
+	tx *gorm.DB
+}
 
 // NewExamDataRepository 创建试卷数据仓库
 func NewExamDataRepository() ExamDataRepository {
 	return &examDataRepository{}
 }
 
+// WithTx 返回在事务tx中执行操作的试卷数据仓库
+func (r *examDataRepository) WithTx(tx *gorm.DB) ExamDataRepository {
+	return &examDataRepository{tx: tx}
+}
+
 // Create 创建试卷数据
 func (r *examDataRepository) Create(examData *models.ExamData) error {
-	return configs.DB.Create(examData).Error
+	return conn(r.tx).Create(examData).Error
 }
 
 // GetByID 根据ID获取试卷数据
 func (r *examDataRepository) GetByID(id uint) (*models.ExamData, error) {
 	var examData models.ExamData
-	err := configs.DB.Preload("Exam").Preload("Student").Preload("Approver").First(&examData, id).Error
+	err := conn(r.tx).Preload("Exam").Preload("Student").Preload("Approver").First(&examData, id).Error
 	return &examData, err
 }
 
-// Update 更新试卷数据
-func (r *examDataRepository) Update(examData *models.ExamData) error {
-	return configs.DB.Save(examData).Error
+// UpdateResult 只保存答卷的状态、得分和批阅人。GetByID预加载了考试和学生，
+// 用Save保存会把读取时的考试一并写回，覆盖期间发生的状态变更
+func (r *examDataRepository) UpdateResult(examData *models.ExamData) error {
+	return conn(r.tx).Model(examData).Set("gorm:save_associations", false).Updates(map[string]interface{}{
+		"status":      examData.Status,
+		"total_score": examData.TotalScore,
+		"approver_id": examData.ApproverID,
+	}).Error
 }
 
 // Delete 删除试卷数据
 func (r *examDataRepository) Delete(id uint) error {
-	return configs.DB.Delete(&models.ExamData{}, id).Error
+	return conn(r.tx).Delete(&models.ExamData{}, id).Error
 }
 
 // List 获取所有试卷数据
 func (r *examDataRepository) List() ([]models.ExamData, error) {
 	var examDataList []models.ExamData
-	err := configs.DB.Preload("Student").Preload("Exam").Find(&examDataList).Error
+	err := conn(r.tx).Preload("Student").Preload("Exam").Find(&examDataList).Error
 	return examDataList, err
 }
 
 // ListByStudent 根据学生ID获取试卷数据
 func (r *examDataRepository) ListByStudent(studentID uint) ([]models.ExamData, error) {
 	var examDataList []models.ExamData
-	err := configs.DB.Where("student_id = ?", studentID).Preload("Exam").Find(&examDataList).Error
+	err := conn(r.tx).Where("student_id = ?", studentID).Preload("Exam").Find(&examDataList).Error
 	return examDataList, err
 }
 
 // ListByExam 根据考试ID获取试卷数据
 func (r *examDataRepository) ListByExam(examID uint) ([]models.ExamData, error) {
 	var examDataList []models.ExamData
-	err := configs.DB.Where("exam_id = ?", examID).Preload("Student").Find(&examDataList).Error
+	err := conn(r.tx).Where("exam_id = ?", examID).Preload("Student").Find(&examDataList).Error
 	return examDataList, err
 }
 
 // ListByStatus 根据状态获取试卷数据
 func (r *examDataRepository) ListByStatus(status string) ([]models.ExamData, error) {
 	var examDataList []models.ExamData
-	err := configs.DB.Where("status = ?", status).
+	err := conn(r.tx).Where("status = ?", status).
 		Preload("Student").
 		Preload("Exam").
 		Preload("Exam.Creator").
@@ -83,7 +96,7 @@ func (r *examDataRepository) ListByStatus(status string) ([]models.ExamData, err
 // GetExamsByStudentID 获取分配给学生的所有试卷
 func (r *examDataRepository) GetExamsByStudentID(studentID uint) ([]models.ExamData, error) {
 	var examDataList []models.ExamData
-	err := configs.DB.Where("student_id = ?", studentID).
+	err := conn(r.tx).Where("student_id = ?", studentID).
 		Preload("Exam").
 		Preload("Exam.Creator").
 		Find(&examDataList).Error
@@ -1,12 +1,15 @@
 package repositories
 
 import (
-	"github.com/exam-approval-system/configs"
+	"time"
+
 	"github.com/exam-approval-system/models"
+	"github.com/jinzhu/gorm"
 )
 
 // ExamRepository 考试仓库接口
 type ExamRepository interface {
+	WithTx(tx *gorm.DB) ExamRepository
 	Create(exam *models.Exam) error
 	GetByID(id uint) (*models.Exam, error)
 	Update(exam *models.Exam) error
@@ -17,99 +20,164 @@ type ExamRepository interface {
 	ListPendingApproval() ([]models.Exam, error)
 	ListPublished() ([]models.Exam, error)
 	AddComment(comment *models.Comment) error
-	GetCommentsByExamID(examID uint) ([]models.Comment, error)
 	CreateExamData(examData *models.ExamData) error
 	GetExamDataByExamAndStudent(examID, studentID uint) (*models.ExamData, error)
+	DeleteExamData(examID uint) (int64, error)
+	Transition(exam *models.Exam, transition *models.ExamTransition) (bool, error)
+	AddTransition(transition *models.ExamTransition) error
+	ListTransitions(examID uint) ([]models.ExamTransition, error)
+	ListTransitionsByActions(actions []string, before time.Time) ([]models.ExamTransition, error)
 }
 
-// examRepository 考试仓库实现
-type examRepository struct{}
+// examRepository 考试仓库实现，tx不为空时所有操作在该事务中执行
+type examRepository struct {
+	tx *gorm.DB
+}
 
 // NewExamRepository 创建考试仓库
 func NewExamRepository() ExamRepository {
 	return &examRepository{}
 }
 
+// WithTx 返回在事务tx中执行操作的考试仓库
+func (r *examRepository) WithTx(tx *gorm.DB) ExamRepository {
+	return &examRepository{tx: tx}
+}
+
 // Create 创建考试
 func (r *examRepository) Create(exam *models.Exam) error {
-	return configs.DB.Create(exam).Error
+	return conn(r.tx).Create(exam).Error
 }
 
 // GetByID 根据ID获取考试
 func (r *examRepository) GetByID(id uint) (*models.Exam, error) {
 	var exam models.Exam
-	err := configs.DB.Preload("Creator").Preload("Approver").Preload("Papers").First(&exam, id).Error
+	err := conn(r.tx).Preload("Creator").Preload("Approver").Preload("Papers").First(&exam, id).Error
 	return &exam, err
 }
 
-// Update 更新考试
+// Update 更新考试，状态和审批人只能通过Transition修改
 func (r *examRepository) Update(exam *models.Exam) error {
-	return configs.DB.Save(exam).Error
+	return conn(r.tx).Omit("status", "approver_id").Save(exam).Error
 }
 
-// Delete 删除考试
+// Delete 删除考试及其状态变更记录、审批任务和超时审批记录
 func (r *examRepository) Delete(id uint) error {
-	return configs.DB.Delete(&models.Exam{}, id).Error
+	return conn(r.tx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("exam_id = ?", id).Delete(&models.ExamTransition{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("exam_id = ?", id).Delete(&models.ExamApprovalTask{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("exam_id = ?", id).Delete(&models.OverdueApproval{}).Error; err != nil {
+			return err
+		}
+		if _, err := deleteComments(tx, "exam_id = ?", id); err != nil {
+			return err
+		}
+		return tx.Delete(&models.Exam{}, id).Error
+	})
 }
 
 // List 获取所有考试
 func (r *examRepository) List() ([]models.Exam, error) {
 	var exams []models.Exam
-	err := configs.DB.Preload("Creator").Find(&exams).Error
+	err := conn(r.tx).Preload("Creator").Find(&exams).Error
 	return exams, err
 }
 
 // ListByCreator 根据创建者获取考试
 func (r *examRepository) ListByCreator(creatorID uint) ([]models.Exam, error) {
 	var exams []models.Exam
-	err := configs.DB.Where("creator_id = ?", creatorID).Preload("Creator").Find(&exams).Error
+	err := conn(r.tx).Where("creator_id = ?", creatorID).Preload("Creator").Find(&exams).Error
 	return exams, err
 }
 
 // ListByStatus 根据状态获取考试
 func (r *examRepository) ListByStatus(status string) ([]models.Exam, error) {
 	var exams []models.Exam
-	err := configs.DB.Where("status = ?", status).Preload("Creator").Find(&exams).Error
+	err := conn(r.tx).Where("status = ?", status).Preload("Creator").Find(&exams).Error
 	return exams, err
 }
 
 // ListPendingApproval 获取待审批的考试
 func (r *examRepository) ListPendingApproval() ([]models.Exam, error) {
 	var exams []models.Exam
-	err := configs.DB.Where("status = ?", models.StatusPending).Preload("Creator").Find(&exams).Error
+	err := conn(r.tx).Where("status = ?", models.StatusPending).Preload("Creator").Find(&exams).Error
 	return exams, err
 }
 
 // ListPublished 获取已发布的考试
 func (r *examRepository) ListPublished() ([]models.Exam, error) {
 	var exams []models.Exam
-	err := configs.DB.Where("status = ?", models.StatusPublished).Preload("Creator").Find(&exams).Error
+	err := conn(r.tx).Where("status = ?", models.StatusPublished).Preload("Creator").Find(&exams).Error
 	return exams, err
 }
 
 // AddComment 添加评论
 func (r *examRepository) AddComment(comment *models.Comment) error {
-	return configs.DB.Create(comment).Error
-}
-
-// GetCommentsByExamID 根据考试ID获取评论
-func (r *examRepository) GetCommentsByExamID(examID uint) ([]models.Comment, error) {
-	var comments []models.Comment
-	err := configs.DB.Where("exam_id = ?", examID).Preload("User").Order("created_at desc").Find(&comments).Error
-	return comments, err
+	return conn(r.tx).Create(comment).Error
 }
 
 // CreateExamData 创建试卷数据
 func (r *examRepository) CreateExamData(examData *models.ExamData) error {
-	return configs.DB.Create(examData).Error
+	return conn(r.tx).Create(examData).Error
 }
 
 // GetExamDataByExamAndStudent 根据考试ID和学生ID获取试卷数据
 func (r *examRepository) GetExamDataByExamAndStudent(examID, studentID uint) (*models.ExamData, error) {
 	var examData models.ExamData
-	err := configs.DB.Where("exam_id = ? AND student_id = ?", examID, studentID).First(&examData).Error
+	err := conn(r.tx).Where("exam_id = ? AND student_id = ?", examID, studentID).First(&examData).Error
 	if err != nil {
 		return nil, err
 	}
 	return &examData, nil
 }
+
+// DeleteExamData 删除考试分配给学生的全部试卷数据，返回删除的数量
+func (r *examRepository) DeleteExamData(examID uint) (int64, error) {
+	result := conn(r.tx).Where("exam_id = ?", examID).Delete(&models.ExamData{})
+	return result.RowsAffected, result.Error
+}
+
+// Transition 在考试状态仍为transition.FromStatus时保存exam中的新状态和审批人，并写入状态变更记录；
+// 状态已被其他操作修改时返回false
+func (r *examRepository) Transition(exam *models.Exam, transition *models.ExamTransition) (bool, error) {
+	changed := false
+	err := conn(r.tx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Exam{}).
+			Where("id = ? AND status = ?", exam.ID, transition.FromStatus).
+			Updates(map[string]interface{}{
+				"status":      exam.Status,
+				"approver_id": exam.ApproverID,
+				"updated_at":  time.Now(),
+			})
+		if result.Error != nil || result.RowsAffected != 1 {
+			return result.Error
+		}
+		changed = true
+		return tx.Create(transition).Error
+	})
+	return changed && err == nil, err
+}
+
+// AddTransition 写入状态变更记录
+func (r *examRepository) AddTransition(transition *models.ExamTransition) error {
+	return conn(r.tx).Create(transition).Error
+}
+
+// ListTransitions 获取考试的状态变更记录，按变更顺序排列
+func (r *examRepository) ListTransitions(examID uint) ([]models.ExamTransition, error) {
+	var transitions []models.ExamTransition
+	err := conn(r.tx).Where("exam_id = ?", examID).Order("id").Find(&transitions).Error
+	return transitions, err
+}
+
+// ListTransitionsByActions 获取before之前指定操作的状态变更记录，按考试、变更顺序排列
+func (r *examRepository) ListTransitionsByActions(actions []string, before time.Time) ([]models.ExamTransition, error) {
+	var transitions []models.ExamTransition
+	err := conn(r.tx).Where("action IN (?) AND created_at < ?", actions, before).
+		Order("exam_id").Order("id").Find(&transitions).Error
+	return transitions, err
+}
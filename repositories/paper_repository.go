@@ -1,52 +1,342 @@
 package repositories
 
 import (
-	"github.com/exam-approval-system/configs"
+	"time"
+
 	"github.com/exam-approval-system/models"
+	"github.com/jinzhu/gorm"
 )
 
 // PaperRepository 试卷仓库接口
 type PaperRepository interface {
-	Create(paper *models.Paper) error
+	WithTx(tx *gorm.DB) PaperRepository
+	Create(paper *models.Paper, editorID uint) error
 	GetByID(id uint) (*models.Paper, error)
 	GetByExamID(examID uint) ([]models.Paper, error)
-	Update(paper *models.Paper) error
+	Sign(paper *models.Paper) error
+	Revise(paper *models.Paper, editorID uint, restoredFrom int) (*models.PaperRevision, error)
 	Delete(id uint) error
+	DeleteByExam(examID uint) (int64, error)
+	ListRevisions(paperID uint) ([]models.PaperRevision, error)
+	GetRevision(paperID uint, revision int) (*models.PaperRevision, error)
+	Lock(paper *models.Paper, hash string, at time.Time) (bool, error)
+	AddAuditEvent(event *models.PaperAuditEvent) error
+	ListAuditEvents(examID, paperID uint, event string) ([]models.PaperAuditEvent, error)
 }
 
-// paperRepository 试卷仓库实现
-type paperRepository struct{}
+// paperRepository 试卷仓库实现，tx不为空时所有操作在该事务中执行
+type paperRepository struct {
+	tx *gorm.DB
+}
 
 // NewPaperRepository 创建试卷仓库
 func NewPaperRepository() PaperRepository {
 	return &paperRepository{}
 }
 
-// Create 创建试卷
-func (r *paperRepository) Create(paper *models.Paper) error {
-	return configs.DB.Create(paper).Error
+// WithTx 返回在事务tx中执行操作的试卷仓库
+func (r *paperRepository) WithTx(tx *gorm.DB) PaperRepository {
+	return &paperRepository{tx: tx}
+}
+
+// preloadQuestions 按顺序预加载题目及选项
+func preloadQuestions(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Questions", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Questions.Options", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Questions.Rubric", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Questions.Rubric.Levels", func(db *gorm.DB) *gorm.DB { return db.Order("position") })
+}
+
+// Create 创建试卷，题目和选项随试卷一并创建，并记录第一个版本
+func (r *paperRepository) Create(paper *models.Paper, editorID uint) error {
+	return conn(r.tx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(paper).Error; err != nil {
+			return err
+		}
+		_, err := addRevision(tx, paper, editorID, 0)
+		return err
+	})
 }
 
 // GetByID 根据ID获取试卷
 func (r *paperRepository) GetByID(id uint) (*models.Paper, error) {
 	var paper models.Paper
-	err := configs.DB.First(&paper, id).Error
+	err := preloadQuestions(conn(r.tx)).First(&paper, id).Error
 	return &paper, err
 }
 
 // GetByExamID 根据考试ID获取试卷
 func (r *paperRepository) GetByExamID(examID uint) ([]models.Paper, error) {
 	var papers []models.Paper
-	err := configs.DB.Where("exam_id = ?", examID).Find(&papers).Error
+	err := preloadQuestions(conn(r.tx)).Where("exam_id = ?", examID).Find(&papers).Error
 	return papers, err
 }
 
-// Update 更新试卷
-func (r *paperRepository) Update(paper *models.Paper) error {
-	return configs.DB.Save(paper).Error
+// Sign 保存试卷的签名信息，不修改试卷内容
+func (r *paperRepository) Sign(paper *models.Paper) error {
+	return conn(r.tx).Model(paper).Updates(map[string]interface{}{
+		"signature": paper.Signature,
+		"signed_at": paper.SignedAt,
+		"signed_by": paper.SignedBy,
+	}).Error
+}
+
+// Revise 更新试卷内容并记录新版本，内容与最新版本相同时不记录，返回最新版本。
+// restoredFrom 不为0时表示这次更新是恢复到该版本，已锁定的试卷返回models.ErrPaperLocked
+func (r *paperRepository) Revise(paper *models.Paper, editorID uint, restoredFrom int) (*models.PaperRevision, error) {
+	var revision *models.PaperRevision
+	err := conn(r.tx).Transaction(func(tx *gorm.DB) error {
+		var stored models.Paper
+		if err := tx.Select("locked_at").First(&stored, paper.ID).Error; err != nil {
+			return err
+		}
+		if stored.Locked() {
+			return models.ErrPaperLocked
+		}
+		if err := savePaper(tx, paper); err != nil {
+			return err
+		}
+		var err error
+		revision, err = addRevision(tx, paper, editorID, restoredFrom)
+		return err
+	})
+	return revision, err
+}
+
+// savePaper 保存试卷及其题目：已有的题目原地更新，新题目创建，不在列表中的旧题目删除，选项和评分标准整体替换
+func savePaper(tx *gorm.DB, paper *models.Paper) error {
+	noAssoc := tx.Set("gorm:save_associations", false)
+	if err := noAssoc.Save(paper).Error; err != nil {
+		return err
+	}
+
+	var existing []uint
+	if err := tx.Model(&models.Question{}).Where("paper_id = ?", paper.ID).Pluck("id", &existing).Error; err != nil {
+		return err
+	}
+	saved := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		saved[id] = true
+	}
+
+	keep := []uint{0}
+	for i := range paper.Questions {
+		question := &paper.Questions[i]
+		question.PaperID = paper.ID
+		// 恢复历史版本时，之后被删除的题目按原ID重新创建
+		save := noAssoc.Save
+		if question.ID != 0 && !saved[question.ID] {
+			save = noAssoc.Create
+		}
+		if err := save(question).Error; err != nil {
+			return err
+		}
+		keep = append(keep, question.ID)
+
+		if err := tx.Where("question_id = ?", question.ID).Delete(&models.QuestionOption{}).Error; err != nil {
+			return err
+		}
+		for j := range question.Options {
+			option := &question.Options[j]
+			option.ID = 0
+			option.QuestionID = question.ID
+			if err := tx.Create(option).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := deleteRubrics(tx, []uint{question.ID}); err != nil {
+			return err
+		}
+		for j := range question.Rubric {
+			criterion := &question.Rubric[j]
+			criterion.ID = 0
+			criterion.QuestionID = question.ID
+			for k := range criterion.Levels {
+				criterion.Levels[k].ID = 0
+			}
+			if err := tx.Create(criterion).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	var removed []uint
+	if err := tx.Model(&models.Question{}).Where("paper_id = ? AND id NOT IN (?)", paper.ID, keep).Pluck("id", &removed).Error; err != nil {
+		return err
+	}
+	if err := deleteRubrics(tx, removed); err != nil {
+		return err
+	}
+	if err := tx.Where("question_id IN (?)", append(removed, 0)).Delete(&models.QuestionOption{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("paper_id = ? AND id NOT IN (?)", paper.ID, keep).Delete(&models.Question{}).Error
+}
+
+// addRevision 记录试卷当前内容为新版本，内容与最新版本相同时返回最新版本
+func addRevision(tx *gorm.DB, paper *models.Paper, editorID uint, restoredFrom int) (*models.PaperRevision, error) {
+	revision, err := models.NewPaperRevision(paper)
+	if err != nil {
+		return nil, err
+	}
+	revision.EditorID = editorID
+	revision.RestoredFrom = restoredFrom
+	revision.Revision = 1
+
+	var latest models.PaperRevision
+	err = tx.Where("paper_id = ?", paper.ID).Order("revision desc").First(&latest).Error
+	if err == nil {
+		if latest.SameContent(revision) {
+			return &latest, nil
+		}
+		revision.Revision = latest.Revision + 1
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+	if err := tx.Create(revision).Error; err != nil {
+		return nil, err
+	}
+	return revision, nil
 }
 
-// Delete 删除试卷
+// deleteRubrics 删除题目的评分标准及等级
+func deleteRubrics(tx *gorm.DB, questionIDs []uint) error {
+	if len(questionIDs) == 0 {
+		return nil
+	}
+	criteria := tx.Model(&models.RubricCriterion{}).Select("id").Where("question_id IN (?)", questionIDs).SubQuery()
+	if err := tx.Where("criterion_id IN ?", criteria).Delete(&models.RubricLevel{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("question_id IN (?)", questionIDs).Delete(&models.RubricCriterion{}).Error
+}
+
+// Delete 删除试卷及其题目、选项、评分标准和历史版本，试卷已锁定时返回models.ErrPaperLocked
 func (r *paperRepository) Delete(id uint) error {
-	return configs.DB.Delete(&models.Paper{}, id).Error
+	return conn(r.tx).Transaction(func(tx *gorm.DB) error {
+		return deletePaper(tx, id)
+	})
+}
+
+// DeleteByExam 删除考试的全部试卷，任一试卷已锁定时返回models.ErrPaperLocked，不删除任何试卷
+func (r *paperRepository) DeleteByExam(examID uint) (int64, error) {
+	var deleted int64
+	err := conn(r.tx).Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		if err := tx.Model(&models.Paper{}).Where("exam_id = ?", examID).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := deletePaper(tx, id); err != nil {
+				return err
+			}
+		}
+		deleted = int64(len(ids))
+		return nil
+	})
+	return deleted, err
+}
+
+// deletePaper 在事务中删除未锁定的试卷及其题目、选项、评分标准和历史版本
+func deletePaper(tx *gorm.DB, id uint) error {
+	var locked int
+	if err := tx.Model(&models.Paper{}).Where("id = ? AND locked_at IS NOT NULL", id).Count(&locked).Error; err != nil {
+		return err
+	}
+	if locked > 0 {
+		return models.ErrPaperLocked
+	}
+
+	var questions []uint
+	if err := tx.Model(&models.Question{}).Where("paper_id = ?", id).Pluck("id", &questions).Error; err != nil {
+		return err
+	}
+	if err := deleteRubrics(tx, questions); err != nil {
+		return err
+	}
+	if err := tx.Where("question_id IN (?)", append(questions, 0)).Delete(&models.QuestionOption{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("paper_id = ?", id).Delete(&models.Question{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("paper_id = ?", id).Delete(&models.PaperRevision{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&models.Paper{}, id).Error
+}
+
+// ListRevisions 获取试卷的全部版本，按版本号倒序排列
+func (r *paperRepository) ListRevisions(paperID uint) ([]models.PaperRevision, error) {
+	var revisions []models.PaperRevision
+	err := conn(r.tx).Where("paper_id = ?", paperID).Order("revision desc").Find(&revisions).Error
+	return revisions, err
+}
+
+// GetRevision 获取试卷的指定版本
+func (r *paperRepository) GetRevision(paperID uint, revision int) (*models.PaperRevision, error) {
+	var v models.PaperRevision
+	err := conn(r.tx).Where("paper_id = ? AND revision = ?", paperID, revision).First(&v).Error
+	return &v, err
+}
+
+// Lock 锁定试卷：当前内容与最新版本不同时先记录新版本，再保存锁定的哈希、时间和版本号，
+// 并将试卷状态改为已审批。试卷已锁定时不修改，返回false
+func (r *paperRepository) Lock(paper *models.Paper, hash string, at time.Time) (bool, error) {
+	locked := false
+	err := conn(r.tx).Transaction(func(tx *gorm.DB) error {
+		var stored models.Paper
+		if err := tx.Select("locked_at").First(&stored, paper.ID).Error; err != nil {
+			return err
+		}
+		if stored.Locked() {
+			return nil
+		}
+		revision, err := addRevision(tx, paper, 0, 0)
+		if err != nil {
+			return err
+		}
+		result := tx.Model(&models.Paper{}).Where("id = ? AND locked_at IS NULL", paper.ID).Updates(map[string]interface{}{
+			"status":          models.StatusApproved,
+			"locked_hash":     hash,
+			"locked_at":       at,
+			"locked_revision": revision.Revision,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			locked = true
+			paper.Status = models.StatusApproved
+			paper.LockedHash = hash
+			paper.LockedAt = &at
+			paper.LockedRevision = revision.Revision
+		}
+		return nil
+	})
+	return locked, err
+}
+
+// AddAuditEvent 写入试卷审计事件
+func (r *paperRepository) AddAuditEvent(event *models.PaperAuditEvent) error {
+	return conn(r.tx).Create(event).Error
+}
+
+// ListAuditEvents 按条件获取试卷审计事件，为零值的条件不参与过滤，最新的在前
+func (r *paperRepository) ListAuditEvents(examID, paperID uint, event string) ([]models.PaperAuditEvent, error) {
+	db := conn(r.tx)
+	if examID != 0 {
+		db = db.Where("exam_id = ?", examID)
+	}
+	if paperID != 0 {
+		db = db.Where("paper_id = ?", paperID)
+	}
+	if event != "" {
+		db = db.Where("event = ?", event)
+	}
+	var events []models.PaperAuditEvent
+	err := db.Order("id desc").Find(&events).Error
+	return events, err
 }
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/jinzhu/gorm"
+)
+
+// SessionRepository 会话仓库接口
+type SessionRepository interface {
+	WithTx(tx *gorm.DB) SessionRepository
+	Create(session *models.Session) error
+	GetByID(id uint) (*models.Session, error)
+	GetBySessionID(sessionID string) (*models.Session, error)
+	ListActiveByUser(userID uint, now time.Time) ([]models.Session, error)
+	Touch(id uint, lastSeen time.Time) error
+	Revoke(id uint, revokedAt time.Time) error
+	RevokeAllByUser(userID uint, revokedAt time.Time) (int64, error)
+	DeleteExpired(before time.Time) error
+	DeleteByUser(userID uint) error
+	DeleteAll() error
+}
+
+// sessionRepository 会话仓库实现，tx不为空时所有操作在该事务中执行
+type sessionRepository struct {
+	tx *gorm.DB
+}
+
+// NewSessionRepository 创建会话仓库
+func NewSessionRepository() SessionRepository {
+	return &sessionRepository{}
+}
+
+// WithTx 返回在事务tx中执行操作的会话仓库
+func (r *sessionRepository) WithTx(tx *gorm.DB) SessionRepository {
+	return &sessionRepository{tx: tx}
+}
+
+// Create 创建会话
+func (r *sessionRepository) Create(session *models.Session) error {
+	return conn(r.tx).Create(session).Error
+}
+
+// GetByID 根据ID获取会话
+func (r *sessionRepository) GetByID(id uint) (*models.Session, error) {
+	var session models.Session
+	err := conn(r.tx).First(&session, id).Error
+	return &session, err
+}
+
+// GetBySessionID 根据令牌中的会话ID获取会话
+func (r *sessionRepository) GetBySessionID(sessionID string) (*models.Session, error) {
+	var session models.Session
+	err := conn(r.tx).Where("session_id = ?", sessionID).First(&session).Error
+	return &session, err
+}
+
+// ListActiveByUser 获取用户未注销且未过期的会话，按最近活动时间倒序
+func (r *sessionRepository) ListActiveByUser(userID uint, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := conn(r.tx).Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at desc").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Touch 更新会话最近活动时间
+func (r *sessionRepository) Touch(id uint, lastSeen time.Time) error {
+	return conn(r.tx).Model(&models.Session{}).Where("id = ?", id).Update("last_seen_at", lastSeen).Error
+}
+
+// Revoke 注销单个会话
+func (r *sessionRepository) Revoke(id uint, revokedAt time.Time) error {
+	return conn(r.tx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", revokedAt).Error
+}
+
+// RevokeAllByUser 注销用户的全部会话，返回被注销的会话数
+func (r *sessionRepository) RevokeAllByUser(userID uint, revokedAt time.Time) (int64, error) {
+	result := conn(r.tx).Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", revokedAt)
+	return result.RowsAffected, result.Error
+}
+
+// DeleteExpired 清理已过期的会话记录
+func (r *sessionRepository) DeleteExpired(before time.Time) error {
+	return conn(r.tx).Where("expires_at < ?", before).Delete(&models.Session{}).Error
+}
+
+// DeleteByUser 删除用户的全部会话记录
+func (r *sessionRepository) DeleteByUser(userID uint) error {
+	return conn(r.tx).Where("user_id = ?", userID).Delete(&models.Session{}).Error
+}
+
+// DeleteAll 删除全部会话记录
+func (r *sessionRepository) DeleteAll() error {
+	return conn(r.tx).Delete(&models.Session{}).Error
+}
@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/models"
+)
+
+// SettingsRepository 系统设置仓库接口
+type SettingsRepository interface {
+	Get() (*models.SystemSettings, error)
+	Save(settings *models.SystemSettings) error
+}
+
+// settingsRepository 系统设置仓库实现
+type settingsRepository struct{}
+
+// NewSettingsRepository 创建系统设置仓库
+func NewSettingsRepository() SettingsRepository {
+	return &settingsRepository{}
+}
+
+// Get 获取系统设置，表中不存在记录时返回gorm.ErrRecordNotFound
+func (r *settingsRepository) Get() (*models.SystemSettings, error) {
+	var settings models.SystemSettings
+	err := configs.DB().Order("id").First(&settings).Error
+	return &settings, err
+}
+
+// Save 保存系统设置
+func (r *settingsRepository) Save(settings *models.SystemSettings) error {
+	return configs.DB().Save(settings).Error
+}
@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"github.com/exam-approval-system/models"
+	"github.com/jinzhu/gorm"
+)
+
+// SubmissionRepository 答卷提交仓库接口
+type SubmissionRepository interface {
+	WithTx(tx *gorm.DB) SubmissionRepository
+	Create(submission *models.Submission) error
+	GetByID(id uint) (*models.Submission, error)
+	GetLatestByExamData(examDataID uint) (*models.Submission, error)
+	ListByExamData(examDataID uint) ([]models.Submission, error)
+	CountByExamData(examDataID uint) (int, error)
+	CountByExamStatus(examID uint, status string) (int, error)
+	SaveGrades(submission *models.Submission) error
+	DeleteByExam(examID uint) (int64, error)
+	DeleteByStudent(studentID uint) (int64, error)
+}
+
+// submissionRepository 答卷提交仓库实现，tx不为空时所有操作在该事务中执行
+type submissionRepository struct {
+	tx *gorm.DB
+}
+
+// NewSubmissionRepository 创建答卷提交仓库
+func NewSubmissionRepository() SubmissionRepository {
+	return &submissionRepository{}
+}
+
+// WithTx 返回在事务tx中执行操作的提交仓库
+func (r *submissionRepository) WithTx(tx *gorm.DB) SubmissionRepository {
+	return &submissionRepository{tx: tx}
+}
+
+// preloadAnswers 按提交顺序预加载作答及对应题目
+func preloadAnswers(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Answers", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Answers.CriterionScores").
+		Preload("Answers.Question").
+		Preload("Answers.Question.Options", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Answers.Question.Rubric", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Answers.Question.Rubric.Levels", func(db *gorm.DB) *gorm.DB { return db.Order("position") })
+}
+
+// Create 创建提交记录，作答随提交一并创建
+func (r *submissionRepository) Create(submission *models.Submission) error {
+	return conn(r.tx).Create(submission).Error
+}
+
+// GetByID 根据ID获取提交记录
+func (r *submissionRepository) GetByID(id uint) (*models.Submission, error) {
+	var submission models.Submission
+	err := preloadAnswers(conn(r.tx)).First(&submission, id).Error
+	return &submission, err
+}
+
+// GetLatestByExamData 获取答题记录的最近一次提交，没有提交时返回gorm.ErrRecordNotFound
+func (r *submissionRepository) GetLatestByExamData(examDataID uint) (*models.Submission, error) {
+	var submission models.Submission
+	err := preloadAnswers(conn(r.tx)).Where("exam_data_id = ?", examDataID).Order("attempt desc").First(&submission).Error
+	return &submission, err
+}
+
+// ListByExamData 获取答题记录的全部提交，按提交次数排序
+func (r *submissionRepository) ListByExamData(examDataID uint) ([]models.Submission, error) {
+	var submissions []models.Submission
+	err := preloadAnswers(conn(r.tx)).Where("exam_data_id = ?", examDataID).Order("attempt").Find(&submissions).Error
+	return submissions, err
+}
+
+// CountByExamData 统计答题记录的提交次数
+func (r *submissionRepository) CountByExamData(examDataID uint) (int, error) {
+	var count int
+	err := conn(r.tx).Model(&models.Submission{}).Where("exam_data_id = ?", examDataID).Count(&count).Error
+	return count, err
+}
+
+// CountByExamStatus 统计考试中处于指定评分状态的提交数量
+func (r *submissionRepository) CountByExamStatus(examID uint, status string) (int, error) {
+	var count int
+	err := conn(r.tx).Model(&models.Submission{}).Where("exam_id = ? AND status = ?", examID, status).Count(&count).Error
+	return count, err
+}
+
+// SaveGrades 保存各题得分、反馈和评分维度得分，以及提交的总分和评分状态
+func (r *submissionRepository) SaveGrades(submission *models.Submission) error {
+	return conn(r.tx).Transaction(func(tx *gorm.DB) error {
+		noAssoc := tx.Set("gorm:save_associations", false)
+		for i := range submission.Answers {
+			answer := &submission.Answers[i]
+			err := noAssoc.Model(answer).Updates(map[string]interface{}{
+				"score":       answer.Score,
+				"auto_graded": answer.AutoGraded,
+				"graded_by":   answer.GradedBy,
+				"graded_at":   answer.GradedAt,
+				"feedback":    answer.Feedback,
+			}).Error
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Where("answer_id = ?", answer.ID).Delete(&models.CriterionScore{}).Error; err != nil {
+				return err
+			}
+			for j := range answer.CriterionScores {
+				score := &answer.CriterionScores[j]
+				score.ID = 0
+				score.AnswerID = answer.ID
+				if err := tx.Create(score).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return noAssoc.Model(submission).Updates(map[string]interface{}{
+			"status": submission.Status,
+			"score":  submission.Score,
+		}).Error
+	})
+}
+
+// DeleteByExam 删除考试的全部提交及作答，返回删除的提交数量
+func (r *submissionRepository) DeleteByExam(examID uint) (int64, error) {
+	return r.deleteWhere("exam_id = ?", examID)
+}
+
+// DeleteByStudent 删除学生的全部提交及作答，返回删除的提交数量
+func (r *submissionRepository) DeleteByStudent(studentID uint) (int64, error) {
+	return r.deleteWhere("student_id = ?", studentID)
+}
+
+// deleteWhere 在事务中删除满足条件的提交及其作答和评分维度得分
+func (r *submissionRepository) deleteWhere(query string, args ...interface{}) (int64, error) {
+	var deleted int64
+	err := conn(r.tx).Transaction(func(tx *gorm.DB) error {
+		submissions := tx.Model(&models.Submission{}).Select("id").Where(query, args...).SubQuery()
+		answers := tx.Model(&models.Answer{}).Select("id").Where("submission_id IN ?", submissions).SubQuery()
+		if err := tx.Where("answer_id IN ?", answers).Delete(&models.CriterionScore{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("submission_id IN ?", submissions).Delete(&models.Answer{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where(query, args...).Delete(&models.Submission{})
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	return deleted, err
+}
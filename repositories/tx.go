@@ -0,0 +1,15 @@
+package repositories
+
+import (
+	"github.com/exam-approval-system/configs"
+	"github.com/jinzhu/gorm"
+)
+
+// conn 返回仓库使用的数据库连接：通过WithTx创建的仓库为该事务，否则为configs.DB()返回的当前连接。
+// 恢复备份时连接会被替换，因此每次操作时读取
+func conn(tx *gorm.DB) *gorm.DB {
+	if tx != nil {
+		return tx
+	}
+	return configs.DB()
+}
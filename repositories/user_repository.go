@@ -1,12 +1,13 @@
 package repositories
 
 import (
-	"github.com/exam-approval-system/configs"
 	"github.com/exam-approval-system/models"
+	"github.com/jinzhu/gorm"
 )
 
 // UserRepository 用户仓库接口
 type UserRepository interface {
+	WithTx(tx *gorm.DB) UserRepository
 	Create(user *models.User) error
 	GetByID(id uint) (*models.User, error)
 	GetByUsername(username string) (*models.User, error)
@@ -16,53 +17,60 @@ type UserRepository interface {
 	ListByRole(role string) ([]models.User, error)
 }
 
-// userRepository 用户仓库实现
-type userRepository struct{}
+// userRepository 用户仓库实现，tx不为空时所有操作在该事务中执行
+type userRepository struct {
+	tx *gorm.DB
+}
 
 // NewUserRepository 创建用户仓库
 func NewUserRepository() UserRepository {
 	return &userRepository{}
 }
 
+// WithTx 返回在事务tx中执行操作的用户仓库
+func (r *userRepository) WithTx(tx *gorm.DB) UserRepository {
+	return &userRepository{tx: tx}
+}
+
 // Create 创建用户
 func (r *userRepository) Create(user *models.User) error {
-	return configs.DB.Create(user).Error
+	return conn(r.tx).Create(user).Error
 }
 
 // GetByID 根据ID获取用户
 func (r *userRepository) GetByID(id uint) (*models.User, error) {
 	var user models.User
-	err := configs.DB.First(&user, id).Error
+	err := conn(r.tx).First(&user, id).Error
 	return &user, err
 }
 
 // GetByUsername 根据用户名获取用户
 func (r *userRepository) GetByUsername(username string) (*models.User, error) {
 	var user models.User
-	err := configs.DB.Where("username = ?", username).First(&user).Error
+	err := conn(r.tx).Where("username = ?", username).First(&user).Error
 	return &user, err
 }
 
 // Update 更新用户
 func (r *userRepository) Update(user *models.User) error {
-	return configs.DB.Save(user).Error
+	return conn(r.tx).Save(user).Error
 }
 
 // Delete 删除用户
 func (r *userRepository) Delete(id uint) error {
-	return configs.DB.Delete(&models.User{}, id).Error
+	return conn(r.tx).Delete(&models.User{}, id).Error
 }
 
 // List 获取所有用户
 func (r *userRepository) List() ([]models.User, error) {
 	var users []models.User
-	err := configs.DB.Find(&users).Error
+	err := conn(r.tx).Find(&users).Error
 	return users, err
 }
 
 // ListByRole 根据角色获取用户
 func (r *userRepository) ListByRole(role string) ([]models.User, error) {
 	var users []models.User
-	err := configs.DB.Where("role = ?", role).Find(&users).Error
+	err := conn(r.tx).Where("role = ?", role).Find(&users).Error
 	return users, err
 }
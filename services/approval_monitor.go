@@ -0,0 +1,87 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// approvalMonitorInterval 检查超时审批的间隔
+const approvalMonitorInterval = 5 * time.Minute
+
+// ApprovalMonitor 超时审批调度器接口
+type ApprovalMonitor interface {
+	Start()
+	Stop()
+}
+
+// approvalMonitor 超时审批调度器实现，定期标记超过科目审批时限的审批，
+// 并将超过转交时限仍未处理的审批转交给超时转交审批人
+type approvalMonitor struct {
+	approvalService    ApprovalService
+	slaService         ApprovalSLAService
+	maintenanceService MaintenanceService
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewApprovalMonitor 创建超时审批调度器
+func NewApprovalMonitor(approvalService ApprovalService, slaService ApprovalSLAService, maintenanceService MaintenanceService) ApprovalMonitor {
+	return &approvalMonitor{
+		approvalService:    approvalService,
+		slaService:         slaService,
+		maintenanceService: maintenanceService,
+		stop:               make(chan struct{}),
+		done:               make(chan struct{}),
+	}
+}
+
+// Start 在后台启动调度循环
+func (m *approvalMonitor) Start() {
+	go m.loop()
+}
+
+// Stop 停止调度循环，并等待正在进行的检查完成
+func (m *approvalMonitor) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+	<-m.done
+}
+
+// loop 调度循环，启动时立即检查一次，之后每隔 approvalMonitorInterval 检查一次
+func (m *approvalMonitor) loop() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(approvalMonitorInterval)
+	defer ticker.Stop()
+
+	for {
+		m.check()
+
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check 标记并转交超时的审批，维护模式下跳过本次检查
+func (m *approvalMonitor) check() {
+	if err := m.maintenanceService.Enter(); err != nil {
+		return
+	}
+	defer m.maintenanceService.Leave()
+
+	if count, err := m.slaService.FlagOverdue(); err != nil {
+		log.Printf("标记超时审批失败: %v", err)
+	} else if count > 0 {
+		log.Printf("已标记 %d 项超时审批", count)
+	}
+	if count, err := m.approvalService.EscalateOverdue(); err != nil {
+		log.Printf("检查超时审批失败: %v", err)
+	} else if count > 0 {
+		log.Printf("已转交 %d 项超时审批", count)
+	}
+}
@@ -0,0 +1,721 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrApprovalChainNotFound 审批链不存在
+	ErrApprovalChainNotFound = errors.New("审批链不存在")
+	// ErrNoApprovalTask 当前用户在本轮审批中没有待处理的任务
+	ErrNoApprovalTask = errors.New("没有待处理的审批任务")
+	// ErrDelegationNotFound 委托不存在，或当前用户不能管理该委托
+	ErrDelegationNotFound = errors.New("委托不存在")
+)
+
+// ReviewAccess 用户对待审批考试的审批权限
+type ReviewAccess struct {
+	Allowed    bool // 用户能否审批该考试
+	OnBehalfOf uint // 用户只作为代理人审批时为委托人ID
+}
+
+// ApprovalOutcome 审批人处理审批任务后本轮审批的结果
+type ApprovalOutcome struct {
+	Decided  bool   // 本轮审批是否已有结果，为false时还需其他审批人处理
+	Approved bool   // 各级审批是否全部通过
+	Comment  string // 未通过时为未通过那一级的全部审批意见
+}
+
+// ApprovalQueueItem 审批人待处理的一项审批
+type ApprovalQueueItem struct {
+	Exam      *models.Exam `json:"exam"`
+	TaskID    uint         `json:"task_id,omitempty"` // 没有审批链的考试为0，由任一管理员审批
+	Round     int          `json:"round,omitempty"`
+	Stage     int          `json:"stage,omitempty"`
+	StageName string       `json:"stage_name"`
+	Required  int          `json:"required,omitempty"`
+	Since     time.Time    `json:"since"` // 开始等待该审批人处理的时间
+	// OnBehalfOf 作为代理人处理时为委托人ID
+	OnBehalfOf     uint   `json:"on_behalf_of,omitempty"`
+	OnBehalfOfName string `json:"on_behalf_of_name,omitempty"`
+	Escalated      bool   `json:"escalated,omitempty"` // 超时未处理，已转交给当前审批人
+}
+
+// ApprovalService 多级审批服务接口：管理各科目的审批链，考试提交审批时按审批链生成审批任务，
+// 审批人的决定汇总为考试审批的结果。审批人不在时可以委托代理人审批，超时未处理的审批转交给超时转交审批人
+type ApprovalService interface {
+	WithTx(tx *gorm.DB) ApprovalService
+	ListChains() ([]models.ApprovalChain, error)
+	GetChain(id uint) (*models.ApprovalChain, error)
+	SaveChain(chain *models.ApprovalChain) error
+	DeleteChain(id uint) error
+	Prepare(exam *models.Exam) error
+	Start(exam *models.Exam) error
+	Reviewer(exam *models.Exam, user *models.User) (*ReviewAccess, error)
+	Review(exam *models.Exam, actor *models.User, approve bool, comment string) (*ApprovalOutcome, error)
+	Queue(user *models.User) ([]ApprovalQueueItem, error)
+	Tasks(examID uint) ([]models.ExamApprovalTask, error)
+	ListDelegations(user *models.User) ([]models.ApprovalDelegation, error)
+	CreateDelegation(delegation *models.ApprovalDelegation) error
+	DeleteDelegation(id uint, user *models.User) error
+	EscalateOverdue() (int, error)
+}
+
+// approvalService 多级审批服务实现
+type approvalService struct {
+	approvalRepository repositories.ApprovalRepository
+	examRepository     repositories.ExamRepository
+	userRepository     repositories.UserRepository
+	settingsService    SettingsService
+}
+
+// NewApprovalService 创建多级审批服务
+func NewApprovalService(
+	approvalRepo repositories.ApprovalRepository,
+	examRepo repositories.ExamRepository,
+	userRepo repositories.UserRepository,
+	settingsService SettingsService,
+) ApprovalService {
+	return &approvalService{
+		approvalRepository: approvalRepo,
+		examRepository:     examRepo,
+		userRepository:     userRepo,
+		settingsService:    settingsService,
+	}
+}
+
+// WithTx 返回在事务tx中读写审批任务、考试和用户的审批服务
+func (s *approvalService) WithTx(tx *gorm.DB) ApprovalService {
+	return &approvalService{
+		approvalRepository: s.approvalRepository.WithTx(tx),
+		examRepository:     s.examRepository.WithTx(tx),
+		userRepository:     s.userRepository.WithTx(tx),
+		settingsService:    s.settingsService,
+	}
+}
+
+// ListChains 获取全部审批链
+func (s *approvalService) ListChains() ([]models.ApprovalChain, error) {
+	return s.approvalRepository.ListChains()
+}
+
+// GetChain 根据ID获取审批链
+func (s *approvalService) GetChain(id uint) (*models.ApprovalChain, error) {
+	chain, err := s.approvalRepository.GetChain(id)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrApprovalChainNotFound
+	}
+	return chain, err
+}
+
+// SaveChain 校验并创建或更新审批链，每个科目只能有一条审批链，审批人必须是教师或管理员。
+// 修改审批链只影响之后提交审批的考试
+func (s *approvalService) SaveChain(chain *models.ApprovalChain) error {
+	if err := chain.Validate(); err != nil {
+		return err
+	}
+	if chain.ID != 0 {
+		if _, err := s.GetChain(chain.ID); err != nil {
+			return err
+		}
+	}
+	existing, err := s.approvalRepository.GetChainByCourse(chain.Course)
+	if err == nil && existing.ID != chain.ID {
+		return fmt.Errorf("科目%s已有审批链", chain.Course)
+	}
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+
+	for _, stage := range chain.Stages {
+		for _, approver := range stage.Approvers {
+			user, err := s.userRepository.GetByID(approver.UserID)
+			if err != nil || (user.Role != models.RoleTeacher && user.Role != models.RoleAdmin) {
+				return fmt.Errorf("第%d级审批的审批人%d不存在或不是教师、管理员", stage.Position, approver.UserID)
+			}
+		}
+	}
+	return s.approvalRepository.SaveChain(chain)
+}
+
+// DeleteChain 删除审批链，之后提交审批的考试由管理员审批
+func (s *approvalService) DeleteChain(id uint) error {
+	if _, err := s.GetChain(id); err != nil {
+		return err
+	}
+	return s.approvalRepository.DeleteChain(id)
+}
+
+// Prepare 检查考试科目的审批链能否用于该考试，考试创建者不参与审批自己的考试
+func (s *approvalService) Prepare(exam *models.Exam) error {
+	_, err := s.plan(exam)
+	return err
+}
+
+// Start 考试提交审批后按科目的审批链生成新一轮审批任务，科目没有审批链时不生成
+func (s *approvalService) Start(exam *models.Exam) error {
+	tasks, err := s.plan(exam)
+	if err != nil || len(tasks) == 0 {
+		return err
+	}
+	return s.approvalRepository.CreateTasks(tasks)
+}
+
+// plan 按科目的审批链生成下一轮审批任务：逐级审批时只有第一级待处理，同时审批时各级都待处理
+func (s *approvalService) plan(exam *models.Exam) ([]models.ExamApprovalTask, error) {
+	chain, err := s.approvalRepository.GetChainByCourse(exam.Course)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	round, err := s.approvalRepository.LatestRound(exam.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []models.ExamApprovalTask
+	for _, stage := range chain.Stages {
+		status := models.ApprovalTaskPending
+		if chain.Mode == models.ApprovalSequential && stage.Position > 1 {
+			status = models.ApprovalTaskWaiting
+		}
+		count := 0
+		for _, approver := range stage.Approvers {
+			if approver.UserID == exam.CreatorID {
+				continue
+			}
+			count++
+			tasks = append(tasks, models.ExamApprovalTask{
+				ExamID:     exam.ID,
+				ChainID:    chain.ID,
+				Round:      round + 1,
+				Stage:      stage.Position,
+				StageName:  stage.Name,
+				Required:   stage.RequiredApprovals,
+				ApproverID: approver.UserID,
+				Status:     status,
+			})
+		}
+		if count < stage.RequiredApprovals {
+			return nil, fmt.Errorf("审批链第%d级(%s)除考试创建者外只有%d名审批人，少于需要通过的%d人", stage.Position, stage.Name, count, stage.RequiredApprovals)
+		}
+	}
+	return tasks, nil
+}
+
+// Reviewer 判断用户能否审批待审批的考试：按审批链审批时为本轮有待处理任务的审批人及其代理人；
+// 否则为管理员、超时转交审批人，以及正在代理管理员的用户
+func (s *approvalService) Reviewer(exam *models.Exam, user *models.User) (*ReviewAccess, error) {
+	tasks, err := s.currentTasks(exam.ID)
+	if err != nil {
+		return nil, err
+	}
+	delegators, err := s.delegators(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if acting := actingTasks(tasks, exam, user.ID, delegators); len(acting) > 0 {
+		return &ReviewAccess{Allowed: true, OnBehalfOf: onBehalfOf(acting, user.ID)}, nil
+	}
+	if chainOpen(tasks) {
+		return &ReviewAccess{}, nil
+	}
+	if user.Role == models.RoleAdmin {
+		return &ReviewAccess{Allowed: true}, nil
+	}
+	if exam.CreatorID != user.ID {
+		for _, delegator := range delegators {
+			if delegator.Role == models.RoleAdmin {
+				return &ReviewAccess{Allowed: true, OnBehalfOf: delegator.ID}, nil
+			}
+		}
+	}
+	return &ReviewAccess{}, nil
+}
+
+// Review 保存审批人对本轮中自己及委托人全部待处理任务的决定，并汇总各级审批的结果：
+// 一级中通过的人数达到要求时本级通过，剩余任务跳过；拒绝的人数使本级无法再达到要求时本轮审批未通过。
+// 没有按审批链审批时审批人的决定即为结果，剩余的超时转交任务跳过
+func (s *approvalService) Review(exam *models.Exam, actor *models.User, approve bool, comment string) (*ApprovalOutcome, error) {
+	tasks, err := s.currentTasks(exam.ID)
+	if err != nil {
+		return nil, err
+	}
+	delegators, err := s.delegators(actor.ID)
+	if err != nil {
+		return nil, err
+	}
+	chained := chainOpen(tasks)
+	status := models.ApprovalTaskRejected
+	if approve {
+		status = models.ApprovalTaskApproved
+	}
+	decided := 0
+	for _, task := range actingTasks(tasks, exam, actor.ID, delegators) {
+		task.Status = status
+		task.Comment = comment
+		task.DecidedByID = actor.ID
+		ok, err := s.approvalRepository.Decide(task)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			decided++
+		}
+	}
+
+	if !chained {
+		if len(tasks) > 0 {
+			if err := s.approvalRepository.UpdateStatus(exam.ID, tasks[0].Round, 0, models.ApprovalTaskPending, models.ApprovalTaskSkipped); err != nil {
+				return nil, err
+			}
+		}
+		return &ApprovalOutcome{Decided: true, Approved: approve, Comment: comment}, nil
+	}
+	if decided == 0 {
+		return nil, ErrNoApprovalTask
+	}
+	return s.evaluate(exam.ID)
+}
+
+// evaluate 重新读取本轮审批任务并推进审批：跳过已通过级别的剩余任务，逐级审批时开始下一级
+func (s *approvalService) evaluate(examID uint) (*ApprovalOutcome, error) {
+	tasks, err := s.currentTasks(examID)
+	if err != nil || len(tasks) == 0 {
+		return nil, err
+	}
+	round := tasks[0].Round
+
+	type stageState struct {
+		name               string
+		total, required    int
+		approved, rejected int
+		waiting            bool
+		comments           []string
+	}
+	var order []int
+	stages := make(map[int]*stageState)
+	for _, task := range tasks {
+		state, ok := stages[task.Stage]
+		if !ok {
+			state = &stageState{name: task.StageName, required: task.Required}
+			stages[task.Stage] = state
+			order = append(order, task.Stage)
+		}
+		state.total++
+		switch task.Status {
+		case models.ApprovalTaskApproved:
+			state.approved++
+		case models.ApprovalTaskRejected:
+			state.rejected++
+		case models.ApprovalTaskWaiting:
+			state.waiting = true
+		}
+		if task.Comment != "" && task.DecidedAt != nil {
+			name := s.approverName(task.ApproverID)
+			if task.DecidedByID != 0 && task.DecidedByID != task.ApproverID {
+				name = s.approverName(task.DecidedByID) + "(代" + name + ")"
+			}
+			state.comments = append(state.comments, name+"："+task.Comment)
+		}
+	}
+
+	for _, stage := range order {
+		state := stages[stage]
+		if state.total-state.rejected < state.required {
+			// 本级已无法达到要求的通过人数，结束本轮审批
+			for _, from := range []string{models.ApprovalTaskPending, models.ApprovalTaskWaiting} {
+				if err := s.approvalRepository.UpdateStatus(examID, round, 0, from, models.ApprovalTaskSkipped); err != nil {
+					return nil, err
+				}
+			}
+			comment := fmt.Sprintf("第%d级审批(%s)未通过", stage, state.name)
+			if len(state.comments) > 0 {
+				comment += "：" + strings.Join(state.comments, "；")
+			}
+			return &ApprovalOutcome{Decided: true, Comment: comment}, nil
+		}
+	}
+
+	next := 0
+	for _, stage := range order {
+		state := stages[stage]
+		if state.approved < state.required {
+			if next == 0 {
+				next = stage
+			}
+			continue
+		}
+		if err := s.approvalRepository.UpdateStatus(examID, round, stage, models.ApprovalTaskPending, models.ApprovalTaskSkipped); err != nil {
+			return nil, err
+		}
+	}
+	if next == 0 {
+		return &ApprovalOutcome{Decided: true, Approved: true}, nil
+	}
+	if stages[next].waiting {
+		// 逐级审批时前面各级都已通过，开始下一级审批
+		if err := s.approvalRepository.UpdateStatus(examID, round, next, models.ApprovalTaskWaiting, models.ApprovalTaskPending); err != nil {
+			return nil, err
+		}
+	}
+	return &ApprovalOutcome{}, nil
+}
+
+// Queue 获取等待用户处理的审批：分配给用户及其委托人的待处理审批任务，
+// 管理员和正在代理管理员的用户还包括没有按审批链审批的待审批考试
+func (s *approvalService) Queue(user *models.User) ([]ApprovalQueueItem, error) {
+	delegators, err := s.delegators(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	approverIDs := []uint{user.ID}
+	adminAccess := user.Role == models.RoleAdmin
+	for _, delegator := range delegators {
+		approverIDs = append(approverIDs, delegator.ID)
+		adminAccess = adminAccess || delegator.Role == models.RoleAdmin
+	}
+
+	tasks, err := s.approvalRepository.ListPendingByApprovers(approverIDs)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]ApprovalQueueItem, 0, len(tasks))
+	queued := make(map[uint]bool)
+	for _, task := range tasks {
+		if task.Exam == nil || task.Exam.Status != models.StatusPending {
+			continue
+		}
+		item := ApprovalQueueItem{
+			Exam:      task.Exam,
+			TaskID:    task.ID,
+			Round:     task.Round,
+			Stage:     task.Stage,
+			StageName: task.StageName,
+			Required:  task.Required,
+			Since:     task.UpdatedAt,
+			Escalated: task.EscalatedFromID != 0 || task.ChainID == 0,
+		}
+		if task.ApproverID != user.ID {
+			// 只列出作为代理人实际可以处理的委托人任务
+			current, err := s.currentTasks(task.ExamID)
+			if err != nil {
+				return nil, err
+			}
+			if !containsTask(actingTasks(current, task.Exam, user.ID, delegators), task.ID) {
+				continue
+			}
+			item.OnBehalfOf = task.ApproverID
+			item.OnBehalfOfName = s.approverName(task.ApproverID)
+		}
+		items = append(items, item)
+		queued[task.ExamID] = true
+	}
+	if !adminAccess {
+		return items, nil
+	}
+
+	exams, err := s.examRepository.ListPendingApproval()
+	if err != nil {
+		return nil, err
+	}
+	for i := range exams {
+		if queued[exams[i].ID] {
+			continue
+		}
+		access, err := s.Reviewer(&exams[i], user)
+		if err != nil {
+			return nil, err
+		}
+		if !access.Allowed {
+			continue
+		}
+		item := ApprovalQueueItem{Exam: &exams[i], StageName: models.AdminApprovalStageName, Since: exams[i].UpdatedAt, OnBehalfOf: access.OnBehalfOf}
+		if access.OnBehalfOf != 0 {
+			item.OnBehalfOfName = s.approverName(access.OnBehalfOf)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Tasks 获取考试所有轮次的审批任务
+func (s *approvalService) Tasks(examID uint) ([]models.ExamApprovalTask, error) {
+	return s.approvalRepository.ListTasks(examID, 0)
+}
+
+// ListDelegations 获取用户作为委托人或代理人的委托
+func (s *approvalService) ListDelegations(user *models.User) ([]models.ApprovalDelegation, error) {
+	return s.approvalRepository.ListDelegations(user.ID)
+}
+
+// CreateDelegation 创建委托，代理人必须是教师或管理员，同一委托人的委托时间不能重叠。
+// 代理人本身的委托不会继续传递
+func (s *approvalService) CreateDelegation(delegation *models.ApprovalDelegation) error {
+	if err := delegation.Validate(); err != nil {
+		return err
+	}
+	if !delegation.EndTime.After(time.Now()) {
+		return errors.New("委托的结束时间已过")
+	}
+	delegate, err := s.userRepository.GetByID(delegation.DelegateID)
+	if err != nil || (delegate.Role != models.RoleTeacher && delegate.Role != models.RoleAdmin) {
+		return errors.New("代理人不存在或不是教师、管理员")
+	}
+	count, err := s.approvalRepository.CountOverlappingDelegations(delegation)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该时间范围内已有其他委托")
+	}
+	return s.approvalRepository.CreateDelegation(delegation)
+}
+
+// DeleteDelegation 删除委托，只有委托人和管理员可以删除
+func (s *approvalService) DeleteDelegation(id uint, user *models.User) error {
+	delegation, err := s.approvalRepository.GetDelegation(id)
+	if gorm.IsRecordNotFoundError(err) {
+		return ErrDelegationNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if delegation.UserID != user.ID && user.Role != models.RoleAdmin {
+		return ErrDelegationNotFound
+	}
+	return s.approvalRepository.DeleteDelegation(id)
+}
+
+// EscalateOverdue 将待处理超过设置时限的审批转交给超时转交审批人：审批链中的任务改由其处理，
+// 没有审批链的待审批考试为其生成一条审批任务。超时转交审批人不处理自己创建的考试，
+// 也不接手自己已参与的审批级别。返回转交的数量
+func (s *approvalService) EscalateOverdue() (int, error) {
+	settings := s.settingsService.Get()
+	sla := settings.ApprovalSLA()
+	if sla <= 0 || settings.ApprovalFallbackApprover == 0 {
+		return 0, nil
+	}
+	fallback, err := s.userRepository.GetByID(settings.ApprovalFallbackApprover)
+	if err != nil || (fallback.Role != models.RoleTeacher && fallback.Role != models.RoleAdmin) {
+		return 0, fmt.Errorf("超时转交审批人%d不存在或不是教师、管理员", settings.ApprovalFallbackApprover)
+	}
+	before := time.Now().Add(-sla)
+	count := 0
+
+	tasks, err := s.approvalRepository.ListStalePending(before)
+	if err != nil {
+		return count, err
+	}
+	for i := range tasks {
+		task := &tasks[i]
+		if task.ChainID == 0 || task.ApproverID == fallback.ID || task.Exam == nil ||
+			task.Exam.Status != models.StatusPending || task.Exam.CreatorID == fallback.ID {
+			continue
+		}
+		round, err := s.approvalRepository.ListTasks(task.ExamID, task.Round)
+		if err != nil {
+			return count, err
+		}
+		if holdsStage(round, fallback.ID, task.Stage) {
+			continue
+		}
+		from := task.ApproverID
+		escalated, err := s.approvalRepository.Escalate(task, fallback.ID)
+		if err != nil {
+			return count, err
+		}
+		if escalated {
+			log.Printf("考试(%d)第%d级审批超时，已从用户%d转交给用户%d", task.ExamID, task.Stage, from, fallback.ID)
+			count++
+		}
+	}
+
+	exams, err := s.examRepository.ListPendingApproval()
+	if err != nil {
+		return count, err
+	}
+	submitted, err := s.submittedAt()
+	if err != nil {
+		return count, err
+	}
+	for i := range exams {
+		exam := &exams[i]
+		waiting, ok := submitted[exam.ID]
+		if !ok {
+			// 没有提交记录的旧数据按最后修改时间计算
+			waiting = exam.UpdatedAt
+		}
+		if !waiting.Before(before) || exam.CreatorID == fallback.ID {
+			continue
+		}
+		current, err := s.currentTasks(exam.ID)
+		if err != nil {
+			return count, err
+		}
+		if anyOpen(current) {
+			continue
+		}
+		round := 0
+		if len(current) > 0 {
+			round = current[0].Round
+		}
+		task := models.ExamApprovalTask{
+			ExamID:     exam.ID,
+			Round:      round + 1,
+			Stage:      1,
+			StageName:  models.AdminApprovalStageName,
+			Required:   1,
+			ApproverID: fallback.ID,
+			Status:     models.ApprovalTaskPending,
+		}
+		if err := s.approvalRepository.CreateTasks([]models.ExamApprovalTask{task}); err != nil {
+			return count, err
+		}
+		log.Printf("考试(%d)超时未审批，已转交给用户%d", exam.ID, fallback.ID)
+		count++
+	}
+	return count, nil
+}
+
+// submittedAt 获取各考试最近一次提交审批的时间，考试的其他修改不影响等待时长
+func (s *approvalService) submittedAt() (map[uint]time.Time, error) {
+	transitions, err := s.examRepository.ListTransitionsByActions([]string{models.ExamActionSubmit}, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	submitted := make(map[uint]time.Time)
+	for _, transition := range transitions {
+		submitted[transition.ExamID] = transition.CreatedAt
+	}
+	return submitted, nil
+}
+
+// delegators 获取当前委托userID代理审批的用户，已删除的委托人跳过
+func (s *approvalService) delegators(userID uint) ([]models.User, error) {
+	delegations, err := s.approvalRepository.ListActiveDelegations(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	for _, delegation := range delegations {
+		user, err := s.userRepository.GetByID(delegation.UserID)
+		if err != nil {
+			continue
+		}
+		users = append(users, *user)
+	}
+	return users, nil
+}
+
+// actingTasks 用户在本轮可以处理的待处理任务：分配给自己的任务，以及作为代理人可以处理的委托人任务。
+// 代理人不处理自己创建的考试，每一级最多代替一名委托人处理，且不代替处理自己也参与审批的级别，避免一人在同一级计为多人
+func actingTasks(tasks []models.ExamApprovalTask, exam *models.Exam, userID uint, delegators []models.User) []*models.ExamApprovalTask {
+	delegated := make(map[uint]bool, len(delegators))
+	for _, delegator := range delegators {
+		delegated[delegator.ID] = true
+	}
+	taken := make(map[int]bool)
+	for _, task := range tasks {
+		if task.ApproverID == userID {
+			taken[task.Stage] = true
+		}
+	}
+
+	var acting []*models.ExamApprovalTask
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Status != models.ApprovalTaskPending {
+			continue
+		}
+		if task.ApproverID == userID {
+			acting = append(acting, task)
+			continue
+		}
+		if delegated[task.ApproverID] && exam.CreatorID != userID && !taken[task.Stage] {
+			acting = append(acting, task)
+			taken[task.Stage] = true
+		}
+	}
+	return acting
+}
+
+// onBehalfOf 用户处理的任务全部属于同一名委托人时返回该委托人ID，包含自己的任务时返回0
+func onBehalfOf(tasks []*models.ExamApprovalTask, userID uint) uint {
+	delegator := uint(0)
+	for _, task := range tasks {
+		if task.ApproverID == userID || (delegator != 0 && delegator != task.ApproverID) {
+			return 0
+		}
+		delegator = task.ApproverID
+	}
+	return delegator
+}
+
+// chainOpen 本轮是否还有未处理的审批链任务，即考试是否正在按审批链审批
+func chainOpen(tasks []models.ExamApprovalTask) bool {
+	for _, task := range tasks {
+		if task.ChainID != 0 && task.Open() {
+			return true
+		}
+	}
+	return false
+}
+
+// anyOpen 本轮是否还有未处理的任务
+func anyOpen(tasks []models.ExamApprovalTask) bool {
+	for _, task := range tasks {
+		if task.Open() {
+			return true
+		}
+	}
+	return false
+}
+
+// holdsStage 用户在本轮的该级审批中是否已有任务
+func holdsStage(tasks []models.ExamApprovalTask, userID uint, stage int) bool {
+	for _, task := range tasks {
+		if task.ApproverID == userID && task.Stage == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// containsTask 判断任务列表中是否包含该任务
+func containsTask(tasks []*models.ExamApprovalTask, id uint) bool {
+	for _, task := range tasks {
+		if task.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// currentTasks 获取考试最近一轮的审批任务
+func (s *approvalService) currentTasks(examID uint) ([]models.ExamApprovalTask, error) {
+	round, err := s.approvalRepository.LatestRound(examID)
+	if err != nil || round == 0 {
+		return nil, err
+	}
+	return s.approvalRepository.ListTasks(examID, round)
+}
+
+// approverName 审批人的姓名，用户已删除时使用ID
+func (s *approvalService) approverName(userID uint) string {
+	user, err := s.userRepository.GetByID(userID)
+	if err != nil {
+		return fmt.Sprintf("用户%d", userID)
+	}
+	return user.Name
+}
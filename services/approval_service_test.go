@@ -0,0 +1,405 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exam-approval-system/models"
+)
+
+func TestApprovalChainReview(t *testing.T) {
+	type stage struct {
+		approvers []string
+		required  int
+	}
+	type step struct {
+		actor   string
+		action  string
+		comment string
+		status  string // 执行后考试的状态
+		err     string
+	}
+	tests := []struct {
+		name   string
+		mode   string
+		stages []stage
+		steps  []step
+		// 最后一步的状态变更记录中的委托人
+		onBehalfOf string
+		// 退回时状态变更记录中的意见应包含的内容
+		rejectComment string
+	}{
+		{
+			name:   "逐级审批全部通过",
+			mode:   models.ApprovalSequential,
+			stages: []stage{{[]string{"a", "b"}, 1}, {[]string{"c"}, 1}},
+			steps: []step{
+				{actor: "c", action: models.ExamActionApprove, status: models.StatusPending, err: ErrTransitionForbidden.Error()},
+				{actor: "a", action: models.ExamActionApprove, status: models.StatusPending},
+				{actor: "b", action: models.ExamActionApprove, status: models.StatusPending, err: ErrTransitionForbidden.Error()},
+				{actor: "c", action: models.ExamActionApprove, status: models.StatusApproved},
+			},
+		},
+		{
+			name:   "一级中拒绝的人数使本级无法通过",
+			mode:   models.ApprovalSequential,
+			stages: []stage{{[]string{"a", "b"}, 2}, {[]string{"c"}, 1}},
+			steps: []step{
+				{actor: "a", action: models.ExamActionApprove, comment: "可以", status: models.StatusPending},
+				{actor: "b", action: models.ExamActionReject, comment: "题量太大", status: models.StatusRejected},
+				{actor: "c", action: models.ExamActionApprove, status: models.StatusRejected, err: ErrTransitionForbidden.Error()},
+			},
+			rejectComment: "第1级审批(第1级)未通过",
+		},
+		{
+			name:   "同时审批",
+			mode:   models.ApprovalParallel,
+			stages: []stage{{[]string{"a"}, 1}, {[]string{"c"}, 1}},
+			steps: []step{
+				{actor: "c", action: models.ExamActionApprove, status: models.StatusPending},
+				{actor: "c", action: models.ExamActionApprove, status: models.StatusPending, err: ErrTransitionForbidden.Error()},
+				{actor: "a", action: models.ExamActionApprove, status: models.StatusApproved},
+			},
+		},
+		{
+			name:   "审批链进行中时管理员不能审批",
+			mode:   models.ApprovalSequential,
+			stages: []stage{{[]string{"a"}, 1}},
+			steps: []step{
+				{actor: "admin", action: models.ExamActionApprove, status: models.StatusPending, err: ErrTransitionForbidden.Error()},
+				{actor: "a", action: models.ExamActionReject, comment: "重做", status: models.StatusRejected},
+			},
+			rejectComment: "重做",
+		},
+		{
+			name:   "代理人审批",
+			mode:   models.ApprovalSequential,
+			stages: []stage{{[]string{"a"}, 1}},
+			steps: []step{
+				{actor: "d", action: models.ExamActionApprove, status: models.StatusApproved},
+			},
+			onBehalfOf: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			teacher := env.user(t, models.RoleTeacher)
+			users := map[string]*models.User{"admin": env.user(t, models.RoleAdmin)}
+			for _, name := range []string{"a", "b", "c", "d"} {
+				users[name] = env.user(t, models.RoleTeacher)
+			}
+			chain := &models.ApprovalChain{Course: "数学", Mode: tt.mode}
+			for i, s := range tt.stages {
+				st := models.ApprovalStage{Name: "第" + string(rune('1'+i)) + "级", RequiredApprovals: s.required}
+				for _, name := range s.approvers {
+					st.Approvers = append(st.Approvers, models.ApprovalStageApprover{UserID: users[name].ID})
+				}
+				chain.Stages = append(chain.Stages, st)
+			}
+			if err := env.approval.SaveChain(chain); err != nil {
+				t.Fatal(err)
+			}
+			if tt.onBehalfOf != "" {
+				err := env.approval.CreateDelegation(&models.ApprovalDelegation{
+					UserID:     users[tt.onBehalfOf].ID,
+					DelegateID: users["d"].ID,
+					StartTime:  time.Now().Add(-time.Hour),
+					EndTime:    time.Now().Add(time.Hour),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			exam, _ := env.draftExam(t, teacher, "数学")
+			env.fire(t, exam, teacher, models.ExamActionSubmit, "")
+			for i, s := range tt.steps {
+				_, err := env.exam.TransitionExam(exam.ID, users[s.actor].ID, s.action, s.comment)
+				switch {
+				case s.err == "" && err != nil:
+					t.Fatalf("第%d步 %s %s: %v", i+1, s.actor, s.action, err)
+				case s.err != "" && (err == nil || !strings.Contains(err.Error(), s.err)):
+					t.Fatalf("第%d步 %s %s: error = %v, 期望包含 %q", i+1, s.actor, s.action, err, s.err)
+				}
+				current, _ := env.exam.GetExamByID(exam.ID)
+				if current.Status != s.status {
+					t.Fatalf("第%d步 %s %s 后状态为 %s, 期望 %s", i+1, s.actor, s.action, current.Status, s.status)
+				}
+			}
+
+			history, err := env.exam.ListTransitions(exam.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := history[len(history)-1]
+			if tt.onBehalfOf != "" && last.OnBehalfOfID != users[tt.onBehalfOf].ID {
+				t.Errorf("委托人 = %d, 期望 %d", last.OnBehalfOfID, users[tt.onBehalfOf].ID)
+			}
+			if tt.rejectComment != "" && !strings.Contains(last.Comment, tt.rejectComment) {
+				t.Errorf("退回意见 = %q, 期望包含 %q", last.Comment, tt.rejectComment)
+			}
+		})
+	}
+}
+
+func TestApprovalPrepare(t *testing.T) {
+	tests := []struct {
+		name     string
+		creator  bool // 考试创建者是否为唯一的审批人之一
+		required int
+		wantErr  string
+	}{
+		{name: "审批人足够", required: 1},
+		{name: "创建者不审批自己的考试", creator: true, required: 2, wantErr: "除考试创建者外只有1名审批人"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			teacher := env.user(t, models.RoleTeacher)
+			other := env.user(t, models.RoleTeacher)
+			approvers := []models.ApprovalStageApprover{{UserID: other.ID}}
+			if tt.creator {
+				approvers = append(approvers, models.ApprovalStageApprover{UserID: teacher.ID})
+			}
+			chain := &models.ApprovalChain{
+				Course: "物理",
+				Stages: []models.ApprovalStage{{Name: "教研组", RequiredApprovals: tt.required, Approvers: approvers}},
+			}
+			if err := env.approval.SaveChain(chain); err != nil {
+				t.Fatal(err)
+			}
+			exam, _ := env.draftExam(t, teacher, "物理")
+
+			_, err := env.exam.TransitionExam(exam.ID, teacher.ID, models.ExamActionSubmit, "")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				tasks, _ := env.approval.Tasks(exam.ID)
+				if len(tasks) != 1 || tasks[0].ApproverID != other.ID || tasks[0].Status != models.ApprovalTaskPending {
+					t.Errorf("审批任务 = %+v", tasks)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("提交审批 error = %v, 期望包含 %q", err, tt.wantErr)
+			}
+			current, _ := env.exam.GetExamByID(exam.ID)
+			if current.Status != models.StatusDraft {
+				t.Errorf("提交失败后状态为 %s", current.Status)
+			}
+		})
+	}
+}
+
+func TestApprovalDelegation(t *testing.T) {
+	tests := []struct {
+		name        string
+		delegate    string // 代理人
+		start, end  time.Duration
+		deleted     bool // 委托是否已被删除
+		wantRouted  bool // 代理人能否看到并审批委托人的任务
+		wantCreator bool // 代理人是否为考试创建者
+	}{
+		{name: "生效中的委托", delegate: "d", start: -time.Hour, end: time.Hour, wantRouted: true},
+		{name: "尚未开始的委托", delegate: "d", start: time.Hour, end: 2 * time.Hour},
+		{name: "已删除的委托", delegate: "d", start: -time.Hour, end: time.Hour, deleted: true},
+		{name: "代理人不审批自己创建的考试", delegate: "teacher", start: -time.Hour, end: time.Hour, wantCreator: true},
+		{name: "代理人已参与同一级审批", delegate: "b", start: -time.Hour, end: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			users := map[string]*models.User{}
+			for _, name := range []string{"teacher", "a", "b", "d"} {
+				users[name] = env.user(t, models.RoleTeacher)
+			}
+			chain := &models.ApprovalChain{Course: "数学", Mode: models.ApprovalSequential, Stages: []models.ApprovalStage{{
+				Name: "教研组", RequiredApprovals: 2,
+				Approvers: []models.ApprovalStageApprover{{UserID: users["a"].ID}, {UserID: users["b"].ID}},
+			}}}
+			if err := env.approval.SaveChain(chain); err != nil {
+				t.Fatal(err)
+			}
+			delegation := &models.ApprovalDelegation{
+				UserID:     users["a"].ID,
+				DelegateID: users[tt.delegate].ID,
+				StartTime:  time.Now().Add(tt.start),
+				EndTime:    time.Now().Add(tt.end),
+			}
+			if err := env.approval.CreateDelegation(delegation); err != nil {
+				t.Fatal(err)
+			}
+			if tt.deleted {
+				if err := env.approval.DeleteDelegation(delegation.ID, users["a"]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			exam, _ := env.draftExam(t, users["teacher"], "数学")
+			env.fire(t, exam, users["teacher"], models.ExamActionSubmit, "")
+
+			delegate := users[tt.delegate]
+			queue, err := env.approval.Queue(delegate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			routed := false
+			for _, item := range queue {
+				if item.Exam.ID == exam.ID && item.OnBehalfOf == users["a"].ID {
+					routed = true
+				}
+			}
+			if routed != tt.wantRouted {
+				t.Errorf("代理人的待审批列表中有委托人的任务 = %v, 期望 %v", routed, tt.wantRouted)
+			}
+
+			current, _ := env.exam.GetExamByID(exam.ID)
+			access, err := env.approval.Reviewer(current, delegate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantRouted && (!access.Allowed || access.OnBehalfOf != users["a"].ID) {
+				t.Errorf("Reviewer() = %+v, 期望代替用户%d审批", access, users["a"].ID)
+			}
+			if !tt.wantRouted && access.OnBehalfOf != 0 {
+				t.Errorf("Reviewer() = %+v, 期望不能代替委托人审批", access)
+			}
+			if tt.wantCreator && access.Allowed {
+				t.Error("考试创建者不能审批自己的考试")
+			}
+		})
+	}
+}
+
+func TestApprovalDelegationAudit(t *testing.T) {
+	env := newTestEnv(t)
+	teacher := env.user(t, models.RoleTeacher)
+	a := env.user(t, models.RoleTeacher)
+	b := env.user(t, models.RoleTeacher)
+	d := env.user(t, models.RoleTeacher)
+	chain := &models.ApprovalChain{Course: "数学", Mode: models.ApprovalSequential, Stages: []models.ApprovalStage{
+		{Name: "第1级", RequiredApprovals: 1, Approvers: []models.ApprovalStageApprover{{UserID: a.ID}}},
+		{Name: "第2级", RequiredApprovals: 1, Approvers: []models.ApprovalStageApprover{{UserID: b.ID}}},
+	}}
+	if err := env.approval.SaveChain(chain); err != nil {
+		t.Fatal(err)
+	}
+	err := env.approval.CreateDelegation(&models.ApprovalDelegation{
+		UserID: b.ID, DelegateID: d.ID, StartTime: time.Now().Add(-time.Hour), EndTime: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exam, _ := env.draftExam(t, teacher, "数学")
+	env.fire(t, exam, teacher, models.ExamActionSubmit, "")
+	env.fire(t, exam, a, models.ExamActionApprove, "")
+	env.fire(t, exam, d, models.ExamActionApprove, "代审")
+
+	// 代理人的审批记在委托人的任务上，状态变更记录同时保留操作人和委托人
+	tasks, err := env.approval.Tasks(exam.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || tasks[1].ApproverID != b.ID || tasks[1].DecidedByID != d.ID ||
+		tasks[1].Status != models.ApprovalTaskApproved || tasks[1].Comment != "代审" {
+		t.Fatalf("审批任务 = %+v", tasks)
+	}
+	if tasks[0].DecidedByID != a.ID {
+		t.Errorf("第1级任务的处理人 = %d, 期望 %d", tasks[0].DecidedByID, a.ID)
+	}
+	history, err := env.exam.ListTransitions(exam.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var audits []uint
+	for _, transition := range history {
+		if transition.Action == models.ExamActionApprove {
+			audits = append(audits, transition.ActorID, transition.OnBehalfOfID)
+		}
+	}
+	if want := []uint{d.ID, b.ID}; !reflect.DeepEqual(audits, want) {
+		t.Errorf("审批记录的操作人和委托人 = %v, 期望 %v", audits, want)
+	}
+}
+
+func TestEscalateOverdue(t *testing.T) {
+	tests := []struct {
+		name string
+		// chain 考试科目是否配置了审批链
+		chain bool
+		// submitted 提交审批距今的时长，updated 考试最后修改距今的时长
+		submitted, updated time.Duration
+		// fallbackCreates 超时转交审批人是否为考试创建者
+		fallbackCreates bool
+		want            int
+	}{
+		{name: "审批链任务超时", chain: true, submitted: 3 * time.Hour, updated: 3 * time.Hour, want: 1},
+		{name: "审批链任务未超时", chain: true, submitted: 30 * time.Minute, updated: 30 * time.Minute},
+		{name: "无审批链的考试提交后超时", submitted: 3 * time.Hour, updated: 3 * time.Hour, want: 1},
+		{name: "无审批链的考试提交后修改过仍按提交时间计算", submitted: 3 * time.Hour, updated: time.Minute, want: 1},
+		{name: "无审批链的考试最近重新提交", submitted: 30 * time.Minute, updated: 3 * time.Hour},
+		{name: "超时转交审批人不处理自己的考试", submitted: 3 * time.Hour, updated: 3 * time.Hour, fallbackCreates: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			admin := env.user(t, models.RoleAdmin)
+			teacher := env.user(t, models.RoleTeacher)
+			approver := env.user(t, models.RoleTeacher)
+			fallback := env.user(t, models.RoleTeacher)
+			if tt.fallbackCreates {
+				teacher = fallback
+			}
+			if _, err := env.settings.Update(map[string]interface{}{
+				"approval_escalation_hours":  float64(1),
+				"approval_fallback_approver": float64(fallback.ID),
+			}, admin.ID); err != nil {
+				t.Fatal(err)
+			}
+			if tt.chain {
+				chain := &models.ApprovalChain{Course: "数学", Stages: []models.ApprovalStage{{
+					Name: "教研组", RequiredApprovals: 1, Approvers: []models.ApprovalStageApprover{{UserID: approver.ID}},
+				}}}
+				if err := env.approval.SaveChain(chain); err != nil {
+					t.Fatal(err)
+				}
+			}
+			exam, _ := env.draftExam(t, teacher, "数学")
+			env.fire(t, exam, teacher, models.ExamActionSubmit, "")
+			submitted, updated := time.Now().Add(-tt.submitted), time.Now().Add(-tt.updated)
+			execSQL(t, "UPDATE exam_transitions SET created_at = ? WHERE exam_id = ?", submitted, exam.ID)
+			execSQL(t, "UPDATE exam_approval_tasks SET updated_at = ? WHERE exam_id = ?", submitted, exam.ID)
+			execSQL(t, "UPDATE exams SET updated_at = ? WHERE id = ?", updated, exam.ID)
+
+			count, err := env.approval.EscalateOverdue()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.want {
+				t.Fatalf("EscalateOverdue() = %d, 期望 %d", count, tt.want)
+			}
+			queue, err := env.approval.Queue(fallback)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if escalated := len(queue) == 1 && queue[0].Escalated; escalated != (tt.want > 0) {
+				t.Errorf("超时转交审批人的待审批列表 = %+v", queue)
+			}
+			if tt.chain && tt.want > 0 {
+				tasks, _ := env.approval.Tasks(exam.ID)
+				if len(tasks) != 1 || tasks[0].ApproverID != fallback.ID || tasks[0].EscalatedFromID != approver.ID {
+					t.Errorf("转交后的审批任务 = %+v", tasks)
+				}
+			}
+
+			// 已转交的审批不会重复转交
+			if count, err := env.approval.EscalateOverdue(); err != nil || count != 0 {
+				t.Errorf("再次转交 = %d, %v, 期望 0", count, err)
+			}
+		})
+	}
+}
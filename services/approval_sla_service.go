@@ -0,0 +1,351 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+	"github.com/jinzhu/gorm"
+)
+
+// ErrApprovalSLANotFound 科目审批时限不存在
+var ErrApprovalSLANotFound = errors.New("审批时限不存在")
+
+// ApprovalLatencyStats 一组审批的耗时统计，耗时为提交审批到审批通过或拒绝的时间
+type ApprovalLatencyStats struct {
+	ApproverID   uint    `json:"approver_id,omitempty"`
+	Name         string  `json:"name,omitempty"` // 审批人姓名
+	Course       string  `json:"course,omitempty"`
+	SLAHours     int     `json:"sla_hours,omitempty"` // 科目当前的审批时限
+	Count        int     `json:"count"`
+	Approved     int     `json:"approved"`
+	Rejected     int     `json:"rejected"`
+	Overdue      int     `json:"overdue"` // 超过科目当前审批时限的次数
+	AverageHours float64 `json:"average_hours"`
+	P95Hours     float64 `json:"p95_hours"`
+}
+
+// ApprovalLatencyReport 审批耗时报表，统计在From到To之间审批通过或拒绝的考试
+type ApprovalLatencyReport struct {
+	From        time.Time              `json:"from"`
+	To          time.Time              `json:"to"`
+	Overall     ApprovalLatencyStats   `json:"overall"`
+	ByApprover  []ApprovalLatencyStats `json:"by_approver"`
+	ByCourse    []ApprovalLatencyStats `json:"by_course"`
+	OpenOverdue int                    `json:"open_overdue"` // 当前尚未结束的超时审批
+}
+
+// ApprovalSLAService 审批时限服务接口：管理各科目的审批时限，标记超时的审批，统计审批耗时
+type ApprovalSLAService interface {
+	ListSLAs() ([]models.ApprovalSLA, error)
+	SaveSLA(sla *models.ApprovalSLA) error
+	DeleteSLA(id uint) error
+	FlagOverdue() (int, error)
+	ListOverdue(openOnly bool) ([]models.OverdueApproval, error)
+	LatencyReport(from, to time.Time) (*ApprovalLatencyReport, error)
+}
+
+// approvalSLAService 审批时限服务实现
+type approvalSLAService struct {
+	slaRepository  repositories.ApprovalSLARepository
+	examRepository repositories.ExamRepository
+	userRepository repositories.UserRepository
+}
+
+// NewApprovalSLAService 创建审批时限服务
+func NewApprovalSLAService(
+	slaRepo repositories.ApprovalSLARepository,
+	examRepo repositories.ExamRepository,
+	userRepo repositories.UserRepository,
+) ApprovalSLAService {
+	return &approvalSLAService{
+		slaRepository:  slaRepo,
+		examRepository: examRepo,
+		userRepository: userRepo,
+	}
+}
+
+// ListSLAs 获取全部科目审批时限
+func (s *approvalSLAService) ListSLAs() ([]models.ApprovalSLA, error) {
+	return s.slaRepository.ListSLAs()
+}
+
+// SaveSLA 设置科目的审批时限，科目已有时限时更新。只影响之后标记的超时审批
+func (s *approvalSLAService) SaveSLA(sla *models.ApprovalSLA) error {
+	if err := sla.Validate(); err != nil {
+		return err
+	}
+	existing, err := s.slaRepository.GetSLAByCourse(sla.Course)
+	if err == nil {
+		sla.ID = existing.ID
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+	return s.slaRepository.SaveSLA(sla)
+}
+
+// DeleteSLA 删除科目审批时限，该科目的考试不再标记超时
+func (s *approvalSLAService) DeleteSLA(id uint) error {
+	_, err := s.slaRepository.GetSLA(id)
+	if gorm.IsRecordNotFoundError(err) {
+		return ErrApprovalSLANotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.slaRepository.DeleteSLA(id)
+}
+
+// FlagOverdue 标记提交审批后超过科目审批时限仍待审批的考试，并为已审批通过或拒绝的超时审批记录结束时间。
+// 返回新标记的数量
+func (s *approvalSLAService) FlagOverdue() (int, error) {
+	slas, err := s.slaMap()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	count := 0
+
+	if len(slas) > 0 {
+		exams, err := s.examRepository.ListPendingApproval()
+		if err != nil {
+			return count, err
+		}
+		for _, exam := range exams {
+			sla, ok := slas[exam.Course]
+			if !ok {
+				continue
+			}
+			transitions, err := s.examRepository.ListTransitions(exam.ID)
+			if err != nil {
+				return count, err
+			}
+			submit := lastSubmit(transitions)
+			if submit == nil {
+				continue
+			}
+			due := submit.CreatedAt.Add(sla.Duration())
+			if now.Before(due) {
+				continue
+			}
+			flagged, err := s.slaRepository.FlagOverdue(&models.OverdueApproval{
+				ExamID:       exam.ID,
+				TransitionID: submit.ID,
+				Course:       exam.Course,
+				SubmittedAt:  submit.CreatedAt,
+				DueAt:        due,
+				FlaggedAt:    now,
+			})
+			if err != nil {
+				return count, err
+			}
+			if flagged {
+				log.Printf("考试(%d)提交审批已超过%d小时仍未审批", exam.ID, sla.Hours)
+				count++
+			}
+		}
+	}
+
+	open, err := s.slaRepository.ListOverdue(true)
+	if err != nil {
+		return count, err
+	}
+	for _, overdue := range open {
+		transitions, err := s.examRepository.ListTransitions(overdue.ExamID)
+		if err != nil {
+			return count, err
+		}
+		for _, transition := range transitions {
+			if transition.ID > overdue.TransitionID && transition.FromStatus == models.StatusPending {
+				if err := s.slaRepository.ResolveOverdue(overdue.ID, transition.CreatedAt, transition.Action); err != nil {
+					return count, err
+				}
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+// ListOverdue 获取超时审批，openOnly为true时只返回尚未审批的
+func (s *approvalSLAService) ListOverdue(openOnly bool) ([]models.OverdueApproval, error) {
+	return s.slaRepository.ListOverdue(openOnly)
+}
+
+// latencyGroup 统计审批耗时时的一组样本
+type latencyGroup struct {
+	stats ApprovalLatencyStats
+	hours []float64
+}
+
+// add 加入一次审批的耗时
+func (g *latencyGroup) add(hours float64, approved, overdue bool) {
+	g.hours = append(g.hours, hours)
+	g.stats.Count++
+	if approved {
+		g.stats.Approved++
+	} else {
+		g.stats.Rejected++
+	}
+	if overdue {
+		g.stats.Overdue++
+	}
+}
+
+// result 计算平均耗时和按最近秩法计算的95分位耗时，保留两位小数
+func (g *latencyGroup) result() ApprovalLatencyStats {
+	stats := g.stats
+	if len(g.hours) == 0 {
+		return stats
+	}
+	sort.Float64s(g.hours)
+	total := 0.0
+	for _, h := range g.hours {
+		total += h
+	}
+	rank := int(math.Ceil(0.95*float64(len(g.hours)))) - 1
+	stats.AverageHours = math.Round(total/float64(len(g.hours))*100) / 100
+	stats.P95Hours = math.Round(g.hours[rank]*100) / 100
+	return stats
+}
+
+// LatencyReport 按状态变更记录统计From到To之间审批通过或拒绝的考试从提交审批到审批完成的耗时，
+// 分别按作出最终决定的审批人和科目汇总
+func (s *approvalSLAService) LatencyReport(from, to time.Time) (*ApprovalLatencyReport, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("结束日期必须晚于开始日期")
+	}
+	transitions, err := s.examRepository.ListTransitionsByActions(
+		[]string{models.ExamActionSubmit, models.ExamActionApprove, models.ExamActionReject}, to)
+	if err != nil {
+		return nil, err
+	}
+	slas, err := s.slaMap()
+	if err != nil {
+		return nil, err
+	}
+	exams, err := s.examRepository.List()
+	if err != nil {
+		return nil, err
+	}
+	courses := make(map[uint]string, len(exams))
+	for _, exam := range exams {
+		courses[exam.ID] = exam.Course
+	}
+
+	overall := &latencyGroup{}
+	approvers := make(map[uint]*latencyGroup)
+	byCourse := make(map[string]*latencyGroup)
+	var submittedAt *time.Time
+	examID := uint(0)
+	for i := range transitions {
+		transition := &transitions[i]
+		if transition.ExamID != examID {
+			examID, submittedAt = transition.ExamID, nil
+		}
+		if transition.Action == models.ExamActionSubmit {
+			submittedAt = &transition.CreatedAt
+			continue
+		}
+		if submittedAt == nil || transition.FromStatus != models.StatusPending {
+			continue
+		}
+		latency := transition.CreatedAt.Sub(*submittedAt)
+		submittedAt = nil
+		if transition.CreatedAt.Before(from) {
+			continue
+		}
+
+		course := courses[transition.ExamID]
+		approved := transition.Action == models.ExamActionApprove
+		overdue := false
+		if sla, ok := slas[course]; ok {
+			overdue = latency > sla.Duration()
+		}
+		hours := latency.Hours()
+
+		overall.add(hours, approved, overdue)
+		approver, ok := approvers[transition.ActorID]
+		if !ok {
+			approver = &latencyGroup{stats: ApprovalLatencyStats{ApproverID: transition.ActorID, Name: s.userName(transition.ActorID)}}
+			approvers[transition.ActorID] = approver
+		}
+		approver.add(hours, approved, overdue)
+		group, ok := byCourse[course]
+		if !ok {
+			group = &latencyGroup{stats: ApprovalLatencyStats{Course: course}}
+			if sla, ok := slas[course]; ok {
+				group.stats.SLAHours = sla.Hours
+			}
+			byCourse[course] = group
+		}
+		group.add(hours, approved, overdue)
+	}
+
+	openOverdue, err := s.slaRepository.CountOpenOverdue()
+	if err != nil {
+		return nil, err
+	}
+	report := &ApprovalLatencyReport{
+		From:        from,
+		To:          to,
+		Overall:     overall.result(),
+		ByApprover:  make([]ApprovalLatencyStats, 0, len(approvers)),
+		ByCourse:    make([]ApprovalLatencyStats, 0, len(byCourse)),
+		OpenOverdue: openOverdue,
+	}
+	for _, group := range approvers {
+		report.ByApprover = append(report.ByApprover, group.result())
+	}
+	for _, group := range byCourse {
+		report.ByCourse = append(report.ByCourse, group.result())
+	}
+	sort.Slice(report.ByApprover, func(i, j int) bool {
+		a, b := report.ByApprover[i], report.ByApprover[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.ApproverID < b.ApproverID
+	})
+	sort.Slice(report.ByCourse, func(i, j int) bool {
+		return report.ByCourse[i].Course < report.ByCourse[j].Course
+	})
+	return report, nil
+}
+
+// slaMap 按科目获取审批时限
+func (s *approvalSLAService) slaMap() (map[string]*models.ApprovalSLA, error) {
+	slas, err := s.slaRepository.ListSLAs()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*models.ApprovalSLA, len(slas))
+	for i := range slas {
+		result[slas[i].Course] = &slas[i]
+	}
+	return result, nil
+}
+
+// userName 用户的姓名，用户已删除时使用ID
+func (s *approvalSLAService) userName(userID uint) string {
+	user, err := s.userRepository.GetByID(userID)
+	if err != nil {
+		return fmt.Sprintf("用户%d", userID)
+	}
+	return user.Name
+}
+
+// lastSubmit 考试最近一次提交审批的记录，没有时返回nil
+func lastSubmit(transitions []models.ExamTransition) *models.ExamTransition {
+	for i := len(transitions) - 1; i >= 0; i-- {
+		if transitions[i].Action == models.ExamActionSubmit {
+			return &transitions[i]
+		}
+	}
+	return nil
+}
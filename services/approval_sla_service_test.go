@@ -0,0 +1,125 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+)
+
+func TestLatencyGroupResult(t *testing.T) {
+	tests := []struct {
+		name        string
+		hours       []float64
+		wantAverage float64
+		wantP95     float64
+	}{
+		{name: "没有样本"},
+		{name: "一个样本", hours: []float64{3.5}, wantAverage: 3.5, wantP95: 3.5},
+		{name: "二十个样本取第19个", hours: []float64{20, 19, 18, 17, 16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}, wantAverage: 10.5, wantP95: 19},
+		{name: "四个样本取最大值", hours: []float64{2, 10, 4, 6}, wantAverage: 5.5, wantP95: 10},
+		{name: "保留两位小数", hours: []float64{1, 1, 1.006}, wantAverage: 1, wantP95: 1.01},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := &latencyGroup{}
+			for _, h := range tt.hours {
+				group.add(h, true, false)
+			}
+			stats := group.result()
+			if stats.Count != len(tt.hours) || stats.AverageHours != tt.wantAverage || stats.P95Hours != tt.wantP95 {
+				t.Errorf("result() = %+v, 期望 %d次 平均%g 95分位%g", stats, len(tt.hours), tt.wantAverage, tt.wantP95)
+			}
+		})
+	}
+}
+
+func TestLatencyReport(t *testing.T) {
+	env := newTestEnv(t)
+	sla := NewApprovalSLAService(repositories.NewApprovalSLARepository(), env.exams, env.users)
+	teacher := env.user(t, models.RoleTeacher)
+	a := env.user(t, models.RoleAdmin)
+	b := env.user(t, models.RoleTeacher)
+	if err := sla.SaveSLA(&models.ApprovalSLA{Course: "数学", Hours: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(d, h int) time.Time { return time.Date(2024, 7, d, h, 0, 0, 0, time.Local) }
+	// transition 写入一条指定时间的状态变更记录
+	transition := func(examID uint, action string, actor uint, at time.Time) {
+		t.Helper()
+		from, to := models.StatusPending, models.StatusApproved
+		switch action {
+		case models.ExamActionSubmit:
+			from, to = models.StatusDraft, models.StatusPending
+		case models.ExamActionReject:
+			to = models.StatusRejected
+		}
+		execSQL(t, "INSERT INTO exam_transitions (exam_id, action, from_status, to_status, actor_id, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+			examID, action, from, to, actor, at)
+	}
+	exam := func(course string) uint {
+		e, _ := env.draftExam(t, teacher, course)
+		return e.ID
+	}
+
+	// 数学：2小时通过；10小时拒绝后重新提交，4小时通过
+	first := exam("数学")
+	transition(first, models.ExamActionSubmit, teacher.ID, day(2, 0))
+	transition(first, models.ExamActionApprove, a.ID, day(2, 2))
+	second := exam("数学")
+	transition(second, models.ExamActionSubmit, teacher.ID, day(3, 0))
+	transition(second, models.ExamActionReject, a.ID, day(3, 10))
+	transition(second, models.ExamActionSubmit, teacher.ID, day(4, 0))
+	transition(second, models.ExamActionApprove, b.ID, day(4, 4))
+	// 物理：统计开始前提交、开始后6小时通过的计入
+	third := exam("物理")
+	transition(third, models.ExamActionSubmit, teacher.ID, day(1, 0).Add(-4*time.Hour))
+	transition(third, models.ExamActionApprove, b.ID, day(1, 2))
+	// 统计范围之前和之后审批完成的不计入
+	before := exam("物理")
+	transition(before, models.ExamActionSubmit, teacher.ID, day(1, 0).Add(-48*time.Hour))
+	transition(before, models.ExamActionApprove, a.ID, day(1, 0).Add(-24*time.Hour))
+	after := exam("数学")
+	transition(after, models.ExamActionSubmit, teacher.ID, day(31, 20))
+	transition(after, models.ExamActionApprove, a.ID, day(31, 0).Add(25*time.Hour))
+	// 尚未审批的考试不计入耗时，超时后计入当前超时审批数
+	pending := exam("数学")
+	execSQL(t, "UPDATE exams SET status = ? WHERE id = ?", models.StatusPending, pending)
+	transition(pending, models.ExamActionSubmit, teacher.ID, day(5, 0))
+	if _, err := sla.FlagOverdue(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := sla.LatencyReport(day(1, 0), day(1, 0).AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantOverall := ApprovalLatencyStats{Count: 4, Approved: 3, Rejected: 1, Overdue: 1, AverageHours: 5.5, P95Hours: 10}
+	if report.Overall != wantOverall {
+		t.Errorf("Overall = %+v, 期望 %+v", report.Overall, wantOverall)
+	}
+	wantApprovers := []ApprovalLatencyStats{
+		{ApproverID: a.ID, Name: a.Name, Count: 2, Approved: 1, Rejected: 1, Overdue: 1, AverageHours: 6, P95Hours: 10},
+		{ApproverID: b.ID, Name: b.Name, Count: 2, Approved: 2, AverageHours: 5, P95Hours: 6},
+	}
+	if !reflect.DeepEqual(report.ByApprover, wantApprovers) {
+		t.Errorf("ByApprover = %+v, 期望 %+v", report.ByApprover, wantApprovers)
+	}
+	wantCourses := []ApprovalLatencyStats{
+		{Course: "数学", SLAHours: 5, Count: 3, Approved: 2, Rejected: 1, Overdue: 1, AverageHours: 5.33, P95Hours: 10},
+		{Course: "物理", Count: 1, Approved: 1, AverageHours: 6, P95Hours: 6},
+	}
+	if !reflect.DeepEqual(report.ByCourse, wantCourses) {
+		t.Errorf("ByCourse = %+v, 期望 %+v", report.ByCourse, wantCourses)
+	}
+	if report.OpenOverdue != 1 {
+		t.Errorf("OpenOverdue = %d, 期望 1", report.OpenOverdue)
+	}
+
+	if _, err := sla.LatencyReport(day(2, 0), day(2, 0)); err == nil {
+		t.Error("结束日期不晚于开始日期时应返回错误")
+	}
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+	"github.com/jinzhu/gorm"
+)
+
+// submitTolerance 截止时间后仍视为按时交卷的时长，用于抵消倒计时结束自动提交时的网络延迟
+const submitTolerance = 30 * time.Second
+
+var (
+	// ErrNoActiveAttempt 没有作答中的会话
+	ErrNoActiveAttempt = errors.New("没有进行中的作答，请重新进入考试")
+	// ErrAttemptExpired 已超过截止时间，暂存的作答已由系统自动提交
+	ErrAttemptExpired = errors.New("已超过作答截止时间，系统已自动提交暂存的作答")
+	// ErrDraftConflict 暂存的作答已在其他页面更新
+	ErrDraftConflict = errors.New("作答已在其他页面更新")
+)
+
+// AttemptService 限时作答服务接口
+type AttemptService interface {
+	Start(exam *models.Exam, examData *models.ExamData) (*models.ExamAttempt, error)
+	Submit(exam *models.Exam, examData *models.ExamData, responses map[uint]string) (*models.Submission, error)
+	SaveDraft(examData *models.ExamData, version int, responses map[uint]string) (*models.ExamAttempt, error)
+	ExpireOverdue() (int, error)
+}
+
+// attemptService 限时作答服务实现
+type attemptService struct {
+	attemptRepository  repositories.AttemptRepository
+	paperRepository    repositories.PaperRepository
+	examDataRepository repositories.ExamDataRepository
+	submissionService  SubmissionService
+	settingsService    SettingsService
+}
+
+// NewAttemptService 创建限时作答服务
+func NewAttemptService(attemptRepo repositories.AttemptRepository, paperRepo repositories.PaperRepository, examDataRepo repositories.ExamDataRepository, submissionService SubmissionService, settingsService SettingsService) AttemptService {
+	return &attemptService{
+		attemptRepository:  attemptRepo,
+		paperRepository:    paperRepo,
+		examDataRepository: examDataRepo,
+		submissionService:  submissionService,
+		settingsService:    settingsService,
+	}
+}
+
+// closeAfter 返回会话不再接受交卷的时间，包括网络延迟容差和系统设置的宽限期
+func (s *attemptService) closeAfter(deadline time.Time) time.Time {
+	return deadline.Add(submitTolerance + s.settingsService.Get().LateGrace())
+}
+
+// Start 开始或继续作答：已有作答中的会话时直接返回，否则在考试开放时间内创建新会话
+// 个人截止时间为开始时间加考试下各试卷时长之和与考试结束时间中较早的一个。
+// 同一答题记录同时开始时只有一个请求能创建会话，其余请求返回该会话
+func (s *attemptService) Start(exam *models.Exam, examData *models.ExamData) (*models.ExamAttempt, error) {
+	now := time.Now()
+	attempt, err := s.attemptRepository.GetActive(examData.ID)
+	if err == nil {
+		if attempt.Deadline != nil && now.After(s.closeAfter(*attempt.Deadline)) {
+			s.expire(attempt, examData)
+			return nil, ErrAttemptExpired
+		}
+		return attempt, nil
+	}
+	if !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+
+	if !exam.StartTime.IsZero() && now.Before(exam.StartTime) {
+		return nil, fmt.Errorf("考试尚未开始，开始时间: %s", exam.StartTime.Format("2006-01-02 15:04"))
+	}
+	if !exam.EndTime.IsZero() && !now.Before(exam.EndTime) {
+		return nil, errors.New("考试已结束")
+	}
+
+	if err := s.checkAttemptPolicy(exam, examData, now); err != nil {
+		return nil, err
+	}
+
+	papers, err := s.paperRepository.GetByExamID(exam.ID)
+	if err != nil {
+		return nil, err
+	}
+	var minutes int
+	for _, paper := range papers {
+		minutes += paper.Duration
+	}
+
+	attempt = &models.ExamAttempt{
+		ExamDataID: examData.ID,
+		ExamID:     exam.ID,
+		StudentID:  examData.StudentID,
+		Status:     models.AttemptInProgress,
+		StartedAt:  now,
+		Deadline:   models.AttemptDeadline(exam, time.Duration(minutes)*time.Minute, now),
+	}
+	if err := s.attemptRepository.Create(attempt); err != nil {
+		// 唯一索引拒绝了第二个作答中的会话，说明其他请求已经开始作答
+		if active, activeErr := s.attemptRepository.GetActive(examData.ID); activeErr == nil {
+			return active, nil
+		}
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// checkAttemptPolicy 按考试的作答次数、作答间隔检查能否开始新的作答；
+// 按最高分或平均分计分时，上一次提交批阅完成后才能再次作答
+func (s *attemptService) checkAttemptPolicy(exam *models.Exam, examData *models.ExamData, now time.Time) error {
+	submissions, err := s.submissionService.List(examData.ID)
+	if err != nil {
+		return err
+	}
+	if len(submissions) == 0 {
+		return nil
+	}
+	if exam.MaxAttempts > 0 && len(submissions) >= exam.MaxAttempts {
+		return fmt.Errorf("已达到最多作答次数(%d次)", exam.MaxAttempts)
+	}
+
+	last := submissions[len(submissions)-1]
+	gradedOnly := exam.ScorePolicy == models.ScorePolicyHighest || exam.ScorePolicy == models.ScorePolicyAverage
+	if gradedOnly && last.Status != models.SubmissionGraded {
+		return errors.New("上一次提交尚未批阅完成，批阅后才能再次作答")
+	}
+	if next := last.SubmittedAt.Add(time.Duration(exam.AttemptCooldown) * time.Minute); now.Before(next) {
+		return fmt.Errorf("两次作答需间隔%d分钟，请在%s之后再次作答", exam.AttemptCooldown, next.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+// Submit 结束作答中的会话并保存提交，responses中的选项使用学生看到的展示标签。
+// 截止时间后在宽限期内交卷会被标记为逾期，超过宽限期时拒绝本次提交，改为自动提交暂存的作答
+func (s *attemptService) Submit(exam *models.Exam, examData *models.ExamData, responses map[uint]string) (*models.Submission, error) {
+	attempt, err := s.attemptRepository.GetActive(examData.ID)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrNoActiveAttempt
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	questions, err := s.submissionService.ExamQuestions(examData.ExamID)
+	if err != nil {
+		return nil, err
+	}
+	responses = models.NewShuffle(examData.ID, questions).ToCanonical(responses)
+
+	now := time.Now()
+	if attempt.Deadline != nil {
+		if now.After(s.closeAfter(*attempt.Deadline)) {
+			s.expire(attempt, examData)
+			return nil, ErrAttemptExpired
+		}
+		attempt.Late = now.After(attempt.Deadline.Add(submitTolerance))
+	}
+
+	attempt.Status = models.AttemptSubmitted
+	attempt.SubmittedAt = &now
+	submission, err := s.closeAndSubmit(attempt, examData, responses)
+	if errors.Is(err, errAttemptClosed) {
+		return nil, ErrAttemptExpired
+	}
+	return submission, err
+}
+
+// errAttemptClosed 会话已被其他请求或超时自动交卷结束
+var errAttemptClosed = errors.New("作答会话已结束")
+
+// closeAndSubmit 在同一事务中结束会话并保存提交：先按作答中的状态结束会话，
+// 避免与超时自动交卷重复提交；保存提交失败时一起回滚，会话仍为作答中
+func (s *attemptService) closeAndSubmit(attempt *models.ExamAttempt, examData *models.ExamData, responses map[uint]string) (*models.Submission, error) {
+	var submission *models.Submission
+	err := configs.DB().Transaction(func(tx *gorm.DB) error {
+		attemptRepo := s.attemptRepository.WithTx(tx)
+		closed, err := attemptRepo.Close(attempt)
+		if err != nil {
+			return err
+		}
+		if !closed {
+			return errAttemptClosed
+		}
+
+		submission, err = s.submissionService.WithTx(tx).Submit(examData, attempt, responses)
+		if err != nil {
+			return err
+		}
+		return attemptRepo.SetSubmission(attempt.ID, submission.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+// SaveDraft 暂存作答中的会话的全部作答，选项使用展示标签，暂存时换算为原有标签；
+// version 为页面上次读取或保存时的暂存版本。版本已被其他页面更新时返回ErrDraftConflict和最新的会话，由页面决定载入还是覆盖
+func (s *attemptService) SaveDraft(examData *models.ExamData, version int, responses map[uint]string) (*models.ExamAttempt, error) {
+	attempt, err := s.attemptRepository.GetActive(examData.ID)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrNoActiveAttempt
+	}
+	if err != nil {
+		return nil, err
+	}
+	if attempt.Deadline != nil && time.Now().After(s.closeAfter(*attempt.Deadline)) {
+		s.expire(attempt, examData)
+		return nil, ErrAttemptExpired
+	}
+	if attempt.DraftVersion != version {
+		return attempt, ErrDraftConflict
+	}
+
+	questions, err := s.submissionService.ExamQuestions(examData.ExamID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.Question, len(questions))
+	for i := range questions {
+		byID[questions[i].ID] = &questions[i]
+	}
+	responses = models.NewShuffle(examData.ID, questions).ToCanonical(responses)
+
+	draft := make(map[uint]string, len(responses))
+	for questionID, response := range responses {
+		question := byID[questionID]
+		if question == nil && (questionID != 0 || len(questions) > 0) {
+			return nil, fmt.Errorf("题目 %d 不属于该考试", questionID)
+		}
+		if question != nil {
+			if response, err = normalizeResponse(question, response); err != nil {
+				return nil, fmt.Errorf("第%d题: %v", question.Position, err)
+			}
+		}
+		if response != "" {
+			draft[questionID] = response
+		}
+	}
+	data, err := json.Marshal(draft)
+	if err != nil {
+		return nil, err
+	}
+
+	attempt.Draft = string(data)
+	saved, err := s.attemptRepository.SaveDraft(attempt, version)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		// 读取之后被其他页面抢先保存或已交卷
+		latest, err := s.attemptRepository.GetActive(examData.ID)
+		if err != nil {
+			return nil, ErrNoActiveAttempt
+		}
+		return latest, ErrDraftConflict
+	}
+	return attempt, nil
+}
+
+// ExpireOverdue 自动提交已超过截止时间和宽限期的会话，返回处理的会话数量
+func (s *attemptService) ExpireOverdue() (int, error) {
+	before := time.Now().Add(-submitTolerance - s.settingsService.Get().LateGrace())
+	attempts, err := s.attemptRepository.ListOverdue(before)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range attempts {
+		examData, err := s.examDataRepository.GetByID(attempts[i].ExamDataID)
+		if err != nil {
+			log.Printf("作答会话(%d)的答题记录不存在: %v", attempts[i].ID, err)
+			examData = nil
+		}
+		s.expire(&attempts[i], examData)
+	}
+	return len(attempts), nil
+}
+
+// expire 结束超时的会话并提交暂存的作答，没有暂存时各题记为未作答。
+// 提交失败时会话仍为作答中，由下一次超时检查重试；答题记录不存在时只结束会话
+func (s *attemptService) expire(attempt *models.ExamAttempt, examData *models.ExamData) {
+	now := time.Now()
+	attempt.Status = models.AttemptExpired
+	attempt.SubmittedAt = &now
+	attempt.AutoSubmitted = true
+	if examData == nil {
+		if _, err := s.attemptRepository.Close(attempt); err != nil {
+			log.Printf("结束作答会话(%d)失败: %v", attempt.ID, err)
+		}
+		return
+	}
+
+	submission, err := s.closeAndSubmit(attempt, examData, attempt.Responses())
+	if errors.Is(err, errAttemptClosed) {
+		return
+	}
+	if err != nil {
+		log.Printf("作答会话(%d)超时自动交卷失败: %v", attempt.ID, err)
+		return
+	}
+	log.Printf("作答会话(%d)已超时，自动提交为答题记录(%d)的第%d次提交", attempt.ID, examData.ID, submission.Attempt)
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+	"github.com/jinzhu/gorm"
+)
+
+func TestAttemptSaveDraft(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     int // 提交时带的暂存版本，-1表示使用当前版本
+		responses   func(choice, truth uint) map[uint]string
+		wantErr     error
+		wantErrText string
+		wantVersion int
+		wantDraft   func(choice, truth uint) map[uint]string
+	}{
+		{
+			name:        "保存并换算为原有标签",
+			version:     -1,
+			responses:   func(choice, truth uint) map[uint]string { return map[uint]string{choice: "display:B", truth: "TRUE"} },
+			wantVersion: 2,
+			wantDraft:   func(choice, truth uint) map[uint]string { return map[uint]string{choice: "B", truth: "true"} },
+		},
+		{
+			name:        "空作答不保存",
+			version:     -1,
+			responses:   func(choice, truth uint) map[uint]string { return map[uint]string{choice: " ", truth: "false"} },
+			wantVersion: 2,
+			wantDraft:   func(choice, truth uint) map[uint]string { return map[uint]string{truth: "false"} },
+		},
+		{
+			name:        "版本已被其他页面更新",
+			version:     0,
+			responses:   func(choice, truth uint) map[uint]string { return map[uint]string{truth: "false"} },
+			wantErr:     ErrDraftConflict,
+			wantVersion: 1,
+			wantDraft:   func(choice, truth uint) map[uint]string { return map[uint]string{truth: "true"} },
+		},
+		{
+			name:        "题目不属于该考试",
+			version:     -1,
+			responses:   func(choice, truth uint) map[uint]string { return map[uint]string{truth + 100: "x"} },
+			wantErrText: "不属于该考试",
+			wantVersion: 1,
+			wantDraft:   func(choice, truth uint) map[uint]string { return map[uint]string{truth: "true"} },
+		},
+		{
+			name:        "无效的判断题答案",
+			version:     -1,
+			responses:   func(choice, truth uint) map[uint]string { return map[uint]string{truth: "yes"} },
+			wantErrText: "判断题答案必须是true或false",
+			wantVersion: 1,
+			wantDraft:   func(choice, truth uint) map[uint]string { return map[uint]string{truth: "true"} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			student := env.user(t, models.RoleStudent)
+			exam, paper, examData := env.publishedExam(t, student)
+			choice, truth := paper.Questions[0].ID, paper.Questions[1].ID
+			shuffle := models.NewShuffle(examData.ID, paper.Questions)
+
+			attempt, err := env.attempt.Start(exam, examData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 第一次暂存，版本变为1
+			if attempt, err = env.attempt.SaveDraft(examData, attempt.DraftVersion, map[uint]string{truth: "true"}); err != nil {
+				t.Fatal(err)
+			}
+
+			responses := tt.responses(choice, truth)
+			for id, response := range responses {
+				// 选项使用学生看到的展示标签
+				if strings.HasPrefix(response, "display:") {
+					responses[id] = shuffle.ToDisplay(map[uint]string{id: strings.TrimPrefix(response, "display:")})[id]
+				}
+			}
+			version := tt.version
+			if version < 0 {
+				version = attempt.DraftVersion
+			}
+			_, err = env.attempt.SaveDraft(examData, version, responses)
+			switch {
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Fatalf("SaveDraft() error = %v, 期望 %v", err, tt.wantErr)
+			case tt.wantErrText != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErrText)):
+				t.Fatalf("SaveDraft() error = %v, 期望包含 %q", err, tt.wantErrText)
+			case tt.wantErr == nil && tt.wantErrText == "" && err != nil:
+				t.Fatalf("SaveDraft() error = %v", err)
+			}
+
+			saved, err := env.attempts.GetActive(examData.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.DraftVersion != tt.wantVersion {
+				t.Errorf("DraftVersion = %d, 期望 %d", saved.DraftVersion, tt.wantVersion)
+			}
+			want := tt.wantDraft(choice, truth)
+			got := saved.Responses()
+			if len(got) != len(want) {
+				t.Fatalf("暂存的作答 = %v, 期望 %v", got, want)
+			}
+			for id, response := range want {
+				if got[id] != response {
+					t.Errorf("暂存的作答 = %v, 期望 %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAttemptSubmit(t *testing.T) {
+	env := newTestEnv(t)
+	student := env.user(t, models.RoleStudent)
+	exam, paper, examData := env.publishedExam(t, student)
+	choice, truth := paper.Questions[0].ID, paper.Questions[1].ID
+
+	if _, err := env.attempt.Submit(exam, examData, map[uint]string{truth: "true"}); !errors.Is(err, ErrNoActiveAttempt) {
+		t.Fatalf("未开始作答时交卷 error = %v", err)
+	}
+	first, err := env.attempt.Start(exam, examData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := env.attempt.Start(exam, examData)
+	if err != nil || again.ID != first.ID {
+		t.Fatalf("重复进入考试返回会话 %v (%v), 期望继续会话 %d", again, err, first.ID)
+	}
+
+	display := models.NewShuffle(examData.ID, paper.Questions).ToDisplay(map[uint]string{choice: "B"})[choice]
+	submission, err := env.attempt.Submit(exam, examData, map[uint]string{choice: display, truth: "false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if submission.Status != models.SubmissionGraded || submission.Score != 5 {
+		t.Errorf("提交状态 %s 得分 %g, 期望自动评分为5分", submission.Status, submission.Score)
+	}
+	if _, err := env.attempts.GetActive(examData.ID); err == nil {
+		t.Error("交卷后会话仍在作答中")
+	}
+	if _, err := env.attempt.Submit(exam, examData, map[uint]string{truth: "true"}); !errors.Is(err, ErrNoActiveAttempt) {
+		t.Errorf("重复交卷 error = %v, 期望 %v", err, ErrNoActiveAttempt)
+	}
+}
+
+func TestAttemptPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		cooldown    int
+		wantErr     string
+	}{
+		{name: "不限次数"},
+		{name: "达到最多作答次数", maxAttempts: 1, wantErr: "已达到最多作答次数(1次)"},
+		{name: "作答间隔", cooldown: 30, wantErr: "两次作答需间隔30分钟"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			student := env.user(t, models.RoleStudent)
+			exam, paper, examData := env.publishedExam(t, student)
+			exam.MaxAttempts = tt.maxAttempts
+			exam.AttemptCooldown = tt.cooldown
+
+			if _, err := env.attempt.Start(exam, examData); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := env.attempt.Submit(exam, examData, map[uint]string{paper.Questions[1].ID: "true"}); err != nil {
+				t.Fatal(err)
+			}
+			_, err := env.attempt.Start(exam, examData)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("再次作答 error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("再次作答 error = %v, 期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// racingAttemptRepository 第一次读取作答中的会话时，模拟另一个请求在检查之后抢先创建了会话other
+type racingAttemptRepository struct {
+	repositories.AttemptRepository
+	other *models.ExamAttempt
+	raced bool
+}
+
+func (r *racingAttemptRepository) GetActive(examDataID uint) (*models.ExamAttempt, error) {
+	if !r.raced {
+		r.raced = true
+		if err := r.AttemptRepository.Create(r.other); err != nil {
+			return nil, err
+		}
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.AttemptRepository.GetActive(examDataID)
+}
+
+func TestAttemptStartRace(t *testing.T) {
+	env := newTestEnv(t)
+	student := env.user(t, models.RoleStudent)
+	exam, _, examData := env.publishedExam(t, student)
+	racing := &racingAttemptRepository{
+		AttemptRepository: env.attempts,
+		other: &models.ExamAttempt{
+			ExamDataID: examData.ID, ExamID: exam.ID, StudentID: student.ID,
+			Status: models.AttemptInProgress, StartedAt: time.Now(),
+		},
+	}
+	service := NewAttemptService(racing, env.papers, env.examData, env.submission, env.settings)
+
+	attempt, err := service.Start(exam, examData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.ID != racing.other.ID {
+		t.Errorf("Start() 返回会话 %d, 期望抢先创建的会话 %d", attempt.ID, racing.other.ID)
+	}
+	if count, _ := env.attempts.CountActiveByExam(exam.ID); count != 1 {
+		t.Errorf("作答中的会话有 %d 个, 期望 1 个", count)
+	}
+}
+
+func TestAttemptSubmitFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		submit func(t *testing.T, env *testEnv, exam *models.Exam, examData *models.ExamData, truth uint) error
+	}{
+		{
+			name: "交卷",
+			submit: func(t *testing.T, env *testEnv, exam *models.Exam, examData *models.ExamData, truth uint) error {
+				_, err := env.attempt.Submit(exam, examData, map[uint]string{truth: "true"})
+				return err
+			},
+		},
+		{
+			name: "超时自动交卷",
+			submit: func(t *testing.T, env *testEnv, exam *models.Exam, examData *models.ExamData, truth uint) error {
+				execSQL(t, "UPDATE exam_attempts SET deadline = ? WHERE exam_data_id = ?", time.Now().Add(-time.Hour), examData.ID)
+				if count, err := env.attempt.ExpireOverdue(); err != nil || count != 1 {
+					return errors.New("未检查到超时的会话")
+				}
+				_, err := env.submission.GetLatest(examData.ID)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			student := env.user(t, models.RoleStudent)
+			exam, paper, examData := env.publishedExam(t, student)
+			truth := paper.Questions[1].ID
+			if _, err := env.attempt.Start(exam, examData); err != nil {
+				t.Fatal(err)
+			}
+
+			// 保存提交失败时会话仍为作答中，可以再次交卷
+			execSQL(t, `CREATE TRIGGER fail_submit BEFORE INSERT ON submissions BEGIN SELECT RAISE(ABORT, 'submit failed'); END`)
+			if err := tt.submit(t, env, exam, examData, truth); err == nil {
+				t.Fatal("保存提交失败时应返回错误")
+			}
+			attempt, err := env.attempts.GetActive(examData.ID)
+			if err != nil {
+				t.Fatalf("提交失败后会话已结束: %v", err)
+			}
+			if attempt.SubmittedAt != nil || attempt.ActiveExamDataID == nil {
+				t.Errorf("提交失败后会话 = %+v", attempt)
+			}
+
+			execSQL(t, "DROP TRIGGER fail_submit")
+			if err := tt.submit(t, env, exam, examData, truth); err != nil {
+				t.Fatalf("再次交卷 error = %v", err)
+			}
+			if _, err := env.attempts.GetActive(examData.ID); err == nil {
+				t.Error("交卷后会话仍在作答中")
+			}
+		})
+	}
+}
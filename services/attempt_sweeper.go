@@ -0,0 +1,79 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// attemptSweepInterval 检查超时作答会话的间隔
+const attemptSweepInterval = 30 * time.Second
+
+// AttemptSweeper 超时作答自动交卷调度器接口
+type AttemptSweeper interface {
+	Start()
+	Stop()
+}
+
+// attemptSweeper 超时作答自动交卷调度器实现，定期提交已超过截止时间的作答会话
+type attemptSweeper struct {
+	attemptService     AttemptService
+	maintenanceService MaintenanceService
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewAttemptSweeper 创建超时作答自动交卷调度器
+func NewAttemptSweeper(attemptService AttemptService, maintenanceService MaintenanceService) AttemptSweeper {
+	return &attemptSweeper{
+		attemptService:     attemptService,
+		maintenanceService: maintenanceService,
+		stop:               make(chan struct{}),
+		done:               make(chan struct{}),
+	}
+}
+
+// Start 在后台启动调度循环
+func (s *attemptSweeper) Start() {
+	go s.loop()
+}
+
+// Stop 停止调度循环，并等待正在进行的自动交卷完成
+func (s *attemptSweeper) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// loop 调度循环，启动时立即检查一次，之后每隔 attemptSweepInterval 检查一次
+func (s *attemptSweeper) loop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(attemptSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		s.sweep()
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweep 提交超时的作答，维护模式下跳过本次检查
+func (s *attemptSweeper) sweep() {
+	if err := s.maintenanceService.Enter(); err != nil {
+		return
+	}
+	defer s.maintenanceService.Leave()
+
+	if count, err := s.attemptService.ExpireOverdue(); err != nil {
+		log.Printf("检查超时作答失败: %v", err)
+	} else if count > 0 {
+		log.Printf("已自动提交 %d 个超时作答", count)
+	}
+}
@@ -3,32 +3,83 @@ package services
 import (
 	"errors"
 	"fmt"
+	"log"
+	"sync"
+	"time"
 
 	"github.com/exam-approval-system/models"
 	"github.com/exam-approval-system/repositories"
+	"github.com/exam-approval-system/utils"
 )
 
+// SessionTokenTTL 会话令牌有效期
+const SessionTokenTTL = 24 * time.Hour
+
+// loginLockoutDuration 连续登录失败达到上限后的锁定时长
+const loginLockoutDuration = 15 * time.Minute
+
+// sessionTouchInterval 最近活动时间的最小刷新间隔，避免每个请求都写库
+const sessionTouchInterval = time.Minute
+
+// LoginResult 登录结果
+type LoginResult struct {
+	User      *models.User
+	Session   *models.Session
+	Token     string
+	ExpiresAt time.Time
+}
+
 // AuthService 认证服务接口
 type AuthService interface {
-	Login(username, password, role string) (*models.User, error)
+	Login(username, password, role, device, ip string) (*LoginResult, error)
 	Register(user *models.User) error
 	GetUserProfile(userID uint) (*models.User, error)
+	Authenticate(token string) (*models.User, *models.Session, error)
+	Logout(token string) error
+	ListSessions(userID uint) ([]models.Session, error)
+	RevokeSession(userID, sessionID uint) error
+	RevokeAllSessions(userID uint) (int64, error)
+}
+
+// loginFailure 用户连续登录失败记录
+type loginFailure struct {
+	count       int
+	lockedUntil time.Time
 }
 
 // authService 认证服务实现
 type authService struct {
-	userRepository repositories.UserRepository
+	userRepository    repositories.UserRepository
+	sessionRepository repositories.SessionRepository
+	settingsService   SettingsService
+
+	mu          sync.RWMutex
+	idleTimeout time.Duration
+	failures    map[string]*loginFailure
 }
 
 // NewAuthService 创建认证服务
-func NewAuthService(userRepo repositories.UserRepository) AuthService {
-	return &authService{
-		userRepository: userRepo,
+func NewAuthService(userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository, settingsService SettingsService) AuthService {
+	s := &authService{
+		userRepository:    userRepo,
+		sessionRepository: sessionRepo,
+		settingsService:   settingsService,
+		failures:          make(map[string]*loginFailure),
 	}
+
+	// 会话空闲超时跟随系统设置变化
+	settingsService.Subscribe(func(settings models.SystemSettings) {
+		s.setIdleTimeout(settings.SessionIdleTimeout())
+	})
+	return s
 }
 
-// Login 用户登录
-func (s *authService) Login(username, password, role string) (*models.User, error) {
+// Login 用户登录，验证通过后创建会话并签发令牌
+func (s *authService) Login(username, password, role, device, ip string) (*LoginResult, error) {
+	if until, locked := s.lockedUntil(username); locked {
+		return nil, fmt.Errorf("登录失败次数过多，请于%s后重试", until.Format("15:04"))
+	}
+
 	user, err := s.userRepository.GetByUsername(username)
 	if err != nil {
 		return nil, errors.New("用户不存在")
@@ -36,15 +87,17 @@ func (s *authService) Login(username, password, role string) (*models.User, erro
 
 	// 验证密码
 	if err := user.CheckPassword(password); err != nil {
+		s.recordFailure(username)
 		return nil, errors.New("密码错误")
 	}
+	s.clearFailures(username)
 
 	// 验证角色（仅当用户提供了角色时才验证）
 	if role != "" && user.Role != role {
 		return nil, errors.New("用户角色不匹配")
 	}
 
-	return user, nil
+	return s.createSession(user, device, ip)
 }
 
 // Register 用户注册
@@ -55,6 +108,10 @@ func (s *authService) Register(user *models.User) error {
 		return errors.New("用户名已存在")
 	}
 
+	if err := ValidatePassword(s.settingsService, user.Password); err != nil {
+		return err
+	}
+
 	// 加密密码
 	if err := user.SetPassword(user.Password); err != nil {
 		return fmt.Errorf("密码加密失败: %v", err)
@@ -68,3 +125,203 @@ func (s *authService) Register(user *models.User) error {
 func (s *authService) GetUserProfile(userID uint) (*models.User, error) {
 	return s.userRepository.GetByID(userID)
 }
+
+// createSession 记录登录会话并签发对应的令牌
+func (s *authService) createSession(user *models.User, device, ip string) (*LoginResult, error) {
+	sessionID, err := utils.NewSessionID()
+	if err != nil {
+		return nil, fmt.Errorf("生成会话ID失败: %v", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(SessionTokenTTL)
+	token, err := utils.GenerateSessionToken(utils.SessionClaims{
+		SessionID: sessionID,
+		UserID:    user.ID,
+		Role:      user.Role,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("生成会话令牌失败: %v", err)
+	}
+
+	// 顺带清理已过期的会话记录
+	if err := s.sessionRepository.DeleteExpired(now); err != nil {
+		log.Printf("清理过期会话失败: %v", err)
+	}
+
+	session := &models.Session{
+		SessionID: sessionID,
+		UserID:    user.ID,
+		Device:    truncate(device, 255),
+		IP:        truncate(ip, 64),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.sessionRepository.Create(session); err != nil {
+		return nil, fmt.Errorf("保存会话失败: %v", err)
+	}
+
+	return &LoginResult{
+		User:      user,
+		Session:   session,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Authenticate 校验会话令牌并返回对应的用户和会话
+func (s *authService) Authenticate(token string) (*models.User, *models.Session, error) {
+	if token == "" {
+		return nil, nil, errors.New("未登录")
+	}
+
+	claims, err := utils.ParseSessionToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, err := s.sessionRepository.GetBySessionID(claims.SessionID)
+	if err != nil || session.UserID != claims.UserID {
+		return nil, nil, errors.New("会话不存在")
+	}
+
+	now := time.Now()
+	if !session.Active(now, s.IdleTimeout()) {
+		// 空闲超时的会话直接注销，避免之后再被激活
+		if session.RevokedAt == nil {
+			s.sessionRepository.Revoke(session.ID, now)
+		}
+		return nil, nil, errors.New("会话已失效")
+	}
+
+	// 每次都从数据库读取用户，保证角色变更和用户删除立即生效
+	user, err := s.userRepository.GetByID(claims.UserID)
+	if err != nil {
+		return nil, nil, errors.New("用户不存在")
+	}
+
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		if err := s.sessionRepository.Touch(session.ID, now); err != nil {
+			log.Printf("更新会话活动时间失败: %v", err)
+		}
+		session.LastSeenAt = now
+	}
+
+	return user, session, nil
+}
+
+// Logout 注销会话令牌
+func (s *authService) Logout(token string) error {
+	claims, err := utils.ParseSessionToken(token)
+	if err != nil {
+		// 令牌本身已失效，视为已注销
+		return nil
+	}
+
+	session, err := s.sessionRepository.GetBySessionID(claims.SessionID)
+	if err != nil {
+		return nil
+	}
+	return s.sessionRepository.Revoke(session.ID, time.Now())
+}
+
+// ListSessions 获取用户当前有效的会话
+func (s *authService) ListSessions(userID uint) ([]models.Session, error) {
+	sessions, err := s.sessionRepository.ListActiveByUser(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// 过滤掉已空闲超时但尚未被注销的会话
+	now := time.Now()
+	idleTimeout := s.IdleTimeout()
+	active := make([]models.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.Active(now, idleTimeout) {
+			active = append(active, session)
+		}
+	}
+	return active, nil
+}
+
+// RevokeSession 注销用户的指定会话
+func (s *authService) RevokeSession(userID, sessionID uint) error {
+	session, err := s.sessionRepository.GetByID(sessionID)
+	if err != nil || session.UserID != userID {
+		return errors.New("会话不存在")
+	}
+	return s.sessionRepository.Revoke(session.ID, time.Now())
+}
+
+// RevokeAllSessions 注销用户的全部会话（退出所有设备）
+func (s *authService) RevokeAllSessions(userID uint) (int64, error) {
+	return s.sessionRepository.RevokeAllByUser(userID, time.Now())
+}
+
+// IdleTimeout 获取会话空闲超时时间
+func (s *authService) IdleTimeout() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.idleTimeout
+}
+
+// setIdleTimeout 设置会话空闲超时时间
+func (s *authService) setIdleTimeout(timeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idleTimeout = timeout
+}
+
+// lockedUntil 判断用户名是否因连续登录失败而被锁定
+func (s *authService) lockedUntil(username string) (time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	failure, ok := s.failures[username]
+	if !ok || time.Now().After(failure.lockedUntil) {
+		return time.Time{}, false
+	}
+	return failure.lockedUntil, true
+}
+
+// recordFailure 记录一次登录失败，达到系统设置的上限后锁定该用户名
+func (s *authService) recordFailure(username string) {
+	maxAttempts := s.settingsService.Get().MaxLoginAttempts
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	failure, ok := s.failures[username]
+	if !ok {
+		failure = &loginFailure{}
+		s.failures[username] = failure
+	}
+	failure.count++
+	if failure.count >= maxAttempts {
+		failure.count = 0
+		failure.lockedUntil = time.Now().Add(loginLockoutDuration)
+	}
+}
+
+// clearFailures 登录成功后清除失败记录
+func (s *authService) clearFailures(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, username)
+}
+
+// ValidatePassword 按系统设置校验密码强度
+func ValidatePassword(settingsService SettingsService, password string) error {
+	minLength := settingsService.Get().MinPasswordLength
+	if len([]rune(password)) < minLength {
+		return fmt.Errorf("密码长度不能少于%d位", minLength)
+	}
+	return nil
+}
+
+// truncate 按字节截断字符串以适配字段长度
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	return value[:max]
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+)
+
+const (
+	// backupCheckInterval 检查是否需要执行定时备份的间隔
+	backupCheckInterval = time.Minute
+	// backupRetryDelay 定时备份失败后的重试间隔
+	backupRetryDelay = time.Hour
+)
+
+// BackupScheduler 定时备份调度器接口
+type BackupScheduler interface {
+	Start()
+	Stop()
+	NextRun() (time.Time, bool)
+}
+
+// backupScheduler 定时备份调度器实现，按系统设置中的 auto_backup 与 backup_frequency 执行备份
+type backupScheduler struct {
+	backupService      BackupService
+	settingsService    SettingsService
+	runRepository      repositories.BackupRunRepository
+	maintenanceService MaintenanceService
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewBackupScheduler 创建定时备份调度器
+func NewBackupScheduler(backupService BackupService, settingsService SettingsService, runRepo repositories.BackupRunRepository, maintenanceService MaintenanceService) BackupScheduler {
+	return &backupScheduler{
+		backupService:      backupService,
+		settingsService:    settingsService,
+		runRepository:      runRepo,
+		maintenanceService: maintenanceService,
+		stop:               make(chan struct{}),
+		done:               make(chan struct{}),
+	}
+}
+
+// Start 在后台启动调度循环
+func (s *backupScheduler) Start() {
+	go s.loop()
+}
+
+// Stop 停止调度循环，并等待正在执行的备份完成
+func (s *backupScheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// NextRun 返回下一次定时备份的时间，自动备份关闭或数据库不支持备份时第二个返回值为false
+func (s *backupScheduler) NextRun() (time.Time, bool) {
+	settings := s.settingsService.Get()
+	if !settings.AutoBackup || !configs.Database.IsSQLite() {
+		return time.Time{}, false
+	}
+
+	next := time.Now()
+	if last, err := s.runRepository.GetLatestSuccess(); err == nil {
+		if t, err := models.NextBackupTime(settings.BackupFrequency, last.StartedAt); err == nil {
+			next = t
+		}
+	}
+	// 上次执行失败时按重试间隔推迟
+	if latest, err := s.runRepository.GetLatest(); err == nil && latest.Status == models.BackupRunFailed {
+		if retry := latest.StartedAt.Add(backupRetryDelay); retry.After(next) {
+			next = retry
+		}
+	}
+	return next, true
+}
+
+// loop 调度循环，启动时立即检查一次，之后每隔 backupCheckInterval 检查一次
+func (s *backupScheduler) loop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(backupCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		s.check()
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check 到达定时备份时间时执行备份，维护模式下跳过本次检查
+func (s *backupScheduler) check() {
+	if err := s.maintenanceService.Enter(); err != nil {
+		return
+	}
+	defer s.maintenanceService.Leave()
+
+	if next, enabled := s.NextRun(); enabled && !time.Now().Before(next) {
+		s.run()
+	}
+}
+
+// run 执行一次定时备份并记录结果
+func (s *backupScheduler) run() {
+	run := &models.BackupRun{
+		Frequency: s.settingsService.Get().BackupFrequency,
+		StartedAt: time.Now(),
+	}
+
+	backup, err := s.backupService.Create(0, "定时自动备份")
+	run.FinishedAt = time.Now()
+	if err != nil {
+		run.Status = models.BackupRunFailed
+		run.Error = err.Error()
+		log.Printf("定时备份失败: %v", err)
+	} else {
+		run.Status = models.BackupRunSuccess
+		run.BackupID = backup.ID
+		log.Printf("定时备份完成: %s", backup.ID)
+	}
+
+	if err := s.runRepository.Create(run); err != nil {
+		log.Printf("记录定时备份结果失败: %v", err)
+	}
+}
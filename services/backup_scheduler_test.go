@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+)
+
+// stubBackupService 记录定时备份调用次数的备份服务替身，err不为空时备份失败
+type stubBackupService struct {
+	BackupService
+	err     error
+	created int
+}
+
+func (s *stubBackupService) Create(createdBy uint, note string) (*BackupInfo, error) {
+	s.created++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &BackupInfo{ID: "backup_20260101_000000_000"}, nil
+}
+
+func TestBackupSchedulerCheck(t *testing.T) {
+	ago := func(d time.Duration) time.Time { return time.Now().Add(-d) }
+
+	tests := []struct {
+		name        string
+		autoBackup  bool
+		runs        []models.BackupRun // 已有的执行记录
+		maintenance bool
+		backupErr   error
+		wantRun     string // 本次检查新增的执行记录状态，为空表示不执行备份
+	}{
+		{name: "自动备份关闭", autoBackup: false},
+		{name: "从未备份", autoBackup: true, wantRun: models.BackupRunSuccess},
+		{
+			name:       "未到下次备份时间",
+			autoBackup: true,
+			runs:       []models.BackupRun{{Status: models.BackupRunSuccess, StartedAt: ago(12 * time.Hour)}},
+		},
+		{
+			name:       "已到下次备份时间",
+			autoBackup: true,
+			runs:       []models.BackupRun{{Status: models.BackupRunSuccess, StartedAt: ago(25 * time.Hour)}},
+			wantRun:    models.BackupRunSuccess,
+		},
+		{
+			name:       "失败后未到重试时间",
+			autoBackup: true,
+			runs: []models.BackupRun{
+				{Status: models.BackupRunSuccess, StartedAt: ago(48 * time.Hour)},
+				{Status: models.BackupRunFailed, StartedAt: ago(10 * time.Minute)},
+			},
+		},
+		{
+			name:       "失败后已到重试时间",
+			autoBackup: true,
+			runs: []models.BackupRun{
+				{Status: models.BackupRunSuccess, StartedAt: ago(48 * time.Hour)},
+				{Status: models.BackupRunFailed, StartedAt: ago(2 * time.Hour)},
+			},
+			wantRun: models.BackupRunSuccess,
+		},
+		{name: "维护模式下跳过", autoBackup: true, maintenance: true},
+		{name: "备份失败时记录错误", autoBackup: true, backupErr: errors.New("磁盘已满"), wantRun: models.BackupRunFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			changes := map[string]interface{}{"auto_backup": tt.autoBackup, "backup_frequency": models.BackupDaily}
+			if _, err := env.settings.Update(changes, 0); err != nil {
+				t.Fatal(err)
+			}
+			runs := repositories.NewBackupRunRepository()
+			for i := range tt.runs {
+				if err := runs.Create(&tt.runs[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			maintenance := NewMaintenanceService()
+			if tt.maintenance {
+				if err := maintenance.Begin(time.Second); err != nil {
+					t.Fatal(err)
+				}
+			}
+			backups := &stubBackupService{err: tt.backupErr}
+			scheduler := NewBackupScheduler(backups, env.settings, runs, maintenance).(*backupScheduler)
+
+			scheduler.check()
+
+			recent, err := runs.ListRecent(10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantRun == "" {
+				if backups.created != 0 || len(recent) != len(tt.runs) {
+					t.Fatalf("执行备份 %d 次, 执行记录 %d 条, 期望不执行备份", backups.created, len(recent))
+				}
+				return
+			}
+			if backups.created != 1 || len(recent) != len(tt.runs)+1 {
+				t.Fatalf("执行备份 %d 次, 执行记录 %d 条, 期望执行一次备份", backups.created, len(recent))
+			}
+			latest, err := runs.GetLatest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if latest.Status != tt.wantRun || latest.Frequency != models.BackupDaily {
+				t.Errorf("执行记录 = %+v, 期望状态 %s", latest, tt.wantRun)
+			}
+			if tt.backupErr != nil && latest.Error != tt.backupErr.Error() {
+				t.Errorf("错误信息 = %q, 期望 %q", latest.Error, tt.backupErr.Error())
+			}
+			if tt.backupErr == nil && latest.BackupID == "" {
+				t.Error("成功的执行记录没有备份ID")
+			}
+		})
+	}
+}
@@ -0,0 +1,459 @@
+package services
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/repositories"
+)
+
+// 备份状态常量
+const (
+	BackupStatusCompleted = "completed" // 快照文件完整
+	BackupStatusMissing   = "missing"   // 清单存在但快照文件丢失或大小不符
+)
+
+// 备份相关错误
+var (
+	ErrBackupNotFound = errors.New("备份不存在")
+	ErrBackupInvalid  = errors.New("备份文件校验失败")
+	// ErrBackupUnsupported 备份基于SQLite文件快照，其他数据库请使用其自带的备份工具
+	ErrBackupUnsupported = errors.New("当前数据库不支持在线备份，仅支持SQLite")
+)
+
+// restoreDrainTimeout 恢复备份前等待进行中的请求结束的最长时间，测试时缩短
+var restoreDrainTimeout = 30 * time.Second
+
+// backupIDPattern 备份ID格式，同时用于防止路径穿越
+var backupIDPattern = regexp.MustCompile(`^backup_\d{8}_\d{6}_\d{3}$`)
+
+// BackupInfo 备份清单，与快照文件一起保存为 <id>.json
+type BackupInfo struct {
+	ID        string    `json:"id"`
+	FileName  string    `json:"file_name"`
+	Size      int64     `json:"size"`
+	Checksum  string    `json:"checksum"` // 快照文件的SHA-256
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy uint      `json:"created_by"`
+	Note      string    `json:"note"`
+	Status    string    `json:"status"`
+}
+
+// BackupService 数据库备份服务接口
+type BackupService interface {
+	Create(createdBy uint, note string) (*BackupInfo, error)
+	List() ([]BackupInfo, error)
+	Get(id string) (*BackupInfo, string, error)
+	Restore(id string, restoredBy uint) (*BackupInfo, error)
+}
+
+// backupService 数据库备份服务实现，备份、清理和恢复操作串行执行
+type backupService struct {
+	dir                string
+	sessionRepository  repositories.SessionRepository
+	settingsService    SettingsService
+	maintenanceService MaintenanceService
+	mu                 sync.Mutex
+}
+
+// NewBackupService 创建数据库备份服务，备份目录不存在时自动创建
+func NewBackupService(dir string, sessionRepo repositories.SessionRepository, settingsService SettingsService,
+	maintenanceService MaintenanceService) (BackupService, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("创建备份目录失败: %v", err)
+	}
+	return &backupService{
+		dir:                dir,
+		sessionRepository:  sessionRepo,
+		settingsService:    settingsService,
+		maintenanceService: maintenanceService,
+	}, nil
+}
+
+// Create 创建数据库快照，并按系统设置中的保留数量清理旧备份
+func (s *backupService) Create(createdBy uint, note string) (*BackupInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := s.create(createdBy, note)
+	if err != nil {
+		return nil, err
+	}
+	s.prune(s.settingsService.Get().BackupCount)
+	return info, nil
+}
+
+// List 获取全部备份，按创建时间倒序排列
+func (s *backupService) List() ([]BackupInfo, error) {
+	manifests, err := filepath.Glob(filepath.Join(s.dir, "backup_*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]BackupInfo, 0, len(manifests))
+	for _, manifest := range manifests {
+		id := strings.TrimSuffix(filepath.Base(manifest), ".json")
+		info, err := s.readManifest(id)
+		if err != nil {
+			log.Printf("忽略无法读取的备份清单 %s: %v", manifest, err)
+			continue
+		}
+		backups = append(backups, *info)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// Get 获取备份清单及快照文件路径
+func (s *backupService) Get(id string) (*BackupInfo, string, error) {
+	info, err := s.readManifest(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if info.Status != BackupStatusCompleted {
+		return nil, "", ErrBackupInvalid
+	}
+	return info, s.snapshotPath(id), nil
+}
+
+// Restore 校验快照后替换当前数据库，替换前会自动备份当前数据库，返回该自动备份。
+// 恢复期间处于维护模式，替换前等待进行中的请求和后台任务结束，避免它们使用已关闭的连接或写入被覆盖的数据。
+// 快照中的登录会话可能已在备份后注销，恢复后清除全部会话，所有用户需要重新登录
+func (s *backupService) Restore(id string, restoredBy uint) (*BackupInfo, error) {
+	if !configs.Database.IsSQLite() {
+		return nil, ErrBackupUnsupported
+	}
+	info, path, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySnapshot(info, path); err != nil {
+		return nil, err
+	}
+
+	// 先进入维护模式再加锁，定时备份在维护模式下不会开始，已开始的可以完成
+	if err := s.maintenanceService.Begin(restoreDrainTimeout); err != nil {
+		return nil, err
+	}
+	defer s.maintenanceService.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 恢复前先备份当前数据库，便于回退
+	restorePoint, err := s.create(restoredBy, "恢复 "+id+" 前的自动备份")
+	if err != nil {
+		return nil, fmt.Errorf("创建恢复前备份失败: %v", err)
+	}
+
+	if err := swapDatabase(path); err != nil {
+		log.Printf("恢复备份 %s 失败，回退到 %s: %v", id, restorePoint.ID, err)
+		if rollbackErr := swapDatabase(s.snapshotPath(restorePoint.ID)); rollbackErr != nil {
+			return nil, fmt.Errorf("恢复失败且无法回退: %v", rollbackErr)
+		}
+		return nil, fmt.Errorf("恢复失败，已回退: %v", err)
+	}
+
+	// 数据库已整体替换，重新加载缓存的设置
+	if err := s.settingsService.Reload(); err != nil {
+		log.Printf("恢复备份后重新加载系统设置失败: %v", err)
+	}
+	if err := s.sessionRepository.DeleteAll(); err != nil {
+		return restorePoint, fmt.Errorf("已从备份 %s 恢复数据库，但清除登录会话失败: %v", id, err)
+	}
+	log.Printf("已从备份 %s 恢复数据库", id)
+	return restorePoint, nil
+}
+
+// create 使用 VACUUM INTO 生成一致性快照并写入清单
+func (s *backupService) create(createdBy uint, note string) (*BackupInfo, error) {
+	if !configs.Database.IsSQLite() {
+		return nil, ErrBackupUnsupported
+	}
+
+	now := time.Now()
+	id := fmt.Sprintf("backup_%s_%03d", now.Format("20060102_150405"), now.Nanosecond()/int(time.Millisecond))
+	path := s.snapshotPath(id)
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("备份 %s 已存在", id)
+	}
+
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		return nil, fmt.Errorf("创建备份目录失败: %v", err)
+	}
+
+	// VACUUM INTO 要求目标文件不存在
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if err := configs.DB().Exec("VACUUM INTO ?", tmp).Error; err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("生成数据库快照失败: %v", err)
+	}
+
+	checksum, size, err := fileChecksum(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("计算备份校验和失败: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	info := &BackupInfo{
+		ID:        id,
+		FileName:  id + ".db",
+		Size:      size,
+		Checksum:  checksum,
+		CreatedAt: now,
+		CreatedBy: createdBy,
+		Note:      note,
+		Status:    BackupStatusCompleted,
+	}
+	if err := s.writeManifest(info); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("写入备份清单失败: %v", err)
+	}
+	return info, nil
+}
+
+// prune 只保留最新的 keep 份备份
+func (s *backupService) prune(keep int) {
+	backups, err := s.List()
+	if err != nil {
+		log.Printf("清理旧备份失败: %v", err)
+		return
+	}
+	for i := keep; i < len(backups); i++ {
+		id := backups[i].ID
+		if err := os.Remove(s.snapshotPath(id)); err != nil && !os.IsNotExist(err) {
+			log.Printf("删除备份 %s 失败: %v", id, err)
+			continue
+		}
+		os.Remove(s.manifestPath(id))
+		log.Printf("已清理旧备份 %s", id)
+	}
+}
+
+// readManifest 读取备份清单，并根据快照文件是否完整设置状态
+func (s *backupService) readManifest(id string) (*BackupInfo, error) {
+	if !backupIDPattern.MatchString(id) {
+		return nil, ErrBackupNotFound
+	}
+
+	data, err := os.ReadFile(s.manifestPath(id))
+	if os.IsNotExist(err) {
+		return nil, ErrBackupNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var info BackupInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	if info.ID != id {
+		return nil, ErrBackupInvalid
+	}
+
+	info.Status = BackupStatusCompleted
+	if stat, err := os.Stat(s.snapshotPath(id)); err != nil || stat.Size() != info.Size {
+		info.Status = BackupStatusMissing
+	}
+	return &info, nil
+}
+
+// writeManifest 先写临时文件再重命名，避免留下不完整的清单
+func (s *backupService) writeManifest(info *BackupInfo) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.manifestPath(info.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.manifestPath(info.ID))
+}
+
+// snapshotPath 返回快照文件路径
+func (s *backupService) snapshotPath(id string) string {
+	return filepath.Join(s.dir, id+".db")
+}
+
+// manifestPath 返回清单文件路径
+func (s *backupService) manifestPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// verifySnapshot 校验快照的校验和、完整性，以及迁移版本与当前数据库一致并包含当前数据库的全部表
+func verifySnapshot(info *BackupInfo, path string) error {
+	checksum, _, err := fileChecksum(path)
+	if err != nil {
+		return err
+	}
+	if checksum != info.Checksum {
+		return fmt.Errorf("%w: 校验和不匹配", ErrBackupInvalid)
+	}
+
+	snapshot, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	var result string
+	if err := snapshot.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("%w: %v", ErrBackupInvalid, err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("%w: 完整性检查未通过: %s", ErrBackupInvalid, result)
+	}
+
+	// 不同迁移版本的快照与当前程序的表结构不一致，不能直接替换
+	currentVersion, err := schemaVersion(configs.DB().DB())
+	if err != nil {
+		return err
+	}
+	snapshotVersion, err := schemaVersion(snapshot)
+	if err != nil {
+		return fmt.Errorf("%w: 读取迁移版本失败: %v", ErrBackupInvalid, err)
+	}
+	if snapshotVersion != currentVersion {
+		return fmt.Errorf("%w: 备份的数据库迁移版本为 %d，当前为 %d，请使用对应版本的程序恢复", ErrBackupInvalid, snapshotVersion, currentVersion)
+	}
+
+	current, err := listTables(configs.DB().DB())
+	if err != nil {
+		return err
+	}
+	tables, err := listTables(snapshot)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBackupInvalid, err)
+	}
+	for name := range current {
+		if !tables[name] {
+			return fmt.Errorf("%w: 缺少数据表 %s，与当前数据库结构不兼容", ErrBackupInvalid, name)
+		}
+	}
+	return nil
+}
+
+// schemaVersion 读取数据库已执行的最高迁移版本
+func schemaVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// listTables 列出SQLite数据库中的用户表
+func listTables(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables[name] = true
+	}
+	return tables, rows.Err()
+}
+
+// swapDatabase 关闭当前连接，用快照替换数据库文件后重新连接，调用前需进入维护模式。
+// WAL模式下先把日志写回数据库文件，关闭后删除旧数据库遗留的-wal和-shm文件，避免重新连接时应用到新文件上
+func swapDatabase(snapshotPath string) error {
+	dbPath := configs.Database.SQLitePath()
+	tmp := dbPath + ".restore"
+	if err := copyFile(snapshotPath, tmp); err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if err := configs.DB().Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error; err != nil {
+		return fmt.Errorf("写回WAL日志失败: %v", err)
+	}
+	configs.DB().Close()
+	swapErr := removeSidecars(dbPath)
+	if swapErr == nil {
+		swapErr = os.Rename(tmp, dbPath)
+	}
+
+	// 无论替换是否成功都重新打开连接
+	db, err := configs.OpenDB()
+	if err != nil {
+		return err
+	}
+	configs.SetDB(db)
+	return swapErr
+}
+
+// removeSidecars 删除SQLite数据库的WAL日志、共享内存和回滚日志文件
+func removeSidecars(dbPath string) error {
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile 复制文件并同步到磁盘
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// fileChecksum 计算文件的SHA-256校验和及大小
+func fileChecksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
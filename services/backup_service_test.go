@@ -0,0 +1,143 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+)
+
+func TestBackupRestore(t *testing.T) {
+	tests := []struct {
+		name string
+		// 修改快照后重新计算校验和，模拟其他版本程序生成的备份
+		modify  string
+		busy    bool // 恢复时是否有未结束的请求
+		wal     bool // 数据库是否使用WAL模式
+		wantErr string
+	}{
+		{name: "恢复备份"},
+		{name: "WAL模式", wal: true},
+		{name: "迁移版本较新", modify: "INSERT INTO schema_migrations (version, name, applied_at) VALUES (9999, 'future', CURRENT_TIMESTAMP)", wantErr: "迁移版本为 9999"},
+		{name: "迁移版本较旧", modify: "DELETE FROM schema_migrations WHERE version = (SELECT MAX(version) FROM schema_migrations)", wantErr: "请使用对应版本的程序恢复"},
+		{name: "有请求未结束", busy: true, wantErr: ErrMaintenanceBusy.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			maintenance := NewMaintenanceService()
+			service, err := NewBackupService(t.TempDir(), repositories.NewSessionRepository(), env.settings, maintenance)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wal {
+				execSQL(t, "PRAGMA journal_mode=WAL")
+			}
+			kept := env.user(t, models.RoleTeacher)
+			// 快照中的会话在恢复后应被清除
+			sessions := repositories.NewSessionRepository()
+			if err := sessions.Create(&models.Session{SessionID: "s1", UserID: kept.ID, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+				t.Fatal(err)
+			}
+			backup, path, err := createBackup(service)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.modify != "" {
+				rewriteSnapshot(t, service, backup, path, tt.modify)
+			}
+			added := env.user(t, models.RoleTeacher)
+			if tt.busy {
+				maintenance.Enter()
+				defer maintenance.Leave()
+				saved := restoreDrainTimeout
+				restoreDrainTimeout = 50 * time.Millisecond
+				defer func() { restoreDrainTimeout = saved }()
+			}
+
+			restorePoint, err := service.Restore(backup.ID, kept.ID)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Restore() error = %v, 期望包含 %q", err, tt.wantErr)
+				}
+				if _, err := env.users.GetByID(added.ID); err != nil {
+					t.Errorf("恢复失败后数据库被替换: %v", err)
+				}
+				if maintenance.Active() {
+					t.Error("恢复失败后仍处于维护模式")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restorePoint == nil || maintenance.Active() {
+				t.Errorf("恢复前备份 = %v, 维护模式 = %v", restorePoint, maintenance.Active())
+			}
+			if _, err := env.users.GetByID(kept.ID); err != nil {
+				t.Errorf("备份中的用户不存在: %v", err)
+			}
+			if _, err := env.users.GetByID(added.ID); err == nil {
+				t.Error("备份后创建的用户在恢复后仍存在")
+			}
+			if _, err := sessions.GetBySessionID("s1"); err == nil {
+				t.Error("恢复后仍保留备份中的登录会话")
+			}
+			for _, suffix := range []string{"-wal", "-shm"} {
+				if _, err := os.Stat(configs.Database.SQLitePath() + suffix); err == nil {
+					t.Errorf("恢复后仍存在旧数据库的%s文件", suffix)
+				}
+			}
+		})
+	}
+}
+
+// createBackup 创建备份并返回快照文件路径
+func createBackup(service BackupService) (*BackupInfo, string, error) {
+	info, err := service.Create(0, "测试")
+	if err != nil {
+		return nil, "", err
+	}
+	_, path, err := service.Get(info.ID)
+	return info, path, err
+}
+
+// rewriteSnapshot 在快照中执行SQL并更新清单中的校验和
+func rewriteSnapshot(t *testing.T, service BackupService, info *BackupInfo, path, query string) {
+	t.Helper()
+	snapshot, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snapshot.Exec(query); err != nil {
+		t.Fatal(err)
+	}
+	snapshot.Close()
+
+	if info.Checksum, info.Size, err = fileChecksum(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.(*backupService).writeManifest(info); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackupRestoreRequiresSQLite(t *testing.T) {
+	saved := configs.Database
+	defer func() { configs.Database = saved }()
+	configs.Database = configs.DatabaseConfig{Driver: configs.DriverPostgres, DSN: "host=db"}
+
+	service, err := NewBackupService(t.TempDir(), nil, nil, NewMaintenanceService())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Restore("backup_20240101_000000_000", 1); !errors.Is(err, ErrBackupUnsupported) {
+		t.Errorf("Restore() error = %v, 期望 %v", err, ErrBackupUnsupported)
+	}
+}
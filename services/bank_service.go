@@ -0,0 +1,263 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+	"github.com/jinzhu/gorm"
+)
+
+// maxBankTags 每道题目每种类型最多的标签数量
+const maxBankTags = 20
+
+var (
+	// ErrBankQuestionNotFound 题库题目不存在或对当前教师不可见
+	ErrBankQuestionNotFound = errors.New("题库题目不存在")
+	// ErrBankQuestionForbidden 只有题目的创建者才能修改或删除
+	ErrBankQuestionForbidden = errors.New("只能修改或删除自己创建的题目")
+)
+
+// QuestionBankService 题库服务接口
+type QuestionBankService interface {
+	Create(question *models.BankQuestion) error
+	Get(id, viewerID uint) (*models.BankQuestion, error)
+	Update(id, editorID uint, changes *models.BankQuestion) (*models.BankQuestion, error)
+	Delete(id, userID uint) error
+	Search(filter repositories.BankQuestionFilter, page, pageSize int) ([]models.BankQuestion, int, error)
+	Versions(id, viewerID uint) ([]models.BankQuestionVersion, error)
+	Tags(viewerID uint, kind string) ([]models.BankTagCount, error)
+	ResolveQuestions(questions []models.Question, exam *models.Exam) error
+	Generate(blueprint *models.Blueprint, exam *models.Exam) ([]models.Question, error)
+}
+
+// questionBankService 题库服务实现
+type questionBankService struct {
+	bankRepository repositories.BankRepository
+}
+
+// NewQuestionBankService 创建题库服务
+func NewQuestionBankService(bankRepo repositories.BankRepository) QuestionBankService {
+	return &questionBankService{
+		bankRepository: bankRepo,
+	}
+}
+
+// Create 校验并创建题库题目，question.Question为完整题目，OwnerID为创建的教师
+func (s *questionBankService) Create(question *models.BankQuestion) error {
+	if err := s.prepare(question); err != nil {
+		return err
+	}
+	question.ID = 0
+	return s.bankRepository.Create(question, question.OwnerID)
+}
+
+// Get 获取题库题目及当前版本的完整题目
+func (s *questionBankService) Get(id, viewerID uint) (*models.BankQuestion, error) {
+	question, err := s.find(id, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if question.Question, err = models.ParseBankBody(question.Body); err != nil {
+		return nil, err
+	}
+	return question, nil
+}
+
+// Update 修改题库题目，题目内容、答案或评分规则变化时生成新版本，
+// 只修改科目、共享、难度和标签时版本号不变
+func (s *questionBankService) Update(id, editorID uint, changes *models.BankQuestion) (*models.BankQuestion, error) {
+	question, err := s.find(id, editorID)
+	if err != nil {
+		return nil, err
+	}
+	if question.OwnerID != editorID {
+		return nil, ErrBankQuestionForbidden
+	}
+
+	changes.OwnerID = question.OwnerID
+	if err := s.prepare(changes); err != nil {
+		return nil, err
+	}
+	newVersion := changes.Body != question.Body
+
+	question.Course = changes.Course
+	question.Shared = changes.Shared
+	question.Type = changes.Type
+	question.Content = changes.Content
+	question.Score = changes.Score
+	question.Difficulty = changes.Difficulty
+	question.Body = changes.Body
+	question.Tags = changes.Tags
+	question.Question = changes.Question
+	if err := s.bankRepository.Update(question, editorID, newVersion); err != nil {
+		return nil, err
+	}
+	return question, nil
+}
+
+// Delete 删除题库题目，已引用该题目的试卷不受影响
+func (s *questionBankService) Delete(id, userID uint) error {
+	question, err := s.find(id, userID)
+	if err != nil {
+		return err
+	}
+	if question.OwnerID != userID {
+		return ErrBankQuestionForbidden
+	}
+	return s.bankRepository.Delete(id)
+}
+
+// Search 分页搜索教师可见的题库题目，page从1开始
+func (s *questionBankService) Search(filter repositories.BankQuestionFilter, page, pageSize int) ([]models.BankQuestion, int, error) {
+	filter.Course = strings.TrimSpace(filter.Course)
+	filter.Keyword = strings.TrimSpace(filter.Keyword)
+	filter.Topics = normalizeTagNames(filter.Topics)
+	filter.KnowledgePoints = normalizeTagNames(filter.KnowledgePoints)
+	if filter.MinDifficulty > 0 && filter.MaxDifficulty > 0 && filter.MinDifficulty > filter.MaxDifficulty {
+		return nil, 0, errors.New("最低难度不能高于最高难度")
+	}
+	return s.bankRepository.Search(filter, (page-1)*pageSize, pageSize)
+}
+
+// Versions 获取题库题目的全部版本及各版本的完整题目
+func (s *questionBankService) Versions(id, viewerID uint) ([]models.BankQuestionVersion, error) {
+	if _, err := s.find(id, viewerID); err != nil {
+		return nil, err
+	}
+	versions, err := s.bankRepository.ListVersions(id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		if versions[i].Question, err = models.ParseBankBody(versions[i].Body); err != nil {
+			return nil, err
+		}
+	}
+	return versions, nil
+}
+
+// Tags 统计教师可见题目的标签
+func (s *questionBankService) Tags(viewerID uint, kind string) ([]models.BankTagCount, error) {
+	if kind != "" && kind != models.BankTagTopic && kind != models.BankTagKnowledgePoint {
+		return nil, fmt.Errorf("未知的标签类型: %s", kind)
+	}
+	return s.bankRepository.ListTags(viewerID, kind)
+}
+
+// ResolveQuestions 用题库内容填充试卷中只提供了题库题目ID的题目。
+// 未指定版本时使用当前版本；试卷中填写了分值时覆盖题库的默认分值。
+// 题目只能来自考试创建者自己的题库或同一科目下共享的题目
+func (s *questionBankService) ResolveQuestions(questions []models.Question, exam *models.Exam) error {
+	for i := range questions {
+		question := &questions[i]
+		if question.BankQuestionID == 0 || strings.TrimSpace(question.Content) != "" {
+			continue
+		}
+
+		bankQuestion, err := s.bankRepository.GetByID(question.BankQuestionID)
+		if err != nil || !bankQuestion.UsableIn(exam.CreatorID, exam.Course) {
+			return fmt.Errorf("第%d题: 题库题目 %d 不存在或不能在本科目中使用", i+1, question.BankQuestionID)
+		}
+		version := question.BankVersion
+		if version == 0 {
+			version = bankQuestion.Version
+		}
+		snapshot, err := s.bankRepository.GetVersion(bankQuestion.ID, version)
+		if err != nil {
+			return fmt.Errorf("第%d题: 题库题目 %d 没有版本 %d", i+1, bankQuestion.ID, version)
+		}
+		resolved, err := models.ParseBankBody(snapshot.Body)
+		if err != nil {
+			return err
+		}
+
+		resolved.ID = question.ID
+		resolved.BankQuestionID = bankQuestion.ID
+		resolved.BankVersion = version
+		if question.Score > 0 {
+			resolved.Score = question.Score
+		}
+		*question = *resolved
+	}
+	return nil
+}
+
+// find 获取教师可见的题库题目
+func (s *questionBankService) find(id, viewerID uint) (*models.BankQuestion, error) {
+	question, err := s.bankRepository.GetByID(id)
+	if gorm.IsRecordNotFoundError(err) || (err == nil && !question.VisibleTo(viewerID)) {
+		return nil, ErrBankQuestionNotFound
+	}
+	return question, err
+}
+
+// prepare 校验题目、难度和标签，并生成题库中保存的题目JSON和列表字段
+func (s *questionBankService) prepare(question *models.BankQuestion) error {
+	question.Course = strings.TrimSpace(question.Course)
+	if question.Course == "" {
+		return errors.New("科目不能为空")
+	}
+	if question.Difficulty == 0 {
+		question.Difficulty = 3
+	}
+	if question.Difficulty < models.MinDifficulty || question.Difficulty > models.MaxDifficulty {
+		return fmt.Errorf("难度必须在%d-%d之间", models.MinDifficulty, models.MaxDifficulty)
+	}
+	if question.Question == nil {
+		return errors.New("题目不能为空")
+	}
+
+	body := *question.Question
+	body.Content = strings.TrimSpace(body.Content)
+	body.Answer = strings.TrimSpace(body.Answer)
+	if err := validateQuestion(&body); err != nil {
+		return err
+	}
+
+	tags := make([]models.BankTag, 0, len(question.Tags))
+	counts := make(map[string]int)
+	seen := make(map[models.BankTag]bool)
+	for _, tag := range question.Tags {
+		tag = models.BankTag{Kind: tag.Kind, Name: strings.TrimSpace(tag.Name)}
+		if tag.Kind != models.BankTagTopic && tag.Kind != models.BankTagKnowledgePoint {
+			return fmt.Errorf("未知的标签类型: %s", tag.Kind)
+		}
+		if tag.Name == "" || len([]rune(tag.Name)) > 50 {
+			return errors.New("标签名称不能为空且不能超过50个字符")
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		if counts[tag.Kind]++; counts[tag.Kind] > maxBankTags {
+			return fmt.Errorf("每种标签最多%d个", maxBankTags)
+		}
+		tags = append(tags, tag)
+	}
+
+	data, err := models.BankBody(body)
+	if err != nil {
+		return err
+	}
+	question.Body = data
+	question.Question = &body
+	question.Type = body.Type
+	question.Content = body.Content
+	question.Score = body.Score
+	question.Tags = tags
+	return nil
+}
+
+// normalizeTagNames 去除标签名称首尾空白并忽略空名称
+func normalizeTagNames(names []string) []string {
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+)
+
+// bankQuestion 创建教师题库中的判断题
+func (e *testEnv) bankQuestion(t *testing.T, owner *models.User, course, content string, shared bool, difficulty int, topics ...string) *models.BankQuestion {
+	t.Helper()
+	question := &models.BankQuestion{
+		OwnerID: owner.ID, Course: course, Shared: shared, Difficulty: difficulty,
+		Question: &models.Question{Type: models.QuestionTrueFalse, Content: content, Score: 2, Answer: "true"},
+	}
+	for _, topic := range topics {
+		question.Tags = append(question.Tags, models.BankTag{Kind: models.BankTagTopic, Name: topic})
+	}
+	if err := e.bank.Create(question); err != nil {
+		t.Fatal(err)
+	}
+	return question
+}
+
+func TestQuestionBankUpdate(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user(t, models.RoleTeacher)
+	other := env.user(t, models.RoleTeacher)
+	question := env.bankQuestion(t, owner, "数学", "1是奇数", false, 2, "数论")
+
+	tests := []struct {
+		name        string
+		editor      *models.User
+		change      func(q *models.Question) // 修改题目内容，为空时只修改标签和难度
+		wantErr     error
+		wantVersion int
+	}{
+		{name: "其他教师不能查看未共享的题目", editor: other, wantErr: ErrBankQuestionNotFound, wantVersion: 1},
+		{name: "只修改标签和难度不产生新版本", editor: owner, wantVersion: 1},
+		{name: "修改题干产生新版本", editor: owner, change: func(q *models.Question) { q.Content = "1不是偶数" }, wantVersion: 2},
+		{name: "修改答案产生新版本", editor: owner, change: func(q *models.Question) { q.Answer = "false" }, wantVersion: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, err := env.bank.Get(question.ID, owner.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := *current.Question
+			if tt.change != nil {
+				tt.change(&body)
+			}
+			changes := &models.BankQuestion{
+				Course: "数学", Difficulty: 4, Question: &body,
+				Tags: []models.BankTag{{Kind: models.BankTagKnowledgePoint, Name: "奇偶性"}},
+			}
+
+			updated, err := env.bank.Update(question.ID, tt.editor.ID, changes)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Update() error = %v, 期望 %v", err, tt.wantErr)
+			}
+			if err == nil && (updated.Version != tt.wantVersion || updated.Difficulty != 4) {
+				t.Errorf("版本 = %d, 难度 = %d, 期望版本 %d, 难度 4", updated.Version, updated.Difficulty, tt.wantVersion)
+			}
+			versions, err := env.bank.Versions(question.ID, owner.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(versions) != tt.wantVersion {
+				t.Fatalf("版本数 = %d, 期望 %d", len(versions), tt.wantVersion)
+			}
+		})
+	}
+
+	// 历史版本保留当时的题目内容
+	versions, err := env.bank.Versions(question.ID, owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := make(map[int]string)
+	for _, version := range versions {
+		contents[version.Version] = version.Question.Content + "/" + version.Question.Answer
+	}
+	if contents[1] != "1是奇数/true" || contents[2] != "1不是偶数/true" || contents[3] != "1不是偶数/false" {
+		t.Errorf("各版本内容 = %v", contents)
+	}
+
+	if err := env.bank.Delete(question.ID, other.ID); !errors.Is(err, ErrBankQuestionNotFound) {
+		t.Errorf("其他教师删除 error = %v, 期望 %v", err, ErrBankQuestionNotFound)
+	}
+}
+
+func TestQuestionBankSearch(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user(t, models.RoleTeacher)
+	other := env.user(t, models.RoleTeacher)
+	own := env.bankQuestion(t, owner, "数学", "2是质数", false, 2, "数论", "质数")
+	shared := env.bankQuestion(t, other, "数学", "3是质数", true, 4, "数论")
+	env.bankQuestion(t, other, "数学", "4是合数", false, 3, "数论")
+	otherCourse := env.bankQuestion(t, other, "物理", "光速不变", true, 5)
+
+	tests := []struct {
+		name    string
+		filter  repositories.BankQuestionFilter
+		want    []uint
+		wantErr string
+	}{
+		{name: "自己的题目和共享的题目", want: []uint{own.ID, shared.ID, otherCourse.ID}},
+		{name: "只看自己的题目", filter: repositories.BankQuestionFilter{OwnedOnly: true}, want: []uint{own.ID}},
+		{name: "按科目", filter: repositories.BankQuestionFilter{Course: " 数学 "}, want: []uint{own.ID, shared.ID}},
+		{name: "需同时具有全部主题", filter: repositories.BankQuestionFilter{Topics: []string{"数论", "质数"}}, want: []uint{own.ID}},
+		{name: "按难度", filter: repositories.BankQuestionFilter{MinDifficulty: 3, MaxDifficulty: 4}, want: []uint{shared.ID}},
+		{name: "按关键字", filter: repositories.BankQuestionFilter{Keyword: "质数"}, want: []uint{own.ID, shared.ID}},
+		{name: "难度范围无效", filter: repositories.BankQuestionFilter{MinDifficulty: 4, MaxDifficulty: 2}, wantErr: "最低难度不能高于最高难度"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.ViewerID = owner.ID
+			questions, total, err := env.bank.Search(tt.filter, 1, 10)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Search() error = %v, 期望包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[uint]bool)
+			for _, question := range questions {
+				got[question.ID] = true
+			}
+			if total != len(tt.want) || len(got) != len(tt.want) {
+				t.Fatalf("Search() 返回 %v (共%d条), 期望 %v", got, total, tt.want)
+			}
+			for _, id := range tt.want {
+				if !got[id] {
+					t.Errorf("Search() 返回 %v, 缺少 %d", got, id)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveQuestions(t *testing.T) {
+	env := newTestEnv(t)
+	owner := env.user(t, models.RoleTeacher)
+	other := env.user(t, models.RoleTeacher)
+	own := env.bankQuestion(t, owner, "数学", "2是质数", false, 2)
+	shared := env.bankQuestion(t, other, "数学", "3是质数", true, 3)
+	private := env.bankQuestion(t, other, "数学", "4是合数", false, 3)
+	physics := env.bankQuestion(t, other, "物理", "光速不变", true, 3)
+
+	// 修改后题目有两个版本
+	body := *own.Question
+	body.Content = "2是唯一的偶质数"
+	if _, err := env.bank.Update(own.ID, owner.ID, &models.BankQuestion{Course: "数学", Question: &body}); err != nil {
+		t.Fatal(err)
+	}
+	exam := &models.Exam{CreatorID: owner.ID, Course: "数学"}
+
+	tests := []struct {
+		name        string
+		question    models.Question
+		wantContent string
+		wantScore   float64
+		wantVersion int
+		wantErr     bool
+	}{
+		{name: "自己的题目使用当前版本", question: models.Question{BankQuestionID: own.ID}, wantContent: "2是唯一的偶质数", wantScore: 2, wantVersion: 2},
+		{name: "指定版本", question: models.Question{BankQuestionID: own.ID, BankVersion: 1}, wantContent: "2是质数", wantScore: 2, wantVersion: 1},
+		{name: "试卷中的分值覆盖默认分值", question: models.Question{BankQuestionID: shared.ID, Score: 5}, wantContent: "3是质数", wantScore: 5, wantVersion: 1},
+		{name: "其他教师未共享的题目", question: models.Question{BankQuestionID: private.ID}, wantErr: true},
+		{name: "其他科目共享的题目", question: models.Question{BankQuestionID: physics.ID}, wantErr: true},
+		{name: "版本不存在", question: models.Question{BankQuestionID: own.ID, BankVersion: 9}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questions := []models.Question{tt.question}
+			err := env.bank.ResolveQuestions(questions, exam)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveQuestions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := questions[0]
+			if got.Content != tt.wantContent || got.Score != tt.wantScore || got.BankVersion != tt.wantVersion || got.BankQuestionID != tt.question.BankQuestionID {
+				t.Errorf("ResolveQuestions() = %q %g分 版本%d, 期望 %q %g分 版本%d", got.Content, got.Score, got.BankVersion, tt.wantContent, tt.wantScore, tt.wantVersion)
+			}
+		})
+	}
+}
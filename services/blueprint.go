@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+)
+
+// maxBlueprintSeed 自动生成的随机种子上限，保证在JavaScript中可以精确表示
+const maxBlueprintSeed = 1<<53 - 1
+
+// BlueprintShortfall 题库中符合某条规则的题目不足
+type BlueprintShortfall struct {
+	Rule      int    `json:"rule"` // 规则序号，从1开始
+	Condition string `json:"condition"`
+	Required  int    `json:"required"`
+	Available int    `json:"available"` // 排除前面规则已抽到的题目后符合条件的数量
+}
+
+// BlueprintError 题库无法满足组卷方案，列出所有不足的规则
+type BlueprintError struct {
+	Shortfalls []BlueprintShortfall
+}
+
+// Error 实现error接口
+func (e *BlueprintError) Error() string {
+	parts := make([]string, 0, len(e.Shortfalls))
+	for _, s := range e.Shortfalls {
+		parts = append(parts, fmt.Sprintf("规则%d(%s)需要%d道题目，题库中只有%d道符合条件", s.Rule, s.Condition, s.Required, s.Available))
+	}
+	return "题库无法满足组卷方案: " + strings.Join(parts, "；")
+}
+
+// Generate 按组卷方案从考试创建者可在该科目中引用的题库题目里随机抽题，
+// 返回引用题库题目当前版本的试卷题目，由创建试卷时填充内容。
+// 规则按顺序抽取且题目不重复；Seed为0时生成新的随机种子并写回blueprint
+func (s *questionBankService) Generate(blueprint *models.Blueprint, exam *models.Exam) ([]models.Question, error) {
+	if err := blueprint.Validate(); err != nil {
+		return nil, err
+	}
+	if blueprint.Seed == 0 {
+		blueprint.Seed = rand.Int63n(maxBlueprintSeed) + 1
+	}
+	rng := rand.New(rand.NewSource(blueprint.Seed))
+
+	var (
+		questions  []models.Question
+		shortfalls []BlueprintShortfall
+		sum        float64
+	)
+	used := make(map[uint]bool)
+	for i := range blueprint.Rules {
+		rule := &blueprint.Rules[i]
+		rule.Topics = normalizeTagNames(rule.Topics)
+		rule.KnowledgePoints = normalizeTagNames(rule.KnowledgePoints)
+
+		candidates, err := s.bankRepository.Find(repositories.BankQuestionFilter{
+			ViewerID:        exam.CreatorID,
+			Course:          exam.Course,
+			Type:            rule.Type,
+			MinDifficulty:   rule.MinDifficulty,
+			MaxDifficulty:   rule.MaxDifficulty,
+			Topics:          rule.Topics,
+			KnowledgePoints: rule.KnowledgePoints,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		available := candidates[:0]
+		for _, candidate := range candidates {
+			if !used[candidate.ID] {
+				available = append(available, candidate)
+			}
+		}
+		if len(available) < rule.Count {
+			shortfalls = append(shortfalls, BlueprintShortfall{
+				Rule:      i + 1,
+				Condition: rule.String(),
+				Required:  rule.Count,
+				Available: len(available),
+			})
+			continue
+		}
+
+		rng.Shuffle(len(available), func(a, b int) { available[a], available[b] = available[b], available[a] })
+		for _, picked := range available[:rule.Count] {
+			used[picked.ID] = true
+			score := picked.Score
+			if rule.Score > 0 {
+				score = rule.Score
+			}
+			sum += score
+			questions = append(questions, models.Question{
+				BankQuestionID: picked.ID,
+				BankVersion:    picked.Version,
+				Score:          rule.Score,
+			})
+		}
+	}
+	if len(shortfalls) > 0 {
+		return nil, &BlueprintError{Shortfalls: shortfalls}
+	}
+
+	if blueprint.TotalScore == 0 {
+		blueprint.TotalScore = sum
+	}
+	if math.Abs(sum-blueprint.TotalScore) > scoreEpsilon {
+		return nil, fmt.Errorf("按组卷方案抽到的题目分值之和(%g)与试卷总分(%g)不一致，请调整各规则的分值", sum, blueprint.TotalScore)
+	}
+	return questions, nil
+}
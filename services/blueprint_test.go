@@ -0,0 +1,177 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/exam-approval-system/models"
+)
+
+// pickedIDs 返回抽到的题库题目ID
+func pickedIDs(questions []models.Question) []uint {
+	ids := make([]uint, 0, len(questions))
+	for _, q := range questions {
+		ids = append(ids, q.BankQuestionID)
+	}
+	return ids
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	env := newTestEnv(t)
+	teacher := env.user(t, models.RoleTeacher)
+	for i := 0; i < 10; i++ {
+		env.bankQuestion(t, teacher, "数学", fmt.Sprintf("代数题%d", i), false, 1+i%5, "代数")
+		env.bankQuestion(t, teacher, "数学", fmt.Sprintf("几何题%d", i), false, 1+i%5, "几何")
+	}
+	exam := &models.Exam{CreatorID: teacher.ID, Course: "数学"}
+	rules := func() []models.BlueprintRule {
+		return []models.BlueprintRule{
+			{Count: 3, Topics: []string{"代数"}, Score: 5},
+			{Count: 2, Topics: []string{"几何"}, MinDifficulty: 3},
+			{Count: 4},
+		}
+	}
+
+	tests := []struct {
+		name string
+		seed int64
+	}{
+		{"固定种子", 42},
+		{"另一个固定种子", 20240601},
+		{"自动生成种子", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blueprint := &models.Blueprint{Seed: tt.seed, Rules: rules()}
+			first, err := env.bank.Generate(blueprint, exam)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.seed == 0 && blueprint.Seed == 0 {
+				t.Fatal("未写回自动生成的随机种子")
+			}
+			if tt.seed != 0 && blueprint.Seed != tt.seed {
+				t.Errorf("随机种子 = %d, 期望保持 %d", blueprint.Seed, tt.seed)
+			}
+
+			// 相同的种子重复组卷得到相同的题目和顺序
+			again, err := env.bank.Generate(&models.Blueprint{Seed: blueprint.Seed, Rules: rules()}, exam)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(pickedIDs(first), pickedIDs(again)) {
+				t.Errorf("相同种子的抽题结果不同: %v 和 %v", pickedIDs(first), pickedIDs(again))
+			}
+
+			if len(first) != 9 {
+				t.Fatalf("抽到%d道题目, 期望9道", len(first))
+			}
+			seen := make(map[uint]bool)
+			for _, id := range pickedIDs(first) {
+				if seen[id] {
+					t.Errorf("题目%d被重复抽取", id)
+				}
+				seen[id] = true
+			}
+			// 前三道使用规则分值，其余题目使用题库默认分值(2分)
+			if want := 3*5 + 6*2.0; blueprint.TotalScore != want {
+				t.Errorf("试卷总分 = %g, 期望 %g", blueprint.TotalScore, want)
+			}
+		})
+	}
+
+	// 不同的种子通常得到不同的结果
+	a, err := env.bank.Generate(&models.Blueprint{Seed: 1, Rules: rules()}, exam)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := env.bank.Generate(&models.Blueprint{Seed: 2, Rules: rules()}, exam)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(pickedIDs(a), pickedIDs(b)) {
+		t.Errorf("种子1和2的抽题结果相同: %v", pickedIDs(a))
+	}
+}
+
+func TestGenerateShortfalls(t *testing.T) {
+	env := newTestEnv(t)
+	teacher := env.user(t, models.RoleTeacher)
+	other := env.user(t, models.RoleTeacher)
+	for i := 0; i < 3; i++ {
+		env.bankQuestion(t, teacher, "数学", fmt.Sprintf("代数题%d", i), false, 2, "代数")
+	}
+	env.bankQuestion(t, teacher, "数学", "几何题", false, 4, "几何")
+	env.bankQuestion(t, teacher, "物理", "力学题", false, 2, "代数")
+	env.bankQuestion(t, other, "数学", "共享代数题", true, 2, "代数")
+	env.bankQuestion(t, other, "数学", "私有代数题", false, 2, "代数")
+	exam := &models.Exam{CreatorID: teacher.ID, Course: "数学"}
+
+	tests := []struct {
+		name       string
+		blueprint  models.Blueprint
+		want       []BlueprintShortfall
+		wantErrMsg string
+	}{
+		{
+			name:      "题库足够",
+			blueprint: models.Blueprint{Seed: 7, Rules: []models.BlueprintRule{{Count: 4, Topics: []string{"代数"}}}},
+		},
+		{
+			name:      "只计算可引用的题目",
+			blueprint: models.Blueprint{Seed: 7, Rules: []models.BlueprintRule{{Count: 5, Topics: []string{"代数"}}}},
+			want:      []BlueprintShortfall{{Rule: 1, Condition: "主题代数", Required: 5, Available: 4}},
+		},
+		{
+			name: "排除前面规则抽到的题目",
+			blueprint: models.Blueprint{Seed: 7, Rules: []models.BlueprintRule{
+				{Count: 4, Topics: []string{"代数"}},
+				{Count: 2},
+			}},
+			want: []BlueprintShortfall{{Rule: 2, Condition: "不限条件", Required: 2, Available: 1}},
+		},
+		{
+			name: "列出所有不足的规则",
+			blueprint: models.Blueprint{Seed: 7, Rules: []models.BlueprintRule{
+				{Count: 2, Topics: []string{"几何"}},
+				{Count: 1, Topics: []string{"代数"}},
+				{Count: 1, Topics: []string{"代数"}, MinDifficulty: 3},
+			}},
+			want: []BlueprintShortfall{
+				{Rule: 1, Condition: "主题几何", Required: 2, Available: 1},
+				{Rule: 3, Condition: "主题代数，难度不低于3", Required: 1, Available: 0},
+			},
+		},
+		{
+			name:       "分值之和与总分不一致",
+			blueprint:  models.Blueprint{Seed: 7, TotalScore: 100, Rules: []models.BlueprintRule{{Count: 2, Topics: []string{"代数"}}}},
+			wantErrMsg: "按组卷方案抽到的题目分值之和(4)与试卷总分(100)不一致，请调整各规则的分值",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questions, err := env.bank.Generate(&tt.blueprint, exam)
+			var blueprintErr *BlueprintError
+			switch {
+			case tt.want != nil:
+				if !errors.As(err, &blueprintErr) {
+					t.Fatalf("错误 = %v, 期望BlueprintError", err)
+				}
+				if !reflect.DeepEqual(blueprintErr.Shortfalls, tt.want) {
+					t.Errorf("不足的规则 = %+v, 期望 %+v", blueprintErr.Shortfalls, tt.want)
+				}
+				if questions != nil {
+					t.Errorf("题库不足时不应返回题目: %v", pickedIDs(questions))
+				}
+			case tt.wantErrMsg != "":
+				if err == nil || err.Error() != tt.wantErrMsg {
+					t.Errorf("错误 = %v, 期望 %s", err, tt.wantErrMsg)
+				}
+			case err != nil:
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrCommentNotFound 评论不存在、不属于该考试或对当前用户不可见
+	ErrCommentNotFound = errors.New("评论不存在")
+	// ErrCommentForbidden 无权修改评论
+	ErrCommentForbidden = errors.New("无权修改该评论")
+)
+
+// CommentService 考试评论服务接口：按类型和可见范围发表、查看评论，回复、解决讨论串并保留编辑历史
+type CommentService interface {
+	List(viewerID uint, filter repositories.CommentFilter) ([]models.Comment, error)
+	Create(authorID uint, comment *models.Comment) error
+	Edit(examID, commentID, editorID uint, content string) (*models.Comment, error)
+	SetResolved(examID, commentID, userID uint, resolved bool) (*models.Comment, error)
+	History(examID, commentID, viewerID uint) ([]models.CommentRevision, error)
+}
+
+// commentService 考试评论服务实现
+type commentService struct {
+	commentRepository  repositories.CommentRepository
+	examRepository     repositories.ExamRepository
+	paperRepository    repositories.PaperRepository
+	examDataRepository repositories.ExamDataRepository
+	userRepository     repositories.UserRepository
+}
+
+// NewCommentService 创建考试评论服务
+func NewCommentService(commentRepo repositories.CommentRepository, examRepo repositories.ExamRepository,
+	paperRepo repositories.PaperRepository, examDataRepo repositories.ExamDataRepository,
+	userRepo repositories.UserRepository) CommentService {
+	return &commentService{
+		commentRepository:  commentRepo,
+		examRepository:     examRepo,
+		paperRepository:    paperRepo,
+		examDataRepository: examDataRepo,
+		userRepository:     userRepo,
+	}
+}
+
+// List 获取考试中当前用户可见的讨论串，过滤条件作用于顶层评论，每个讨论串附带按时间排列的回复
+func (s *commentService) List(viewerID uint, filter repositories.CommentFilter) ([]models.Comment, error) {
+	viewer, err := s.userRepository.GetByID(viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.exam(filter.ExamID); err != nil {
+		return nil, err
+	}
+
+	threads, err := s.commentRepository.ListThreads(filter)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[uint]uint)
+	visible := make([]models.Comment, 0, len(threads))
+	var ids []uint
+	for _, thread := range threads {
+		if thread.VisibleTo(viewer, s.sheetOwner(owners, thread.ExamDataID)) {
+			visible = append(visible, thread)
+			ids = append(ids, thread.ID)
+		}
+	}
+
+	replies, err := s.commentRepository.ListReplies(ids)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[uint]int, len(visible))
+	for i := range visible {
+		index[visible[i].ID] = i
+	}
+	for _, reply := range replies {
+		if i, ok := index[reply.ParentID]; ok {
+			visible[i].Replies = append(visible[i].Replies, reply)
+		}
+	}
+	return visible, nil
+}
+
+// Create 发表评论。ParentID不为0时回复其所在的讨论串，回复沿用讨论串的类型、可见范围和定位，
+// 否则按作者角色校验类型和可见范围，并校验定位的试卷、题目和答卷属于该考试
+func (s *commentService) Create(authorID uint, comment *models.Comment) error {
+	author, err := s.userRepository.GetByID(authorID)
+	if err != nil {
+		return err
+	}
+	exam, err := s.exam(comment.ExamID)
+	if err != nil {
+		return err
+	}
+	if author.Role == models.RoleStudent {
+		switch exam.Status {
+		case models.StatusPublished, models.StatusClosed, models.StatusArchived:
+		default:
+			return errors.New("考试发布后学生才能参与讨论")
+		}
+	}
+
+	if comment.ParentID != 0 {
+		root, err := s.visible(comment.ExamID, comment.ParentID, author)
+		if err != nil {
+			return err
+		}
+		if root.ParentID != 0 {
+			if root, err = s.visible(comment.ExamID, root.ParentID, author); err != nil {
+				return err
+			}
+		}
+		if comment.Content, err = models.ValidateCommentContent(comment.Content); err != nil {
+			return err
+		}
+		comment.InheritThread(root)
+	} else {
+		if err := comment.Normalize(author); err != nil {
+			return err
+		}
+		if err := s.checkAnchors(comment); err != nil {
+			return err
+		}
+	}
+
+	comment.ID = 0
+	comment.UserID = author.ID
+	comment.Resolved = false
+	comment.ResolvedBy = 0
+	comment.ResolvedAt = nil
+	comment.EditedAt = nil
+	if err := s.commentRepository.Create(comment); err != nil {
+		return err
+	}
+	comment.User = *author
+	return nil
+}
+
+// Edit 修改评论内容，只有作者可以修改，修改前的内容记入编辑历史
+func (s *commentService) Edit(examID, commentID, editorID uint, content string) (*models.Comment, error) {
+	editor, err := s.userRepository.GetByID(editorID)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := s.visible(examID, commentID, editor)
+	if err != nil {
+		return nil, err
+	}
+	if comment.UserID != editor.ID {
+		return nil, ErrCommentForbidden
+	}
+	if content, err = models.ValidateCommentContent(content); err != nil {
+		return nil, err
+	}
+	if content == comment.Content {
+		return comment, nil
+	}
+	if err := s.commentRepository.Edit(comment, content, editor.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// SetResolved 将讨论串标记为已解决或重新打开，讨论串的作者、教师和管理员可以操作
+func (s *commentService) SetResolved(examID, commentID, userID uint, resolved bool) (*models.Comment, error) {
+	user, err := s.userRepository.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := s.visible(examID, commentID, user)
+	if err != nil {
+		return nil, err
+	}
+	if comment.ParentID != 0 {
+		return nil, errors.New("只能解决或重新打开顶层评论")
+	}
+	if user.Role == models.RoleStudent && comment.UserID != user.ID {
+		return nil, ErrCommentForbidden
+	}
+	if comment.Resolved == resolved {
+		return comment, nil
+	}
+
+	comment.Resolved = resolved
+	comment.ResolvedBy = 0
+	comment.ResolvedAt = nil
+	if resolved {
+		now := time.Now()
+		comment.ResolvedBy = user.ID
+		comment.ResolvedAt = &now
+	}
+	if err := s.commentRepository.SetResolved(comment); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// History 获取评论的编辑历史
+func (s *commentService) History(examID, commentID, viewerID uint) ([]models.CommentRevision, error) {
+	viewer, err := s.userRepository.GetByID(viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.visible(examID, commentID, viewer); err != nil {
+		return nil, err
+	}
+	return s.commentRepository.ListRevisions(commentID)
+}
+
+// exam 获取考试，不存在时返回ErrExamNotFound
+func (s *commentService) exam(examID uint) (*models.Exam, error) {
+	exam, err := s.examRepository.GetByID(examID)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrExamNotFound
+	}
+	return exam, err
+}
+
+// visible 获取考试下对用户可见的评论，回复按所在讨论串判断，不可见时返回ErrCommentNotFound
+func (s *commentService) visible(examID, commentID uint, user *models.User) (*models.Comment, error) {
+	comment, err := s.commentRepository.GetByID(commentID)
+	if gorm.IsRecordNotFoundError(err) || (err == nil && comment.ExamID != examID) {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !comment.VisibleTo(user, s.sheetOwner(nil, comment.ExamDataID)) {
+		return nil, ErrCommentNotFound
+	}
+	return comment, nil
+}
+
+// sheetOwner 获取答卷所属的学生，examDataID为0或答卷不存在时返回0，owners用于缓存查询结果
+func (s *commentService) sheetOwner(owners map[uint]uint, examDataID uint) uint {
+	if examDataID == 0 {
+		return 0
+	}
+	if owner, ok := owners[examDataID]; ok {
+		return owner
+	}
+	var owner uint
+	if examData, err := s.examDataRepository.GetByID(examDataID); err == nil {
+		owner = examData.StudentID
+	}
+	if owners != nil {
+		owners[examDataID] = owner
+	}
+	return owner
+}
+
+// checkAnchors 校验评论定位的试卷、题目和答卷属于该考试，只指定题目时补全所在试卷
+func (s *commentService) checkAnchors(comment *models.Comment) error {
+	if comment.ExamDataID != 0 {
+		examData, err := s.examDataRepository.GetByID(comment.ExamDataID)
+		if err != nil || examData.ExamID != comment.ExamID {
+			return errors.New("答卷不属于该考试")
+		}
+	}
+	if comment.PaperID == 0 && comment.QuestionID == 0 {
+		return nil
+	}
+
+	var papers []models.Paper
+	if comment.PaperID != 0 {
+		paper, err := s.paperRepository.GetByID(comment.PaperID)
+		if err != nil || paper.ExamID != comment.ExamID {
+			return errors.New("试卷不属于该考试")
+		}
+		papers = append(papers, *paper)
+	} else {
+		var err error
+		if papers, err = s.paperRepository.GetByExamID(comment.ExamID); err != nil {
+			return err
+		}
+	}
+	if comment.QuestionID == 0 {
+		return nil
+	}
+	for _, paper := range papers {
+		for _, question := range paper.Questions {
+			if question.ID == comment.QuestionID {
+				comment.PaperID = paper.ID
+				return nil
+			}
+		}
+	}
+	return errors.New("题目不属于该试卷")
+}
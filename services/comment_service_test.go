@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+)
+
+func TestCommentStudentVisibility(t *testing.T) {
+	env := newTestEnv(t)
+	comments := NewCommentService(repositories.NewCommentRepository(), env.exams, env.papers, env.examData, env.users)
+	student := env.user(t, models.RoleStudent)
+	classmate := env.user(t, models.RoleStudent)
+	teacher := env.user(t, models.RoleTeacher)
+	exam, _, sheet := env.publishedExam(t, student)
+	otherSheet, err := env.exams.GetExamDataByExamAndStudent(exam.ID, classmate.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// post 发表评论并返回评论ID
+	post := func(author *models.User, comment models.Comment) uint {
+		t.Helper()
+		comment.ExamID = exam.ID
+		if err := comments.Create(author.ID, &comment); err != nil {
+			t.Fatal(err)
+		}
+		return comment.ID
+	}
+	review := post(teacher, models.Comment{Kind: models.CommentKindReview, Visibility: models.CommentVisibilityPublic, Content: "题量偏大"})
+	staff := post(teacher, models.Comment{Kind: models.CommentKindDiscussion, Visibility: models.CommentVisibilityStaff, Content: "内部讨论"})
+	public := post(teacher, models.Comment{Kind: models.CommentKindDiscussion, Content: "考试注意事项"})
+	own := post(teacher, models.Comment{Kind: models.CommentKindGrading, ExamDataID: sheet.ID, Content: "第2题步骤不完整"})
+	others := post(teacher, models.Comment{Kind: models.CommentKindGrading, ExamDataID: otherSheet.ID, Content: "第1题概念错误"})
+	staffGrading := post(teacher, models.Comment{Kind: models.CommentKindGrading, Visibility: models.CommentVisibilityStaff, ExamDataID: sheet.ID, Content: "可能抄袭"})
+	hidden := map[uint]string{review: "审批意见", staff: "仅教师可见的讨论", others: "其他学生的批阅评语", staffGrading: "仅教师可见的批阅评语"}
+	// 隐藏讨论串中的回复沿用讨论串的可见范围
+	for root, name := range map[uint]string{review: "审批意见的回复", staff: "内部讨论的回复", others: "其他学生批阅评语的回复"} {
+		hidden[post(teacher, models.Comment{ParentID: root, Content: "回复"})] = name
+	}
+	publicReply := post(teacher, models.Comment{ParentID: public, Content: "请带计算器"})
+	studentReply := post(student, models.Comment{ParentID: own, Content: "已订正"})
+
+	t.Run("列表只包含可见的讨论串和回复", func(t *testing.T) {
+		threads, err := comments.List(student.ID, repositories.CommentFilter{ExamID: exam.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[uint][]uint)
+		for _, thread := range threads {
+			for _, reply := range thread.Replies {
+				got[thread.ID] = append(got[thread.ID], reply.ID)
+			}
+			if _, ok := got[thread.ID]; !ok {
+				got[thread.ID] = nil
+			}
+		}
+		want := map[uint][]uint{public: {publicReply}, own: {studentReply}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("学生看到的讨论串和回复 = %v, 期望 %v", got, want)
+		}
+	})
+
+	t.Run("按其他学生的答卷筛选", func(t *testing.T) {
+		threads, err := comments.List(student.ID, repositories.CommentFilter{ExamID: exam.ID, ExamDataID: otherSheet.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(threads) != 0 {
+			t.Errorf("学生看到其他学生答卷的批阅评语: %+v", threads)
+		}
+	})
+
+	t.Run("教师看到全部讨论串", func(t *testing.T) {
+		threads, err := comments.List(teacher.ID, repositories.CommentFilter{ExamID: exam.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(threads) != 6 {
+			t.Errorf("教师看到%d个讨论串, 期望6个", len(threads))
+		}
+	})
+
+	for id, name := range hidden {
+		t.Run("不能直接访问"+name, func(t *testing.T) {
+			if _, err := comments.History(exam.ID, id, student.ID); !errors.Is(err, ErrCommentNotFound) {
+				t.Errorf("History() error = %v, 期望 %v", err, ErrCommentNotFound)
+			}
+			if _, err := comments.Edit(exam.ID, id, student.ID, "修改"); !errors.Is(err, ErrCommentNotFound) {
+				t.Errorf("Edit() error = %v, 期望 %v", err, ErrCommentNotFound)
+			}
+			reply := models.Comment{ExamID: exam.ID, ParentID: id, Content: "回复"}
+			if err := comments.Create(student.ID, &reply); !errors.Is(err, ErrCommentNotFound) {
+				t.Errorf("回复 error = %v, 期望 %v", err, ErrCommentNotFound)
+			}
+		})
+	}
+}
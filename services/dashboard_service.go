@@ -71,7 +71,7 @@ func (s *dashboardService) GetStudentDashboardStats(userID uint) (*DashboardStat
 	// 计算统计数据
 	for _, exam := range exams {
 		switch exam.Status {
-		case models.StatusApproved, models.StatusPublished:
+		case models.StatusApproved, models.StatusPublished, models.StatusClosed, models.StatusArchived:
 			approvedCount++
 		case models.StatusPending:
 			pendingCount++
@@ -148,7 +148,7 @@ func (s *dashboardService) GetTeacherDashboardStats(userID uint) (*DashboardStat
 	// 计算统计数据并收集待审批试卷
 	for _, exam := range exams {
 		switch exam.Status {
-		case models.StatusApproved, models.StatusPublished:
+		case models.StatusApproved, models.StatusPublished, models.StatusClosed, models.StatusArchived:
 			approvedCount++
 		case models.StatusPending:
 			pendingCount++
@@ -214,7 +214,7 @@ func (s *dashboardService) GetAdminDashboardStats() (*DashboardStats, error) {
 	// 计算统计数据
 	for _, exam := range exams {
 		switch exam.Status {
-		case models.StatusApproved, models.StatusPublished:
+		case models.StatusApproved, models.StatusPublished, models.StatusClosed, models.StatusArchived:
 			approvedCount++
 		case models.StatusPending:
 			pendingCount++
@@ -2,44 +2,80 @@ package services
 
 import (
 	"errors"
+	"time"
 
+	"github.com/exam-approval-system/configs"
 	"github.com/exam-approval-system/models"
 	"github.com/exam-approval-system/repositories"
+	"github.com/jinzhu/gorm"
 )
 
+// ErrExamDeleteForbidden 只有考试创建者和管理员可以删除考试
+var ErrExamDeleteForbidden = errors.New("只有考试创建者和管理员可以删除考试")
+
 // ExamService 考试服务接口
 type ExamService interface {
+	WithTx(tx *gorm.DB) ExamService
 	CreateExam(exam *models.Exam) error
 	GetExamByID(id uint) (*models.Exam, error)
 	UpdateExam(exam *models.Exam) error
 	DeleteExam(id uint) error
+	RemoveExam(id uint, actor *models.User) error
 	ListExams() ([]models.Exam, error)
 	ListExamsByCreator(creatorID uint) ([]models.Exam, error)
 	ListExamsByStatus(status string) ([]models.Exam, error)
 	ListPendingExams() ([]models.Exam, error)
-	SubmitForApproval(examID uint) error
-	ApproveExam(examID, approverID uint, comment string) error
-	RejectExam(examID, approverID uint, comment string) error
-	PublishExam(examID uint) error
-	AddComment(comment *models.Comment) error
-	GetCommentsByExamID(examID uint) ([]models.Comment, error)
+	TransitionExam(examID, actorID uint, action, comment string) (*models.Exam, error)
+	ExamActions(exam *models.Exam, user *models.User) []string
+	ListTransitions(examID uint) ([]models.ExamTransition, error)
+	TimeInState(examID uint) (*ExamTimeInState, error)
 }
 
 // examService 考试服务实现
 type examService struct {
-	examRepository repositories.ExamRepository
-	userRepository repositories.UserRepository
+	tx                   *gorm.DB // 通过WithTx创建时为所在的事务
+	examRepository       repositories.ExamRepository
+	userRepository       repositories.UserRepository
+	paperRepository      repositories.PaperRepository
+	submissionRepository repositories.SubmissionRepository
+	attemptRepository    repositories.AttemptRepository
+	stateMachine         ExamStateMachine
 }
 
 // NewExamService 创建考试服务
-func NewExamService(examRepo repositories.ExamRepository, userRepo repositories.UserRepository) ExamService {
+func NewExamService(
+	examRepo repositories.ExamRepository,
+	userRepo repositories.UserRepository,
+	paperRepo repositories.PaperRepository,
+	submissionRepo repositories.SubmissionRepository,
+	attemptRepo repositories.AttemptRepository,
+	stateMachine ExamStateMachine,
+) ExamService {
+	return &examService{
+		examRepository:       examRepo,
+		userRepository:       userRepo,
+		paperRepository:      paperRepo,
+		submissionRepository: submissionRepo,
+		attemptRepository:    attemptRepo,
+		stateMachine:         stateMachine,
+	}
+}
+
+// WithTx 返回在事务tx中读写考试、试卷、提交和作答会话的考试服务，用于在其他操作的事务中删除考试。
+// 状态变更仍由状态机在独立的事务中执行
+func (s *examService) WithTx(tx *gorm.DB) ExamService {
 	return &examService{
-		examRepository: examRepo,
-		userRepository: userRepo,
+		tx:                   tx,
+		examRepository:       s.examRepository.WithTx(tx),
+		userRepository:       s.userRepository.WithTx(tx),
+		paperRepository:      s.paperRepository.WithTx(tx),
+		submissionRepository: s.submissionRepository.WithTx(tx),
+		attemptRepository:    s.attemptRepository.WithTx(tx),
+		stateMachine:         s.stateMachine,
 	}
 }
 
-// CreateExam 创建考试
+// CreateExam 创建草稿状态的考试
 func (s *examService) CreateExam(exam *models.Exam) error {
 	// 验证创建者是教师
 	creator, err := s.userRepository.GetByID(exam.CreatorID)
@@ -49,44 +85,17 @@ func (s *examService) CreateExam(exam *models.Exam) error {
 	if creator.Role != models.RoleTeacher {
 		return errors.New("只有教师才能创建考试")
 	}
-
-	// 创建试卷
-	if err := s.examRepository.Create(exam); err != nil {
+	if err := exam.ValidateAttemptPolicy(); err != nil {
 		return err
 	}
 
-	// 如果试卷状态为已发布，则自动分配给所有学生
-	if exam.Status == models.StatusPublished {
-		// 获取所有学生
-		students, err := s.userRepository.ListByRole(models.RoleStudent)
-		if err != nil {
-			return err
-		}
-
-		// 为每个学生创建ExamData记录
-		for _, student := range students {
-			// 先检查是否已存在相同的记录
-			existingExamData, err := s.examRepository.GetExamDataByExamAndStudent(exam.ID, student.ID)
-			if err == nil && existingExamData != nil {
-				// 如果记录已存在，跳过创建
-				continue
-			}
-
-			examData := &models.ExamData{
-				ExamID:     exam.ID,
-				StudentID:  student.ID,
-				Title:      exam.Title,
-				Course:     exam.Course,
-				TotalScore: exam.TotalScore,
-				Status:     "assigned", // 直接设置为已分配状态
-			}
-			if err := s.examRepository.CreateExamData(examData); err != nil {
-				return err
-			}
-		}
+	// 新建的考试都是草稿，之后的状态变更通过状态机进行
+	exam.Status = models.StatusDraft
+	exam.ApproverID = 0
+	if err := s.examRepository.Create(exam); err != nil {
+		return err
 	}
-
-	return nil
+	return s.stateMachine.Created(exam)
 }
 
 // GetExamByID 根据ID获取考试
@@ -104,6 +113,9 @@ func (s *examService) UpdateExam(exam *models.Exam) error {
 	if currentExam.Status != models.StatusDraft && currentExam.Status != models.StatusRejected {
 		return errors.New("只能修改草稿或被拒绝状态的考试")
 	}
+	if err := exam.ValidateAttemptPolicy(); err != nil {
+		return err
+	}
 
 	return s.examRepository.Update(exam)
 }
@@ -119,7 +131,52 @@ func (s *examService) DeleteExam(id uint) error {
 		return errors.New("只能删除草稿状态的考试")
 	}
 
-	return s.examRepository.Delete(id)
+	return s.purge(id)
+}
+
+// RemoveExam 由考试创建者或管理员删除考试及其全部数据。审批通过后试卷已锁定，
+// 已审批、已发布、已关闭和已归档的考试不能删除，返回models.ErrPaperLocked
+func (s *examService) RemoveExam(id uint, actor *models.User) error {
+	exam, err := s.examRepository.GetByID(id)
+	if gorm.IsRecordNotFoundError(err) {
+		return ErrExamNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if actor.Role != models.RoleAdmin && exam.CreatorID != actor.ID {
+		return ErrExamDeleteForbidden
+	}
+	if exam.Locked() {
+		return models.ErrPaperLocked
+	}
+	return s.purge(id)
+}
+
+// purge 在同一事务中删除考试的试卷、学生试卷数据、提交、作答会话和考试本身，
+// 考试的评论、状态变更记录和审批任务随考试删除。任一试卷已锁定时全部回滚。
+// 通过WithTx创建时在所在的事务中执行
+func (s *examService) purge(id uint) error {
+	db := s.tx
+	if db == nil {
+		db = configs.DB()
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if _, err := s.paperRepository.WithTx(tx).DeleteByExam(id); err != nil {
+			return err
+		}
+		if _, err := s.submissionRepository.WithTx(tx).DeleteByExam(id); err != nil {
+			return err
+		}
+		if _, err := s.attemptRepository.WithTx(tx).DeleteByExam(id); err != nil {
+			return err
+		}
+		examRepo := s.examRepository.WithTx(tx)
+		if _, err := examRepo.DeleteExamData(id); err != nil {
+			return err
+		}
+		return examRepo.Delete(id)
+	})
 }
 
 // ListExams 获取所有考试
@@ -142,114 +199,46 @@ func (s *examService) ListPendingExams() ([]models.Exam, error) {
 	return s.examRepository.ListPendingApproval()
 }
 
-// SubmitForApproval 提交考试审批
-func (s *examService) SubmitForApproval(examID uint) error {
-	exam, err := s.examRepository.GetByID(examID)
-	if err != nil {
-		return err
-	}
-	if exam.Status != models.StatusDraft && exam.Status != models.StatusRejected {
-		return errors.New("只能提交草稿或被拒绝状态的考试")
-	}
-
-	exam.Status = models.StatusPending
-	return s.examRepository.Update(exam)
+// TransitionExam 由actorID对应的用户对考试执行状态变更，规则见ExamStateMachine
+func (s *examService) TransitionExam(examID, actorID uint, action, comment string) (*models.Exam, error) {
+	return s.stateMachine.Fire(examID, actorID, action, comment)
 }
 
-// ApproveExam 审批通过考试
-func (s *examService) ApproveExam(examID, approverID uint, comment string) error {
-	// 验证审批者是管理员
-	approver, err := s.userRepository.GetByID(approverID)
-	if err != nil {
-		return errors.New("审批者不存在")
-	}
-	if approver.Role != models.RoleAdmin {
-		return errors.New("只有管理员才能审批考试")
-	}
-
-	exam, err := s.examRepository.GetByID(examID)
-	if err != nil {
-		return err
-	}
-	if exam.Status != models.StatusPending {
-		return errors.New("只能审批待审批状态的考试")
-	}
-
-	// 添加评论
-	if comment != "" {
-		commentObj := &models.Comment{
-			ExamID:  examID,
-			UserID:  approverID,
-			Content: comment,
-		}
-		if err := s.examRepository.AddComment(commentObj); err != nil {
-			return err
-		}
-	}
-
-	// 更新考试状态
-	exam.Status = models.StatusApproved
-	exam.ApproverID = approverID
-	return s.examRepository.Update(exam)
+// ExamActions 返回用户当前可以对考试执行的状态变更操作
+func (s *examService) ExamActions(exam *models.Exam, user *models.User) []string {
+	return s.stateMachine.Actions(exam, user)
 }
 
-// RejectExam 拒绝考试
-func (s *examService) RejectExam(examID, approverID uint, comment string) error {
-	// 验证审批者是管理员
-	approver, err := s.userRepository.GetByID(approverID)
-	if err != nil {
-		return errors.New("审批者不存在")
-	}
-	if approver.Role != models.RoleAdmin {
-		return errors.New("只有管理员才能拒绝考试")
-	}
+// ExamTimeInState 考试在各状态累计停留的时间
+type ExamTimeInState struct {
+	ExamID uint                   `json:"exam_id"`
+	Status string                 `json:"status"`
+	Since  *time.Time             `json:"since"` // 进入当前状态的时间，没有状态变更记录时为空
+	States []models.StateDuration `json:"states"`
+}
 
+// TimeInState 按状态变更记录计算考试在各状态累计停留的时间
+func (s *examService) TimeInState(examID uint) (*ExamTimeInState, error) {
 	exam, err := s.examRepository.GetByID(examID)
 	if err != nil {
-		return err
-	}
-	if exam.Status != models.StatusPending {
-		return errors.New("只能拒绝待审批状态的考试")
-	}
-
-	// 添加评论
-	if comment != "" {
-		commentObj := &models.Comment{
-			ExamID:  examID,
-			UserID:  approverID,
-			Content: comment,
-		}
-		if err := s.examRepository.AddComment(commentObj); err != nil {
-			return err
-		}
+		return nil, ErrExamNotFound
 	}
-
-	// 更新考试状态
-	exam.Status = models.StatusRejected
-	exam.ApproverID = approverID
-	return s.examRepository.Update(exam)
-}
-
-// PublishExam 发布考试
-func (s *examService) PublishExam(examID uint) error {
-	exam, err := s.examRepository.GetByID(examID)
+	transitions, err := s.stateMachine.History(examID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if exam.Status != models.StatusApproved {
-		return errors.New("只能发布已审批通过的考试")
+	result := &ExamTimeInState{
+		ExamID: exam.ID,
+		Status: exam.Status,
+		States: models.TimeInState(transitions, time.Now()),
 	}
-
-	exam.Status = models.StatusPublished
-	return s.examRepository.Update(exam)
-}
-
-// AddComment 添加评论
-func (s *examService) AddComment(comment *models.Comment) error {
-	return s.examRepository.AddComment(comment)
+	if len(transitions) > 0 {
+		result.Since = &transitions[len(transitions)-1].CreatedAt
+	}
+	return result, nil
 }
 
-// GetCommentsByExamID 根据考试ID获取评论
-func (s *examService) GetCommentsByExamID(examID uint) ([]models.Comment, error) {
-	return s.examRepository.GetCommentsByExamID(examID)
+// ListTransitions 获取考试的状态变更记录
+func (s *examService) ListTransitions(examID uint) ([]models.ExamTransition, error) {
+	return s.stateMachine.History(examID)
 }
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/exam-approval-system/models"
+)
+
+func TestRemoveExam(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   string
+		approve bool
+		wantErr error
+	}{
+		{name: "创建者删除草稿", actor: "teacher"},
+		{name: "管理员删除草稿", actor: "admin"},
+		{name: "其他教师", actor: "other", wantErr: ErrExamDeleteForbidden},
+		{name: "已审批的考试", actor: "admin", approve: true, wantErr: models.ErrPaperLocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			actors := map[string]*models.User{
+				"teacher": env.user(t, models.RoleTeacher),
+				"other":   env.user(t, models.RoleTeacher),
+				"admin":   env.user(t, models.RoleAdmin),
+			}
+			exam, paper := env.draftExam(t, actors["teacher"], "数学")
+			if tt.approve {
+				env.fire(t, exam, actors["teacher"], models.ExamActionSubmit, "")
+				env.fire(t, exam, actors["admin"], models.ExamActionApprove, "")
+			}
+
+			err := env.exam.RemoveExam(exam.ID, actors[tt.actor])
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RemoveExam() error = %v, 期望 %v", err, tt.wantErr)
+			}
+			_, examErr := env.exams.GetByID(exam.ID)
+			_, paperErr := env.papers.GetByID(paper.ID)
+			if deleted := examErr != nil && paperErr != nil; deleted != (tt.wantErr == nil) {
+				t.Errorf("考试和试卷已删除 = %v, 期望 %v", deleted, tt.wantErr == nil)
+			}
+		})
+	}
+}
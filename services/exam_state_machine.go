@@ -0,0 +1,389 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrExamNotFound 考试不存在
+	ErrExamNotFound = errors.New("考试不存在")
+	// ErrTransitionForbidden 当前用户的角色不能执行该操作，或教师操作的不是自己创建的考试
+	ErrTransitionForbidden = errors.New("无权对该考试执行此操作")
+	// ErrTransitionConflict 考试状态已被其他操作修改
+	ErrTransitionConflict = errors.New("考试状态已被其他操作修改，请刷新后重试")
+)
+
+// examActionNames 状态变更操作的名称，用于错误提示和页面按钮
+var examActionNames = map[string]string{
+	models.ExamActionSubmit:  "提交审批",
+	models.ExamActionApprove: "审批通过",
+	models.ExamActionReject:  "审批拒绝",
+	models.ExamActionPublish: "发布",
+	models.ExamActionClose:   "关闭",
+	models.ExamActionArchive: "归档",
+}
+
+// examStatusNames 考试状态的名称，用于错误提示
+var examStatusNames = map[string]string{
+	models.StatusDraft:     "草稿",
+	models.StatusPending:   "待审批",
+	models.StatusApproved:  "已审批",
+	models.StatusRejected:  "已拒绝",
+	models.StatusPublished: "已发布",
+	models.StatusClosed:    "已关闭",
+	models.StatusArchived:  "已归档",
+}
+
+// ExamActionName 返回状态变更操作的名称
+func ExamActionName(action string) string {
+	if name, ok := examActionNames[action]; ok {
+		return name
+	}
+	return action
+}
+
+// examTransitionRule 一种状态变更：允许的起始状态、目标状态、可执行的角色，以及执行前的检查和执行后的处理
+type examTransitionRule struct {
+	from   []string
+	to     string
+	roles  []string // 教师只能操作自己创建的考试
+	review bool     // 审批操作：能否执行由ApprovalService判断，不检查角色
+	guard  func(exam *models.Exam, comment string) error
+	effect func(exam *models.Exam) error
+}
+
+// ExamStateMachine 考试状态机，考试状态只能通过它变更：
+// 草稿 → 待审批 → 已审批/已拒绝 → 已发布 → 已关闭 → 已归档，被拒绝的考试修改后可以重新提交审批。
+// 审批通过和拒绝由ApprovalService判断审批人：没有审批链时为管理员，科目设置了审批链时汇总各级审批人的决定后执行，
+// 审批人不在时可以由其代理人审批。审批通过后锁定考试的试卷内容
+type ExamStateMachine interface {
+	Created(exam *models.Exam) error
+	Fire(examID, actorID uint, action, comment string) (*models.Exam, error)
+	Actions(exam *models.Exam, actor *models.User) []string
+	History(examID uint) ([]models.ExamTransition, error)
+}
+
+// examStateMachine 考试状态机实现
+type examStateMachine struct {
+	examRepository       repositories.ExamRepository
+	userRepository       repositories.UserRepository
+	submissionRepository repositories.SubmissionRepository
+	attemptRepository    repositories.AttemptRepository
+	approvalService      ApprovalService
+	paperLockService     PaperLockService
+	actions              []string // 按流程顺序排列的操作
+	rules                map[string]examTransitionRule
+}
+
+// NewExamStateMachine 创建考试状态机
+func NewExamStateMachine(
+	examRepo repositories.ExamRepository,
+	userRepo repositories.UserRepository,
+	submissionRepo repositories.SubmissionRepository,
+	attemptRepo repositories.AttemptRepository,
+	approvalService ApprovalService,
+	paperLockService PaperLockService,
+) ExamStateMachine {
+	m := &examStateMachine{
+		examRepository:       examRepo,
+		userRepository:       userRepo,
+		submissionRepository: submissionRepo,
+		attemptRepository:    attemptRepo,
+		approvalService:      approvalService,
+		paperLockService:     paperLockService,
+		actions: []string{
+			models.ExamActionSubmit,
+			models.ExamActionApprove,
+			models.ExamActionReject,
+			models.ExamActionPublish,
+			models.ExamActionClose,
+			models.ExamActionArchive,
+		},
+	}
+	m.rules = map[string]examTransitionRule{
+		models.ExamActionSubmit: {
+			from:   []string{models.StatusDraft, models.StatusRejected},
+			to:     models.StatusPending,
+			roles:  []string{models.RoleTeacher},
+			guard:  func(exam *models.Exam, comment string) error { return approvalService.Prepare(exam) },
+			effect: approvalService.Start,
+		},
+		models.ExamActionApprove: {
+			from:   []string{models.StatusPending},
+			to:     models.StatusApproved,
+			review: true,
+			effect: paperLockService.Lock,
+		},
+		models.ExamActionReject: {
+			from:   []string{models.StatusPending},
+			to:     models.StatusRejected,
+			review: true,
+			guard:  requireRejectReason,
+		},
+		models.ExamActionPublish: {
+			from:   []string{models.StatusApproved},
+			to:     models.StatusPublished,
+			roles:  []string{models.RoleTeacher, models.RoleAdmin},
+			guard:  requireNotEnded,
+			effect: m.assignStudents,
+		},
+		models.ExamActionClose: {
+			from:  []string{models.StatusPublished},
+			to:    models.StatusClosed,
+			roles: []string{models.RoleTeacher, models.RoleAdmin},
+		},
+		models.ExamActionArchive: {
+			from:  []string{models.StatusClosed},
+			to:    models.StatusArchived,
+			roles: []string{models.RoleTeacher, models.RoleAdmin},
+			guard: m.requireSettled,
+		},
+	}
+	return m
+}
+
+// Created 记录考试以草稿状态创建
+func (m *examStateMachine) Created(exam *models.Exam) error {
+	return m.examRepository.AddTransition(&models.ExamTransition{
+		ExamID:    exam.ID,
+		Action:    models.ExamActionCreate,
+		ToStatus:  exam.Status,
+		ActorID:   exam.CreatorID,
+		ActorRole: models.RoleTeacher,
+	})
+}
+
+// Fire 由actorID对应的用户对考试执行状态变更，依次检查操作、角色、当前状态和前置条件，
+// 成功后写入状态变更记录并返回更新后的考试。审批和拒绝时填写的意见同时保存为考试评论。
+// 按审批链审批时只记录审批人的决定，本轮审批有结果后才变更状态，此时返回的考试仍为待审批状态。
+// 代理人审批时状态变更记录中同时记录委托人。审批决定、状态变更、评论和变更后的处理在同一事务中执行，
+// 任一步失败时全部回滚
+func (m *examStateMachine) Fire(examID, actorID uint, action, comment string) (*models.Exam, error) {
+	var exam *models.Exam
+	err := configs.DB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		exam, err = m.withTx(tx).fire(examID, actorID, action, comment)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return exam, nil
+}
+
+// withTx 返回在事务tx中读写的状态机
+func (m *examStateMachine) withTx(tx *gorm.DB) *examStateMachine {
+	return NewExamStateMachine(
+		m.examRepository.WithTx(tx),
+		m.userRepository.WithTx(tx),
+		m.submissionRepository.WithTx(tx),
+		m.attemptRepository.WithTx(tx),
+		m.approvalService.WithTx(tx),
+		m.paperLockService.WithTx(tx),
+	).(*examStateMachine)
+}
+
+// fire 执行Fire的各项检查和状态变更
+func (m *examStateMachine) fire(examID, actorID uint, action, comment string) (*models.Exam, error) {
+	rule, ok := m.rules[action]
+	if !ok {
+		return nil, fmt.Errorf("未知的考试操作: %s", action)
+	}
+	actor, err := m.userRepository.GetByID(actorID)
+	if err != nil {
+		return nil, errors.New("操作用户不存在")
+	}
+	exam, err := m.examRepository.GetByID(examID)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrExamNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, onBehalfOf, err := m.allowed(rule, exam, actor)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrTransitionForbidden
+	}
+	if !containsString(rule.from, exam.Status) {
+		return nil, fmt.Errorf("考试当前为%s状态，不能%s", examStatusNames[exam.Status], ExamActionName(action))
+	}
+	comment = strings.TrimSpace(comment)
+	if rule.guard != nil {
+		if err := rule.guard(exam, comment); err != nil {
+			return nil, err
+		}
+	}
+
+	if rule.review {
+		outcome, err := m.approvalService.Review(exam, actor, action == models.ExamActionApprove, comment)
+		if err != nil {
+			return nil, err
+		}
+		if !outcome.Decided {
+			return exam, nil
+		}
+		if !outcome.Approved {
+			// 按审批链审批时，退回给创建者时附上未通过那一级的全部审批意见
+			comment = outcome.Comment
+		}
+	}
+
+	transition := &models.ExamTransition{
+		ExamID:       exam.ID,
+		Action:       action,
+		FromStatus:   exam.Status,
+		ToStatus:     rule.to,
+		ActorID:      actor.ID,
+		ActorRole:    actor.Role,
+		OnBehalfOfID: onBehalfOf,
+		Comment:      comment,
+	}
+	exam.Status = rule.to
+	if action == models.ExamActionApprove || action == models.ExamActionReject {
+		exam.ApproverID = actor.ID
+	}
+	changed, err := m.examRepository.Transition(exam, transition)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return nil, ErrTransitionConflict
+	}
+
+	if comment != "" && (action == models.ExamActionApprove || action == models.ExamActionReject) {
+		review := &models.Comment{
+			ExamID:     exam.ID,
+			UserID:     actor.ID,
+			Kind:       models.CommentKindReview,
+			Visibility: models.CommentVisibilityStaff,
+			Content:    comment,
+		}
+		if err := m.examRepository.AddComment(review); err != nil {
+			return nil, err
+		}
+	}
+	if rule.effect != nil {
+		if err := rule.effect(exam); err != nil {
+			return nil, err
+		}
+	}
+	return exam, nil
+}
+
+// Actions 返回用户当前可以对考试执行的操作，不检查前置条件
+func (m *examStateMachine) Actions(exam *models.Exam, actor *models.User) []string {
+	var actions []string
+	for _, action := range m.actions {
+		rule := m.rules[action]
+		if !containsString(rule.from, exam.Status) {
+			continue
+		}
+		if allowed, _, err := m.allowed(rule, exam, actor); err == nil && allowed {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// History 获取考试的状态变更记录
+func (m *examStateMachine) History(examID uint) ([]models.ExamTransition, error) {
+	return m.examRepository.ListTransitions(examID)
+}
+
+// allowed 检查用户能否执行该操作，代理人审批时同时返回委托人ID。
+// 审批操作由ApprovalService判断；其他操作按角色检查，教师只能操作自己创建的考试
+func (m *examStateMachine) allowed(rule examTransitionRule, exam *models.Exam, actor *models.User) (bool, uint, error) {
+	if rule.review {
+		access, err := m.approvalService.Reviewer(exam, actor)
+		if err != nil {
+			return false, 0, err
+		}
+		return access.Allowed, access.OnBehalfOf, nil
+	}
+	if !containsString(rule.roles, actor.Role) {
+		return false, 0, nil
+	}
+	return actor.Role != models.RoleTeacher || exam.CreatorID == actor.ID, 0, nil
+}
+
+// requireRejectReason 拒绝考试时必须填写理由
+func requireRejectReason(exam *models.Exam, comment string) error {
+	if comment == "" {
+		return errors.New("拒绝考试时必须填写理由")
+	}
+	return nil
+}
+
+// requireNotEnded 已过结束时间的考试不能发布
+func requireNotEnded(exam *models.Exam, comment string) error {
+	if !exam.EndTime.IsZero() && !time.Now().Before(exam.EndTime) {
+		return errors.New("考试已过结束时间，不能发布")
+	}
+	return nil
+}
+
+// requireSettled 归档前所有作答都已交卷，所有提交都已评分
+func (m *examStateMachine) requireSettled(exam *models.Exam, comment string) error {
+	active, err := m.attemptRepository.CountActiveByExam(exam.ID)
+	if err != nil {
+		return err
+	}
+	if active > 0 {
+		return fmt.Errorf("还有%d名学生正在作答，不能归档", active)
+	}
+	pending, err := m.submissionRepository.CountByExamStatus(exam.ID, models.SubmissionPendingReview)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("还有%d份提交等待评分，不能归档", pending)
+	}
+	return nil
+}
+
+// assignStudents 考试发布后分配给所有学生，已分配的学生跳过
+func (m *examStateMachine) assignStudents(exam *models.Exam) error {
+	students, err := m.userRepository.ListByRole(models.RoleStudent)
+	if err != nil {
+		return err
+	}
+	for _, student := range students {
+		if existing, err := m.examRepository.GetExamDataByExamAndStudent(exam.ID, student.ID); err == nil && existing != nil {
+			continue
+		}
+		examData := &models.ExamData{
+			ExamID:     exam.ID,
+			StudentID:  student.ID,
+			Title:      exam.Title,
+			Course:     exam.Course,
+			TotalScore: exam.TotalScore,
+			Status:     "assigned",
+		}
+		if err := m.examRepository.CreateExamData(examData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// containsString 判断values中是否包含value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
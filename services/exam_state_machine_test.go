@@ -0,0 +1,222 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/models"
+)
+
+func TestExamStateMachineFire(t *testing.T) {
+	type step struct {
+		actor   string // teacher 考试创建者，other 其他教师，admin，student
+		action  string
+		comment string
+		status  string // 执行后考试的状态
+		err     string // 期望的错误，为空表示成功
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "完整流程",
+			steps: []step{
+				{actor: "teacher", action: models.ExamActionSubmit, status: models.StatusPending},
+				{actor: "admin", action: models.ExamActionApprove, comment: "同意", status: models.StatusApproved},
+				{actor: "teacher", action: models.ExamActionPublish, status: models.StatusPublished},
+				{actor: "admin", action: models.ExamActionClose, status: models.StatusClosed},
+				{actor: "teacher", action: models.ExamActionArchive, status: models.StatusArchived},
+			},
+		},
+		{
+			name: "拒绝后修改重新提交",
+			steps: []step{
+				{actor: "teacher", action: models.ExamActionSubmit, status: models.StatusPending},
+				{actor: "admin", action: models.ExamActionReject, status: models.StatusPending, err: "必须填写理由"},
+				{actor: "admin", action: models.ExamActionReject, comment: "分值不合理", status: models.StatusRejected},
+				{actor: "teacher", action: models.ExamActionSubmit, status: models.StatusPending},
+			},
+		},
+		{
+			name: "角色和创建者检查",
+			steps: []step{
+				{actor: "student", action: models.ExamActionSubmit, status: models.StatusDraft, err: ErrTransitionForbidden.Error()},
+				{actor: "other", action: models.ExamActionSubmit, status: models.StatusDraft, err: ErrTransitionForbidden.Error()},
+				{actor: "teacher", action: models.ExamActionSubmit, status: models.StatusPending},
+				{actor: "teacher", action: models.ExamActionApprove, status: models.StatusPending, err: ErrTransitionForbidden.Error()},
+				{actor: "other", action: models.ExamActionApprove, status: models.StatusPending, err: ErrTransitionForbidden.Error()},
+			},
+		},
+		{
+			name: "当前状态不允许",
+			steps: []step{
+				{actor: "admin", action: models.ExamActionApprove, status: models.StatusDraft, err: "考试当前为草稿状态，不能审批通过"},
+				{actor: "teacher", action: models.ExamActionPublish, status: models.StatusDraft, err: "不能发布"},
+				{actor: "teacher", action: "delete", status: models.StatusDraft, err: "未知的考试操作"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			actors := map[string]*models.User{
+				"teacher": env.user(t, models.RoleTeacher),
+				"other":   env.user(t, models.RoleTeacher),
+				"admin":   env.user(t, models.RoleAdmin),
+				"student": env.user(t, models.RoleStudent),
+			}
+			exam, _ := env.draftExam(t, actors["teacher"], "数学")
+
+			for i, s := range tt.steps {
+				_, err := env.exam.TransitionExam(exam.ID, actors[s.actor].ID, s.action, s.comment)
+				switch {
+				case s.err == "" && err != nil:
+					t.Fatalf("第%d步 %s: %v", i+1, s.action, err)
+				case s.err != "" && (err == nil || !strings.Contains(err.Error(), s.err)):
+					t.Fatalf("第%d步 %s: error = %v, 期望包含 %q", i+1, s.action, err, s.err)
+				}
+				current, err := env.exam.GetExamByID(exam.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if current.Status != s.status {
+					t.Fatalf("第%d步 %s 后状态为 %s, 期望 %s", i+1, s.action, current.Status, s.status)
+				}
+			}
+		})
+	}
+}
+
+func TestExamStateMachineEffects(t *testing.T) {
+	env := newTestEnv(t)
+	teacher := env.user(t, models.RoleTeacher)
+	admin := env.user(t, models.RoleAdmin)
+	students := []*models.User{env.user(t, models.RoleStudent), env.user(t, models.RoleStudent)}
+	exam, paper := env.draftExam(t, teacher, "数学")
+
+	env.fire(t, exam, teacher, models.ExamActionSubmit, "")
+	env.fire(t, exam, admin, models.ExamActionApprove, "同意")
+
+	// 审批通过后试卷锁定，审批意见保存为审批评论
+	locked, err := env.papers.GetByID(paper.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !locked.Locked() || locked.Status != models.StatusApproved {
+		t.Errorf("审批通过后试卷未锁定: locked_at=%v status=%s", locked.LockedAt, locked.Status)
+	}
+	var comments []models.Comment
+	configs.DB().Where("exam_id = ?", exam.ID).Find(&comments)
+	if len(comments) != 1 || comments[0].Kind != models.CommentKindReview || comments[0].Content != "同意" {
+		t.Errorf("审批评论 = %+v", comments)
+	}
+
+	// 发布后分配给所有学生，重复分配时跳过
+	env.fire(t, exam, teacher, models.ExamActionPublish, "")
+	assigned, err := env.examData.ListByExam(exam.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assigned) != len(students) {
+		t.Errorf("发布后分配给 %d 名学生, 期望 %d", len(assigned), len(students))
+	}
+
+	history, err := env.exam.ListTransitions(exam.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, transition := range history {
+		actions = append(actions, transition.Action)
+	}
+	want := []string{models.ExamActionCreate, models.ExamActionSubmit, models.ExamActionApprove, models.ExamActionPublish}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("状态变更记录 = %v, 期望 %v", actions, want)
+	}
+}
+
+// 变更后的处理失败时，审批决定、状态变更和评论一起回滚
+func TestExamStateMachineRollsBackFailedEffect(t *testing.T) {
+	env := newTestEnv(t)
+	teacher := env.user(t, models.RoleTeacher)
+	admin := env.user(t, models.RoleAdmin)
+	exam, _ := env.draftExam(t, teacher, "数学")
+	env.fire(t, exam, teacher, models.ExamActionSubmit, "")
+
+	db := configs.DB()
+	err := db.Exec(`CREATE TRIGGER fail_lock BEFORE INSERT ON paper_audit_events BEGIN SELECT RAISE(ABORT, 'lock failed'); END`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.exam.TransitionExam(exam.ID, admin.ID, models.ExamActionApprove, "同意"); err == nil {
+		t.Fatal("锁定试卷失败时审批通过应返回错误")
+	}
+
+	current, _ := env.exam.GetExamByID(exam.ID)
+	if current.Status != models.StatusPending {
+		t.Errorf("状态 = %s, 期望仍为待审批", current.Status)
+	}
+	var transitions, comments, locked int
+	db.Table("exam_transitions").Where("exam_id = ? AND action = ?", exam.ID, models.ExamActionApprove).Count(&transitions)
+	db.Table("comments").Where("exam_id = ?", exam.ID).Count(&comments)
+	db.Table("papers").Where("exam_id = ? AND locked_at IS NOT NULL", exam.ID).Count(&locked)
+	if transitions != 0 || comments != 0 || locked != 0 {
+		t.Errorf("回滚后仍有 %d 条状态变更记录、%d 条评论、%d 份锁定的试卷", transitions, comments, locked)
+	}
+}
+
+func TestExamStateMachineArchiveRequiresSettled(t *testing.T) {
+	env := newTestEnv(t)
+	teacher := env.user(t, models.RoleTeacher)
+	admin := env.user(t, models.RoleAdmin)
+	env.user(t, models.RoleStudent)
+	exam, _ := env.draftExam(t, teacher, "数学")
+	env.fire(t, exam, teacher, models.ExamActionSubmit, "")
+	env.fire(t, exam, admin, models.ExamActionApprove, "")
+	exam = env.fire(t, exam, teacher, models.ExamActionPublish, "")
+
+	assigned, _ := env.examData.ListByExam(exam.ID)
+	if _, err := env.attempt.Start(exam, &assigned[0]); err != nil {
+		t.Fatal(err)
+	}
+	env.fire(t, exam, teacher, models.ExamActionClose, "")
+	_, err := env.exam.TransitionExam(exam.ID, teacher.ID, models.ExamActionArchive, "")
+	if err == nil || !strings.Contains(err.Error(), "正在作答") {
+		t.Fatalf("有学生作答中时归档: error = %v", err)
+	}
+}
+
+func TestExamActions(t *testing.T) {
+	env := newTestEnv(t)
+	teacher := env.user(t, models.RoleTeacher)
+	other := env.user(t, models.RoleTeacher)
+	admin := env.user(t, models.RoleAdmin)
+	student := env.user(t, models.RoleStudent)
+	exam, _ := env.draftExam(t, teacher, "数学")
+	pending := env.fire(t, exam, teacher, models.ExamActionSubmit, "")
+
+	tests := []struct {
+		name string
+		exam *models.Exam
+		user *models.User
+		want []string
+	}{
+		{"创建者的草稿", &models.Exam{ID: exam.ID, CreatorID: teacher.ID, Status: models.StatusDraft}, teacher, []string{models.ExamActionSubmit}},
+		{"其他教师的草稿", &models.Exam{ID: exam.ID, CreatorID: teacher.ID, Status: models.StatusDraft}, other, nil},
+		{"管理员审批", pending, admin, []string{models.ExamActionApprove, models.ExamActionReject}},
+		{"创建者不能审批", pending, teacher, nil},
+		{"管理员关闭", &models.Exam{ID: exam.ID, CreatorID: teacher.ID, Status: models.StatusPublished}, admin, []string{models.ExamActionClose}},
+		{"学生", &models.Exam{ID: exam.ID, CreatorID: teacher.ID, Status: models.StatusClosed}, student, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := env.exam.ExamActions(tt.exam, tt.user); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExamActions() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
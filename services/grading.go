@@ -0,0 +1,143 @@
+package services
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/exam-approval-system/models"
+)
+
+// AutoGrade 为提交中的客观题和未作答的题目自动评分，主观题留待教师评分
+// questions 以题目ID为键，最后更新提交的总分和评分状态
+func AutoGrade(submission *models.Submission, questions map[uint]*models.Question) {
+	now := time.Now()
+	for i := range submission.Answers {
+		answer := &submission.Answers[i]
+		question := questions[answer.QuestionID]
+		if question == nil || answer.IsGraded() {
+			continue
+		}
+
+		score, ok := GradeResponse(question, answer.Response)
+		if !ok {
+			continue
+		}
+		answer.Score = &score
+		answer.AutoGraded = true
+		answer.GradedAt = &now
+	}
+	submission.UpdateScore()
+}
+
+// GradeResponse 按题型计算单题得分，主观题且已作答时返回false表示需要人工评分
+func GradeResponse(question *models.Question, response string) (float64, bool) {
+	response = strings.TrimSpace(response)
+	if response == "" {
+		return 0, true
+	}
+
+	switch question.Type {
+	case models.QuestionSingleChoice:
+		if response == strings.Join(correctLabels(question), ",") {
+			return question.Score, true
+		}
+		return 0, true
+
+	case models.QuestionMultipleChoice:
+		return gradeMultipleChoice(question, response), true
+
+	case models.QuestionTrueFalse:
+		if strings.EqualFold(response, question.Answer) {
+			return question.Score, true
+		}
+		return 0, true
+
+	case models.QuestionFillBlank:
+		if matchBlank(question.MatchMode, question.Answer, response) {
+			return question.Score, true
+		}
+		return 0, true
+
+	case models.QuestionNumeric:
+		expected, err := strconv.ParseFloat(question.Answer, 64)
+		if err != nil {
+			return 0, false
+		}
+		actual, err := strconv.ParseFloat(response, 64)
+		if err == nil && math.Abs(actual-expected) <= question.Tolerance+scoreEpsilon {
+			return question.Score, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// gradeMultipleChoice 按部分得分规则计算多选题得分
+func gradeMultipleChoice(question *models.Question, response string) float64 {
+	correct := make(map[string]bool)
+	for _, label := range correctLabels(question) {
+		correct[label] = true
+	}
+
+	right, wrong := 0, 0
+	for _, label := range strings.Split(response, ",") {
+		if correct[label] {
+			right++
+		} else {
+			wrong++
+		}
+	}
+
+	switch question.PartialCredit {
+	case models.PartialCreditProportional:
+		if wrong > 0 {
+			return 0
+		}
+		return roundScore(question.Score * float64(right) / float64(len(correct)))
+	case models.PartialCreditPenalty:
+		return roundScore(math.Max(0, question.Score*float64(right-wrong)/float64(len(correct))))
+	default:
+		if wrong == 0 && right == len(correct) {
+			return question.Score
+		}
+		return 0
+	}
+}
+
+// correctLabels 返回按顺序排列的正确选项标签
+func correctLabels(question *models.Question) []string {
+	var labels []string
+	for _, option := range question.Options {
+		if option.IsCorrect {
+			labels = append(labels, option.Label)
+		}
+	}
+	return labels
+}
+
+// whitespace 匹配连续空白
+var whitespace = regexp.MustCompile(`\s+`)
+
+// matchBlank 按匹配方式比较填空题作答与标准答案
+func matchBlank(mode, expected, actual string) bool {
+	expected = strings.TrimSpace(expected)
+	switch mode {
+	case models.MatchExact:
+		return actual == expected
+	case models.MatchIgnoreSpace:
+		return strings.EqualFold(whitespace.ReplaceAllString(actual, ""), whitespace.ReplaceAllString(expected, ""))
+	case models.MatchRegex:
+		re, err := regexp.Compile(`^(?:` + expected + `)$`)
+		return err == nil && re.MatchString(actual)
+	default:
+		return strings.EqualFold(whitespace.ReplaceAllString(actual, " "), whitespace.ReplaceAllString(expected, " "))
+	}
+}
+
+// roundScore 将得分保留两位小数
+func roundScore(score float64) float64 {
+	return math.Round(score*100) / 100
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+)
+
+// AnswerGrade 教师对一道题的评分，设置了评分标准的题目按维度评分，其余题目直接给分
+type AnswerGrade struct {
+	QuestionID uint             `json:"questionId"`
+	Score      *float64         `json:"score"`
+	Criteria   []CriterionGrade `json:"criteria"`
+	Feedback   string           `json:"feedback"`
+}
+
+// CriterionGrade 教师为评分维度选择的等级
+type CriterionGrade struct {
+	CriterionID uint `json:"criterionId"`
+	LevelID     uint `json:"levelId"`
+}
+
+// GradingService 人工评分服务接口
+type GradingService interface {
+	Grade(examData *models.ExamData, graderID uint, grades []AnswerGrade) (*models.Submission, error)
+	ScoreRange(examID uint) (total, passing float64, err error)
+	Report(examData *models.ExamData) (models.GradeResult, error)
+}
+
+// gradingService 人工评分服务实现
+type gradingService struct {
+	submissionRepository repositories.SubmissionRepository
+	examDataRepository   repositories.ExamDataRepository
+	paperRepository      repositories.PaperRepository
+	settingsService      SettingsService
+}
+
+// NewGradingService 创建人工评分服务
+func NewGradingService(submissionRepo repositories.SubmissionRepository, examDataRepo repositories.ExamDataRepository, paperRepo repositories.PaperRepository, settingsService SettingsService) GradingService {
+	return &gradingService{
+		submissionRepository: submissionRepo,
+		examDataRepository:   examDataRepo,
+		paperRepository:      paperRepo,
+		settingsService:      settingsService,
+	}
+}
+
+// ScoreRange 获取考试的总分和及格分，由考试下各试卷的设置汇总得出
+func (s *gradingService) ScoreRange(examID uint) (float64, float64, error) {
+	papers, err := s.paperRepository.GetByExamID(examID)
+	if err != nil {
+		return 0, 0, err
+	}
+	total, passing := models.ScoreRange(papers)
+	return total, passing, nil
+}
+
+// Report 按系统设置的等级制换算答题记录的成绩
+func (s *gradingService) Report(examData *models.ExamData) (models.GradeResult, error) {
+	total, passing, err := s.ScoreRange(examData.ExamID)
+	if err != nil {
+		return models.GradeResult{}, err
+	}
+	return models.MapGrade(s.settingsService.Get().GradeScale, examData.TotalScore, total, passing), nil
+}
+
+// Grade 为答题记录最近一次提交评分，所有题目都评分后提交的总分由各题得分汇总得出，
+// 答题记录的成绩按考试的计分方式由各次提交的得分汇总得出
+// 已自动评分的客观题也可以由教师改分，未提交评分的题目保留原有得分
+func (s *gradingService) Grade(examData *models.ExamData, graderID uint, grades []AnswerGrade) (*models.Submission, error) {
+	submission, err := s.submissionRepository.GetLatestByExamData(examData.ID)
+	if err != nil {
+		return nil, errors.New("学生尚未提交答案")
+	}
+
+	total, _, err := s.ScoreRange(examData.ExamID)
+	if err != nil {
+		return nil, err
+	}
+
+	answers := make(map[uint]*models.Answer, len(submission.Answers))
+	for i := range submission.Answers {
+		answers[submission.Answers[i].QuestionID] = &submission.Answers[i]
+	}
+
+	now := time.Now()
+	for _, grade := range grades {
+		answer := answers[grade.QuestionID]
+		if answer == nil && grade.QuestionID == 0 {
+			return nil, errors.New("该提交需要按题目评分")
+		}
+		if answer == nil {
+			return nil, fmt.Errorf("题目 %d 不在该提交中", grade.QuestionID)
+		}
+		if err := applyGrade(answer, grade, total); err != nil {
+			if answer.Question != nil {
+				return nil, fmt.Errorf("第%d题: %v", answer.Question.Position, err)
+			}
+			return nil, err
+		}
+		if grade.Score != nil || len(grade.Criteria) > 0 {
+			answer.AutoGraded = false
+			answer.GradedBy = graderID
+			answer.GradedAt = &now
+		}
+	}
+
+	submission.UpdateScore()
+	if submission.Status != models.SubmissionGraded {
+		return nil, errors.New("还有题目尚未评分")
+	}
+	if submission.Score > total+scoreEpsilon {
+		return nil, fmt.Errorf("总分(%g)不能超过考试总分(%g)", submission.Score, total)
+	}
+	if err := s.submissionRepository.SaveGrades(submission); err != nil {
+		return nil, err
+	}
+
+	submissions, err := s.submissionRepository.ListByExamData(examData.ID)
+	if err != nil {
+		return nil, err
+	}
+	examData.ApproverID = graderID
+	settleExamData(examData, submissions)
+	if err := s.examDataRepository.UpdateResult(examData); err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+// applyGrade 校验并写入单道题的评分，已评分的题目可以只提交反馈
+// 整卷作答的满分为考试总分
+func applyGrade(answer *models.Answer, grade AnswerGrade, examTotal float64) error {
+	answer.Feedback = grade.Feedback
+	if grade.Score == nil && len(grade.Criteria) == 0 {
+		if !answer.IsGraded() {
+			return errors.New("缺少得分")
+		}
+		return nil
+	}
+
+	question := answer.Question
+	if question != nil && question.HasRubric() {
+		if grade.Score != nil {
+			return errors.New("设置了评分标准的题目需要按评分维度评分")
+		}
+		return applyRubric(answer, question, grade.Criteria)
+	}
+
+	if len(grade.Criteria) > 0 {
+		return errors.New("该题没有评分标准")
+	}
+
+	max := examTotal
+	if question != nil {
+		max = question.Score
+	}
+	score := *grade.Score
+	if score < 0 || score > max+scoreEpsilon {
+		return fmt.Errorf("得分必须在0-%g之间", max)
+	}
+	answer.Score = &score
+	answer.CriterionScores = nil
+	return nil
+}
+
+// applyRubric 按评分维度计算得分，每个维度都必须选择一个等级
+func applyRubric(answer *models.Answer, question *models.Question, criteria []CriterionGrade) error {
+	selected := make(map[uint]uint, len(criteria))
+	for _, grade := range criteria {
+		if question.FindCriterion(grade.CriterionID) == nil {
+			return fmt.Errorf("评分维度 %d 不属于该题", grade.CriterionID)
+		}
+		selected[grade.CriterionID] = grade.LevelID
+	}
+
+	var total float64
+	answer.CriterionScores = nil
+	for _, criterion := range question.Rubric {
+		levelID, ok := selected[criterion.ID]
+		if !ok {
+			return fmt.Errorf("评分维度%s尚未评分", criterion.Name)
+		}
+		level := criterion.FindLevel(levelID)
+		if level == nil {
+			return fmt.Errorf("等级 %d 不属于评分维度%s", levelID, criterion.Name)
+		}
+		total += level.Points
+		answer.CriterionScores = append(answer.CriterionScores, models.CriterionScore{
+			CriterionID: criterion.ID,
+			LevelID:     level.ID,
+			Points:      level.Points,
+		})
+	}
+
+	total = roundScore(total)
+	answer.Score = &total
+	return nil
+}
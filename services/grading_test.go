@@ -0,0 +1,180 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exam-approval-system/models"
+)
+
+func TestGradeResponse(t *testing.T) {
+	options := func(correct ...string) []models.QuestionOption {
+		var result []models.QuestionOption
+		for _, label := range []string{"A", "B", "C", "D"} {
+			isCorrect := false
+			for _, c := range correct {
+				isCorrect = isCorrect || c == label
+			}
+			result = append(result, models.QuestionOption{Label: label, IsCorrect: isCorrect})
+		}
+		return result
+	}
+	single := &models.Question{Type: models.QuestionSingleChoice, Score: 4, Options: options("B")}
+	multiple := func(rule string) *models.Question {
+		return &models.Question{Type: models.QuestionMultipleChoice, Score: 6, PartialCredit: rule, Options: options("A", "B", "C")}
+	}
+	blank := func(mode, answer string) *models.Question {
+		return &models.Question{Type: models.QuestionFillBlank, Score: 2, MatchMode: mode, Answer: answer}
+	}
+
+	tests := []struct {
+		name     string
+		question *models.Question
+		response string
+		want     float64
+		wantOK   bool
+	}{
+		{"单选正确", single, "B", 4, true},
+		{"单选错误", single, "A", 0, true},
+		{"未作答", single, "  ", 0, true},
+		{"多选全对", multiple(models.PartialCreditNone), "A,B,C", 6, true},
+		{"多选漏选不得分", multiple(models.PartialCreditNone), "A,B", 0, true},
+		{"多选按比例得分", multiple(models.PartialCreditProportional), "A,B", 4, true},
+		{"多选按比例得分时错选不得分", multiple(models.PartialCreditProportional), "A,D", 0, true},
+		{"多选错选扣分", multiple(models.PartialCreditPenalty), "A,B,D", 2, true},
+		{"多选扣分最低为0", multiple(models.PartialCreditPenalty), "A,D", 0, true},
+		{"判断题忽略大小写", &models.Question{Type: models.QuestionTrueFalse, Score: 1, Answer: "true"}, "TRUE", 1, true},
+		{"填空忽略大小写和多余空白", blank(models.MatchIgnoreCase, "Hello World"), "hello   world", 2, true},
+		{"填空完全一致", blank(models.MatchExact, "Hello"), "hello", 0, true},
+		{"填空忽略所有空白", blank(models.MatchIgnoreSpace, "H2 O"), "h2o", 2, true},
+		{"填空正则需完整匹配", blank(models.MatchRegex, "colou?r"), "colours", 0, true},
+		{"填空正则", blank(models.MatchRegex, "colou?r"), "color", 2, true},
+		{"数值题在误差内", &models.Question{Type: models.QuestionNumeric, Score: 3, Answer: "3.14", Tolerance: 0.01}, "3.15", 3, true},
+		{"数值题超出误差", &models.Question{Type: models.QuestionNumeric, Score: 3, Answer: "3.14", Tolerance: 0.01}, "3.2", 0, true},
+		{"数值题作答不是数字", &models.Question{Type: models.QuestionNumeric, Score: 3, Answer: "3.14"}, "pi", 0, true},
+		{"主观题需人工评分", &models.Question{Type: models.QuestionEssay, Score: 10}, "论述", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := GradeResponse(tt.question, tt.response)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("GradeResponse(%q) = %g, %v, 期望 %g, %v", tt.response, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGradingServiceGrade(t *testing.T) {
+	score := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name      string
+		grades    func(essay *models.Question) []AnswerGrade
+		wantErr   string
+		wantScore float64
+	}{
+		{
+			name: "按评分维度评分",
+			grades: func(essay *models.Question) []AnswerGrade {
+				return []AnswerGrade{{QuestionID: essay.ID, Criteria: []CriterionGrade{
+					{CriterionID: essay.Rubric[0].ID, LevelID: essay.Rubric[0].Levels[0].ID},
+					{CriterionID: essay.Rubric[1].ID, LevelID: essay.Rubric[1].Levels[1].ID},
+				}}}
+			},
+			wantScore: 5 + 6 + 2,
+		},
+		{
+			name: "有评分标准时不能直接给分",
+			grades: func(essay *models.Question) []AnswerGrade {
+				return []AnswerGrade{{QuestionID: essay.ID, Score: score(8)}}
+			},
+			wantErr: "需要按评分维度评分",
+		},
+		{
+			name: "评分维度未全部评分",
+			grades: func(essay *models.Question) []AnswerGrade {
+				return []AnswerGrade{{QuestionID: essay.ID, Criteria: []CriterionGrade{
+					{CriterionID: essay.Rubric[0].ID, LevelID: essay.Rubric[0].Levels[0].ID},
+				}}}
+			},
+			wantErr: "尚未评分",
+		},
+		{
+			name:    "主观题缺少得分",
+			grades:  func(essay *models.Question) []AnswerGrade { return nil },
+			wantErr: "还有题目尚未评分",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			teacher := env.user(t, models.RoleTeacher)
+			admin := env.user(t, models.RoleAdmin)
+			student := env.user(t, models.RoleStudent)
+			exam := &models.Exam{
+				Title: "论文", Course: "语文", CreatorID: teacher.ID,
+				StartTime: time.Now().Add(-time.Hour), EndTime: time.Now().Add(time.Hour),
+			}
+			if err := env.exam.CreateExam(exam); err != nil {
+				t.Fatal(err)
+			}
+			paper := &models.Paper{
+				ExamID: exam.ID, Title: "作文", TotalScore: 15, PassingScore: 9,
+				Questions: []models.Question{
+					{Type: models.QuestionTrueFalse, Content: "已阅读要求", Score: 5, Answer: "true"},
+					{
+						Type: models.QuestionEssay, Content: "写一篇作文", Score: 10,
+						Rubric: []models.RubricCriterion{
+							{Name: "立意", Levels: []models.RubricLevel{{Label: "好", Points: 6}, {Label: "差", Points: 2}}},
+							{Name: "文采", Levels: []models.RubricLevel{{Label: "好", Points: 4}, {Label: "差", Points: 2}}},
+						},
+					},
+				},
+			}
+			if err := env.paper.CreatePaper(paper); err != nil {
+				t.Fatal(err)
+			}
+			env.fire(t, exam, teacher, models.ExamActionSubmit, "")
+			env.fire(t, exam, admin, models.ExamActionApprove, "")
+			exam = env.fire(t, exam, teacher, models.ExamActionPublish, "")
+			examData, _ := env.exams.GetExamDataByExamAndStudent(exam.ID, student.ID)
+			paper, _ = env.papers.GetByID(paper.ID)
+			essay := &paper.Questions[1]
+
+			if _, err := env.attempt.Start(exam, examData); err != nil {
+				t.Fatal(err)
+			}
+			submission, err := env.attempt.Submit(exam, examData, map[uint]string{paper.Questions[0].ID: "true", essay.ID: "……"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if submission.Status != models.SubmissionPendingReview {
+				t.Fatalf("提交状态 = %s, 期望等待批阅", submission.Status)
+			}
+
+			graded, err := env.grading.Grade(examData, teacher.ID, tt.grades(essay))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Grade() error = %v, 期望包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if graded.Status != models.SubmissionGraded || graded.Score != tt.wantScore {
+				t.Errorf("评分后状态 %s 得分 %g, 期望 %g", graded.Status, graded.Score, tt.wantScore)
+			}
+			saved, _ := env.examData.GetByID(examData.ID)
+			if saved.TotalScore != tt.wantScore || saved.Status != models.StatusApproved {
+				t.Errorf("答题记录成绩 %g 状态 %s", saved.TotalScore, saved.Status)
+			}
+			// 评分不改写考试的状态
+			current, _ := env.exam.GetExamByID(exam.ID)
+			if current.Status != models.StatusPublished {
+				t.Errorf("评分后考试状态为 %s", current.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/migrations"
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+)
+
+func TestMain(m *testing.M) {
+	// 迁移和服务的日志与测试结果无关
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// testEnv 连接到独立测试数据库的服务，组装方式与main.go一致
+type testEnv struct {
+	users       repositories.UserRepository
+	exams       repositories.ExamRepository
+	papers      repositories.PaperRepository
+	examData    repositories.ExamDataRepository
+	attempts    repositories.AttemptRepository
+	settings    SettingsService
+	userService UserService
+	approval    ApprovalService
+	paperLock   PaperLockService
+	exam        ExamService
+	paper       PaperService
+	bank        QuestionBankService
+	submission  SubmissionService
+	grading     GradingService
+	attempt     AttemptService
+	nextUserSeq int
+}
+
+// newTestEnv 为每个测试创建执行过全部迁移的SQLite数据库，测试结束后恢复原有的数据库配置和连接。
+// 服务测试只在SQLite上运行，MySQL和PostgreSQL只由configs包的替身驱动覆盖到OpenDB为止
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	savedConfig := configs.Database
+	configs.Database = configs.DatabaseConfig{Driver: configs.DriverSQLite, DSN: filepath.Join(t.TempDir(), "exam.db")}
+	db, err := configs.OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.NewMigrator(db).Up(); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+	saved := configs.SetDB(db)
+	t.Cleanup(func() {
+		// 恢复备份会替换连接，关闭的是测试结束时的当前连接
+		configs.SetDB(saved).Close()
+		configs.Database = savedConfig
+	})
+
+	env := &testEnv{
+		users:    repositories.NewUserRepository(),
+		exams:    repositories.NewExamRepository(),
+		papers:   repositories.NewPaperRepository(),
+		examData: repositories.NewExamDataRepository(),
+		attempts: repositories.NewAttemptRepository(),
+	}
+	submissionRepo := repositories.NewSubmissionRepository()
+	if env.settings, err = NewSettingsService(repositories.NewSettingsRepository(), env.users); err != nil {
+		t.Fatal(err)
+	}
+	env.approval = NewApprovalService(repositories.NewApprovalRepository(), env.exams, env.users, env.settings)
+	env.paperLock = NewPaperLockService(env.papers)
+	stateMachine := NewExamStateMachine(env.exams, env.users, submissionRepo, env.attempts, env.approval, env.paperLock)
+	env.exam = NewExamService(env.exams, env.users, env.papers, submissionRepo, env.attempts, stateMachine)
+	env.userService = NewUserService(env.users, repositories.NewSessionRepository(), repositories.NewApprovalRepository(), env.exam, env.settings)
+	env.bank = NewQuestionBankService(repositories.NewBankRepository())
+	env.paper = NewPaperService(env.papers, env.exams, env.bank)
+	env.submission = NewSubmissionService(submissionRepo, env.papers, env.examData)
+	env.grading = NewGradingService(submissionRepo, env.examData, env.papers, env.settings)
+	env.attempt = NewAttemptService(env.attempts, env.papers, env.examData, env.submission, env.settings)
+	return env
+}
+
+// user 创建指定角色的用户
+func (e *testEnv) user(t *testing.T, role string) *models.User {
+	t.Helper()
+	e.nextUserSeq++
+	user := &models.User{
+		Username: fmt.Sprintf("%s%d", role, e.nextUserSeq),
+		Password: "x",
+		Name:     fmt.Sprintf("%s %d", role, e.nextUserSeq),
+		Role:     role,
+	}
+	if err := e.users.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// draftExam 由教师创建一场正在开放的草稿考试，并为其创建一份包含单选题和判断题的试卷
+func (e *testEnv) draftExam(t *testing.T, creator *models.User, course string) (*models.Exam, *models.Paper) {
+	t.Helper()
+	exam := &models.Exam{
+		Title:     "期中考试",
+		Course:    course,
+		StartTime: time.Now().Add(-time.Hour),
+		EndTime:   time.Now().Add(24 * time.Hour),
+		CreatorID: creator.ID,
+	}
+	if err := e.exam.CreateExam(exam); err != nil {
+		t.Fatal(err)
+	}
+	paper := &models.Paper{
+		ExamID:       exam.ID,
+		Title:        "试卷A",
+		Duration:     60,
+		TotalScore:   10,
+		PassingScore: 6,
+		Questions: []models.Question{
+			{
+				Type: models.QuestionSingleChoice, Content: "1+1=?", Score: 5,
+				Options: []models.QuestionOption{
+					{Label: "A", Content: "1"},
+					{Label: "B", Content: "2", IsCorrect: true},
+					{Label: "C", Content: "3"},
+				},
+			},
+			{Type: models.QuestionTrueFalse, Content: "地球是圆的", Score: 5, Answer: "true"},
+		},
+	}
+	if err := e.paper.CreatePaper(paper); err != nil {
+		t.Fatal(err)
+	}
+	return exam, paper
+}
+
+// fire 执行状态变更，失败时终止测试
+func (e *testEnv) fire(t *testing.T, exam *models.Exam, actor *models.User, action, comment string) *models.Exam {
+	t.Helper()
+	updated, err := e.exam.TransitionExam(exam.ID, actor.ID, action, comment)
+	if err != nil {
+		t.Fatalf("%s: %v", action, err)
+	}
+	return updated
+}
+
+// publishedExam 创建并发布一场考试，返回考试、试卷和分配给学生的答题记录
+func (e *testEnv) publishedExam(t *testing.T, student *models.User) (*models.Exam, *models.Paper, *models.ExamData) {
+	t.Helper()
+	teacher := e.user(t, models.RoleTeacher)
+	admin := e.user(t, models.RoleAdmin)
+	exam, paper := e.draftExam(t, teacher, "数学")
+	e.fire(t, exam, teacher, models.ExamActionSubmit, "")
+	e.fire(t, exam, admin, models.ExamActionApprove, "")
+	exam = e.fire(t, exam, teacher, models.ExamActionPublish, "")
+	examData, err := e.exams.GetExamDataByExamAndStudent(exam.ID, student.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paper, err = e.papers.GetByID(paper.ID); err != nil {
+		t.Fatal(err)
+	}
+	return exam, paper, examData
+}
+
+// execSQL 绕过服务直接修改数据库
+func execSQL(t *testing.T, sql string, values ...interface{}) {
+	t.Helper()
+	if err := configs.DB().Exec(sql, values...).Error; err != nil {
+		t.Fatal(err)
+	}
+}
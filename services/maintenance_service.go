@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// 维护模式相关错误
+var (
+	ErrMaintenance = errors.New("系统维护中，请稍后重试")
+	// ErrMaintenanceBusy 等待进行中的请求结束超时，未进入维护模式
+	ErrMaintenanceBusy = errors.New("仍有请求未结束，请稍后重试")
+)
+
+// MaintenanceService 维护模式服务接口。请求和后台任务在使用数据库前调用 Enter、结束后调用 Leave；
+// 恢复备份等需要替换数据库的操作调用 Begin 拒绝新的操作并等待进行中的操作结束
+type MaintenanceService interface {
+	Enter() error
+	Leave()
+	Begin(timeout time.Duration) error
+	End()
+	Active() bool
+}
+
+// maintenanceService 维护模式服务实现
+type maintenanceService struct {
+	mu       sync.Mutex
+	inFlight int           // 进行中的操作数量
+	active   bool          // 是否处于维护模式
+	drained  chan struct{} // 维护模式下进行中的操作全部结束时关闭
+}
+
+// NewMaintenanceService 创建维护模式服务
+func NewMaintenanceService() MaintenanceService {
+	return &maintenanceService{}
+}
+
+// Enter 开始一个使用数据库的操作，维护模式下返回ErrMaintenance
+func (s *maintenanceService) Enter() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active {
+		return ErrMaintenance
+	}
+	s.inFlight++
+	return nil
+}
+
+// Leave 结束Enter开始的操作
+func (s *maintenanceService) Leave() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inFlight--
+	if s.active && s.inFlight == 0 {
+		close(s.drained)
+	}
+}
+
+// Begin 进入维护模式并等待进行中的操作结束，超过timeout时退出维护模式并返回ErrMaintenanceBusy。
+// 成功后调用方完成维护时需调用End
+func (s *maintenanceService) Begin(timeout time.Duration) error {
+	s.mu.Lock()
+	if s.active {
+		s.mu.Unlock()
+		return ErrMaintenance
+	}
+	s.active = true
+	drained := make(chan struct{})
+	if s.inFlight == 0 {
+		close(drained)
+	}
+	s.drained = drained
+	s.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+		return nil
+	case <-timer.C:
+		s.End()
+		return ErrMaintenanceBusy
+	}
+}
+
+// End 退出维护模式
+func (s *maintenanceService) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active = false
+}
+
+// Active 判断是否处于维护模式
+func (s *maintenanceService) Active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
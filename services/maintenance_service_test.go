@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceBegin(t *testing.T) {
+	tests := []struct {
+		name     string
+		inFlight int
+		leave    int // Begin开始等待后结束的操作数量
+		wantErr  error
+	}{
+		{name: "没有进行中的操作", inFlight: 0},
+		{name: "等待进行中的操作结束", inFlight: 2, leave: 2},
+		{name: "操作未结束时超时", inFlight: 2, leave: 1, wantErr: ErrMaintenanceBusy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMaintenanceService()
+			for i := 0; i < tt.inFlight; i++ {
+				if err := m.Enter(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			go func() {
+				for i := 0; i < tt.leave; i++ {
+					time.Sleep(10 * time.Millisecond)
+					m.Leave()
+				}
+			}()
+
+			err := m.Begin(200 * time.Millisecond)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Begin() error = %v, 期望 %v", err, tt.wantErr)
+			}
+			if m.Active() != (tt.wantErr == nil) {
+				t.Errorf("Active() = %v", m.Active())
+			}
+			if tt.wantErr != nil {
+				// 超时后退出维护模式，新的操作可以开始
+				if err := m.Enter(); err != nil {
+					t.Errorf("超时后 Enter() error = %v", err)
+				}
+				return
+			}
+
+			if err := m.Enter(); !errors.Is(err, ErrMaintenance) {
+				t.Errorf("维护模式下 Enter() error = %v, 期望 %v", err, ErrMaintenance)
+			}
+			if err := m.Begin(time.Millisecond); !errors.Is(err, ErrMaintenance) {
+				t.Errorf("重复进入维护模式 error = %v, 期望 %v", err, ErrMaintenance)
+			}
+			m.End()
+			if err := m.Enter(); err != nil {
+				t.Errorf("退出维护模式后 Enter() error = %v", err)
+			}
+		})
+	}
+}
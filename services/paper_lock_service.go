@@ -0,0 +1,193 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrPaperNotFound 试卷不存在
+	ErrPaperNotFound = errors.New("试卷不存在")
+	// ErrPaperTampered 试卷内容与审批通过时锁定的内容不一致
+	ErrPaperTampered = errors.New("试卷内容与审批通过时不一致，已停止提供，请联系管理员")
+	// ErrPaperNotLocked 已审批考试的试卷没有锁定，例如审批通过时锁定失败或恢复了旧的备份
+	ErrPaperNotLocked = errors.New("试卷未在审批通过时锁定，已停止提供，请联系管理员")
+)
+
+// PaperLockService 试卷锁定服务接口：考试审批通过时锁定各试卷内容的哈希，
+// 读取已审批考试的试卷时校验内容未被修改，不一致时拒绝提供并写入审计事件
+type PaperLockService interface {
+	WithTx(tx *gorm.DB) PaperLockService
+	Lock(exam *models.Exam) error
+	Verify(exam *models.Exam, papers []models.Paper, userID uint, source string) error
+	VerifyExam(exam *models.Exam, userID uint, source string) error
+	Check(paperID uint) (*models.PaperIntegrity, error)
+	ListEvents(examID, paperID uint, event string) ([]models.PaperAuditEvent, error)
+}
+
+// paperLockService 试卷锁定服务实现
+type paperLockService struct {
+	paperRepository repositories.PaperRepository
+}
+
+// NewPaperLockService 创建试卷锁定服务
+func NewPaperLockService(paperRepo repositories.PaperRepository) PaperLockService {
+	return &paperLockService{paperRepository: paperRepo}
+}
+
+// WithTx 返回在事务tx中锁定和校验试卷的服务
+func (s *paperLockService) WithTx(tx *gorm.DB) PaperLockService {
+	return &paperLockService{paperRepository: s.paperRepository.WithTx(tx)}
+}
+
+// Lock 锁定考试的全部试卷，作为审批通过后的处理执行，审计事件记为审批人锁定
+func (s *paperLockService) Lock(exam *models.Exam) error {
+	papers, err := s.paperRepository.GetByExamID(exam.ID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range papers {
+		paper := &papers[i]
+		hash, err := paper.ContentHash()
+		if err != nil {
+			return err
+		}
+		locked, err := s.paperRepository.Lock(paper, hash, now)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			continue
+		}
+		err = s.paperRepository.AddAuditEvent(&models.PaperAuditEvent{
+			PaperID:      paper.ID,
+			ExamID:       exam.ID,
+			Event:        models.PaperAuditLocked,
+			ExpectedHash: hash,
+			ActualHash:   hash,
+			Source:       models.PaperSourceApprove,
+			UserID:       exam.ApproverID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify 校验已审批考试的试卷内容与锁定时一致，未审批的考试不校验。
+// 内容不一致时为每份这样的试卷写入审计事件，并返回ErrPaperTampered；
+// 试卷未锁定时没有可比较的哈希，不写入审计事件，返回ErrPaperNotLocked
+func (s *paperLockService) Verify(exam *models.Exam, papers []models.Paper, userID uint, source string) error {
+	if !exam.Locked() {
+		return nil
+	}
+	tampered, unlocked := false, false
+	for i := range papers {
+		paper := &papers[i]
+		if !paper.Locked() {
+			unlocked = true
+			continue
+		}
+		hash, err := paper.ContentHash()
+		if err != nil {
+			return err
+		}
+		if hash == paper.LockedHash {
+			continue
+		}
+
+		tampered = true
+		log.Printf("试卷 %d（考试 %d）内容与锁定时不一致，锁定哈希 %q，当前哈希 %q，已拒绝用户 %d 通过 %s 读取",
+			paper.ID, exam.ID, paper.LockedHash, hash, userID, source)
+		err = s.paperRepository.AddAuditEvent(&models.PaperAuditEvent{
+			PaperID:      paper.ID,
+			ExamID:       exam.ID,
+			Event:        models.PaperAuditTampered,
+			ExpectedHash: paper.LockedHash,
+			ActualHash:   hash,
+			Source:       source,
+			UserID:       userID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if tampered {
+		return ErrPaperTampered
+	}
+	if unlocked {
+		return ErrPaperNotLocked
+	}
+	return nil
+}
+
+// VerifyExam 加载考试的全部试卷并校验
+func (s *paperLockService) VerifyExam(exam *models.Exam, userID uint, source string) error {
+	if !exam.Locked() {
+		return nil
+	}
+	papers, err := s.paperRepository.GetByExamID(exam.ID)
+	if err != nil {
+		return err
+	}
+	return s.Verify(exam, papers, userID, source)
+}
+
+// Check 检查试卷的锁定状态和内容是否完整，不写入审计事件。
+// 内容与锁定时不一致时附带锁定版本与当前内容的差异，以及该试卷的审计事件
+func (s *paperLockService) Check(paperID uint) (*models.PaperIntegrity, error) {
+	paper, err := s.paperRepository.GetByID(paperID)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrPaperNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	hash, err := paper.ContentHash()
+	if err != nil {
+		return nil, err
+	}
+	integrity := &models.PaperIntegrity{
+		PaperID:        paper.ID,
+		ExamID:         paper.ExamID,
+		Locked:         paper.Locked(),
+		LockedAt:       paper.LockedAt,
+		LockedRevision: paper.LockedRevision,
+		LockedHash:     paper.LockedHash,
+		CurrentHash:    hash,
+		Intact:         paper.Locked() && hash == paper.LockedHash,
+	}
+
+	if integrity.Locked && !integrity.Intact && paper.LockedRevision != 0 {
+		locked, err := s.paperRepository.GetRevision(paper.ID, paper.LockedRevision)
+		if err == nil && locked.ParseQuestions() == nil {
+			current, err := models.NewPaperRevision(paper)
+			if err != nil {
+				return nil, err
+			}
+			if err := current.ParseQuestions(); err != nil {
+				return nil, err
+			}
+			integrity.Diff = models.DiffPaperRevisions(locked, current)
+		} else if err != nil && !gorm.IsRecordNotFoundError(err) {
+			return nil, err
+		}
+	}
+
+	if integrity.Events, err = s.ListEvents(0, paper.ID, ""); err != nil {
+		return nil, err
+	}
+	return integrity, nil
+}
+
+// ListEvents 按条件获取试卷审计事件，最新的在前
+func (s *paperLockService) ListEvents(examID, paperID uint, event string) ([]models.PaperAuditEvent, error) {
+	return s.paperRepository.ListAuditEvents(examID, paperID, event)
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/exam-approval-system/models"
+)
+
+func TestPaperLockVerify(t *testing.T) {
+	tests := []struct {
+		name       string
+		tamper     func(t *testing.T, paper *models.Paper)
+		wantErr    error
+		wantEvents int // 读取一次后写入的篡改事件数
+		wantIntact bool
+	}{
+		{
+			name:       "内容未修改",
+			wantIntact: true,
+		},
+		{
+			name: "直接修改题目答案",
+			tamper: func(t *testing.T, paper *models.Paper) {
+				execSQL(t, "UPDATE questions SET answer = 'false' WHERE id = ?", paper.Questions[1].ID)
+			},
+			wantErr:    ErrPaperTampered,
+			wantEvents: 1,
+		},
+		{
+			name: "直接修改正确选项",
+			tamper: func(t *testing.T, paper *models.Paper) {
+				execSQL(t, "UPDATE question_options SET is_correct = NOT is_correct WHERE question_id = ?", paper.Questions[0].ID)
+			},
+			wantErr:    ErrPaperTampered,
+			wantEvents: 1,
+		},
+		{
+			name: "审批通过但未锁定",
+			tamper: func(t *testing.T, paper *models.Paper) {
+				execSQL(t, "UPDATE papers SET locked_at = NULL, locked_hash = '' WHERE id = ?", paper.ID)
+			},
+			wantErr: ErrPaperNotLocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			teacher := env.user(t, models.RoleTeacher)
+			admin := env.user(t, models.RoleAdmin)
+			exam, paper := env.draftExam(t, teacher, "数学")
+			env.fire(t, exam, teacher, models.ExamActionSubmit, "")
+			exam = env.fire(t, exam, admin, models.ExamActionApprove, "")
+
+			locked, _ := env.papers.GetByID(paper.ID)
+			if locked.LockedRevision != 1 {
+				t.Errorf("LockedRevision = %d, 期望 1", locked.LockedRevision)
+			}
+			if tt.tamper != nil {
+				tt.tamper(t, locked)
+			}
+
+			// 读取两次，每次读取都拒绝并记录事件
+			for i := 1; i <= 2; i++ {
+				err := env.paperLock.VerifyExam(exam, teacher.ID, models.PaperSourceExamView)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("第%d次读取 error = %v, 期望 %v", i, err, tt.wantErr)
+				}
+				events, err := env.paperLock.ListEvents(exam.ID, 0, models.PaperAuditTampered)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(events) != tt.wantEvents*i {
+					t.Errorf("第%d次读取后有 %d 条篡改事件, 期望 %d", i, len(events), tt.wantEvents*i)
+				}
+			}
+
+			integrity, err := env.paperLock.Check(paper.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if integrity.Intact != tt.wantIntact {
+				t.Errorf("Intact = %v, 期望 %v", integrity.Intact, tt.wantIntact)
+			}
+			if tt.wantEvents > 0 && (integrity.Diff == nil || len(integrity.Diff.Changed) != 1) {
+				t.Errorf("篡改后的差异 = %+v, 期望一道题目被修改", integrity.Diff)
+			}
+		})
+	}
+}
+
+// 未审批的考试不校验试卷
+func TestPaperLockVerifySkipsDraft(t *testing.T) {
+	env := newTestEnv(t)
+	teacher := env.user(t, models.RoleTeacher)
+	exam, paper := env.draftExam(t, teacher, "数学")
+	execSQL(t, "UPDATE questions SET answer = 'false' WHERE paper_id = ?", paper.ID)
+	if err := env.paperLock.VerifyExam(exam, teacher.ID, models.PaperSourceExamView); err != nil {
+		t.Fatalf("草稿考试 VerifyExam() error = %v", err)
+	}
+}
@@ -1,36 +1,53 @@
 package services
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/exam-approval-system/models"
 	"github.com/exam-approval-system/repositories"
 	"github.com/exam-approval-system/utils"
+	"github.com/jinzhu/gorm"
 )
 
+// ErrPaperRevisionNotFound 试卷版本不存在
+var ErrPaperRevisionNotFound = errors.New("试卷版本不存在")
+
 // PaperService 试卷服务接口
 type PaperService interface {
 	CreatePaper(paper *models.Paper) error
+	GeneratePaper(paper *models.Paper, blueprint *models.Blueprint, dryRun bool) error
 	GetPaperByID(id uint) (*models.Paper, error)
 	GetPapersByExamID(examID uint) ([]models.Paper, error)
-	UpdatePaper(paper *models.Paper) error
+	UpdatePaper(paper *models.Paper, editorID uint) error
 	DeletePaper(id uint) error
+	ListRevisions(paperID uint) ([]models.PaperRevision, error)
+	GetRevision(paperID uint, revision int) (*models.PaperRevision, error)
+	DiffRevisions(paperID uint, from, to int) (*models.PaperDiff, error)
+	RestoreRevision(paperID uint, revision int, editorID uint) (*models.Paper, error)
 	SignPaper(paperID uint, signerID uint) error
 	VerifyPaperSignature(paperID uint) (bool, error)
 }
 
 // paperService 试卷服务实现
 type paperService struct {
-	paperRepository repositories.PaperRepository
-	examRepository  repositories.ExamRepository
+	paperRepository     repositories.PaperRepository
+	examRepository      repositories.ExamRepository
+	questionBankService QuestionBankService
 }
 
 // NewPaperService 创建试卷服务
-func NewPaperService(paperRepo repositories.PaperRepository, examRepo repositories.ExamRepository) PaperService {
+func NewPaperService(paperRepo repositories.PaperRepository, examRepo repositories.ExamRepository, questionBankService QuestionBankService) PaperService {
 	return &paperService{
-		paperRepository: paperRepo,
-		examRepository:  examRepo,
+		paperRepository:     paperRepo,
+		examRepository:      examRepo,
+		questionBankService: questionBankService,
 	}
 }
 
@@ -45,9 +62,48 @@ func (s *paperService) CreatePaper(paper *models.Paper) error {
 		return errors.New("只能为草稿或被拒绝状态的考试创建试卷")
 	}
 
-	// 设置试卷状态为草稿
+	// 填充引用的题库题目，校验题目并检查分值之和
+	for i := range paper.Questions {
+		paper.Questions[i].ID = 0
+	}
+	if err := s.questionBankService.ResolveQuestions(paper.Questions, exam); err != nil {
+		return err
+	}
+	if err := ValidateQuestions(paper.Questions, paper.TotalScore); err != nil {
+		return err
+	}
+
+	// 设置试卷状态为草稿，第一个版本记为考试创建者编辑
 	paper.Status = models.StatusDraft
-	return s.paperRepository.Create(paper)
+	return s.paperRepository.Create(paper, exam.CreatorID)
+}
+
+// GeneratePaper 按组卷方案从题库抽题生成试卷，dryRun为true时只填充题目不保存，用于预览
+func (s *paperService) GeneratePaper(paper *models.Paper, blueprint *models.Blueprint, dryRun bool) error {
+	exam, err := s.examRepository.GetByID(paper.ExamID)
+	if err != nil {
+		return errors.New("考试不存在")
+	}
+
+	questions, err := s.questionBankService.Generate(blueprint, exam)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(blueprint)
+	if err != nil {
+		return err
+	}
+	paper.Questions = questions
+	paper.TotalScore = blueprint.TotalScore
+	paper.Blueprint = string(data)
+
+	if !dryRun {
+		return s.CreatePaper(paper)
+	}
+	if err := s.questionBankService.ResolveQuestions(paper.Questions, exam); err != nil {
+		return err
+	}
+	return ValidateQuestions(paper.Questions, paper.TotalScore)
 }
 
 // GetPaperByID 根据ID获取试卷
@@ -60,8 +116,8 @@ func (s *paperService) GetPapersByExamID(examID uint) ([]models.Paper, error) {
 	return s.paperRepository.GetByExamID(examID)
 }
 
-// UpdatePaper 更新试卷
-func (s *paperService) UpdatePaper(paper *models.Paper) error {
+// UpdatePaper 更新试卷，内容有变化时记录新版本
+func (s *paperService) UpdatePaper(paper *models.Paper, editorID uint) error {
 	// 检查关联的考试状态
 	exam, err := s.examRepository.GetByID(paper.ExamID)
 	if err != nil {
@@ -71,7 +127,31 @@ func (s *paperService) UpdatePaper(paper *models.Paper) error {
 		return errors.New("只能修改草稿或被拒绝状态的考试相关试卷")
 	}
 
-	return s.paperRepository.Update(paper)
+	// 已有ID的题目必须属于该试卷
+	existing, err := s.paperRepository.GetByID(paper.ID)
+	if err != nil {
+		return errors.New("试卷不存在")
+	}
+	owned := make(map[uint]bool, len(existing.Questions))
+	for _, question := range existing.Questions {
+		owned[question.ID] = true
+	}
+	for _, question := range paper.Questions {
+		if question.ID != 0 && !owned[question.ID] {
+			return fmt.Errorf("题目 %d 不属于该试卷", question.ID)
+		}
+	}
+
+	// 填充引用的题库题目，校验题目并检查分值之和
+	if err := s.questionBankService.ResolveQuestions(paper.Questions, exam); err != nil {
+		return err
+	}
+	if err := ValidateQuestions(paper.Questions, paper.TotalScore); err != nil {
+		return err
+	}
+
+	_, err = s.paperRepository.Revise(paper, editorID, 0)
+	return err
 }
 
 // DeletePaper 删除试卷
@@ -93,6 +173,91 @@ func (s *paperService) DeletePaper(id uint) error {
 	return s.paperRepository.Delete(id)
 }
 
+// ListRevisions 获取试卷的全部版本，按版本号倒序排列，不包含题目
+func (s *paperService) ListRevisions(paperID uint) ([]models.PaperRevision, error) {
+	return s.paperRepository.ListRevisions(paperID)
+}
+
+// GetRevision 获取试卷的指定版本及其题目
+func (s *paperService) GetRevision(paperID uint, revision int) (*models.PaperRevision, error) {
+	v, err := s.paperRepository.GetRevision(paperID, revision)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrPaperRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := v.ParseQuestions(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// DiffRevisions 比较试卷的两个版本。to为0时取最新版本，from为0时取to的上一个版本
+func (s *paperService) DiffRevisions(paperID uint, from, to int) (*models.PaperDiff, error) {
+	if to == 0 {
+		revisions, err := s.paperRepository.ListRevisions(paperID)
+		if err != nil {
+			return nil, err
+		}
+		if len(revisions) == 0 {
+			return nil, ErrPaperRevisionNotFound
+		}
+		to = revisions[0].Revision
+	}
+	if from == 0 {
+		from = to - 1
+		if from < 1 {
+			return nil, errors.New("试卷只有一个版本，没有可比较的版本")
+		}
+	}
+
+	older, err := s.GetRevision(paperID, from)
+	if err != nil {
+		return nil, err
+	}
+	newer, err := s.GetRevision(paperID, to)
+	if err != nil {
+		return nil, err
+	}
+	return models.DiffPaperRevisions(older, newer), nil
+}
+
+// RestoreRevision 将试卷恢复为指定版本的内容并记录为新版本，只能在考试为草稿或被拒绝时操作。
+// 该版本中之后被删除的题目按原ID重新创建，内容与当前版本相同时不记录新版本
+func (s *paperService) RestoreRevision(paperID uint, revision int, editorID uint) (*models.Paper, error) {
+	paper, err := s.paperRepository.GetByID(paperID)
+	if err != nil {
+		return nil, errors.New("试卷不存在")
+	}
+	exam, err := s.examRepository.GetByID(paper.ExamID)
+	if err != nil {
+		return nil, errors.New("考试不存在")
+	}
+	if exam.Status != models.StatusDraft && exam.Status != models.StatusRejected {
+		return nil, errors.New("只能在考试为草稿或被拒绝时恢复试卷版本")
+	}
+	v, err := s.GetRevision(paperID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	paper.Title = v.Title
+	paper.Content = v.Content
+	paper.Duration = v.Duration
+	paper.TotalScore = v.TotalScore
+	paper.PassingScore = v.PassingScore
+	paper.Questions = v.Questions
+	if err := ValidateQuestions(paper.Questions, paper.TotalScore); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.paperRepository.Revise(paper, editorID, revision); err != nil {
+		return nil, err
+	}
+	return paper, nil
+}
+
 // SignPaper 为试卷签名
 func (s *paperService) SignPaper(paperID uint, signerID uint) error {
 	// 获取试卷信息
@@ -112,7 +277,7 @@ func (s *paperService) SignPaper(paperID uint, signerID uint) error {
 		paper.ID,
 		paper.Title,
 		paper.Content,
-		paper.Questions,
+		paper.QuestionsText(),
 		now,
 	)
 
@@ -122,7 +287,7 @@ func (s *paperService) SignPaper(paperID uint, signerID uint) error {
 	paper.SignedBy = signerID
 
 	// 保存更新
-	return s.paperRepository.Update(paper)
+	return s.paperRepository.Sign(paper)
 }
 
 // VerifyPaperSignature 验证试卷签名
@@ -143,10 +308,202 @@ func (s *paperService) VerifyPaperSignature(paperID uint) (bool, error) {
 		paper.ID,
 		paper.Title,
 		paper.Content,
-		paper.Questions,
+		paper.QuestionsText(),
 		paper.SignedAt,
 		paper.Signature,
 	)
 
 	return isValid, nil
 }
+
+// scoreEpsilon 比较分值时允许的浮点误差
+const scoreEpsilon = 1e-6
+
+// ValidateQuestions 校验题目内容、答案和分值，并规范化题目顺序和选项标签
+// 所有题目分值之和必须等于试卷总分
+func ValidateQuestions(questions []models.Question, totalScore float64) error {
+	if len(questions) == 0 {
+		return errors.New("试卷至少需要一道题目")
+	}
+
+	var sum float64
+	for i := range questions {
+		question := &questions[i]
+		question.Position = i + 1
+		question.Content = strings.TrimSpace(question.Content)
+		question.Answer = strings.TrimSpace(question.Answer)
+
+		if err := validateQuestion(question); err != nil {
+			return fmt.Errorf("第%d题: %v", question.Position, err)
+		}
+		sum += question.Score
+	}
+
+	if math.Abs(sum-totalScore) > scoreEpsilon {
+		return fmt.Errorf("题目分值之和(%g)与试卷总分(%g)不一致", sum, totalScore)
+	}
+	return nil
+}
+
+// validateQuestion 按题型校验单道题目
+func validateQuestion(question *models.Question) error {
+	if question.Content == "" {
+		return errors.New("题目内容不能为空")
+	}
+	if question.Score <= 0 {
+		return errors.New("分值必须大于0")
+	}
+
+	switch question.Type {
+	case models.QuestionSingleChoice, models.QuestionMultipleChoice:
+		correct, err := normalizeOptions(question.Options)
+		if err != nil {
+			return err
+		}
+		if question.Type == models.QuestionSingleChoice && correct != 1 {
+			return errors.New("单选题必须有且只有一个正确选项")
+		}
+		if question.Type == models.QuestionMultipleChoice && correct == 0 {
+			return errors.New("多选题至少需要一个正确选项")
+		}
+		switch question.PartialCredit {
+		case models.PartialCreditNone, models.PartialCreditProportional, models.PartialCreditPenalty:
+		default:
+			return fmt.Errorf("未知的部分得分规则: %s", question.PartialCredit)
+		}
+		question.Answer = ""
+		return validateGradingRules(question)
+
+	case models.QuestionTrueFalse:
+		answer := strings.ToLower(question.Answer)
+		if answer != "true" && answer != "false" {
+			return errors.New("判断题答案必须是true或false")
+		}
+		question.Answer = answer
+
+	case models.QuestionFillBlank:
+		if question.Answer == "" {
+			return errors.New("填空题必须提供标准答案")
+		}
+		switch question.MatchMode {
+		case models.MatchIgnoreCase, models.MatchExact, models.MatchIgnoreSpace:
+		case models.MatchRegex:
+			if _, err := regexp.Compile(question.Answer); err != nil {
+				return fmt.Errorf("标准答案不是有效的正则表达式: %v", err)
+			}
+		default:
+			return fmt.Errorf("未知的匹配方式: %s", question.MatchMode)
+		}
+
+	case models.QuestionNumeric:
+		if _, err := strconv.ParseFloat(question.Answer, 64); err != nil {
+			return errors.New("数值题答案必须是数字")
+		}
+		if question.Tolerance < 0 {
+			return errors.New("允许误差不能为负数")
+		}
+
+	case models.QuestionShortAnswer, models.QuestionEssay:
+		// 主观题的参考答案可以为空，评分标准可选
+		if err := validateRubric(question); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("未知的题目类型: %s", question.Type)
+	}
+
+	if len(question.Options) > 0 {
+		return errors.New("该题型不能设置选项")
+	}
+	return validateGradingRules(question)
+}
+
+// validateGradingRules 检查评分规则是否与题型匹配
+func validateGradingRules(question *models.Question) error {
+	if question.PartialCredit != models.PartialCreditNone && question.Type != models.QuestionMultipleChoice {
+		return errors.New("只有多选题可以设置部分得分规则")
+	}
+	if question.MatchMode != models.MatchIgnoreCase && question.Type != models.QuestionFillBlank {
+		return errors.New("只有填空题可以设置匹配方式")
+	}
+	if question.Tolerance != 0 && question.Type != models.QuestionNumeric {
+		return errors.New("只有数值题可以设置允许误差")
+	}
+	if len(question.Rubric) > 0 && question.Type != models.QuestionShortAnswer && question.Type != models.QuestionEssay {
+		return errors.New("只有简答题和论述题可以设置评分标准")
+	}
+	return nil
+}
+
+// validateRubric 校验评分标准，各评分维度最高分之和必须等于题目分值
+func validateRubric(question *models.Question) error {
+	if len(question.Rubric) == 0 {
+		return nil
+	}
+
+	var sum float64
+	for i := range question.Rubric {
+		criterion := &question.Rubric[i]
+		criterion.Position = i + 1
+		criterion.Name = strings.TrimSpace(criterion.Name)
+		if criterion.Name == "" {
+			return fmt.Errorf("评分维度%d名称不能为空", criterion.Position)
+		}
+		if len(criterion.Levels) == 0 {
+			return fmt.Errorf("评分维度%s至少需要一个等级", criterion.Name)
+		}
+
+		for j := range criterion.Levels {
+			level := &criterion.Levels[j]
+			level.Position = j + 1
+			level.Label = strings.TrimSpace(level.Label)
+			if level.Label == "" {
+				return fmt.Errorf("评分维度%s的等级%d名称不能为空", criterion.Name, level.Position)
+			}
+			if level.Points < 0 {
+				return fmt.Errorf("评分维度%s的等级%s分数不能为负数", criterion.Name, level.Label)
+			}
+		}
+		sum += criterion.MaxPoints()
+	}
+
+	if math.Abs(sum-question.Score) > scoreEpsilon {
+		return fmt.Errorf("评分标准最高分之和(%g)与题目分值(%g)不一致", sum, question.Score)
+	}
+	return nil
+}
+
+// normalizeOptions 校验选择题选项，未填写标签时按顺序生成A、B、C…，返回正确选项数量
+func normalizeOptions(options []models.QuestionOption) (int, error) {
+	if len(options) < 2 {
+		return 0, errors.New("选择题至少需要两个选项")
+	}
+	if len(options) > 26 {
+		return 0, errors.New("选项不能超过26个")
+	}
+
+	labels := make(map[string]bool, len(options))
+	correct := 0
+	for i := range options {
+		option := &options[i]
+		option.Position = i + 1
+		option.Content = strings.TrimSpace(option.Content)
+		option.Label = strings.ToUpper(strings.TrimSpace(option.Label))
+		if option.Label == "" {
+			option.Label = string(rune('A' + i))
+		}
+
+		if option.Content == "" {
+			return 0, fmt.Errorf("选项%s内容不能为空", option.Label)
+		}
+		if labels[option.Label] {
+			return 0, fmt.Errorf("选项标签%s重复", option.Label)
+		}
+		labels[option.Label] = true
+		if option.IsCorrect {
+			correct++
+		}
+	}
+	return correct, nil
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/exam-approval-system/models"
+)
+
+func TestValidateQuestions(t *testing.T) {
+	options := func(correct ...bool) []models.QuestionOption {
+		var opts []models.QuestionOption
+		for i, c := range correct {
+			opts = append(opts, models.QuestionOption{Content: string(rune('1' + i)), IsCorrect: c})
+		}
+		return opts
+	}
+	single := func() models.Question {
+		return models.Question{Type: models.QuestionSingleChoice, Content: "1+1=?", Score: 5, Options: options(false, true)}
+	}
+
+	tests := []struct {
+		name      string
+		questions []models.Question
+		total     float64
+		wantErr   string
+	}{
+		{name: "没有题目", total: 0, wantErr: "至少需要一道题目"},
+		{name: "单选题", questions: []models.Question{single()}, total: 5},
+		{
+			name:      "分值之和与总分不一致",
+			questions: []models.Question{single(), {Type: models.QuestionTrueFalse, Content: "对吗", Score: 5, Answer: "true"}},
+			total:     100,
+			wantErr:   "题目分值之和(10)与试卷总分(100)不一致",
+		},
+		{
+			name:      "小数分值",
+			questions: []models.Question{{Type: models.QuestionTrueFalse, Content: "对吗", Score: 0.1, Answer: "false"}, {Type: models.QuestionTrueFalse, Content: "错吗", Score: 0.2, Answer: "true"}},
+			total:     0.3,
+		},
+		{name: "题目内容为空", questions: []models.Question{{Type: models.QuestionTrueFalse, Content: "  ", Score: 5, Answer: "true"}}, total: 5, wantErr: "第1题: 题目内容不能为空"},
+		{name: "分值为0", questions: []models.Question{{Type: models.QuestionTrueFalse, Content: "对吗", Answer: "true"}}, total: 0, wantErr: "分值必须大于0"},
+		{name: "未知题型", questions: []models.Question{{Type: "matching", Content: "连线", Score: 5}}, total: 5, wantErr: "未知的题目类型"},
+		{
+			name:      "单选题有两个正确选项",
+			questions: []models.Question{{Type: models.QuestionSingleChoice, Content: "选择", Score: 5, Options: options(true, true)}},
+			total:     5,
+			wantErr:   "有且只有一个正确选项",
+		},
+		{
+			name:      "多选题没有正确选项",
+			questions: []models.Question{{Type: models.QuestionMultipleChoice, Content: "选择", Score: 5, Options: options(false, false)}},
+			total:     5,
+			wantErr:   "至少需要一个正确选项",
+		},
+		{
+			name:      "选择题只有一个选项",
+			questions: []models.Question{{Type: models.QuestionSingleChoice, Content: "选择", Score: 5, Options: options(true)}},
+			total:     5,
+			wantErr:   "至少需要两个选项",
+		},
+		{
+			name: "选项标签重复",
+			questions: []models.Question{{Type: models.QuestionSingleChoice, Content: "选择", Score: 5, Options: []models.QuestionOption{
+				{Label: "a", Content: "1", IsCorrect: true}, {Label: "A", Content: "2"},
+			}}},
+			total:   5,
+			wantErr: "选项标签A重复",
+		},
+		{
+			name:      "选项内容为空",
+			questions: []models.Question{{Type: models.QuestionSingleChoice, Content: "选择", Score: 5, Options: []models.QuestionOption{{Content: "1", IsCorrect: true}, {Content: " "}}}},
+			total:     5,
+			wantErr:   "选项B内容不能为空",
+		},
+		{name: "判断题答案无效", questions: []models.Question{{Type: models.QuestionTrueFalse, Content: "对吗", Score: 5, Answer: "yes"}}, total: 5, wantErr: "true或false"},
+		{name: "填空题没有答案", questions: []models.Question{{Type: models.QuestionFillBlank, Content: "填空", Score: 5}}, total: 5, wantErr: "必须提供标准答案"},
+		{name: "数值题答案不是数字", questions: []models.Question{{Type: models.QuestionNumeric, Content: "π", Score: 5, Answer: "三"}}, total: 5, wantErr: "必须是数字"},
+		{
+			name:      "判断题不能设置选项",
+			questions: []models.Question{{Type: models.QuestionTrueFalse, Content: "对吗", Score: 5, Answer: "true", Options: options(true, false)}},
+			total:     5,
+			wantErr:   "该题型不能设置选项",
+		},
+		{name: "简答题可以没有参考答案", questions: []models.Question{{Type: models.QuestionShortAnswer, Content: "简述", Score: 5}}, total: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateQuestions(tt.questions, tt.total)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("ValidateQuestions() error = %v, 期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// 校验时规范化题目顺序、选项顺序、选项标签和判断题答案，并清除选择题的答案字段
+func TestValidateQuestionsNormalizes(t *testing.T) {
+	questions := []models.Question{
+		{Type: models.QuestionMultipleChoice, Content: " 选择 ", Score: 6, Answer: "AB", Options: []models.QuestionOption{
+			{Label: " a ", Content: " 甲 ", IsCorrect: true}, {Content: "乙"}, {Content: "丙", IsCorrect: true},
+		}},
+		{Type: models.QuestionTrueFalse, Content: "对吗", Score: 4, Answer: " TRUE "},
+	}
+	if err := ValidateQuestions(questions, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := questions[0], questions[1]
+	if first.Position != 1 || second.Position != 2 {
+		t.Errorf("题目顺序 = %d, %d, 期望 1, 2", first.Position, second.Position)
+	}
+	if first.Content != "选择" || first.Answer != "" {
+		t.Errorf("选择题 内容 = %q, 答案 = %q", first.Content, first.Answer)
+	}
+	for i, option := range first.Options {
+		if want := string(rune('A' + i)); option.Position != i+1 || option.Label != want {
+			t.Errorf("选项%d 顺序 = %d, 标签 = %q, 期望 %d, %q", i, option.Position, option.Label, i+1, want)
+		}
+	}
+	if first.Options[0].Content != "甲" {
+		t.Errorf("选项内容 = %q, 期望去掉首尾空白", first.Options[0].Content)
+	}
+	if second.Answer != "true" {
+		t.Errorf("判断题答案 = %q, 期望 true", second.Answer)
+	}
+}
+
+func TestPaperRevisions(t *testing.T) {
+	env := newTestEnv(t)
+	teacher := env.user(t, models.RoleTeacher)
+	admin := env.user(t, models.RoleAdmin)
+	exam, created := env.draftExam(t, teacher, "数学")
+
+	edit := func(change func(paper *models.Paper)) func() error {
+		return func() error {
+			paper, err := env.paper.GetPaperByID(created.ID)
+			if err != nil {
+				return err
+			}
+			change(paper)
+			return env.paper.UpdatePaper(paper, teacher.ID)
+		}
+	}
+	steps := []struct {
+		name          string
+		run           func() error
+		wantErr       string
+		wantRevisions int
+	}{
+		{
+			name:          "创建时记录第1版",
+			run:           func() error { return nil },
+			wantRevisions: 1,
+		},
+		{
+			name:          "修改题目记录新版本",
+			run:           edit(func(paper *models.Paper) { paper.Questions[1].Content = "太阳从西边升起" }),
+			wantRevisions: 2,
+		},
+		{
+			name:          "内容未变化时不记录",
+			run:           edit(func(paper *models.Paper) {}),
+			wantRevisions: 2,
+		},
+		{
+			name: "删除题目",
+			run: edit(func(paper *models.Paper) {
+				paper.Questions = paper.Questions[:1]
+				paper.Questions[0].Score = 10
+			}),
+			wantRevisions: 3,
+		},
+		{
+			name:          "分值之和与总分不一致",
+			run:           edit(func(paper *models.Paper) { paper.Questions[0].Score = 8 }),
+			wantErr:       "总分",
+			wantRevisions: 3,
+		},
+		{
+			name: "恢复第1版",
+			run: func() error {
+				_, err := env.paper.RestoreRevision(created.ID, 1, teacher.ID)
+				return err
+			},
+			wantRevisions: 4,
+		},
+		{
+			name: "审批通过后不能修改",
+			run: func() error {
+				env.fire(t, exam, teacher, models.ExamActionSubmit, "")
+				env.fire(t, exam, admin, models.ExamActionApprove, "")
+				return edit(func(paper *models.Paper) { paper.Title = "试卷B" })()
+			},
+			wantErr:       "只能修改草稿或被拒绝状态",
+			wantRevisions: 4,
+		},
+	}
+	for _, step := range steps {
+		err := step.run()
+		switch {
+		case step.wantErr == "" && err != nil:
+			t.Fatalf("%s: %v", step.name, err)
+		case step.wantErr != "" && (err == nil || !strings.Contains(err.Error(), step.wantErr)):
+			t.Fatalf("%s: error = %v, 期望包含 %q", step.name, err, step.wantErr)
+		}
+		revisions, err := env.paper.ListRevisions(created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(revisions) != step.wantRevisions {
+			t.Fatalf("%s: 有 %d 个版本, 期望 %d", step.name, len(revisions), step.wantRevisions)
+		}
+	}
+
+	// 恢复的版本与第1版内容相同，删除的题目按原ID重新创建
+	first, err := env.paper.GetRevision(created.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := env.paper.GetRevision(created.ID, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restored.SameContent(first) || restored.RestoredFrom != 1 {
+		t.Errorf("恢复的版本 RestoredFrom=%d, 内容与第1版相同=%v", restored.RestoredFrom, restored.SameContent(first))
+	}
+
+	tests := []struct {
+		name                            string
+		from, to                        int
+		added, removed, changed, fields int
+	}{
+		{name: "修改题目", from: 1, to: 2, changed: 1},
+		{name: "删除题目并修改分值", from: 2, to: 3, removed: 1, changed: 1},
+		{name: "默认比较最新两个版本", added: 1, changed: 1},
+		{name: "相同内容", from: 1, to: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := env.paper.DiffRevisions(created.ID, tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(diff.Added) != tt.added || len(diff.Removed) != tt.removed || len(diff.Changed) != tt.changed || len(diff.Fields) != tt.fields {
+				t.Errorf("差异: 新增 %d 删除 %d 修改 %d 字段 %d, 期望 %d %d %d %d",
+					len(diff.Added), len(diff.Removed), len(diff.Changed), len(diff.Fields),
+					tt.added, tt.removed, tt.changed, tt.fields)
+			}
+		})
+	}
+}
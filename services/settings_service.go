@@ -0,0 +1,271 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+	"github.com/jinzhu/gorm"
+)
+
+// SettingsSubscriber 设置变更回调，参数为变更后的完整设置
+type SettingsSubscriber func(settings models.SystemSettings)
+
+// SettingsService 系统设置服务接口
+type SettingsService interface {
+	Get() models.SystemSettings
+	Update(changes map[string]interface{}, updatedBy uint) (models.SystemSettings, error)
+	Subscribe(subscriber SettingsSubscriber)
+	Reload() error
+}
+
+// settingsService 系统设置服务实现，设置缓存在内存中，更新时写库并通知订阅者
+type settingsService struct {
+	settingsRepository repositories.SettingsRepository
+	userRepository     repositories.UserRepository
+
+	mu          sync.RWMutex
+	current     models.SystemSettings
+	subscribers []SettingsSubscriber
+}
+
+// settingApplier 校验单个设置项并写入设置副本
+type settingApplier func(settings *models.SystemSettings, value interface{}) error
+
+// settingAppliers 可更新的设置项及其校验规则
+var settingAppliers = map[string]settingApplier{
+	"system_name": func(s *models.SystemSettings, v interface{}) error {
+		name, err := stringSetting(v)
+		if err != nil {
+			return err
+		}
+		name = strings.TrimSpace(name)
+		if name == "" || len([]rune(name)) > 100 {
+			return fmt.Errorf("长度必须在1-100个字符之间")
+		}
+		s.SystemName = name
+		return nil
+	},
+	"admin_email": func(s *models.SystemSettings, v interface{}) error {
+		email, err := stringSetting(v)
+		if err != nil {
+			return err
+		}
+		email = strings.TrimSpace(email)
+		if email != "" && (!strings.Contains(email, "@") || len(email) > 100) {
+			return fmt.Errorf("邮箱格式不正确")
+		}
+		s.AdminEmail = email
+		return nil
+	},
+	"page_size": intSetting(5, 100, func(s *models.SystemSettings, n int) { s.PageSize = n }),
+	"min_password_length": intSetting(6, 64, func(s *models.SystemSettings, n int) {
+		s.MinPasswordLength = n
+	}),
+	"session_timeout": intSetting(5, 1440, func(s *models.SystemSettings, n int) { s.SessionTimeout = n }),
+	"max_login_attempts": intSetting(1, 100, func(s *models.SystemSettings, n int) {
+		s.MaxLoginAttempts = n
+	}),
+	"auto_backup": boolSetting(func(s *models.SystemSettings, b bool) { s.AutoBackup = b }),
+	"backup_frequency": func(s *models.SystemSettings, v interface{}) error {
+		frequency, err := stringSetting(v)
+		if err != nil {
+			return err
+		}
+		frequency = strings.TrimSpace(frequency)
+		if len(frequency) > 20 {
+			return fmt.Errorf("长度不能超过20个字符")
+		}
+		if _, err := models.NextBackupTime(frequency, time.Now()); err != nil {
+			return err
+		}
+		s.BackupFrequency = frequency
+		return nil
+	},
+	"backup_count": intSetting(1, 100, func(s *models.SystemSettings, n int) { s.BackupCount = n }),
+	"late_submission_grace": intSetting(0, 120, func(s *models.SystemSettings, n int) {
+		s.LateSubmissionGrace = n
+	}),
+	"approval_escalation_hours": intSetting(0, 720, func(s *models.SystemSettings, n int) {
+		s.ApprovalEscalationHours = n
+	}),
+	"approval_fallback_approver": intSetting(0, math.MaxInt32, func(s *models.SystemSettings, n int) {
+		s.ApprovalFallbackApprover = uint(n)
+	}),
+	"grade_scale": func(s *models.SystemSettings, v interface{}) error {
+		scale, err := stringSetting(v)
+		if err != nil {
+			return err
+		}
+		if !models.IsGradeScale(scale) {
+			return fmt.Errorf("必须是percent、five_point、letter或pass_fail")
+		}
+		s.GradeScale = scale
+		return nil
+	},
+}
+
+// NewSettingsService 创建系统设置服务，数据库中没有设置时写入默认值
+func NewSettingsService(settingsRepo repositories.SettingsRepository, userRepo repositories.UserRepository) (SettingsService, error) {
+	settings, err := settingsRepo.Get()
+	if gorm.IsRecordNotFoundError(err) {
+		defaults := models.DefaultSystemSettings()
+		settings = &defaults
+		if err := settingsRepo.Save(settings); err != nil {
+			return nil, fmt.Errorf("初始化系统设置失败: %v", err)
+		}
+		log.Printf("已写入默认系统设置")
+	} else if err != nil {
+		return nil, fmt.Errorf("读取系统设置失败: %v", err)
+	}
+
+	return &settingsService{
+		settingsRepository: settingsRepo,
+		userRepository:     userRepo,
+		current:            *settings,
+	}, nil
+}
+
+// Get 获取当前系统设置的副本
+func (s *settingsService) Get() models.SystemSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Update 校验并更新部分设置项，任一项不合法时整体不生效
+func (s *settingsService) Update(changes map[string]interface{}, updatedBy uint) (models.SystemSettings, error) {
+	updated, subscribers, err := s.apply(changes, updatedBy)
+	if err != nil {
+		return s.Get(), err
+	}
+
+	// 在锁外通知订阅者，允许回调中再次读取设置
+	for _, subscriber := range subscribers {
+		subscriber(updated)
+	}
+	return updated, nil
+}
+
+// apply 在写锁内校验、保存设置并更新缓存
+func (s *settingsService) apply(changes map[string]interface{}, updatedBy uint) (models.SystemSettings, []SettingsSubscriber, error) {
+	if len(changes) == 0 {
+		return models.SystemSettings{}, nil, fmt.Errorf("没有需要更新的设置")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := s.current
+	for key, value := range changes {
+		apply, ok := settingAppliers[key]
+		if !ok {
+			return models.SystemSettings{}, nil, fmt.Errorf("未知的设置项: %s", key)
+		}
+		if err := apply(&updated, value); err != nil {
+			return models.SystemSettings{}, nil, fmt.Errorf("设置项 %s 无效: %v", key, err)
+		}
+	}
+	if _, ok := changes["approval_fallback_approver"]; ok && updated.ApprovalFallbackApprover != 0 {
+		if err := s.checkApprover(updated.ApprovalFallbackApprover); err != nil {
+			return models.SystemSettings{}, nil, fmt.Errorf("设置项 approval_fallback_approver 无效: %v", err)
+		}
+	}
+	updated.UpdatedBy = updatedBy
+
+	if err := s.settingsRepository.Save(&updated); err != nil {
+		return models.SystemSettings{}, nil, fmt.Errorf("保存系统设置失败: %v", err)
+	}
+	s.current = updated
+	return updated, append([]SettingsSubscriber(nil), s.subscribers...), nil
+}
+
+// checkApprover 检查超时转交审批人存在且是教师或管理员
+func (s *settingsService) checkApprover(id uint) error {
+	user, err := s.userRepository.GetByID(id)
+	if gorm.IsRecordNotFoundError(err) {
+		return fmt.Errorf("用户不存在")
+	}
+	if err != nil {
+		return err
+	}
+	if user.Role != models.RoleTeacher && user.Role != models.RoleAdmin {
+		return fmt.Errorf("必须是教师或管理员")
+	}
+	return nil
+}
+
+// Reload 从数据库重新读取设置并通知订阅者，用于数据库被整体替换（如恢复备份）之后
+func (s *settingsService) Reload() error {
+	settings, err := s.settingsRepository.Get()
+	if gorm.IsRecordNotFoundError(err) {
+		defaults := models.DefaultSystemSettings()
+		settings = &defaults
+		err = s.settingsRepository.Save(settings)
+	}
+	if err != nil {
+		return fmt.Errorf("读取系统设置失败: %v", err)
+	}
+
+	s.mu.Lock()
+	s.current = *settings
+	subscribers := append([]SettingsSubscriber(nil), s.subscribers...)
+	s.mu.Unlock()
+
+	for _, subscriber := range subscribers {
+		subscriber(*settings)
+	}
+	return nil
+}
+
+// Subscribe 注册设置变更回调，注册时会立即以当前设置调用一次
+func (s *settingsService) Subscribe(subscriber SettingsSubscriber) {
+	s.mu.Lock()
+	s.subscribers = append(s.subscribers, subscriber)
+	current := s.current
+	s.mu.Unlock()
+
+	subscriber(current)
+}
+
+// intSetting 生成整数设置项的校验函数，JSON数字会被解析为float64
+func intSetting(min, max int, set func(*models.SystemSettings, int)) settingApplier {
+	return func(s *models.SystemSettings, v interface{}) error {
+		f, ok := v.(float64)
+		if !ok || f != math.Trunc(f) {
+			return fmt.Errorf("必须是整数")
+		}
+		n := int(f)
+		if n < min || n > max {
+			return fmt.Errorf("必须在%d-%d之间", min, max)
+		}
+		set(s, n)
+		return nil
+	}
+}
+
+// boolSetting 生成布尔设置项的校验函数
+func boolSetting(set func(*models.SystemSettings, bool)) settingApplier {
+	return func(s *models.SystemSettings, v interface{}) error {
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("必须是布尔值")
+		}
+		set(s, b)
+		return nil
+	}
+}
+
+// stringSetting 将设置值转换为字符串
+func stringSetting(v interface{}) (string, error) {
+	str, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("必须是字符串")
+	}
+	return str, nil
+}
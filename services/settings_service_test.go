@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/exam-approval-system/models"
+)
+
+func TestSettingsUpdate(t *testing.T) {
+	env := newTestEnv(t)
+	teacher := env.user(t, models.RoleTeacher)
+	admin := env.user(t, models.RoleAdmin)
+	student := env.user(t, models.RoleStudent)
+
+	tests := []struct {
+		name    string
+		changes map[string]interface{}
+		wantErr bool
+	}{
+		{name: "教师作为超时转交审批人", changes: map[string]interface{}{"approval_fallback_approver": float64(teacher.ID)}},
+		{name: "管理员作为超时转交审批人", changes: map[string]interface{}{"approval_fallback_approver": float64(admin.ID)}},
+		{name: "清除超时转交审批人", changes: map[string]interface{}{"approval_fallback_approver": float64(0)}},
+		{name: "学生不能作为超时转交审批人", changes: map[string]interface{}{"approval_fallback_approver": float64(student.ID)}, wantErr: true},
+		{name: "超时转交审批人不存在", changes: map[string]interface{}{"approval_fallback_approver": float64(9999)}, wantErr: true},
+		{name: "审批人无效时其他设置项也不生效", changes: map[string]interface{}{"page_size": float64(50), "approval_fallback_approver": float64(9999)}, wantErr: true},
+		{name: "已删除的双因素认证开关", changes: map[string]interface{}{"two_factor_auth": true}, wantErr: true},
+		{name: "分页大小超出范围", changes: map[string]interface{}{"page_size": float64(101)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := env.settings.Get()
+			updated, err := env.settings.Update(tt.changes, admin.ID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Update() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if got := env.settings.Get(); got != before {
+					t.Errorf("更新失败后设置 = %+v, 期望保持 %+v", got, before)
+				}
+				return
+			}
+			if want := uint(tt.changes["approval_fallback_approver"].(float64)); updated.ApprovalFallbackApprover != want {
+				t.Errorf("ApprovalFallbackApprover = %d, 期望 %d", updated.ApprovalFallbackApprover, want)
+			}
+		})
+	}
+}
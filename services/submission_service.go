@@ -0,0 +1,204 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/exam-approval-system/models"
+	"github.com/exam-approval-system/repositories"
+	"github.com/jinzhu/gorm"
+)
+
+// SubmissionService 答卷提交服务接口
+type SubmissionService interface {
+	WithTx(tx *gorm.DB) SubmissionService
+	ExamQuestions(examID uint) ([]models.Question, error)
+	Submit(examData *models.ExamData, attempt *models.ExamAttempt, responses map[uint]string) (*models.Submission, error)
+	GetLatest(examDataID uint) (*models.Submission, error)
+	List(examDataID uint) ([]models.Submission, error)
+}
+
+// submissionService 答卷提交服务实现
+type submissionService struct {
+	submissionRepository repositories.SubmissionRepository
+	paperRepository      repositories.PaperRepository
+	examDataRepository   repositories.ExamDataRepository
+}
+
+// NewSubmissionService 创建答卷提交服务
+func NewSubmissionService(submissionRepo repositories.SubmissionRepository, paperRepo repositories.PaperRepository, examDataRepo repositories.ExamDataRepository) SubmissionService {
+	return &submissionService{
+		submissionRepository: submissionRepo,
+		paperRepository:      paperRepo,
+		examDataRepository:   examDataRepo,
+	}
+}
+
+// WithTx 返回在事务tx中读写提交、试卷和答题记录的答卷提交服务
+func (s *submissionService) WithTx(tx *gorm.DB) SubmissionService {
+	return &submissionService{
+		submissionRepository: s.submissionRepository.WithTx(tx),
+		paperRepository:      s.paperRepository.WithTx(tx),
+		examDataRepository:   s.examDataRepository.WithTx(tx),
+	}
+}
+
+// ExamQuestions 获取考试下所有试卷的题目，按试卷和题目顺序排列
+func (s *submissionService) ExamQuestions(examID uint) ([]models.Question, error) {
+	papers, err := s.paperRepository.GetByExamID(examID)
+	if err != nil {
+		return nil, err
+	}
+
+	var questions []models.Question
+	for _, paper := range papers {
+		questions = append(questions, paper.Questions...)
+	}
+	return questions, nil
+}
+
+// Submit 保存作答会话的一次提交并自动评分，responses 以题目ID为键；考试没有结构化题目时以0为键保存整卷作答
+// 未作答的题目保存为空答案，但至少需要回答一道题，超时自动交卷时允许全部为空。
+// 全部题目都已自动评分时按考试的计分方式更新答题记录的成绩，否则进入待批阅状态等待教师评分主观题
+func (s *submissionService) Submit(examData *models.ExamData, attempt *models.ExamAttempt, responses map[uint]string) (*models.Submission, error) {
+	questions, err := s.ExamQuestions(examData.ExamID)
+	if err != nil {
+		return nil, err
+	}
+
+	var answers []models.Answer
+	byID := make(map[uint]*models.Question, len(questions))
+	if len(questions) == 0 {
+		response := strings.TrimSpace(responses[0])
+		if response == "" && !attempt.AutoSubmitted {
+			return nil, errors.New("答案不能为空")
+		}
+		answers = append(answers, models.Answer{Response: response})
+	} else {
+		for i := range questions {
+			byID[questions[i].ID] = &questions[i]
+		}
+		for questionID := range responses {
+			if byID[questionID] == nil {
+				return nil, fmt.Errorf("题目 %d 不属于该考试", questionID)
+			}
+		}
+
+		answered := 0
+		for i := range questions {
+			question := &questions[i]
+			response, err := normalizeResponse(question, responses[question.ID])
+			if err != nil {
+				return nil, fmt.Errorf("第%d题: %v", question.Position, err)
+			}
+			if response != "" {
+				answered++
+			}
+			answers = append(answers, models.Answer{QuestionID: question.ID, Response: response})
+		}
+		if answered == 0 && !attempt.AutoSubmitted {
+			return nil, errors.New("至少需要回答一道题目")
+		}
+	}
+
+	count, err := s.submissionRepository.CountByExamData(examData.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	submission := &models.Submission{
+		ExamDataID:    examData.ID,
+		ExamID:        examData.ExamID,
+		StudentID:     examData.StudentID,
+		Attempt:       count + 1,
+		AttemptID:     attempt.ID,
+		Late:          attempt.Late,
+		AutoSubmitted: attempt.AutoSubmitted,
+		Answers:       answers,
+		SubmittedAt:   time.Now(),
+	}
+	AutoGrade(submission, byID)
+	if err := s.submissionRepository.Create(submission); err != nil {
+		return nil, err
+	}
+
+	submissions, err := s.submissionRepository.ListByExamData(examData.ID)
+	if err != nil {
+		return nil, err
+	}
+	examData.ApproverID = 0
+	settleExamData(examData, submissions)
+	if err := s.examDataRepository.UpdateResult(examData); err != nil {
+		return nil, err
+	}
+	return s.submissionRepository.GetByID(submission.ID)
+}
+
+// GetLatest 获取答题记录的最近一次提交
+func (s *submissionService) GetLatest(examDataID uint) (*models.Submission, error) {
+	return s.submissionRepository.GetLatestByExamData(examDataID)
+}
+
+// List 获取答题记录的全部提交
+func (s *submissionService) List(examDataID uint) ([]models.Submission, error) {
+	return s.submissionRepository.ListByExamData(examDataID)
+}
+
+// settleExamData 按考试的计分方式汇总各次提交的得分，计入成绩的提交都已评分时答题记录完成批阅，否则为待批阅
+func settleExamData(examData *models.ExamData, submissions []models.Submission) {
+	score, graded := examData.Exam.FinalScore(submissions)
+	examData.Status = models.StatusPending
+	examData.TotalScore = 0
+	if graded {
+		examData.Status = models.StatusApproved
+		examData.TotalScore = score
+	}
+}
+
+// normalizeResponse 按题型校验并规范化作答：选择题为大写选项标签，多选题按标签排序后以逗号分隔
+func normalizeResponse(question *models.Question, response string) (string, error) {
+	response = strings.TrimSpace(response)
+	if response == "" {
+		return "", nil
+	}
+
+	switch question.Type {
+	case models.QuestionSingleChoice, models.QuestionMultipleChoice:
+		labels := make(map[string]bool, len(question.Options))
+		for _, option := range question.Options {
+			labels[option.Label] = true
+		}
+
+		selected := make(map[string]bool)
+		for _, label := range strings.Split(response, ",") {
+			label = strings.ToUpper(strings.TrimSpace(label))
+			if label == "" {
+				continue
+			}
+			if !labels[label] {
+				return "", fmt.Errorf("无效的选项: %s", label)
+			}
+			selected[label] = true
+		}
+		if question.Type == models.QuestionSingleChoice && len(selected) > 1 {
+			return "", errors.New("单选题只能选择一个选项")
+		}
+
+		result := make([]string, 0, len(selected))
+		for label := range selected {
+			result = append(result, label)
+		}
+		sort.Strings(result)
+		return strings.Join(result, ","), nil
+
+	case models.QuestionTrueFalse:
+		response = strings.ToLower(response)
+		if response != "true" && response != "false" {
+			return "", errors.New("判断题答案必须是true或false")
+		}
+	}
+	return response, nil
+}
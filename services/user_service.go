@@ -3,10 +3,13 @@ package services
 import (
 	"errors"
 	"fmt"
+	"log"
 	"strconv"
 
+	"github.com/exam-approval-system/configs"
 	"github.com/exam-approval-system/models"
 	"github.com/exam-approval-system/repositories"
+	"github.com/jinzhu/gorm"
 )
 
 // UserService 用户服务接口
@@ -30,13 +33,22 @@ type UserService interface {
 
 // userService 用户服务实现
 type userService struct {
-	userRepository repositories.UserRepository
+	userRepository     repositories.UserRepository
+	sessionRepository  repositories.SessionRepository
+	approvalRepository repositories.ApprovalRepository
+	examService        ExamService
+	settingsService    SettingsService
 }
 
 // NewUserService 创建用户服务
-func NewUserService(userRepo repositories.UserRepository) UserService {
+func NewUserService(userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository,
+	approvalRepo repositories.ApprovalRepository, examService ExamService, settingsService SettingsService) UserService {
 	return &userService{
-		userRepository: userRepo,
+		userRepository:     userRepo,
+		sessionRepository:  sessionRepo,
+		approvalRepository: approvalRepo,
+		examService:        examService,
+		settingsService:    settingsService,
 	}
 }
 
@@ -99,6 +111,9 @@ func (s *userService) ChangePassword(userID uint, oldPassword, newPassword strin
 	}
 
 	// 设置新密码
+	if err := ValidatePassword(s.settingsService, newPassword); err != nil {
+		return err
+	}
 	if err := user.SetPassword(newPassword); err != nil {
 		return fmt.Errorf("密码加密失败: %v", err)
 	}
@@ -133,8 +148,8 @@ func (s *userService) ListUsersWithFilter(role string, status string) ([]models.
 // CreateUser 创建新用户
 func (s *userService) CreateUser(user *models.User) (*models.User, error) {
 	// 检查用户名是否已存在
-	existingUser, _ := s.userRepository.GetByUsername(user.Username)
-	if existingUser != nil {
+	existingUser, err := s.userRepository.GetByUsername(user.Username)
+	if err == nil && existingUser.ID > 0 {
 		return nil, errors.New("用户名已存在")
 	}
 
@@ -143,8 +158,16 @@ func (s *userService) CreateUser(user *models.User) (*models.User, error) {
 		user.Role = models.RoleStudent // 默认为学生角色
 	}
 
+	// 校验并加密密码
+	if err := ValidatePassword(s.settingsService, user.Password); err != nil {
+		return nil, err
+	}
+	if err := user.SetPassword(user.Password); err != nil {
+		return nil, fmt.Errorf("密码加密失败: %v", err)
+	}
+
 	// 保存用户
-	err := s.userRepository.Create(user)
+	err = s.userRepository.Create(user)
 	if err != nil {
 		return nil, err
 	}
@@ -174,7 +197,12 @@ func (s *userService) UpdateUserDetails(idStr string, name string, email string,
 		user.Role = role
 	}
 	if password != "" {
-		user.Password = password
+		if err := ValidatePassword(s.settingsService, password); err != nil {
+			return nil, err
+		}
+		if err := user.SetPassword(password); err != nil {
+			return nil, fmt.Errorf("密码加密失败: %v", err)
+		}
 	}
 	// 注意：此模型中没有Email、Phone和Status字段
 
@@ -187,7 +215,9 @@ func (s *userService) UpdateUserDetails(idStr string, name string, email string,
 	return user, nil
 }
 
-// DeleteUser 删除用户
+// DeleteUser 在同一事务中删除用户、用户的登录会话以及用户作为委托人或代理人的审批委托，
+// 分配给该用户的审批任务超时后转交。删除教师时同时删除其创建的考试，
+// 任一考试的试卷已锁定时返回models.ErrPaperLocked，不删除任何数据
 func (s *userService) DeleteUser(idStr string) error {
 	// 将字符串ID转换为uint
 	id, err := strconv.ParseUint(idStr, 10, 32)
@@ -196,11 +226,45 @@ func (s *userService) DeleteUser(idStr string) error {
 	}
 
 	// 检查用户是否存在
-	_, err = s.userRepository.GetByID(uint(id))
+	user, err := s.userRepository.GetByID(uint(id))
 	if err != nil {
 		return errors.New("用户不存在")
 	}
 
-	// 删除用户
-	return s.userRepository.Delete(uint(id))
+	return configs.DB().Transaction(func(tx *gorm.DB) error {
+		if user.Role == models.RoleTeacher {
+			if err := s.removeExams(tx, user); err != nil {
+				return err
+			}
+		}
+		if err := s.sessionRepository.WithTx(tx).DeleteByUser(uint(id)); err != nil {
+			return err
+		}
+		deleted, err := s.approvalRepository.WithTx(tx).DeleteDelegationsByUser(uint(id))
+		if err != nil {
+			return err
+		}
+		if deleted > 0 {
+			log.Printf("删除用户(ID:%d)的审批委托 %d 条", id, deleted)
+		}
+		return s.userRepository.WithTx(tx).Delete(uint(id))
+	})
+}
+
+// removeExams 在事务tx中按考试服务的删除规则删除教师创建的全部考试
+func (s *userService) removeExams(tx *gorm.DB, teacher *models.User) error {
+	examService := s.examService.WithTx(tx)
+	exams, err := examService.ListExamsByCreator(teacher.ID)
+	if err != nil {
+		return err
+	}
+	for _, exam := range exams {
+		if err := examService.RemoveExam(exam.ID, teacher); err != nil {
+			return fmt.Errorf("删除考试《%s》失败: %w", exam.Title, err)
+		}
+	}
+	if len(exams) > 0 {
+		log.Printf("删除教师(ID:%d)创建的考试 %d 场", teacher.ID, len(exams))
+	}
+	return nil
 }
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/exam-approval-system/configs"
+	"github.com/exam-approval-system/models"
+)
+
+func TestDeleteUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T)
+		wantErr bool
+	}{
+		{name: "删除用户及其会话和委托"},
+		{
+			name: "删除用户失败时保留会话和委托",
+			setup: func(t *testing.T) {
+				execSQL(t, "CREATE TRIGGER keep_users BEFORE DELETE ON users BEGIN SELECT RAISE(ABORT, 'keep'); END")
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			teacher := env.user(t, models.RoleTeacher)
+			other := env.user(t, models.RoleTeacher)
+			now := time.Now()
+			records := []interface{}{
+				&models.Session{SessionID: "s1", UserID: teacher.ID, ExpiresAt: now.Add(time.Hour)},
+				&models.Session{SessionID: "s2", UserID: other.ID, ExpiresAt: now.Add(time.Hour)},
+				&models.ApprovalDelegation{UserID: teacher.ID, DelegateID: other.ID, StartTime: now, EndTime: now.Add(time.Hour)},
+				&models.ApprovalDelegation{UserID: other.ID, DelegateID: teacher.ID, StartTime: now.Add(2 * time.Hour), EndTime: now.Add(3 * time.Hour)},
+			}
+			for _, record := range records {
+				if err := configs.DB().Create(record).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+
+			err := env.userService.DeleteUser(fmt.Sprint(teacher.ID))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeleteUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			wantSessions, wantDelegations, wantUsers := 1, 0, 0
+			if tt.wantErr {
+				wantSessions, wantDelegations, wantUsers = 2, 2, 1
+			}
+			count := func(model interface{}, query string, args ...interface{}) int {
+				var n int
+				if err := configs.DB().Model(model).Where(query, args...).Count(&n).Error; err != nil {
+					t.Fatal(err)
+				}
+				return n
+			}
+			if n := count(&models.Session{}, "1 = 1"); n != wantSessions {
+				t.Errorf("剩余会话 = %d, 期望 %d", n, wantSessions)
+			}
+			if n := count(&models.ApprovalDelegation{}, "1 = 1"); n != wantDelegations {
+				t.Errorf("剩余委托 = %d, 期望 %d", n, wantDelegations)
+			}
+			if n := count(&models.User{}, "id = ?", teacher.ID); n != wantUsers {
+				t.Errorf("用户记录 = %d, 期望 %d", n, wantUsers)
+			}
+		})
+	}
+}
+
+func TestDeleteTeacherExams(t *testing.T) {
+	tests := []struct {
+		name     string
+		approved bool // 教师是否有已审批通过的考试
+		wantErr  error
+	}{
+		{name: "同时删除教师创建的考试"},
+		{name: "有已审批的考试时不删除任何数据", approved: true, wantErr: models.ErrPaperLocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			teacher := env.user(t, models.RoleTeacher)
+			admin := env.user(t, models.RoleAdmin)
+			draft, draftPaper := env.draftExam(t, teacher, "数学")
+			exams := []uint{draft.ID}
+			papers := []uint{draftPaper.ID}
+			if tt.approved {
+				approved, approvedPaper := env.draftExam(t, teacher, "物理")
+				env.fire(t, approved, teacher, models.ExamActionSubmit, "")
+				env.fire(t, approved, admin, models.ExamActionApprove, "")
+				exams = append(exams, approved.ID)
+				papers = append(papers, approvedPaper.ID)
+			}
+			other, otherPaper := env.draftExam(t, env.user(t, models.RoleTeacher), "数学")
+			if err := configs.DB().Create(&models.Session{SessionID: "s1", UserID: teacher.ID, ExpiresAt: time.Now().Add(time.Hour)}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			err := env.userService.DeleteUser(fmt.Sprint(teacher.ID))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeleteUser() error = %v, 期望 %v", err, tt.wantErr)
+			}
+
+			kept := tt.wantErr != nil
+			for i, id := range exams {
+				_, examErr := env.exams.GetByID(id)
+				_, paperErr := env.papers.GetByID(papers[i])
+				if (examErr == nil) != kept || (paperErr == nil) != kept {
+					t.Errorf("考试%d和试卷%d保留 = %v、%v, 期望 %v", id, papers[i], examErr == nil, paperErr == nil, kept)
+				}
+			}
+			if _, err := env.users.GetByID(teacher.ID); (err == nil) != kept {
+				t.Errorf("教师保留 = %v, 期望 %v", err == nil, kept)
+			}
+			var sessions int
+			configs.DB().Model(&models.Session{}).Where("user_id = ?", teacher.ID).Count(&sessions)
+			if (sessions == 1) != kept {
+				t.Errorf("教师的会话有%d个", sessions)
+			}
+			// 其他教师的考试不受影响
+			if _, err := env.exams.GetByID(other.ID); err != nil {
+				t.Errorf("其他教师的考试被删除: %v", err)
+			}
+			if _, err := env.papers.GetByID(otherPaper.ID); err != nil {
+				t.Errorf("其他教师的试卷被删除: %v", err)
+			}
+		})
+	}
+}
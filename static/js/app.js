@@ -226,7 +226,13 @@ function initFormHandlers() {
             const examId = document.getElementById('exam-id').value;
             const title = document.getElementById('title').value;
             const content = document.getElementById('content').value;
-            const questions = document.getElementById('questions').value;
+            let questions;
+            try {
+                questions = JSON.parse(document.getElementById('questions').value || '[]');
+            } catch (err) {
+                showAlert('create-paper-alert', '试题格式错误，请输入JSON数组', 'danger');
+                return;
+            }
             const duration = document.getElementById('duration').value;
             const totalScore = document.getElementById('total-score').value;
             const passingScore = document.getElementById('passing-score').value;
@@ -336,7 +342,7 @@ async function createExam(title, description, course, startTime, endTime) {
  * @param {number} examId 考试ID
  * @param {string} title 试卷标题
  * @param {string} content 试卷内容
- * @param {string} questions 试题JSON字符串
+ * @param {Array} questions 试题列表，每题包含 type、content、score、answer、options
  * @param {number} duration 考试时长（分钟）
  * @param {number} totalScore 总分
  * @param {number} passingScore 及格分数
@@ -583,6 +589,13 @@ async function loadComments(examId) {
                     </div>
                     <div class="card-body">
                         <p>${comment.content}</p>
+                        ${(comment.replies || []).map(reply => `
+                        <div class="border-left pl-3 mt-2">
+                            <strong>${reply.user.name}</strong>
+                            <small class="float-right">${formatDate(reply.created_at)}</small>
+                            <p class="mb-0">${reply.content}</p>
+                        </div>
+                        `).join('')}
                     </div>
                 </div>
                 `;
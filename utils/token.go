@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// 会话令牌相关错误
+var (
+	ErrTokenMalformed = errors.New("会话令牌格式错误")
+	ErrTokenSignature = errors.New("会话令牌签名无效")
+	ErrTokenExpired   = errors.New("会话令牌已过期")
+)
+
+// SessionClaims 会话令牌中携带的声明
+type SessionClaims struct {
+	SessionID string `json:"sid"`
+	UserID    uint   `json:"uid"`
+	Role      string `json:"role"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// sessionSecret 会话令牌签名密钥，优先从环境变量 EXAM_SESSION_SECRET 读取
+var sessionSecret = loadSessionSecret()
+
+// loadSessionSecret 加载会话签名密钥，未配置时生成随机密钥（重启后所有会话失效）
+func loadSessionSecret() []byte {
+	if secret := os.Getenv("EXAM_SESSION_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("生成会话密钥失败: %v", err)
+	}
+	log.Printf("未设置 EXAM_SESSION_SECRET，已使用随机会话密钥，重启后所有登录将失效")
+	return key
+}
+
+// NewSessionID 生成随机会话ID
+func NewSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// GenerateSessionToken 生成带HMAC-SHA256签名的会话令牌，格式为 payload.signature
+func GenerateSessionToken(claims SessionClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + signSessionPayload(encoded), nil
+}
+
+// ParseSessionToken 校验会话令牌的签名和有效期并返回其中的声明
+func ParseSessionToken(token string) (*SessionClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, ErrTokenMalformed
+	}
+
+	// 使用常量时间比较签名，防止时序攻击
+	expected := signSessionPayload(parts[0])
+	if !hmac.Equal([]byte(expected), []byte(parts[1])) {
+		return nil, ErrTokenSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	var claims SessionClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+
+	return &claims, nil
+}
+
+// signSessionPayload 对编码后的载荷计算签名
+func signSessionPayload(encoded string) string {
+	h := hmac.New(sha256.New, sessionSecret)
+	h.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
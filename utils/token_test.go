@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSessionToken(t *testing.T) {
+	valid := SessionClaims{SessionID: "abc", UserID: 7, Role: "teacher", IssuedAt: time.Now().Unix(), ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	token := func(claims SessionClaims) string {
+		t.Helper()
+		token, err := GenerateSessionToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	expired := valid
+	expired.ExpiresAt = time.Now().Add(-time.Second).Unix()
+	signed := token(valid)
+	payload, signature := signed[:strings.Index(signed, ".")], signed[strings.Index(signed, ".")+1:]
+	other := token(SessionClaims{UserID: 1, Role: "admin", ExpiresAt: valid.ExpiresAt})
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"有效令牌", signed, nil},
+		{"空令牌", "", ErrTokenMalformed},
+		{"缺少签名", payload, ErrTokenMalformed},
+		{"多余的分段", signed + ".x", ErrTokenMalformed},
+		{"签名被替换", payload + "." + other[strings.Index(other, ".")+1:], ErrTokenSignature},
+		{"载荷被修改", "e30." + signature, ErrTokenSignature},
+		{"已过期", token(expired), ErrTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseSessionToken(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseSessionToken() error = %v, 期望 %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && *claims != valid {
+				t.Errorf("声明 = %+v, 期望 %+v", *claims, valid)
+			}
+		})
+	}
+}